@@ -0,0 +1,107 @@
+/**
+ * PawaPay Simulator
+ * Serves the PawaPay V2 endpoints locally and posts callbacks to the server, so payment
+ * flows can be tested without the sandbox or a phone
+ *
+ * Usage:
+ *   go run ./cmd/pawapay-sim -addr :8090 -callback http://localhost:8080/pawapay/callback
+ *
+ * Then start the server with PAWAPAY_API_URL=http://localhost:8090 and, to exercise
+ * signature checks, the PAWAPAY_CALLBACK_PUBLIC_KEY this prints on startup.
+ *
+ * Outcomes can be scripted while it runs:
+ *   curl -X POST localhost:8090/sim/next -d '{"outcomes":["fail","duplicate"]}'
+ *   curl -X PUT localhost:8090/sim/phones/260971234567 -d '{"outcome":"timeout"}'
+ */
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yakumwamba/lpg-delivery-system/internal/pawapay/sim"
+)
+
+var (
+	addr          = flag.String("addr", envOr("PAWAPAY_SIM_ADDR", ":8090"), "Address to listen on")
+	callbackURL   = flag.String("callback", envOr("PAWAPAY_SIM_CALLBACK_URL", "http://localhost:8080/pawapay/callback"), "Base URL of the server's PawaPay callback routes")
+	token         = flag.String("token", os.Getenv("PAWAPAY_SIM_TOKEN"), "Bearer token API requests must carry (any token if empty)")
+	outcome       = flag.String("outcome", envOr("PAWAPAY_SIM_OUTCOME", string(sim.OutcomeComplete)), "Default outcome: complete, fail, reject, timeout, duplicate or late")
+	phones        = flag.String("phones", os.Getenv("PAWAPAY_SIM_PHONES"), "Outcomes by number, e.g. 260971234567=fail,260961234567=timeout")
+	callbackDelay = flag.Duration("delay", 2*time.Second, "How long payments stay pending before they settle")
+	lateDelay     = flag.Duration("late-delay", 2*time.Minute, "How long late callbacks are held back")
+	unsigned      = flag.Bool("unsigned", false, "Send callbacks without a signature")
+)
+
+func main() {
+	flag.Parse()
+
+	defaultOutcome, err := sim.ParseOutcome(*outcome)
+	if err != nil {
+		log.Fatalf("Invalid -outcome: %v", err)
+	}
+	scripted, err := parsePhones(*phones)
+	if err != nil {
+		log.Fatalf("Invalid -phones: %v", err)
+	}
+
+	cfg := sim.Config{
+		CallbackURL:   *callbackURL,
+		Token:         *token,
+		Default:       defaultOutcome,
+		Phones:        scripted,
+		CallbackDelay: *callbackDelay,
+		LateDelay:     *lateDelay,
+	}
+	if !*unsigned {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			log.Fatalf("Failed to generate signing key: %v", err)
+		}
+		publicKey, err := sim.PublicKeyPEM(key)
+		if err != nil {
+			log.Fatalf("Failed to encode public key: %v", err)
+		}
+		cfg.SigningKey = key
+		fmt.Printf("PAWAPAY_CALLBACK_PUBLIC_KEY=%s\n", publicKey)
+	}
+
+	log.Printf("✅ PawaPay simulator listening on %s, calling back %s (default outcome: %s)", *addr, *callbackURL, defaultOutcome)
+	log.Fatal(http.ListenAndServe(*addr, sim.NewServer(cfg)))
+}
+
+// parsePhones reads number=outcome pairs separated by commas
+func parsePhones(raw string) (map[string]sim.Outcome, error) {
+	scripted := map[string]sim.Outcome{}
+	for _, pair := range strings.Split(raw, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		number, name, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected number=outcome, got %q", pair)
+		}
+		o, err := sim.ParseOutcome(name)
+		if err != nil {
+			return nil, err
+		}
+		scripted[strings.TrimPrefix(strings.TrimSpace(number), "+")] = o
+	}
+	return scripted, nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
 	"github.com/yakumwamba/lpg-delivery-system/internal/order"
 	"github.com/yakumwamba/lpg-delivery-system/internal/user"
 	"golang.org/x/crypto/bcrypt"
@@ -427,10 +428,10 @@ func (s *SeedData) createOrders(ctx context.Context, count int) error {
 
 		cylinderType := sampleCylinderTypes[i%len(sampleCylinderTypes)]
 		quantity := (i % 3) + 1
-		pricePerUnit := 45000.0 // TZS
-		totalPrice := pricePerUnit * float64(quantity)
-		deliveryFee := 5000.0
-		serviceCharge := totalPrice * 0.05
+		pricePerUnit := money.Kwacha(45000) // TZS
+		totalPrice := pricePerUnit.Times(quantity)
+		deliveryFee := money.Kwacha(5000)
+		serviceCharge := totalPrice.MulRate(0.05)
 		grandTotal := totalPrice + deliveryFee + serviceCharge
 
 		status := sampleOrderStatuses[i%len(sampleOrderStatuses)]
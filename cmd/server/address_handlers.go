@@ -0,0 +1,244 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/address"
+	"github.com/yakumwamba/lpg-delivery-system/internal/order"
+)
+
+// applySavedAddress fills an order's delivery address from the customer's address book
+func applySavedAddress(addressService *address.Service, o *order.Order) error {
+	saved, err := addressService.GetSaved(o.UserID, *o.AddressID)
+	if err != nil {
+		return err
+	}
+	o.StructuredAddress = &saved.Address
+	o.DeliveryAddress = saved.Formatted
+	return nil
+}
+
+// validateStructuredAddress checks a structured address against the service zones and
+// fills in the free-text address and notes from it where the client left them empty
+func validateStructuredAddress(addressService *address.Service, a *address.Address, deliveryAddress, deliveryNotes *string) error {
+	if _, err := addressService.Validate(a); err != nil {
+		return err
+	}
+	if *deliveryAddress == "" {
+		*deliveryAddress = a.Format()
+	}
+	if *deliveryNotes == "" {
+		*deliveryNotes = a.Notes
+	}
+	return nil
+}
+
+// handleValidateAddress lets clients check an address before placing an order with it
+func handleValidateAddress(addressService *address.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var a address.Address
+		if err := c.ShouldBindJSON(&a); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validation, err := addressService.Validate(&a)
+		if err != nil {
+			respondAddressError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"valid":        true,
+			"zone":         validation.Zone,
+			"area_matched": validation.AreaMatched,
+			"formatted":    a.Format(),
+		})
+	}
+}
+
+func handleGetSavedAddresses(addressService *address.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uuid.UUID)
+
+		addresses, err := addressService.ListSaved(userID)
+		if err != nil {
+			respondAddressError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, addresses)
+	}
+}
+
+func handleCreateSavedAddress(addressService *address.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uuid.UUID)
+
+		var req address.SavedAddressRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		saved, err := addressService.CreateSaved(userID, &req)
+		if err != nil {
+			respondAddressError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, saved)
+	}
+}
+
+func handleUpdateSavedAddress(addressService *address.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uuid.UUID)
+		addressID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid address ID"})
+			return
+		}
+
+		var req address.SavedAddressRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		saved, err := addressService.UpdateSaved(userID, addressID, &req)
+		if err != nil {
+			respondAddressError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, saved)
+	}
+}
+
+func handleSetDefaultAddress(addressService *address.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uuid.UUID)
+		addressID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid address ID"})
+			return
+		}
+
+		saved, err := addressService.SetDefault(userID, addressID)
+		if err != nil {
+			respondAddressError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, saved)
+	}
+}
+
+func handleDeleteSavedAddress(addressService *address.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uuid.UUID)
+		addressID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid address ID"})
+			return
+		}
+
+		if err := addressService.DeleteSaved(userID, addressID); err != nil {
+			respondAddressError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Address deleted"})
+	}
+}
+
+func handleGetServiceZones(addressService *address.Service, activeOnly bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		zones, err := addressService.ListZones(activeOnly)
+		if err != nil {
+			respondAddressError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, zones)
+	}
+}
+
+func handleAdminCreateServiceZone(addressService *address.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req address.ZoneRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		zone := req.Zone()
+		if err := addressService.CreateZone(zone); err != nil {
+			respondAddressError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, zone)
+	}
+}
+
+func handleAdminUpdateServiceZone(addressService *address.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		zoneID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid zone ID"})
+			return
+		}
+
+		var req address.ZoneRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		zone := req.Zone()
+		zone.ID = zoneID
+		if err := addressService.UpdateZone(zone); err != nil {
+			respondAddressError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, zone)
+	}
+}
+
+func handleAdminDeleteServiceZone(addressService *address.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		zoneID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid zone ID"})
+			return
+		}
+
+		if err := addressService.DeleteZone(zoneID); err != nil {
+			respondAddressError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Service zone deleted"})
+	}
+}
+
+func respondAddressError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, address.ErrMissingArea), errors.Is(err, address.ErrMissingPin):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, address.ErrOutsideServiceArea), errors.Is(err, address.ErrAreaMismatch):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, address.ErrZoneNotFound), errors.Is(err, address.ErrSavedAddressNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, address.ErrTooManyAddresses), errors.Is(err, address.ErrDuplicateLabel):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/business"
+	"github.com/yakumwamba/lpg-delivery-system/internal/order"
+	"github.com/yakumwamba/lpg-delivery-system/internal/promo"
+)
+
+// Business Account Handlers (members of an account)
+
+func handleGetMyBusinessAccount(businessService *business.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		accountID, role, err := businessService.GetMembership(userID.(uuid.UUID))
+		if err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+
+		account, err := businessService.GetAccount(accountID)
+		if err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"account": account, "role": role})
+	}
+}
+
+func handleGetBusinessMembers(businessService *business.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		accountID, _, err := businessService.GetMembership(userID.(uuid.UUID))
+		if err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+
+		members, err := businessService.ListMembers(accountID)
+		if err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"members": members})
+	}
+}
+
+func handleAddBusinessMember(businessService *business.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			PhoneNumber string              `json:"phone_number" binding:"required"`
+			Role        business.MemberRole `json:"role" binding:"required,oneof=admin orderer"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userID, _ := c.Get("userID")
+		members, err := businessService.AddMember(userID.(uuid.UUID), req.PhoneNumber, req.Role)
+		if err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"members": members})
+	}
+}
+
+func handleRemoveBusinessMember(businessService *business.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		memberID, err := uuid.Parse(c.Param("user_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		userID, _ := c.Get("userID")
+		if err := businessService.RemoveMember(userID.(uuid.UUID), memberID); err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+	}
+}
+
+func handleGetBusinessSites(businessService *business.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		accountID, _, err := businessService.GetMembership(userID.(uuid.UUID))
+		if err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+
+		sites, err := businessService.ListSites(accountID)
+		if err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"sites": sites})
+	}
+}
+
+func handleAddBusinessSite(businessService *business.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var site business.Site
+		if err := c.ShouldBindJSON(&site); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userID, _ := c.Get("userID")
+		created, err := businessService.AddSite(userID.(uuid.UUID), &site)
+		if err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"site": created})
+	}
+}
+
+func handleDeleteBusinessSite(businessService *business.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		siteID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid site ID"})
+			return
+		}
+
+		userID, _ := c.Get("userID")
+		if err := businessService.DeleteSite(userID.(uuid.UUID), siteID); err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Site deleted"})
+	}
+}
+
+func handleGetBusinessStatements(businessService *business.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		accountID, _, err := businessService.GetMembership(userID.(uuid.UUID))
+		if err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+
+		statements, err := businessService.ListStatements(accountID)
+		if err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"statements": statements})
+	}
+}
+
+func handleGetBusinessStatement(businessService *business.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		statementID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid statement ID"})
+			return
+		}
+
+		userID, _ := c.Get("userID")
+		accountID, _, err := businessService.GetMembership(userID.(uuid.UUID))
+		if err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+
+		statement, err := businessService.GetStatement(statementID)
+		if err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+		if statement.AccountID != accountID {
+			respondBusinessError(c, business.ErrStatementNotFound)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"statement": statement})
+	}
+}
+
+// Admin business account handlers
+
+func handleAdminCreateBusinessAccount(businessService *business.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req business.CreateAccountRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		account, err := businessService.CreateAccount(&req)
+		if err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"account": account})
+	}
+}
+
+func handleAdminListBusinessAccounts(businessService *business.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, offset := parseLimitOffset(c, 50, 200)
+		accounts, err := businessService.ListAccounts(limit, offset)
+		if err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"accounts": accounts})
+	}
+}
+
+func handleAdminGetBusinessAccount(businessService *business.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accountID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account ID"})
+			return
+		}
+
+		account, err := businessService.GetAccount(accountID)
+		if err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+		members, err := businessService.ListMembers(accountID)
+		if err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+		sites, err := businessService.ListSites(accountID)
+		if err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+		statements, err := businessService.ListStatements(accountID)
+		if err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"account":    account,
+			"members":    members,
+			"sites":      sites,
+			"statements": statements,
+		})
+	}
+}
+
+func handleAdminUpdateBusinessTerms(businessService *business.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accountID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account ID"})
+			return
+		}
+
+		var req business.UpdateTermsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		account, err := businessService.UpdateTerms(accountID, &req)
+		if err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"account": account})
+	}
+}
+
+// handleAdminGenerateBusinessStatements builds the statements for one month (YYYY-MM, default last month)
+func handleAdminGenerateBusinessStatements(businessService *business.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		period := time.Now().AddDate(0, -1, 0)
+		if raw := c.Query("period"); raw != "" {
+			parsed, err := time.Parse("2006-01", raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "period must be formatted as YYYY-MM"})
+				return
+			}
+			period = parsed
+		}
+
+		count, err := businessService.GenerateMonthlyStatements(period)
+		if err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"statements_generated": count, "period": period.Format("2006-01")})
+	}
+}
+
+func handleAdminMarkStatementPaid(businessService *business.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		statementID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid statement ID"})
+			return
+		}
+
+		statement, err := businessService.MarkStatementPaid(statementID)
+		if err != nil {
+			respondBusinessError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"statement": statement})
+	}
+}
+
+// saveOrder writes a new order. A promotion it uses is counted against the campaign's caps
+// in the same transaction, and the order is refused if that would go over them.
+// On-account orders are linked to the customer's business account and billed on the
+// monthly statement instead of mobile money, as long as the account has credit left; the
+// credit check and the insert share a transaction. Other payment methods cannot carry
+// business fields.
+func saveOrder(orderService *order.Service, businessService *business.Service, promoService *promo.Service, promotion *promo.Applied, o *order.Order) (*order.Order, error) {
+	redeem := func(tx *sql.Tx, created *order.Order) error {
+		if promotion == nil {
+			return nil
+		}
+		return promoService.RedeemTx(tx, promotion, created.ID, created.UserID)
+	}
+
+	o.BusinessAccountID = nil
+	if o.PaymentMethod != order.PaymentMethodOnAccount {
+		o.DeliverySiteID = nil
+		return orderService.CreateOrderWith(o, redeem)
+	}
+
+	var created *order.Order
+	err := businessService.AuthorizeOnAccountOrder(o.UserID, o.DeliverySiteID, o.GrandTotal.Kwacha(),
+		func(tx *sql.Tx, account *business.Account, site *business.Site) error {
+			o.BusinessAccountID = &account.ID
+			o.PaymentStatus = order.PaymentStatusPending
+			if site != nil {
+				o.DeliveryAddress = site.Address
+			}
+			var err error
+			if created, err = orderService.CreateOrderTx(tx, o); err != nil {
+				return err
+			}
+			return redeem(tx, created)
+		})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func respondBusinessError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, business.ErrAccountNotFound), errors.Is(err, business.ErrSiteNotFound),
+		errors.Is(err, business.ErrStatementNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, business.ErrNotMember), errors.Is(err, business.ErrNotAccountAdmin),
+		errors.Is(err, business.ErrAccountSuspended):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, business.ErrAlreadyMember):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, business.ErrCreditLimitExceeded):
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
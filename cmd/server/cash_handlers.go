@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/cash"
+	"github.com/yakumwamba/lpg-delivery-system/internal/loyalty"
+)
+
+// Cash on Delivery Handlers
+
+func handleRecordCashCollection(cashService *cash.Service, loyaltyService *loyalty.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		var req struct {
+			Amount *float64 `json:"amount" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		courierID, _ := c.Get("userID")
+		entry, err := cashService.RecordCollection(courierID.(uuid.UUID), orderID, *req.Amount)
+		if err != nil {
+			respondCashError(c, err)
+			return
+		}
+		// Cash orders earn their points once the money is in hand
+		earnLoyaltyPoints(loyaltyService, orderID)
+
+		c.JSON(http.StatusCreated, gin.H{"collection": entry})
+	}
+}
+
+func handleGetCourierCashFloat(cashService *cash.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, offset := parseLimitOffset(c, 20, 100)
+
+		courierID, _ := c.Get("userID")
+		float, err := cashService.GetFloat(courierID.(uuid.UUID), limit, offset)
+		if err != nil {
+			respondCashError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, float)
+	}
+}
+
+func handleCreateCashRemittance(cashService *cash.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req cash.RemittanceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		courierID, _ := c.Get("userID")
+		remittance, err := cashService.CreateRemittance(courierID.(uuid.UUID), &req)
+		if err != nil {
+			respondCashError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"remittance": remittance})
+	}
+}
+
+func handleGetCourierRemittances(cashService *cash.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, offset := parseLimitOffset(c, 20, 100)
+
+		courierID, _ := c.Get("userID")
+		id := courierID.(uuid.UUID)
+		remittances, err := cashService.ListRemittances(&id, nil, cash.RemittanceStatus(c.Query("status")), limit, offset)
+		if err != nil {
+			respondCashError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"remittances": remittances})
+	}
+}
+
+func handleGetProviderRemittances(cashService *cash.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, offset := parseLimitOffset(c, 20, 100)
+
+		providerID, _ := c.Get("userID")
+		id := providerID.(uuid.UUID)
+		remittances, err := cashService.ListRemittances(nil, &id, cash.RemittanceStatus(c.Query("status")), limit, offset)
+		if err != nil {
+			respondCashError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"remittances": remittances})
+	}
+}
+
+// handleResolveProviderRemittance confirms or disputes a handover made to the provider
+func handleResolveProviderRemittance(cashService *cash.Service, confirm bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		remittanceID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid remittance ID"})
+			return
+		}
+
+		providerID, _ := c.Get("userID")
+		id := providerID.(uuid.UUID)
+		if confirm {
+			err = cashService.ConfirmRemittance(remittanceID, &id)
+		} else {
+			err = cashService.DisputeRemittance(remittanceID, &id)
+		}
+		if err != nil {
+			respondCashError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Remittance updated"})
+	}
+}
+
+// Admin cash handlers
+
+func handleAdminGetOutstandingCash(cashService *cash.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report, err := cashService.OutstandingReport()
+		if err != nil {
+			respondCashError(c, err)
+			return
+		}
+
+		var total float64
+		for _, row := range report {
+			total += row.Balance
+		}
+
+		c.JSON(http.StatusOK, gin.H{"couriers": report, "total_outstanding": total})
+	}
+}
+
+func handleAdminGetCourierCash(cashService *cash.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		courierID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid courier ID"})
+			return
+		}
+		limit, offset := parseLimitOffset(c, 50, 200)
+
+		float, err := cashService.GetFloat(courierID, limit, offset)
+		if err != nil {
+			respondCashError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, float)
+	}
+}
+
+func handleAdminGetRemittances(cashService *cash.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, offset := parseLimitOffset(c, 50, 200)
+		remittances, err := cashService.ListRemittances(nil, nil, cash.RemittanceStatus(c.Query("status")), limit, offset)
+		if err != nil {
+			respondCashError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"remittances": remittances})
+	}
+}
+
+// handleAdminResolveRemittance confirms or disputes a handover made to the platform
+func handleAdminResolveRemittance(cashService *cash.Service, confirm bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		remittanceID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid remittance ID"})
+			return
+		}
+
+		if confirm {
+			err = cashService.ConfirmRemittance(remittanceID, nil)
+		} else {
+			err = cashService.DisputeRemittance(remittanceID, nil)
+		}
+		if err != nil {
+			respondCashError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Remittance updated"})
+	}
+}
+
+func respondCashError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, cash.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, cash.ErrNotAssignedCourier):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, cash.ErrAlreadyCollected), errors.Is(err, cash.ErrRemittanceNotPending),
+		errors.Is(err, cash.ErrOrderNotCollectable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, cash.ErrNotCashOrder), errors.Is(err, cash.ErrInsufficientFloat),
+		errors.Is(err, cash.ErrInvalidRecipient), errors.Is(err, cash.ErrInvalidAmount):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/chat"
+	"github.com/yakumwamba/lpg-delivery-system/pkg/realtime"
+)
+
+// Order Chat Handlers
+
+func handleGetOrderMessages(chatService *chat.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		userID, _ := c.Get("userID")
+		messages, participants, err := chatService.ListMessages(orderID, userID.(uuid.UUID))
+		if err != nil {
+			respondChatError(c, err, "Failed to fetch messages")
+			return
+		}
+
+		role, _ := participants.RoleOf(userID.(uuid.UUID))
+		c.JSON(http.StatusOK, gin.H{
+			"messages":      messages,
+			"closed":        participants.IsClosed(time.Now()),
+			"quick_replies": chat.QuickReplies(role),
+		})
+	}
+}
+
+func handleSendOrderMessage(chatService *chat.Service, hub *realtime.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		var req struct {
+			Body        string `json:"body"`
+			TemplateKey string `json:"template_key"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userID, _ := c.Get("userID")
+		msg, participants, err := chatService.SendMessage(orderID, userID.(uuid.UUID), req.Body, req.TemplateKey)
+		if err != nil {
+			respondChatError(c, err, "Failed to send message")
+			return
+		}
+
+		broadcastChatMessage(hub, participants, msg)
+		c.JSON(http.StatusCreated, gin.H{"message": msg})
+	}
+}
+
+func handleSendOrderAttachment(chatService *chat.Service, hub *realtime.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		file, err := c.FormFile("image")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No image uploaded"})
+			return
+		}
+
+		src, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not read file"})
+			return
+		}
+		defer src.Close()
+
+		userID, _ := c.Get("userID")
+		msg, participants, err := chatService.SendAttachment(orderID, userID.(uuid.UUID), src, file, c.PostForm("caption"))
+		if err != nil {
+			respondChatError(c, err, "Failed to send image")
+			return
+		}
+
+		broadcastChatMessage(hub, participants, msg)
+		c.JSON(http.StatusCreated, gin.H{"message": msg})
+	}
+}
+
+func handleMarkOrderMessagesRead(chatService *chat.Service, hub *realtime.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		userID, _ := c.Get("userID")
+		readerID := userID.(uuid.UUID)
+
+		marked, participants, err := chatService.MarkRead(orderID, readerID)
+		if err != nil {
+			respondChatError(c, err, "Failed to mark messages as read")
+			return
+		}
+
+		if marked > 0 {
+			for _, id := range participants.UserIDs() {
+				if id == readerID {
+					continue
+				}
+				hub.BroadcastChatRead(orderID.String(), id.String(), gin.H{
+					"order_id":  orderID,
+					"reader_id": readerID,
+					"read_at":   time.Now(),
+				})
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"marked_read": marked})
+	}
+}
+
+func handleGetOrderMessageAttachment(chatService *chat.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+		messageID, err := uuid.Parse(c.Param("message_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+			return
+		}
+
+		userID, _ := c.Get("userID")
+		path, err := chatService.GetAttachmentPath(orderID, messageID, userID.(uuid.UUID))
+		if err != nil {
+			respondChatError(c, err, "Failed to fetch attachment")
+			return
+		}
+
+		serveChatAttachment(c, path)
+	}
+}
+
+func handleGetChatQuickReplies() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"customer": chat.QuickReplies(chat.RoleCustomer),
+			"provider": chat.QuickReplies(chat.RoleProvider),
+			"courier":  chat.QuickReplies(chat.RoleCourier),
+		})
+	}
+}
+
+// Admin chat handlers (dispute review)
+
+func handleAdminGetOrderMessages(chatService *chat.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		messages, err := chatService.GetOrderMessages(orderID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"messages": messages})
+	}
+}
+
+func handleAdminGetOrderMessageAttachment(chatService *chat.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+		messageID, err := uuid.Parse(c.Param("message_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+			return
+		}
+
+		path, err := chatService.GetAttachmentPathForAdmin(orderID, messageID)
+		if err != nil {
+			respondChatError(c, err, "Failed to fetch attachment")
+			return
+		}
+
+		serveChatAttachment(c, path)
+	}
+}
+
+// broadcastChatMessage pushes a new message to every participant except the sender
+func broadcastChatMessage(hub *realtime.Hub, participants *chat.Participants, msg *chat.Message) {
+	for _, id := range participants.UserIDs() {
+		if id == msg.SenderID {
+			continue
+		}
+		hub.BroadcastChatMessage(msg.OrderID.String(), id.String(), msg)
+	}
+}
+
+func serveChatAttachment(c *gin.Context, path string) {
+	if _, err := os.Stat(path); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment file not found"})
+		return
+	}
+
+	contentType := "application/octet-stream"
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		contentType = "image/jpeg"
+	case ".png":
+		contentType = "image/png"
+	case ".webp":
+		contentType = "image/webp"
+	}
+
+	c.Header("Content-Type", contentType)
+	c.File(path)
+}
+
+func respondChatError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, chat.ErrOrderNotFound), errors.Is(err, chat.ErrMessageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, chat.ErrNotParticipant):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, chat.ErrChatClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, chat.ErrInvalidMessage):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
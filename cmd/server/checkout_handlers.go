@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/order"
+	"github.com/yakumwamba/lpg-delivery-system/internal/pawapay"
+	"github.com/yakumwamba/lpg-delivery-system/internal/payment"
+	"github.com/yakumwamba/lpg-delivery-system/internal/phone"
+)
+
+// Checkout Handlers
+
+// handleCheckoutOrder starts paying what is left of an order through the gateway for its
+// payment method. Mobile money orders prompt phone_number directly, or use PawaPay's
+// payment page when no number is given; card orders use DPO's checkout page. The client
+// opens redirect_url for the hosted pages.
+func handleCheckoutOrder(orderService *order.Service, paymentService *payment.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		var req struct {
+			PhoneNumber string `json:"phone_number"`
+			Operator    string `json:"operator"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userID := c.MustGet("userID").(uuid.UUID)
+		o, err := orderService.GetOrderByID(orderID)
+		if err != nil || o.UserID != userID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+		if o.Status == order.OrderStatusRejected {
+			c.JSON(http.StatusConflict, gin.H{"error": "Order was rejected and cannot be paid"})
+			return
+		}
+
+		// An attempt still open on the gateway is settled or expired first, so the
+		// payer can't end up paying twice from two payment pages
+		open, err := paymentService.OpenAttempt(o.ID)
+		if err != nil {
+			respondPaymentError(c, err)
+			return
+		}
+		if open != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": payment.ErrAttemptPending.Error(), "payment": open})
+			return
+		}
+
+		amount, err := paymentService.AmountOwed(o.ID)
+		if err != nil {
+			respondPaymentError(c, err)
+			return
+		}
+		if amount <= 0 || o.PaymentStatus == order.PaymentStatusPaid || o.PaymentStatus == order.PaymentStatusRefunded {
+			c.JSON(http.StatusConflict, gin.H{"error": "Order is already paid"})
+			return
+		}
+
+		provider, err := payment.GatewayFor(string(o.PaymentMethod))
+		if err != nil {
+			respondPaymentError(c, err)
+			return
+		}
+
+		p, err := paymentService.Initiate(provider, payment.Request{
+			OrderID:     o.ID,
+			Amount:      amount,
+			PhoneNumber: req.PhoneNumber,
+			Operator:    req.Operator,
+			Hosted:      req.PhoneNumber == "",
+		})
+		if err != nil {
+			respondPaymentError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, p)
+	}
+}
+
+// returnReferenceParams are the query parameters each gateway puts its payment reference
+// in when it sends the payer back
+var returnReferenceParams = map[string][]string{
+	payment.ProviderDPO:     {"TransactionToken", "TransID"},
+	payment.ProviderPawaPay: {"depositId"},
+}
+
+// handlePaymentReturn verifies a payment when a gateway's hosted page sends the payer
+// back. The payer is forwarded to resultURL with the outcome when one is configured.
+func handlePaymentReturn(paymentService *payment.Service, provider, resultURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var reference string
+		for _, param := range returnReferenceParams[provider] {
+			if reference = c.Query(param); reference != "" {
+				break
+			}
+		}
+		if reference == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Payment reference is required"})
+			return
+		}
+
+		p, err := paymentService.Verify(provider, reference)
+		if err != nil {
+			respondPaymentError(c, err)
+			return
+		}
+
+		if resultURL != "" {
+			values := url.Values{}
+			values.Set("order_id", p.OrderID.String())
+			values.Set("status", string(p.Status))
+			c.Redirect(http.StatusFound, resultURL+"?"+values.Encode())
+			return
+		}
+		c.JSON(http.StatusOK, p)
+	}
+}
+
+func respondPaymentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, payment.ErrPaymentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, payment.ErrNoGateway), errors.Is(err, phone.ErrInvalidNumber),
+		errors.Is(err, phone.ErrUnknownOperator), errors.Is(err, pawapay.ErrUnsupportedOperator):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, payment.ErrUnknownGateway), errors.Is(err, pawapay.ErrProviderUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		log.Printf("ERROR: Payment request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Payment request failed"})
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/invoice"
+)
+
+// Invoice Handlers
+
+func handleGetOrderInvoice(invoiceService *invoice.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		userID, _ := c.Get("userID")
+		inv, err := invoiceService.GetCustomerInvoice(orderID, userID.(uuid.UUID))
+		if err != nil {
+			respondInvoiceError(c, err)
+			return
+		}
+
+		writeInvoice(c, inv, "json")
+	}
+}
+
+func handleSendOrderInvoice(invoiceService *invoice.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		userID, _ := c.Get("userID")
+		inv, err := invoiceService.GetCustomerInvoice(orderID, userID.(uuid.UUID))
+		if err != nil {
+			respondInvoiceError(c, err)
+			return
+		}
+
+		sendInvoice(c, invoiceService, inv)
+	}
+}
+
+func handleAdminGetOrderInvoice(invoiceService *invoice.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		inv, err := invoiceService.GetInvoice(orderID)
+		if err != nil {
+			respondInvoiceError(c, err)
+			return
+		}
+
+		writeInvoice(c, inv, "json")
+	}
+}
+
+func handleAdminSendOrderInvoice(invoiceService *invoice.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		inv, err := invoiceService.GetInvoice(orderID)
+		if err != nil {
+			respondInvoiceError(c, err)
+			return
+		}
+
+		sendInvoice(c, invoiceService, inv)
+	}
+}
+
+// handleGetSharedInvoice serves the invoice behind the link sent by SMS or email
+func handleGetSharedInvoice(invoiceService *invoice.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inv, err := invoiceService.GetInvoiceByShareToken(c.Param("token"))
+		if err != nil {
+			respondInvoiceError(c, err)
+			return
+		}
+
+		writeInvoice(c, inv, "html")
+	}
+}
+
+// writeInvoice renders the invoice in the format requested by the "format" query parameter
+func writeInvoice(c *gin.Context, inv *invoice.Invoice, defaultFormat string) {
+	switch c.DefaultQuery("format", defaultFormat) {
+	case "pdf":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", inv.Number+".pdf"))
+		c.Data(http.StatusOK, "application/pdf", invoice.RenderPDF(inv))
+	case "html":
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Status(http.StatusOK)
+		if err := invoice.RenderHTML(c.Writer, inv); err != nil {
+			c.String(http.StatusInternalServerError, "Failed to render invoice")
+		}
+	case "json":
+		c.JSON(http.StatusOK, inv)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be one of json, html or pdf"})
+	}
+}
+
+func sendInvoice(c *gin.Context, invoiceService *invoice.Service, inv *invoice.Invoice) {
+	var req struct {
+		Channel string `json:"channel" binding:"required,oneof=sms email"`
+		To      string `json:"to"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var err error
+	if req.Channel == "sms" {
+		err = invoiceService.SendBySMS(inv, req.To)
+	} else {
+		err = invoiceService.SendByEmail(inv, req.To)
+	}
+	if err != nil {
+		if errors.Is(err, invoice.ErrDeliveryDisabled) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invoice sent", "invoice_number": inv.Number})
+}
+
+func respondInvoiceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, invoice.ErrOrderNotFound), errors.Is(err, invoice.ErrInvoiceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, invoice.ErrOrderNotPaid):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get invoice"})
+	}
+}
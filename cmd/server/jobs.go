@@ -0,0 +1,35 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// runDaily calls job once a day at the given local hour, starting with the next occurrence
+func runDaily(name string, hour int, job func(now time.Time)) {
+	go func() {
+		for {
+			now := time.Now()
+			next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+			if !next.After(now) {
+				next = next.AddDate(0, 0, 1)
+			}
+			time.Sleep(time.Until(next))
+
+			log.Printf("⏰ Running daily job %s", name)
+			job(time.Now())
+		}
+	}()
+}
+
+// runEvery calls job at a fixed interval, starting one interval from now
+func runEvery(name string, interval time.Duration, job func(now time.Time)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			log.Printf("⏰ Running job %s", name)
+			job(now)
+		}
+	}()
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/loyalty"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+	"github.com/yakumwamba/lpg-delivery-system/internal/order"
+	"github.com/yakumwamba/lpg-delivery-system/internal/promo"
+)
+
+// applyLoyaltyPoints prices the points the customer asked to redeem into the order's
+// breakdown, against what the promotion left of the delivery fee or cylinder price.
+// Call it after applyPromotion.
+func applyLoyaltyPoints(loyaltyService *loyalty.Service, o *order.Order, applied *promo.Applied) (*loyalty.Redemption, error) {
+	if o.PointsRedeemed <= 0 {
+		o.PointsTarget = ""
+		o.ApplyLoyalty(0, 0)
+		return nil, nil
+	}
+
+	product, deliveryFee := o.TotalPrice, o.DeliveryFee
+	if applied != nil {
+		if applied.AppliesTo == promo.TargetDeliveryFee {
+			deliveryFee -= money.Kwacha(applied.Discount)
+		} else {
+			product -= money.Kwacha(applied.Discount)
+		}
+	}
+
+	target := loyalty.Target(o.PointsTarget)
+	if target == "" {
+		target = loyalty.TargetDeliveryFee
+	}
+	redemption, err := loyaltyService.QuoteRedemption(o.UserID, o.PointsRedeemed, target, product.Kwacha(), deliveryFee.Kwacha())
+	if err != nil {
+		return nil, err
+	}
+
+	o.PointsTarget = string(redemption.Target)
+	o.ApplyLoyalty(redemption.Points, money.Kwacha(redemption.Amount))
+	return redemption, nil
+}
+
+// earnLoyaltyPoints credits the customer for a delivered order
+func earnLoyaltyPoints(loyaltyService *loyalty.Service, orderID uuid.UUID) {
+	if _, err := loyaltyService.EarnForOrder(orderID); err != nil && !errors.Is(err, loyalty.ErrAlreadyRecorded) {
+		log.Printf("ERROR: Failed to award loyalty points for order %s: %v", orderID, err)
+	}
+}
+
+// restoreLoyaltyPoints gives back points redeemed on an order that didn't go ahead
+func restoreLoyaltyPoints(loyaltyService *loyalty.Service, orderID uuid.UUID) {
+	if _, err := loyaltyService.Restore(orderID); err != nil {
+		log.Printf("ERROR: Failed to restore loyalty points for order %s: %v", orderID, err)
+	}
+}
+
+// reverseLoyaltyPoints takes back points earned on an order that has been refunded
+func reverseLoyaltyPoints(loyaltyService *loyalty.Service, orderID uuid.UUID) {
+	if _, err := loyaltyService.ReverseForRefund(orderID); err != nil {
+		log.Printf("ERROR: Failed to reverse loyalty points for order %s: %v", orderID, err)
+	}
+}
+
+// handleGetLoyaltyPoints returns the customer's points balance, tier and ledger
+func handleGetLoyaltyPoints(loyaltyService *loyalty.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uuid.UUID)
+		limit, offset := parseLimitOffset(c, 50, 200)
+
+		account, err := loyaltyService.GetAccount(userID)
+		if err != nil {
+			respondLoyaltyError(c, err)
+			return
+		}
+		transactions, err := loyaltyService.ListTransactions(userID, limit, offset)
+		if err != nil {
+			respondLoyaltyError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"account":      account,
+			"transactions": transactions,
+		})
+	}
+}
+
+func handleAdminRunLoyaltyExpiry(loyaltyService *loyalty.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := loyaltyService.ExpirePoints(time.Now())
+		if err != nil {
+			respondLoyaltyError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func respondLoyaltyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, loyalty.ErrInsufficientPoints), errors.Is(err, loyalty.ErrBelowMinimum),
+		errors.Is(err, loyalty.ErrNothingToRedeem):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, loyalty.ErrInvalidTarget):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, loyalty.ErrAlreadyRecorded):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("ERROR: Loyalty request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Loyalty request failed"})
+	}
+}
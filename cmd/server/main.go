@@ -20,16 +20,33 @@ import (
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	"github.com/sirupsen/logrus"
+	"github.com/yakumwamba/lpg-delivery-system/internal/address"
 	"github.com/yakumwamba/lpg-delivery-system/internal/admin"
 	"github.com/yakumwamba/lpg-delivery-system/internal/auth"
+	"github.com/yakumwamba/lpg-delivery-system/internal/business"
+	"github.com/yakumwamba/lpg-delivery-system/internal/cash"
+	"github.com/yakumwamba/lpg-delivery-system/internal/chat"
+	"github.com/yakumwamba/lpg-delivery-system/internal/common"
+	"github.com/yakumwamba/lpg-delivery-system/internal/courier"
 	"github.com/yakumwamba/lpg-delivery-system/internal/inventory"
+	"github.com/yakumwamba/lpg-delivery-system/internal/invoice"
 	"github.com/yakumwamba/lpg-delivery-system/internal/location"
+	"github.com/yakumwamba/lpg-delivery-system/internal/loyalty"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+	"github.com/yakumwamba/lpg-delivery-system/internal/notify"
 	"github.com/yakumwamba/lpg-delivery-system/internal/order"
 	"github.com/yakumwamba/lpg-delivery-system/internal/pawapay"
 	"github.com/yakumwamba/lpg-delivery-system/internal/payment"
 	"github.com/yakumwamba/lpg-delivery-system/internal/preferences"
+	"github.com/yakumwamba/lpg-delivery-system/internal/promo"
 	"github.com/yakumwamba/lpg-delivery-system/internal/provider"
+	"github.com/yakumwamba/lpg-delivery-system/internal/reconciliation"
+	"github.com/yakumwamba/lpg-delivery-system/internal/referral"
+	"github.com/yakumwamba/lpg-delivery-system/internal/refund"
+	"github.com/yakumwamba/lpg-delivery-system/internal/review"
+	"github.com/yakumwamba/lpg-delivery-system/internal/usage"
 	"github.com/yakumwamba/lpg-delivery-system/internal/user"
+	"github.com/yakumwamba/lpg-delivery-system/internal/wallet"
 	"github.com/yakumwamba/lpg-delivery-system/pkg/database"
 	"github.com/yakumwamba/lpg-delivery-system/pkg/middleware"
 	"github.com/yakumwamba/lpg-delivery-system/pkg/realtime"
@@ -108,6 +125,23 @@ func main() {
 		pawaPayCallbackURL,
 	)
 
+	// Callbacks must carry PawaPay's signature once its public key is configured. Without
+	// one, unsigned callbacks are only accepted against the sandbox or a local simulator.
+	var pawaPayCallbackVerifier *pawapay.Verifier
+	allowUnsignedCallbacks := pawapay.UnsignedCallbacksAllowed(pawaPayURL)
+	if key := os.Getenv("PAWAPAY_CALLBACK_PUBLIC_KEY"); key != "" {
+		verifier, err := pawapay.NewVerifier(key)
+		if err != nil {
+			log.Fatalf("Invalid PAWAPAY_CALLBACK_PUBLIC_KEY: %v", err)
+		}
+		pawaPayCallbackVerifier = verifier
+		log.Println("✅ PawaPay callback signatures will be verified")
+	} else if allowUnsignedCallbacks {
+		log.Println("⚠️  PAWAPAY_CALLBACK_PUBLIC_KEY not set - PawaPay callbacks are accepted unsigned")
+	} else {
+		log.Println("❌ PAWAPAY_CALLBACK_PUBLIC_KEY not set - PawaPay callbacks are refused until it is")
+	}
+
 	// Initialize Twilio client with environment variables
 	twilioAccountSID := os.Getenv("TWILIO_ACCOUNT_SID")
 	twilioAuthToken := os.Getenv("TWILIO_AUTH_TOKEN")
@@ -126,11 +160,128 @@ func main() {
 	log.Println("📊 Initializing services...")
 	userService := user.NewService(db)
 	orderService := order.NewService(db)
-	paymentService := payment.NewService(db, orderService, userService, pawaPayClient)
+	paymentService := payment.NewService(db, pawaPayClient)
+	walletService := wallet.NewService(db, pawaPayClient)
+	courierService := courier.NewService(db)
+
+	// Mobile money goes through PawaPay and card payments through DPO's hosted checkout.
+	// Payers on either hosted page are sent back to us to verify the payment.
+	publicBaseURL := strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/")
+	paymentService.RegisterGateway(payment.NewPawaPayGateway(pawaPayClient, publicBaseURL+"/payments/pawapay/return"))
+	dpoServiceType := 3854
+	if n, err := strconv.Atoi(os.Getenv("DPO_SERVICE_TYPE")); err == nil && n > 0 {
+		dpoServiceType = n
+	}
+	dpoClient := common.NewClient(os.Getenv("DPO_COMPANY_TOKEN"), os.Getenv("DPO_API_URL"))
+	paymentService.RegisterGateway(payment.NewDPOGateway(dpoClient, publicBaseURL+"/payments/dpo/return", dpoServiceType))
+
+	// Orders and dispatch follow payment outcomes from every gateway. Top-ups after an
+	// edit only settle the edit; the order was already paid and dispatched. A courier is
+	// sent only when the payment is what made the order paid, never for a late success on
+	// an order that was rejected, refunded or already paid.
+	paymentService.Subscribe(payment.EventPaymentCompleted, func(e payment.Event) error {
+		if e.Purpose == payment.PurposeAdjustment {
+			return orderService.SettleTopUp(e.Reference, order.AdjustmentStatusCompleted)
+		}
+		// The wallet marks the orders it settles paid itself
+		if e.Provider != payment.ProviderWallet {
+			marked, err := orderService.MarkPaid(e.OrderID)
+			if err != nil || !marked {
+				return err
+			}
+		}
+		go func() {
+			courierID, err := courierService.AutoAssign(e.OrderID)
+			switch {
+			case err != nil:
+				log.Printf("❌ Failed to auto-assign courier for order %s: %v", e.OrderID, err)
+			case courierID != nil:
+				log.Printf("✅ Auto-assigned courier %s to order %s", courierID, e.OrderID)
+			}
+		}()
+		return nil
+	})
+	paymentService.Subscribe(payment.EventPaymentFailed, func(e payment.Event) error {
+		if e.Purpose == payment.PurposeAdjustment {
+			return orderService.SettleTopUp(e.Reference, order.AdjustmentStatusFailed)
+		}
+		return orderService.MarkPaymentFailed(e.OrderID)
+	})
+	promoService := promo.NewService(db)
+	loyaltyService := loyalty.NewService(db)
 	inventoryService := inventory.NewService(db)
 	locationService := location.NewService(db)
 	providerService := provider.NewService(db)
 	preferencesService := preferences.NewService(db)
+	reviewService := review.NewService(db)
+	chatService := chat.NewService(db)
+	addressService := address.NewService(db)
+	businessService := business.NewService(db)
+	cashService := cash.NewService(db)
+
+	// Refunds above the threshold (ZMW, summed per payment) wait for an admin's approval,
+	// as do customers' refunds on orders that weren't cancelled
+	refundApprovalThreshold := money.Amount(50000)
+	if v, err := money.Parse(os.Getenv("REFUND_APPROVAL_THRESHOLD")); err == nil && v >= 0 {
+		refundApprovalThreshold = v
+	}
+	refundService := refund.NewService(db, pawaPayClient, refundApprovalThreshold)
+	refundService.OnCompleted(func(orderID uuid.UUID) {
+		reverseLoyaltyPoints(loyaltyService, orderID)
+	})
+
+	// Invoices are emailed only when SMTP is configured
+	invoiceMailer := invoice.NewMailer(invoice.MailConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	})
+	invoiceService := invoice.NewService(db, twilioClient, invoiceMailer, os.Getenv("PUBLIC_BASE_URL"))
+
+	// Refill reminders go out by push, or SMS when the app has no push token
+	deepLinkBase := os.Getenv("APP_DEEP_LINK_BASE")
+	if deepLinkBase == "" {
+		deepLinkBase = "zamgas://"
+	}
+	usageService := usage.NewService(db, notify.NewExpoClient(os.Getenv("EXPO_ACCESS_TOKEN")), twilioClient, deepLinkBase)
+	referralService := referral.NewService(db, walletService, deepLinkBase)
+	reminderHour := 8
+	if h, err := strconv.Atoi(os.Getenv("REFILL_REMINDER_HOUR")); err == nil && h >= 0 && h < 24 {
+		reminderHour = h
+	}
+	runDaily("refill-reminders", reminderHour, func(now time.Time) {
+		sent, err := usageService.SendDueReminders(now)
+		if err != nil {
+			log.Printf("❌ Refill reminders failed: %v", err)
+			return
+		}
+		log.Printf("✅ Sent %d refill reminders", sent)
+	})
+	// Lapses expired loyalty points and awards any missed when an order was delivered
+	runDaily("loyalty-points", 3, func(now time.Time) {
+		if awarded, err := loyaltyService.AwardDelivered(now.AddDate(0, 0, -7)); err != nil {
+			log.Printf("❌ Loyalty points awards failed: %v", err)
+		} else if awarded > 0 {
+			log.Printf("✅ Awarded loyalty points for %d delivered orders", awarded)
+		}
+		result, err := loyaltyService.ExpirePoints(now)
+		if err != nil {
+			log.Printf("❌ Loyalty points expiry failed: %v", err)
+			return
+		}
+		log.Printf("✅ Expired %d loyalty points across %d accounts", result.Points, result.Accounts)
+	})
+	// Catches referees whose delivered order was only marked paid later, e.g. cash remittances
+	runDaily("referral-rewards", 2, func(now time.Time) {
+		result, err := referralService.RewardQualified()
+		if err != nil {
+			log.Printf("❌ Referral rewards failed: %v", err)
+			return
+		}
+		log.Printf("✅ Referral rewards: %d rewarded, %d rejected, %d failed", result.Rewarded, result.Rejected, result.Failed)
+	})
 
 	authService := auth.NewService(db, userService, jwtSecret)
 
@@ -152,7 +303,7 @@ func main() {
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "X-Next-Cursor"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -177,7 +328,7 @@ func main() {
 	router.GET("/ws", realtime.HandleWebSocket(hub))
 
 	// Auth routes
-	router.POST("/auth/signup", dbMiddleware, handleSignUp(authService))
+	router.POST("/auth/signup", dbMiddleware, handleSignUp(authService, referralService))
 	router.POST("/auth/signin", dbMiddleware, handleSignIn(authService, db))
 	router.GET("/auth/signout", handleSignOut(authService))
 	router.POST("/auth/send-code", auth.HandleSendCode(userService, authService, twilioClient))
@@ -185,7 +336,7 @@ func main() {
 
 	// Google OAuth routes
 	router.GET("/auth/google", handleGoogleLogin())
-	router.GET("/auth/google/callback", handleGoogleCallback(authService, userService))
+	router.GET("/auth/google/callback", handleGoogleCallback(authService, userService, referralService))
 	// router.POST("/auth/verXify-code", handleVerifyCode(userService, authService))
 
 	// Admin auth routes (separate from regular user auth)
@@ -196,23 +347,74 @@ func main() {
 	userRoutes := router.Group("/user")
 	userRoutes.Use(middleware.AuthMiddleware(authService), dbMiddleware)
 	{
-		userRoutes.GET("/profile", handleGetProfile(userService))
+		userRoutes.GET("/profile", handleGetProfile(userService, loyaltyService))
 		userRoutes.PUT("/profile", handleUpdateProfile(userService))
-		userRoutes.POST("/orders/create", middleware.UserTypeMiddleware(user.UserTypeCustomer), handleCreateOrder(orderService, inventoryService, db, hub))
+		userRoutes.POST("/orders/create", middleware.UserTypeMiddleware(user.UserTypeCustomer), handleCreateOrder(orderService, inventoryService, businessService, addressService, promoService, loyaltyService, db, hub))
 		userRoutes.GET("/orders", middleware.UserTypeMiddleware(user.UserTypeCustomer), handleGetUserOrders(orderService))
+		userRoutes.GET("/orders/suggested", middleware.UserTypeMiddleware(user.UserTypeCustomer), handleGetSuggestedOrder(orderService))
+		userRoutes.POST("/orders/:id/reorder", middleware.UserTypeMiddleware(user.UserTypeCustomer), handleReorder(orderService, businessService, promoService, hub))
+		userRoutes.PATCH("/orders/:id", middleware.UserTypeMiddleware(user.UserTypeCustomer), handleModifyOrder(orderService, businessService, addressService, paymentService, walletService, userService, hub))
+		userRoutes.GET("/orders/:id/edits", middleware.UserTypeMiddleware(user.UserTypeCustomer), handleGetOrderEdits(orderService))
+		userRoutes.POST("/orders/:id/wallet-payment", middleware.UserTypeMiddleware(user.UserTypeCustomer), handlePayOrderWithWallet(walletService, paymentService, hub))
 		// Add this to your routes configuration
 		// Add this to your routes configuration
 		userRoutes.PUT("/orders/:id/payment-status", handleUpdateOrderPaymentStatus(orderService))
+
+		// Post-delivery rating of provider and courier
+		userRoutes.POST("/orders/:id/rating", middleware.UserTypeMiddleware(user.UserTypeCustomer), handleSubmitOrderRating(reviewService))
+		userRoutes.GET("/orders/:id/rating", middleware.UserTypeMiddleware(user.UserTypeCustomer), handleGetOrderRating(reviewService))
+
+		// Receipts and tax invoices for paid orders
+		userRoutes.GET("/orders/:id/invoice", middleware.UserTypeMiddleware(user.UserTypeCustomer), handleGetOrderInvoice(invoiceService))
+		userRoutes.POST("/orders/:id/checkout", middleware.UserTypeMiddleware(user.UserTypeCustomer), handleCheckoutOrder(orderService, paymentService))
+		userRoutes.POST("/orders/:id/invoice/send", middleware.UserTypeMiddleware(user.UserTypeCustomer), handleSendOrderInvoice(invoiceService))
+
+		// Full and partial refunds of mobile money payments
+		userRoutes.POST("/orders/:id/refunds", middleware.UserTypeMiddleware(user.UserTypeCustomer), handleRequestRefund(orderService, refundService))
+		userRoutes.GET("/orders/:id/refunds", middleware.UserTypeMiddleware(user.UserTypeCustomer), handleGetOrderRefunds(orderService, refundService))
 	}
 	router.PUT("/user/location", middleware.AuthMiddleware(authService), handleUpdateUserLocation(userService))
 
+	// In-order chat between customer, provider and assigned courier
+	chatRoutes := router.Group("/orders/:id/messages")
+	chatRoutes.Use(middleware.AuthMiddleware(authService), dbMiddleware)
+	{
+		chatRoutes.GET("", handleGetOrderMessages(chatService))
+		chatRoutes.POST("", handleSendOrderMessage(chatService, hub))
+		chatRoutes.POST("/attachments", handleSendOrderAttachment(chatService, hub))
+		chatRoutes.POST("/read", handleMarkOrderMessagesRead(chatService, hub))
+		chatRoutes.GET("/:message_id/attachment", handleGetOrderMessageAttachment(chatService))
+	}
+	router.GET("/chat/quick-replies", handleGetChatQuickReplies())
+
+	// Business accounts: members, delivery sites and monthly statements
+	businessRoutes := router.Group("/business")
+	businessRoutes.Use(middleware.AuthMiddleware(authService), dbMiddleware, middleware.UserTypeMiddleware(user.UserTypeCustomer))
+	{
+		businessRoutes.GET("/account", handleGetMyBusinessAccount(businessService))
+		businessRoutes.GET("/members", handleGetBusinessMembers(businessService))
+		businessRoutes.POST("/members", handleAddBusinessMember(businessService))
+		businessRoutes.DELETE("/members/:user_id", handleRemoveBusinessMember(businessService))
+		businessRoutes.GET("/sites", handleGetBusinessSites(businessService))
+		businessRoutes.POST("/sites", handleAddBusinessSite(businessService))
+		businessRoutes.DELETE("/sites/:id", handleDeleteBusinessSite(businessService))
+		businessRoutes.GET("/statements", handleGetBusinessStatements(businessService))
+		businessRoutes.GET("/statements/:id", handleGetBusinessStatement(businessService))
+	}
+
+	// Areas we deliver to, for the address picker
+	router.GET("/service-zones", handleGetServiceZones(addressService, true))
+
+	// Public invoice links sent by SMS and email
+	router.GET("/invoices/:token", handleGetSharedInvoice(invoiceService))
+
 	// Provider routes
 	providerRoutes := router.Group("/provider")
 	providerRoutes.Use(middleware.AuthMiddleware(authService), middleware.UserTypeMiddleware(user.UserTypeProvider), dbMiddleware)
 	{
 		providerRoutes.GET("/orders", handleGetProviderOrders(orderService))
 		providerRoutes.PUT("/orders/:id/accept", handleAcceptOrder(orderService))
-		providerRoutes.PUT("/orders/:id/reject", handleRejectOrder(orderService))
+		providerRoutes.PUT("/orders/:id/reject", handleRejectOrder(orderService, walletService, loyaltyService))
 		providerRoutes.GET("/orders/:id", handleGetSingleOrder(orderService, userService))
 		// providerRoutes.GET("/orders/:id/user", handleGetUserDetails(userService))
 		// providerRoutes.GET("/best", handleGetBestProvider(userService, orderService))
@@ -221,6 +423,10 @@ func main() {
 		providerRoutes.GET("/inventory", handleGetProviderInventory(inventoryService))
 		providerRoutes.PUT("/inventory/:id/stock", handleUpdateStock(inventoryService))
 
+		// Cash handed over by couriers for cash-on-delivery orders
+		providerRoutes.GET("/cash/remittances", handleGetProviderRemittances(cashService))
+		providerRoutes.PUT("/cash/remittances/:id/confirm", handleResolveProviderRemittance(cashService, true))
+		providerRoutes.PUT("/cash/remittances/:id/dispute", handleResolveProviderRemittance(cashService, false))
 	}
 
 	customerRoutes := router.Group("/customer")
@@ -237,7 +443,35 @@ func main() {
 		// customerRoutes.PUT("/preferences/provider", handleUpdateProviderPreference(preferencesService))
 
 		// Nearest provider with optional save
-		customerRoutes.POST("/nearest-provider", handleGetNearestProvider(userService, preferencesService))
+		customerRoutes.POST("/nearest-provider", handleGetNearestProvider(userService, preferencesService, addressService))
+
+		// Gas usage prediction and refill reminder settings
+		customerRoutes.GET("/usage", handleGetUsagePrediction(usageService))
+		customerRoutes.PUT("/usage", handleUpdateUsageProfile(usageService))
+
+		// Address book and structured address check against the service zones
+		customerRoutes.GET("/addresses", handleGetSavedAddresses(addressService))
+		customerRoutes.POST("/addresses", handleCreateSavedAddress(addressService))
+		customerRoutes.POST("/addresses/validate", handleValidateAddress(addressService))
+		customerRoutes.PUT("/addresses/:id", handleUpdateSavedAddress(addressService))
+		customerRoutes.PUT("/addresses/:id/default", handleSetDefaultAddress(addressService))
+		customerRoutes.DELETE("/addresses/:id", handleDeleteSavedAddress(addressService))
+
+		// Wallet balance, mobile money top-ups and statement
+		customerRoutes.GET("/wallet", handleGetWallet(walletService))
+		customerRoutes.GET("/wallet/statement", handleGetWalletStatement(walletService))
+		customerRoutes.POST("/wallet/top-ups", handleWalletTopUp(walletService, userService))
+		customerRoutes.GET("/wallet/top-ups/:id", handleGetWalletTopUp(walletService))
+
+		// Running promotions and promo code checks before ordering
+		customerRoutes.GET("/promos", handleGetLivePromotions(promoService))
+		customerRoutes.POST("/promos/quote", handleQuotePromo(orderService, promoService, loyaltyService))
+
+		// Loyalty points balance, tier and ledger
+		customerRoutes.GET("/loyalty", handleGetLoyaltyPoints(loyaltyService))
+
+		// Referral code, share link and rewards earned
+		customerRoutes.GET("/referrals", handleGetReferralDashboard(referralService))
 	}
 
 	// Courier routess
@@ -245,7 +479,7 @@ func main() {
 	courierRoutes.Use(middleware.AuthMiddleware(authService), middleware.UserTypeMiddleware(user.UserTypeCourier), dbMiddleware)
 	{
 		courierRoutes.GET("/orders", handleGetCourierOrders(orderService))
-		courierRoutes.PUT("/orders/:id/update-status", handleUpdateOrderStatus(orderService))
+		courierRoutes.PUT("/orders/:id/update-status", handleUpdateOrderStatus(orderService, referralService, loyaltyService))
 		courierRoutes.POST("/location", handleUpdateLocation(locationService))
 		courierRoutes.PUT("/orders/:id/location", handleUpdateOrderLocation(orderService, locationService))
 		courierRoutes.GET("/orders/:id", handleGetSingleOrder(orderService, userService))
@@ -253,6 +487,12 @@ func main() {
 		courierRoutes.PUT("/orders/:id/decline-assignment", handleDeclineAssignment(orderService))
 		// courierRoutes.GET("/orders/:id/user", handleGetOrderUserDetails(orderService, userService))
 		courierRoutes.GET("/users/:id", handleGetUserDetails(userService))
+
+		// Cash on delivery: collection at the door and handover of the float
+		courierRoutes.POST("/orders/:id/cash-collection", handleRecordCashCollection(cashService, loyaltyService))
+		courierRoutes.GET("/cash", handleGetCourierCashFloat(cashService))
+		courierRoutes.GET("/cash/remittances", handleGetCourierRemittances(cashService))
+		courierRoutes.POST("/cash/remittances", handleCreateCashRemittance(cashService))
 	}
 
 	fmt.Printf("Registering payment routes...")
@@ -264,131 +504,55 @@ func main() {
 
 		paymentRoutes.POST("/deposit", handler.InitiateDepositHandler())
 		paymentRoutes.GET("/status/:depositId", handler.CheckDepositStatusHandler)
-		paymentRoutes.POST("/callback", handler.DepositCallbackHandler())
+		paymentRoutes.GET("/dpo/return", handlePaymentReturn(paymentService, payment.ProviderDPO, os.Getenv("CHECKOUT_RESULT_URL")))
+		paymentRoutes.GET("/pawapay/return", handlePaymentReturn(paymentService, payment.ProviderPawaPay, os.Getenv("CHECKOUT_RESULT_URL")))
 	}
 
 	// PawaPay Callback routes (webhooks from PawaPay)
 	// These endpoints must be public (no auth) as they're called by PawaPay servers
 	pawaPayCallbackHandler := pawapay.NewCallbackHandler(db)
+	pawaPayCallbackHandler.OnDeposit(paymentService.HandleDepositCallback)
+	pawaPayCallbackHandler.OnDeposit(walletService.HandleDepositCallback)
+	pawaPayCallbackHandler.OnRefund(refundService.HandleRefundCallback)
 
-	router.POST("/api/pawapay/callback/deposits", func(c *gin.Context) {
-		logrus.Info("Received PawaPay deposit callback")
-
-		body, err := c.GetRawData()
-		if err != nil {
-			logrus.WithError(err).Error("Failed to read callback body")
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
-			return
-		}
-
-		logrus.WithField("payload", string(body)).Debug("Deposit callback payload")
-
-		if err := pawaPayCallbackHandler.HandleDepositCallback(body); err != nil {
-			logrus.WithError(err).Error("Failed to process deposit callback")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process callback"})
-			return
-		}
-
-		c.JSON(http.StatusOK, gin.H{"status": "success"})
-	})
-
-	router.POST("/api/pawapay/callback/payouts", func(c *gin.Context) {
-		logrus.Info("Received PawaPay payout callback")
-
-		body, err := c.GetRawData()
-		if err != nil {
-			logrus.WithError(err).Error("Failed to read callback body")
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
-			return
-		}
-
-		if err := pawaPayCallbackHandler.HandlePayoutCallback(body); err != nil {
-			logrus.WithError(err).Error("Failed to process payout callback")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process callback"})
-			return
-		}
-
-		c.JSON(http.StatusOK, gin.H{"status": "success"})
-	})
-
-	router.POST("/api/pawapay/callback/refunds", func(c *gin.Context) {
-		logrus.Info("Received PawaPay refund callback")
-
-		body, err := c.GetRawData()
-		if err != nil {
-			logrus.WithError(err).Error("Failed to read callback body")
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
-			return
-		}
-
-		if err := pawaPayCallbackHandler.HandleRefundCallback(body); err != nil {
-			logrus.WithError(err).Error("Failed to process refund callback")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process callback"})
-			return
-		}
-
-		c.JSON(http.StatusOK, gin.H{"status": "success"})
-	})
+	// The default PAWAPAY_CALLBACK_URL points deposits here
+	paymentRoutes.POST("/callback", pawaPaySignatureMiddleware(pawaPayCallbackVerifier, allowUnsignedCallbacks), handlePawaPayCallback(pawaPayCallbackHandler, pawapay.EventDeposit))
 
-	// Also register routes WITHOUT /api prefix (PawaPay is configured to hit these)
-	router.POST("/pawapay/callback/deposits", func(c *gin.Context) {
-		logrus.Info("Received PawaPay deposit callback (no /api prefix)")
-
-		body, err := c.GetRawData()
+	// Payments whose callback never arrived are checked with PawaPay in the background
+	reconcileAfter := 30 * time.Minute
+	if m, err := strconv.Atoi(os.Getenv("PAYMENT_RECONCILE_AFTER_MINUTES")); err == nil && m > 0 {
+		reconcileAfter = time.Duration(m) * time.Minute
+	}
+	reconciliationService := reconciliation.NewService(db, pawaPayClient, pawaPayCallbackHandler, reconcileAfter)
+	runEvery("payment-reconciliation", 15*time.Minute, func(now time.Time) {
+		run, err := reconciliationService.Run(reconciliation.TriggerSchedule)
 		if err != nil {
-			logrus.WithError(err).Error("Failed to read callback body")
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			log.Printf("❌ Payment reconciliation failed: %v", err)
 			return
 		}
-
-		logrus.WithField("payload", string(body)).Info("Deposit callback payload")
-
-		if err := pawaPayCallbackHandler.HandleDepositCallback(body); err != nil {
-			logrus.WithError(err).Error("Failed to process deposit callback")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process callback"})
-			return
-		}
-
-		c.JSON(http.StatusOK, gin.H{"status": "success"})
+		log.Printf("✅ Payment reconciliation: %d checked, %d updated, %d flagged, %d errors",
+			run.Checked, run.Updated, run.Flagged, run.Errors)
 	})
 
-	router.POST("/pawapay/callback/payouts", func(c *gin.Context) {
-		logrus.Info("Received PawaPay payout callback (no /api prefix)")
-
-		body, err := c.GetRawData()
+	// Card payers who closed DPO's page before returning are verified in the background
+	runEvery("dpo-verify", 5*time.Minute, func(now time.Time) {
+		checked, settled, err := paymentService.VerifyPending(payment.ProviderDPO, 10*time.Minute)
 		if err != nil {
-			logrus.WithError(err).Error("Failed to read callback body")
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			log.Printf("❌ DPO payment verification failed: %v", err)
 			return
 		}
-
-		if err := pawaPayCallbackHandler.HandlePayoutCallback(body); err != nil {
-			logrus.WithError(err).Error("Failed to process payout callback")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process callback"})
-			return
+		if checked > 0 {
+			log.Printf("✅ DPO payment verification: %d checked, %d settled", checked, settled)
 		}
-
-		c.JSON(http.StatusOK, gin.H{"status": "success"})
 	})
 
-	router.POST("/pawapay/callback/refunds", func(c *gin.Context) {
-		logrus.Info("Received PawaPay refund callback (no /api prefix)")
-
-		body, err := c.GetRawData()
-		if err != nil {
-			logrus.WithError(err).Error("Failed to read callback body")
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
-			return
-		}
-
-		if err := pawaPayCallbackHandler.HandleRefundCallback(body); err != nil {
-			logrus.WithError(err).Error("Failed to process refund callback")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process callback"})
-			return
-		}
-
-		c.JSON(http.StatusOK, gin.H{"status": "success"})
-	})
+	// Both prefixes are registered; PawaPay is configured to hit the routes without /api
+	for _, prefix := range []string{"/api/pawapay/callback", "/pawapay/callback"} {
+		callbackRoutes := router.Group(prefix, pawaPaySignatureMiddleware(pawaPayCallbackVerifier, allowUnsignedCallbacks))
+		callbackRoutes.POST("/deposits", handlePawaPayCallback(pawaPayCallbackHandler, pawapay.EventDeposit))
+		callbackRoutes.POST("/payouts", handlePawaPayCallback(pawaPayCallbackHandler, pawapay.EventPayout))
+		callbackRoutes.POST("/refunds", handlePawaPayCallback(pawaPayCallbackHandler, pawapay.EventRefund))
+	}
 
 	// Public routes
 	router.GET("/providers", handleGetProviders(userService))
@@ -397,7 +561,8 @@ func main() {
 	router.POST("/image", handleUploadProviderImage(providerService))
 	router.GET("/providers/:provider_id/image", handleGetProviderImage(providerService))
 
-	router.GET("/providers/:provider_id", handleGetProviderByIdCustomer(providerService))
+	router.GET("/providers/:provider_id", handleGetProviderByIdCustomer(providerService, reviewService))
+	router.GET("/providers/:provider_id/reviews", handleGetProviderReviews(reviewService))
 
 	// Admin routes - Protected by admin authentication (separate from regular users)
 	adminService := admin.NewService(db)
@@ -570,6 +735,83 @@ func main() {
 		adminRoutes.PUT("/orders/:id/cancel", handleCancelOrderAdmin(adminService))
 		adminRoutes.PUT("/orders/:id/assign-courier", handleAdminAssignCourier(orderService))
 
+		// Review moderation endpoints
+		adminRoutes.GET("/reviews", handleGetReviewsForModeration(reviewService))
+		adminRoutes.PUT("/reviews/:id/moderate", handleModerateReview(reviewService))
+
+		// Order chat transcripts for dispute review
+		adminRoutes.GET("/orders/:id/messages", handleAdminGetOrderMessages(chatService))
+		adminRoutes.GET("/orders/:id/messages/:message_id/attachment", handleAdminGetOrderMessageAttachment(chatService))
+
+		// Business accounts and credit terms
+		adminRoutes.POST("/business-accounts", handleAdminCreateBusinessAccount(businessService))
+		adminRoutes.GET("/business-accounts", handleAdminListBusinessAccounts(businessService))
+		adminRoutes.GET("/business-accounts/:id", handleAdminGetBusinessAccount(businessService))
+		adminRoutes.PUT("/business-accounts/:id/terms", handleAdminUpdateBusinessTerms(businessService))
+		adminRoutes.POST("/business-statements/generate", handleAdminGenerateBusinessStatements(businessService))
+		adminRoutes.PUT("/business-statements/:id/paid", handleAdminMarkStatementPaid(businessService))
+
+		// Cash on delivery reconciliation
+		adminRoutes.GET("/cash/outstanding", handleAdminGetOutstandingCash(cashService))
+		adminRoutes.GET("/cash/couriers/:id", handleAdminGetCourierCash(cashService))
+		adminRoutes.GET("/cash/remittances", handleAdminGetRemittances(cashService))
+		adminRoutes.PUT("/cash/remittances/:id/confirm", handleAdminResolveRemittance(cashService, true))
+		adminRoutes.PUT("/cash/remittances/:id/dispute", handleAdminResolveRemittance(cashService, false))
+
+		// Customer wallets: refunds, goodwill credits and ledger checks
+		adminRoutes.GET("/wallets/integrity", handleAdminCheckWalletIntegrity(walletService))
+		adminRoutes.GET("/wallets/:id/statement", handleAdminGetWalletStatement(walletService))
+		adminRoutes.POST("/wallets/:id/credits", handleAdminCreditWallet(walletService))
+
+		// Promo codes and automatic discount campaigns with redemption reporting
+		adminRoutes.GET("/promos", handleAdminGetPromoReport(promoService))
+		adminRoutes.POST("/promos", handleAdminCreatePromo(promoService))
+		adminRoutes.GET("/promos/:id", handleAdminGetPromo(promoService))
+		adminRoutes.PUT("/promos/:id", handleAdminUpdatePromo(promoService))
+		adminRoutes.GET("/promos/:id/redemptions", handleAdminGetPromoRedemptions(promoService))
+
+		// Referrals, including those rejected by the fraud checks
+		adminRoutes.GET("/referrals", handleAdminListReferrals(referralService))
+
+		// Refunds: support-raised requests, approval above the threshold and settlement reporting
+		adminRoutes.GET("/refunds", handleAdminListRefunds(refundService))
+		adminRoutes.GET("/refunds/report", handleAdminGetRefundReport(refundService))
+		adminRoutes.GET("/refunds/:id", handleAdminGetRefund(refundService))
+		adminRoutes.PUT("/refunds/:id/approve", handleAdminReviewRefund(refundService, true))
+		adminRoutes.PUT("/refunds/:id/reject", handleAdminReviewRefund(refundService, false))
+		adminRoutes.POST("/refunds/:id/resubmit", handleAdminResubmitRefund(refundService))
+		adminRoutes.POST("/orders/:id/refunds", handleAdminRequestRefund(refundService))
+
+		// Scheduled jobs that can also be triggered by hand
+		adminRoutes.POST("/jobs/refill-reminders/run", handleAdminRunRefillReminders(usageService))
+		adminRoutes.POST("/jobs/referral-rewards/run", handleAdminRunReferralRewards(referralService))
+		adminRoutes.POST("/jobs/loyalty-expiry/run", handleAdminRunLoyaltyExpiry(loyaltyService))
+		adminRoutes.POST("/jobs/payment-reconciliation/run", handleAdminRunPaymentReconciliation(reconciliationService))
+
+		// Pending payments checked against PawaPay and the inconsistencies found
+		adminRoutes.GET("/reconciliation/runs", handleAdminListReconciliationRuns(reconciliationService))
+		adminRoutes.GET("/reconciliation/runs/:id", handleAdminGetReconciliationRun(reconciliationService))
+		adminRoutes.GET("/reconciliation/issues", handleAdminListReconciliationIssues(reconciliationService))
+		adminRoutes.PUT("/reconciliation/issues/:id/resolve", handleAdminResolveReconciliationIssue(reconciliationService))
+		adminRoutes.POST("/reconciliation/statements", handleAdminImportStatement(reconciliationService))
+		adminRoutes.GET("/reconciliation/statements", handleAdminListStatements(reconciliationService))
+		adminRoutes.GET("/reconciliation/statements/:id", handleAdminGetStatement(reconciliationService))
+
+		// Raw PawaPay callbacks as received, and replaying them
+		adminRoutes.GET("/payment-events", handleAdminListPaymentEvents(pawaPayCallbackHandler))
+		adminRoutes.GET("/payment-events/:id", handleAdminGetPaymentEvent(pawaPayCallbackHandler))
+		adminRoutes.POST("/payment-events/:id/replay", handleAdminReplayPaymentEvent(pawaPayCallbackHandler))
+
+		// Service zones used to validate delivery pins
+		adminRoutes.GET("/service-zones", handleGetServiceZones(addressService, false))
+		adminRoutes.POST("/service-zones", handleAdminCreateServiceZone(addressService))
+		adminRoutes.PUT("/service-zones/:id", handleAdminUpdateServiceZone(addressService))
+		adminRoutes.DELETE("/service-zones/:id", handleAdminDeleteServiceZone(addressService))
+
+		// Order invoices
+		adminRoutes.GET("/orders/:id/invoice", handleAdminGetOrderInvoice(invoiceService))
+		adminRoutes.POST("/orders/:id/invoice/send", handleAdminSendOrderInvoice(invoiceService))
+
 		// Settings, reports, and audit endpoints
 		adminRoutes.GET("/settings", handleGetSettings())
 		adminRoutes.PUT("/settings", handleUpdateSettings())
@@ -591,7 +833,7 @@ func main() {
 	router.Run(":8080")
 }
 
-func handleSignUp(authService *auth.Service) gin.HandlerFunc {
+func handleSignUp(authService *auth.Service, referralService *referral.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var signUpData struct {
 			Email         string        `json:"email" binding:"required,email"`
@@ -600,6 +842,8 @@ func handleSignUp(authService *auth.Service) gin.HandlerFunc {
 			ExpoPushToken string        `json:"expoPushToken"`
 			Name          string        `json:"name"`
 			PhoneNumber   string        `json:"phone_number"`
+			ReferralCode  string        `json:"referral_code"`
+			DeviceID      string        `json:"device_id"`
 		}
 
 		if err := c.ShouldBindJSON(&signUpData); err != nil {
@@ -607,6 +851,15 @@ func handleSignUp(authService *auth.Service) gin.HandlerFunc {
 			return
 		}
 		log.Println(signUpData)
+
+		// Reject a mistyped code before creating the account, so the customer can fix it
+		if signUpData.ReferralCode != "" {
+			if _, err := referralService.Lookup(signUpData.ReferralCode); err != nil {
+				respondReferralError(c, err)
+				return
+			}
+		}
+
 		newUser, err := authService.SignUp(signUpData.Email, signUpData.Password, signUpData.UserType, signUpData.ExpoPushToken, signUpData.Name, signUpData.PhoneNumber)
 		if err != nil {
 			log.Println(err)
@@ -621,6 +874,7 @@ func handleSignUp(authService *auth.Service) gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		attributeSignUp(referralService, newUser.ID, signUpData.ReferralCode, signUpData.DeviceID)
 
 		c.JSON(http.StatusCreated, newUser)
 	}
@@ -763,16 +1017,27 @@ func handleSignOut(authService *auth.Service) gin.HandlerFunc {
 		c.JSON(200, gin.H{"message": "Successfully signed out"})
 	}
 }
-func handleGetProfile(userService *user.Service) gin.HandlerFunc {
+func handleGetProfile(userService *user.Service, loyaltyService *loyalty.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, _ := c.Get("userID")
 		userUUID := userID.(uuid.UUID)
-		user, err := userService.GetUserByID(userUUID)
+		profile, err := userService.GetUserByID(userUUID)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 			return
 		}
-		c.JSON(http.StatusOK, user)
+
+		// Customers also get their loyalty points standing; the ledger is at /customer/loyalty
+		response := struct {
+			*user.User
+			Loyalty *loyalty.Account `json:"loyalty,omitempty"`
+		}{User: profile}
+		if profile.UserType == user.UserTypeCustomer {
+			if response.Loyalty, err = loyaltyService.GetAccount(userUUID); err != nil {
+				log.Printf("ERROR: Failed to load loyalty account for user %s: %v", userUUID, err)
+			}
+		}
+		c.JSON(http.StatusOK, response)
 	}
 }
 
@@ -831,7 +1096,7 @@ func handleUpdateProfile(userService *user.Service) gin.HandlerFunc {
 	}
 }
 
-func handleCreateOrder(orderService *order.Service, inventoryService *inventory.Service, db *sql.DB, hub *realtime.Hub) gin.HandlerFunc {
+func handleCreateOrder(orderService *order.Service, inventoryService *inventory.Service, businessService *business.Service, addressService *address.Service, promoService *promo.Service, loyaltyService *loyalty.Service, db *sql.DB, hub *realtime.Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		log.Printf("Starting order creation process - Method: %s", c.Request.Method)
 
@@ -863,47 +1128,78 @@ func handleCreateOrder(orderService *order.Service, inventoryService *inventory.
 
 		newOrder.UserID = userID.(uuid.UUID)
 
-		// Get cylinder price
-		// TODO: Update inventory service to use UUID instead of ObjectID
-		price := 100.0
+		paymentMethod, err := order.ParsePaymentMethod(string(newOrder.PaymentMethod))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		newOrder.PaymentMethod = paymentMethod
 
-		// Check if price is zero
-		if price <= 0 {
-			providerIDStr := "unknown"
-			if newOrder.ProviderID != nil {
-				providerIDStr = newOrder.ProviderID.String()
+		if newOrder.AddressID != nil {
+			if err := applySavedAddress(addressService, &newOrder); err != nil {
+				respondAddressError(c, err)
+				return
+			}
+		}
+		if newOrder.StructuredAddress != nil {
+			if err := validateStructuredAddress(addressService, newOrder.StructuredAddress, &newOrder.DeliveryAddress, &newOrder.DeliveryNotes); err != nil {
+				respondAddressError(c, err)
+				return
 			}
-			log.Printf("WARNING: Cylinder price is zero for ProviderID: %s, CylinderType: %s", providerIDStr, newOrder.CylinderType)
-			// Handle this case appropriately, maybe set a default price or return an error
 		}
 
-		// Calculate all prices
-		newOrder.PricePerUnit = price
-		newOrder.TotalPrice = price * float64(newOrder.Quantity)
-		newOrder.DeliveryFee = 10.0                         // Fixed delivery fee
-		newOrder.ServiceCharge = newOrder.TotalPrice * 0.05 // 5% service charge
-		newOrder.GrandTotal = newOrder.TotalPrice + newOrder.DeliveryFee + newOrder.ServiceCharge
+		// Calculate all prices at the provider's listed price
+		newOrder.ApplyPricing(orderService.UnitPrice(newOrder.ProviderID, newOrder.CylinderType))
+
+		promotion, err := applyPromotion(promoService, &newOrder)
+		if err != nil {
+			respondPromoError(c, err)
+			return
+		}
+		redemption, err := applyLoyaltyPoints(loyaltyService, &newOrder, promotion)
+		if err != nil {
+			respondLoyaltyError(c, err)
+			return
+		}
 
 		// Set timestamps
 		now := time.Now()
 		newOrder.CreatedAt = now
 		newOrder.UpdatedAt = now
 
-		// Set default status if not provided
-		if newOrder.Status == "" {
-			newOrder.Status = "pending"
-		}
-		if newOrder.PaymentStatus == "" {
-			newOrder.PaymentStatus = "pending"
-		}
+		// New orders always start pending and unassigned; payment, the provider and dispatch
+		// move them on, never the client, whose body could otherwise claim an order was paid
+		newOrder.Status = order.OrderStatusPending
+		newOrder.PaymentStatus = order.PaymentStatusPending
+		newOrder.CourierID, newOrder.CourierStatus = nil, ""
 
 		// Log calculated prices
-		log.Printf("Calculated prices - Price per Unit: %.2f, Total Price: %.2f, Delivery Fee: %.2f, Service Charge: %.2f, Grand Total: %.2f", newOrder.PricePerUnit, newOrder.TotalPrice, newOrder.DeliveryFee, newOrder.ServiceCharge, newOrder.GrandTotal)
+		log.Printf("Calculated prices - Price per Unit: %s, Total Price: %s, Delivery Fee: %s, Service Charge: %s, Grand Total: %s", newOrder.PricePerUnit, newOrder.TotalPrice, newOrder.DeliveryFee, newOrder.ServiceCharge, newOrder.GrandTotal)
+
+		// Spend the points before saving the order so a customer can't use them twice
+		if redemption != nil {
+			newOrder.ID = uuid.New()
+			if _, err := loyaltyService.Redeem(newOrder.UserID, newOrder.ID, redemption); err != nil {
+				respondLoyaltyError(c, err)
+				return
+			}
+		}
 
 		// Create the order
-		createdOrder, err := orderService.CreateOrder(&newOrder)
+		createdOrder, err := saveOrder(orderService, businessService, promoService, promotion, &newOrder)
 		if err != nil {
+			if redemption != nil {
+				restoreLoyaltyPoints(loyaltyService, newOrder.ID)
+			}
 			log.Printf("ERROR: Failed to create order: %v", err)
+			if promo.IsRuleError(err) || errors.Is(err, promo.ErrAlreadyRedeemed) {
+				respondPromoError(c, err)
+				return
+			}
+			if newOrder.PaymentMethod == order.PaymentMethodOnAccount {
+				respondBusinessError(c, err)
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to create order: %v", err)})
 			return
 		}
@@ -930,19 +1226,24 @@ func handleCreateOrder(orderService *order.Service, inventoryService *inventory.
 func handleGoogleLogin() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		config := auth.GetGoogleOAuthConfig()
-		// Store user_type in state if provided
-		userType := c.Query("user_type")
-		state := "state"
-		if userType != "" {
-			state = "user_type=" + userType
+		// Carry user_type and the referral attribution through the OAuth round trip in state
+		values := url.Values{}
+		for _, key := range []string{"user_type", "ref", "device_id"} {
+			if v := c.Query(key); v != "" {
+				values.Set(key, v)
+			}
 		}
-		url := config.AuthCodeURL(state, oauth2.AccessTypeOffline)
-		c.Redirect(http.StatusTemporaryRedirect, url)
+		state := values.Encode()
+		if state == "" {
+			state = "state"
+		}
+		authURL := config.AuthCodeURL(state, oauth2.AccessTypeOffline)
+		c.Redirect(http.StatusTemporaryRedirect, authURL)
 	}
 }
 
 // handleGoogleCallback handles Google OAuth callback
-func handleGoogleCallback(authService *auth.Service, userService *user.Service) gin.HandlerFunc {
+func handleGoogleCallback(authService *auth.Service, userService *user.Service, referralService *referral.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		code := c.Query("code")
 		if code == "" {
@@ -950,18 +1251,15 @@ func handleGoogleCallback(authService *auth.Service, userService *user.Service)
 			return
 		}
 
-		// Get user_type from state parameter
-		state := c.Query("state")
+		// Get user_type and referral attribution from state parameter
+		// (format: "user_type=courier&ref=MARY7KQ2&device_id=...")
+		state, _ := url.ParseQuery(c.Query("state"))
 		userType := user.UserTypeCustomer // default
-		if state != "" && state != "state" {
-			// Parse user_type from state (format: "user_type=courier")
-			if len(state) > 10 && state[:10] == "user_type=" {
-				if state[10:] == "courier" {
-					userType = user.UserTypeCourier
-				} else if state[10:] == "provider" {
-					userType = user.UserTypeProvider
-				}
-			}
+		switch state.Get("user_type") {
+		case "courier":
+			userType = user.UserTypeCourier
+		case "provider":
+			userType = user.UserTypeProvider
 		}
 
 		// Exchange code for token
@@ -1010,6 +1308,7 @@ func handleGoogleCallback(authService *auth.Service, userService *user.Service)
 				return
 			}
 			appUser = createdUser
+			attributeSignUp(referralService, appUser.ID, state.Get("ref"), state.Get("device_id"))
 		}
 
 		// Generate JWT token
@@ -1047,7 +1346,7 @@ func handleGoogleCallback(authService *auth.Service, userService *user.Service)
 	}
 }
 
-func handleUpdateOrderStatus(orderService *order.Service) gin.HandlerFunc {
+func handleUpdateOrderStatus(orderService *order.Service, referralService *referral.Service, loyaltyService *loyalty.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		orderID, err := uuid.Parse(c.Param("id"))
 		if err != nil {
@@ -1076,6 +1375,15 @@ func handleUpdateOrderStatus(orderService *order.Service) gin.HandlerFunc {
 			return
 		}
 
+		// Delivery earns loyalty points, and a customer's first delivered and paid order
+		// completes their referral
+		if order.OrderStatus(body.Status) == order.OrderStatusDelivered {
+			earnLoyaltyPoints(loyaltyService, orderID)
+			if delivered, err := orderService.GetOrderByID(orderID); err == nil {
+				rewardReferral(referralService, delivered.UserID)
+			}
+		}
+
 		c.JSON(http.StatusOK, gin.H{"success": true})
 	}
 }
@@ -1084,26 +1392,37 @@ func handleGetUserOrders(orderService *order.Service) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		log.Println("Getting orders.....")
-		userID, _ := c.Get("userID")
-		orders, err := orderService.GetUserOrders(userID.(uuid.UUID))
+		filter, err := parseOrderListFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
+		userID, _ := c.Get("userID")
+		page, err := orderService.GetUserOrders(userID.(uuid.UUID), filter)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+			respondOrderListError(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, orders)
+		writeOrderPage(c, page)
 	}
 }
 
 func handleGetProviderOrders(orderService *order.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		filter, err := parseOrderListFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		providerID, _ := c.Get("userID")
-		orders, err := orderService.GetProviderOrders(providerID.(uuid.UUID))
+		page, err := orderService.GetProviderOrders(providerID.(uuid.UUID), filter)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+			respondOrderListError(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, orders)
+		writeOrderPage(c, page)
 	}
 }
 func handleAcceptOrder(orderService *order.Service) gin.HandlerFunc {
@@ -1138,7 +1457,10 @@ func handleAcceptOrder(orderService *order.Service) gin.HandlerFunc {
 		c.JSON(http.StatusOK, gin.H{"message": "Order accepted successfully"})
 	}
 }
-func handleRejectOrder(orderService *order.Service) gin.HandlerFunc {
+
+// handleRejectOrder rejects an order and returns anything the customer already paid
+// for it by mobile money or wallet to their wallet
+func handleRejectOrder(orderService *order.Service, walletService *wallet.Service, loyaltyService *loyalty.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		orderID, err := uuid.Parse(c.Param("id"))
 		if err != nil {
@@ -1150,21 +1472,115 @@ func handleRejectOrder(orderService *order.Service) gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject order"})
 			return
 		}
+		if _, err := walletService.RefundRejectedOrder(orderID); err != nil {
+			log.Printf("ERROR: Failed to refund rejected order %s to wallet: %v", orderID, err)
+		}
+		restoreLoyaltyPoints(loyaltyService, orderID)
 		c.JSON(http.StatusOK, gin.H{"message": "Order rejected successfully"})
 	}
 }
 
 func handleGetCourierOrders(orderService *order.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		filter, err := parseOrderListFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		courierID, _ := c.Get("userID")
-		orders, err := orderService.GetCourierOrders(courierID.(uuid.UUID))
+		page, err := orderService.GetCourierOrders(courierID.(uuid.UUID), filter)
 		if err != nil {
 			log.Printf("Error fetching courier orders: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+			respondOrderListError(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, orders)
+		writeOrderPage(c, page)
+	}
+}
+
+// parseOrderListFilter reads the pagination, filter and sort query parameters
+// shared by the customer, provider and courier order listings
+func parseOrderListFilter(c *gin.Context) (order.ListFilter, error) {
+	var filter order.ListFilter
+
+	if raw := c.Query("status"); raw != "" {
+		for _, s := range strings.Split(raw, ",") {
+			status, err := order.StringToOrderStatus(strings.TrimSpace(s))
+			if err != nil {
+				return filter, err
+			}
+			filter.Status = append(filter.Status, status)
+		}
+	}
+
+	filter.PaymentStatus = order.PaymentStatus(c.Query("payment_status"))
+	filter.CylinderType = order.CylinderType(c.Query("cylinder_type"))
+	filter.Search = c.Query("q")
+	filter.SortBy = order.SortField(c.Query("sort"))
+	filter.Cursor = c.Query("cursor")
+
+	switch strings.ToLower(c.DefaultQuery("order", "desc")) {
+	case "asc":
+		filter.Ascending = true
+	case "desc":
+	default:
+		return filter, fmt.Errorf("invalid sort order: %s", c.Query("order"))
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return filter, fmt.Errorf("invalid limit: %s", raw)
+		}
+		filter.Limit = limit
+	}
+
+	// Dates accept RFC3339 or YYYY-MM-DD; a bare "to" date includes that whole day
+	if raw := c.Query("from"); raw != "" {
+		from, _, err := parseListDate(raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from date: %s", raw)
+		}
+		filter.From = &from
+	}
+	if raw := c.Query("to"); raw != "" {
+		to, dateOnly, err := parseListDate(raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to date: %s", raw)
+		}
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		filter.To = &to
+	}
+
+	return filter, nil
+}
+
+func parseListDate(raw string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse("2006-01-02", raw)
+	return t, true, err
+}
+
+// writeOrderPage keeps the plain array body existing clients expect and
+// passes the next page's cursor in the X-Next-Cursor header
+func writeOrderPage(c *gin.Context, page *order.OrderPage) {
+	if page.NextCursor != "" {
+		c.Header("X-Next-Cursor", page.NextCursor)
+	}
+	c.JSON(http.StatusOK, page.Orders)
+}
+
+func respondOrderListError(c *gin.Context, err error) {
+	if errors.Is(err, order.ErrInvalidCursor) || errors.Is(err, order.ErrInvalidFilter) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
 }
 
 // Add a handler for updating payment status
@@ -1479,24 +1895,31 @@ func handleUpdateUserLocation(userService *user.Service) gin.HandlerFunc {
 
 // OrderResponse combines Order and User data
 type OrderResponse struct {
-	ID              uuid.UUID  `json:"id,omitempty"`
-	UserID          uuid.UUID  `json:"user_id"`
-	ProviderID      *uuid.UUID `json:"provider_id,omitempty"`
-	CourierID       *uuid.UUID `json:"courier_id,omitempty"`
-	Status          string     `json:"status"`
-	CylinderType    string     `json:"cylinder_type"`
-	Quantity        int        `json:"quantity"`
-	PricePerUnit    float64    `json:"price_per_unit"`
-	TotalPrice      float64    `json:"total_price"`
-	DeliveryFee     float64    `json:"delivery_fee"`
-	ServiceCharge   float64    `json:"service_charge"`
-	GrandTotal      float64    `json:"grand_total"`
-	DeliveryAddress string     `json:"delivery_address"`
-	PaymentMethod   string     `json:"payment_method"`
-	PaymentStatus   string     `json:"payment_status"`
-	CreatedAt       time.Time  `json:"created_at"`
-	UpdatedAt       time.Time  `json:"updated_at"`
-	User            struct {
+	ID              uuid.UUID    `json:"id,omitempty"`
+	UserID          uuid.UUID    `json:"user_id"`
+	ProviderID      *uuid.UUID   `json:"provider_id,omitempty"`
+	CourierID       *uuid.UUID   `json:"courier_id,omitempty"`
+	Status          string       `json:"status"`
+	CylinderType    string       `json:"cylinder_type"`
+	Quantity        int          `json:"quantity"`
+	PricePerUnit    money.Amount `json:"price_per_unit"`
+	TotalPrice      money.Amount `json:"total_price"`
+	DeliveryFee     money.Amount `json:"delivery_fee"`
+	ServiceCharge   money.Amount `json:"service_charge"`
+	Discount        money.Amount `json:"discount"`
+	PromoCode       string       `json:"promo_code,omitempty"`
+	PointsRedeemed  int          `json:"points_redeemed,omitempty"`
+	LoyaltyDiscount money.Amount `json:"loyalty_discount"`
+	GrandTotal      money.Amount `json:"grand_total"`
+	DeliveryAddress string       `json:"delivery_address"`
+	DeliveryNotes   string       `json:"delivery_notes,omitempty"`
+	// StructuredAddress gives couriers the area, landmark and pin behind the address
+	StructuredAddress *address.Address `json:"structured_address,omitempty"`
+	PaymentMethod     string           `json:"payment_method"`
+	PaymentStatus     string           `json:"payment_status"`
+	CreatedAt         time.Time        `json:"created_at"`
+	UpdatedAt         time.Time        `json:"updated_at"`
+	User              struct {
 		ID    uuid.UUID `json:"id"`
 		Name  string    `json:"name"`
 		Email string    `json:"email"`
@@ -1535,23 +1958,29 @@ func handleGetSingleOrder(orderService *order.Service, userService *user.Service
 
 		// Create the response
 		response := OrderResponse{
-			ID:              order.ID,
-			UserID:          order.UserID,
-			ProviderID:      order.ProviderID,
-			CourierID:       order.CourierID,
-			Status:          string(order.Status),
-			CylinderType:    string(order.CylinderType),
-			Quantity:        order.Quantity,
-			PricePerUnit:    order.PricePerUnit,
-			TotalPrice:      order.TotalPrice,
-			DeliveryFee:     order.DeliveryFee,
-			ServiceCharge:   order.ServiceCharge,
-			GrandTotal:      order.GrandTotal,
-			DeliveryAddress: order.DeliveryAddress,
-			PaymentMethod:   order.PaymentMethod,
-			PaymentStatus:   string(order.PaymentStatus),
-			CreatedAt:       order.CreatedAt,
-			UpdatedAt:       order.UpdatedAt,
+			ID:                order.ID,
+			UserID:            order.UserID,
+			ProviderID:        order.ProviderID,
+			CourierID:         order.CourierID,
+			Status:            string(order.Status),
+			CylinderType:      string(order.CylinderType),
+			Quantity:          order.Quantity,
+			PricePerUnit:      order.PricePerUnit,
+			TotalPrice:        order.TotalPrice,
+			DeliveryFee:       order.DeliveryFee,
+			ServiceCharge:     order.ServiceCharge,
+			Discount:          order.Discount,
+			PromoCode:         order.PromoCode,
+			PointsRedeemed:    order.PointsRedeemed,
+			LoyaltyDiscount:   order.LoyaltyDiscount,
+			GrandTotal:        order.GrandTotal,
+			DeliveryAddress:   order.DeliveryAddress,
+			DeliveryNotes:     order.DeliveryNotes,
+			StructuredAddress: order.StructuredAddress,
+			PaymentMethod:     string(order.PaymentMethod),
+			PaymentStatus:     string(order.PaymentStatus),
+			CreatedAt:         order.CreatedAt,
+			UpdatedAt:         order.UpdatedAt,
 		}
 
 		// Add user data to the response
@@ -1807,7 +2236,7 @@ func handleGetProviderImage(providerService *provider.Service) gin.HandlerFunc {
 	}
 }
 
-func handleGetProviderByIdCustomer(providerService *provider.Service) gin.HandlerFunc {
+func handleGetProviderByIdCustomer(providerService *provider.Service, reviewService *review.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		providerID := c.Param("provider_id")
 
@@ -1821,6 +2250,13 @@ func handleGetProviderByIdCustomer(providerService *provider.Service) gin.Handle
 			return
 		}
 
+		// Latest reviews, with comments hidden until approved; the full list is paged via /providers/:provider_id/reviews
+		reviews, err := reviewService.GetPublicReviews(provider.ID, 10, 0)
+		if err != nil {
+			log.Printf("Warning: Failed to fetch reviews for provider %s: %v", providerID, err)
+		}
+		provider.Reviews = reviews
+
 		c.JSON(http.StatusOK, provider)
 	}
 }
@@ -1919,7 +2355,7 @@ func handleUpdateCylinderPreference(preferencesService *preferences.Service) gin
 // 	}
 // }
 
-func handleGetNearestProvider(userService *user.Service, preferencesService *preferences.Service) gin.HandlerFunc {
+func handleGetNearestProvider(userService *user.Service, preferencesService *preferences.Service, addressService *address.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -1927,15 +2363,44 @@ func handleGetNearestProvider(userService *user.Service, preferencesService *pre
 			return
 		}
 
-		// Get the customer's current location
-		customer, err := userService.GetUserByID(userID.(uuid.UUID))
+		var req struct {
+			AddressID *uuid.UUID `json:"address_id"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		// Match from the selected saved address, else the default one, and only fall back
+		// to the last location the app reported when the address book is empty
+		var saved *address.SavedAddress
+		var err error
+		if req.AddressID != nil {
+			saved, err = addressService.GetSaved(userID.(uuid.UUID), *req.AddressID)
+		} else {
+			saved, err = addressService.GetDefault(userID.(uuid.UUID))
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get customer information"})
+			respondAddressError(c, err)
 			return
 		}
 
+		var latitude, longitude *float64
+		if saved != nil {
+			latitude, longitude = &saved.Address.Latitude, &saved.Address.Longitude
+		} else {
+			customer, err := userService.GetUserByID(userID.(uuid.UUID))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get customer information"})
+				return
+			}
+			latitude, longitude = customer.Latitude, customer.Longitude
+		}
+
 		// Find best provider using existing logic
-		bestProvider, err := findBestProvider(userService, customer.Latitude, customer.Longitude)
+		bestProvider, err := findBestProvider(userService, latitude, longitude)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to find nearest provider: %v", err)})
 			return
@@ -1946,10 +2411,14 @@ func handleGetNearestProvider(userService *user.Service, preferencesService *pre
 		//	_ = preferencesService.UpdateProviderPreference(userID.(uuid.UUID), bestProvider.User.ID)
 		// }
 
-		c.JSON(http.StatusOK, gin.H{
+		response := gin.H{
 			"provider": bestProvider,
 			"saved":    false, // Always false since provider preferences are no longer stored
-		})
+		}
+		if saved != nil {
+			response["address_id"] = saved.ID
+		}
+		c.JSON(http.StatusOK, response)
 	}
 }
 
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/address"
+	"github.com/yakumwamba/lpg-delivery-system/internal/business"
+	"github.com/yakumwamba/lpg-delivery-system/internal/order"
+	"github.com/yakumwamba/lpg-delivery-system/internal/payment"
+	"github.com/yakumwamba/lpg-delivery-system/internal/user"
+	"github.com/yakumwamba/lpg-delivery-system/internal/wallet"
+	"github.com/yakumwamba/lpg-delivery-system/pkg/realtime"
+)
+
+// handleModifyOrder lets a customer correct an order before pickup. A paid mobile money
+// order whose total rises is topped up from the customer's phone; one whose total falls
+// is refunded to their wallet.
+func handleModifyOrder(orderService *order.Service, businessService *business.Service, addressService *address.Service, paymentService *payment.Service, walletService *wallet.Service, userService *user.Service, hub *realtime.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uuid.UUID)
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		var req order.ModifyOrderRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if req.StructuredAddress != nil {
+			if _, err := addressService.Validate(req.StructuredAddress); err != nil {
+				respondAddressError(c, err)
+				return
+			}
+		}
+
+		updated, edit, err := orderService.PlanModification(userID, orderID, req)
+		if err != nil {
+			respondOrderEditError(c, err)
+			return
+		}
+
+		// On-account orders draw on the account's credit, so any increase must fit in it
+		if updated.PaymentMethod == order.PaymentMethodOnAccount && edit.NewTotal > edit.PreviousTotal {
+			var saveErr error
+			err = businessService.AuthorizeOnAccountOrder(userID, updated.DeliverySiteID, (edit.NewTotal - edit.PreviousTotal).Kwacha(),
+				func(tx *sql.Tx, _ *business.Account, _ *business.Site) error {
+					saveErr = orderService.SaveModificationTx(tx, updated, edit)
+					return saveErr
+				})
+			if err != nil && saveErr == nil {
+				respondBusinessError(c, err)
+				return
+			}
+		} else {
+			err = orderService.SaveModification(updated, edit)
+		}
+		if err != nil {
+			respondOrderEditError(c, err)
+			return
+		}
+
+		switch edit.AdjustmentType {
+		case order.AdjustmentTopUp:
+			status, ref := order.AdjustmentStatusFailed, ""
+			if customer, err := userService.GetUserByID(userID); err != nil {
+				log.Printf("ERROR: Failed to load customer %s for top-up: %v", userID, err)
+			} else if deposit, err := paymentService.InitiateTopUp(orderID, edit.AdjustmentAmount, customer.PhoneNumber); err != nil {
+				log.Printf("ERROR: Top-up for order %s failed: %v", orderID, err)
+			} else {
+				status, ref = order.AdjustmentStatusInitiated, deposit.TransactionRef
+				switch deposit.Status {
+				case payment.PaymentStatusCompleted:
+					status = order.AdjustmentStatusCompleted
+				case payment.PaymentStatusFailed:
+					status = order.AdjustmentStatusFailed
+				}
+			}
+			if err := orderService.UpdateEditAdjustment(edit, status, ref); err != nil {
+				log.Printf("ERROR: %v", err)
+			}
+		case order.AdjustmentRefund:
+			status, ref := order.AdjustmentStatusFailed, ""
+			reason := fmt.Sprintf("Order %s reduced by customer", orderID)
+			tx, err := walletService.RefundOrder(orderID, edit.AdjustmentAmount.Kwacha(), "order-edit:"+edit.ID.String(), reason)
+			if err != nil {
+				log.Printf("ERROR: Wallet refund for order %s failed: %v", orderID, err)
+			} else {
+				status, ref = order.AdjustmentStatusCompleted, tx.ID.String()
+			}
+			if err := orderService.UpdateEditAdjustment(edit, status, ref); err != nil {
+				log.Printf("ERROR: %v", err)
+			}
+		}
+
+		hub.BroadcastOrderUpdated(updated.ID.String(), updated.UserID.String(), gin.H{
+			"order_id":         updated.ID,
+			"status":           updated.Status,
+			"cylinder_type":    updated.CylinderType,
+			"quantity":         updated.Quantity,
+			"delivery_address": updated.DeliveryAddress,
+			"grand_total":      updated.GrandTotal,
+			"edited":           true,
+		})
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Order updated successfully",
+			"order":   updated,
+			"edit":    edit,
+		})
+	}
+}
+
+// handleGetOrderEdits returns the edit history of one of the customer's orders
+func handleGetOrderEdits(orderService *order.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uuid.UUID)
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		o, err := orderService.GetOrderByID(orderID)
+		if err != nil || o.UserID != userID {
+			c.JSON(http.StatusNotFound, gin.H{"error": order.ErrOrderNotFound.Error()})
+			return
+		}
+
+		edits, err := orderService.GetOrderEdits(orderID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order edits"})
+			return
+		}
+
+		c.JSON(http.StatusOK, edits)
+	}
+}
+
+func respondOrderEditError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, order.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, order.ErrOrderNotEditable), errors.Is(err, order.ErrOnlyAddressEditable),
+		errors.Is(err, order.ErrCylinderUnavailable), errors.Is(err, order.ErrDiscountedItems):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, order.ErrNoChanges), errors.Is(err, order.ErrEmptyAddress):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("ERROR: Failed to modify order: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order"})
+	}
+}
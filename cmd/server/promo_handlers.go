@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/loyalty"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+	"github.com/yakumwamba/lpg-delivery-system/internal/order"
+	"github.com/yakumwamba/lpg-delivery-system/internal/promo"
+)
+
+// applyPromotion prices the order's promo code, or the best automatic campaign when it
+// has none, into its breakdown. Call it after ApplyPricing.
+func applyPromotion(promoService *promo.Service, o *order.Order) (*promo.Applied, error) {
+	applied, err := promoService.Apply(o.PromoCode, promo.OrderContext{
+		UserID:       o.UserID,
+		ProviderID:   o.ProviderID,
+		CylinderType: string(o.CylinderType),
+		Subtotal:     o.TotalPrice.Kwacha(),
+		DeliveryFee:  o.DeliveryFee.Kwacha(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	o.PromoCode, o.CampaignID = "", nil
+	o.ApplyDiscount(0)
+	if applied != nil {
+		o.PromoCode, o.CampaignID = applied.Code, &applied.CampaignID
+		o.ApplyDiscount(money.Kwacha(applied.Discount))
+	}
+	return applied, nil
+}
+
+// handleGetLivePromotions lists the automatic campaigns running now, for banners in the app
+func handleGetLivePromotions(promoService *promo.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		campaigns, err := promoService.ListCampaigns(true)
+		if err != nil {
+			respondPromoError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, campaigns)
+	}
+}
+
+// handleQuotePromo shows the price breakdown an order would get with a promo code, or
+// with the automatic campaigns when no code is given, and with any loyalty points redeemed
+func handleQuotePromo(orderService *order.Service, promoService *promo.Service, loyaltyService *loyalty.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Code         string             `json:"code"`
+			ProviderID   *uuid.UUID         `json:"provider_id"`
+			CylinderType order.CylinderType `json:"cylinder_type" binding:"required"`
+			Quantity     int                `json:"quantity" binding:"required,gt=0"`
+			Points       int                `json:"points_redeemed"`
+			PointsTarget string             `json:"points_target"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		quote := &order.Order{
+			UserID:         c.MustGet("userID").(uuid.UUID),
+			ProviderID:     req.ProviderID,
+			CylinderType:   req.CylinderType,
+			Quantity:       req.Quantity,
+			PromoCode:      req.Code,
+			PointsRedeemed: req.Points,
+			PointsTarget:   req.PointsTarget,
+		}
+		quote.ApplyPricing(orderService.UnitPrice(quote.ProviderID, quote.CylinderType))
+
+		applied, err := applyPromotion(promoService, quote)
+		if err != nil {
+			respondPromoError(c, err)
+			return
+		}
+		redemption, err := applyLoyaltyPoints(loyaltyService, quote, applied)
+		if err != nil {
+			respondLoyaltyError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"price_per_unit":   quote.PricePerUnit,
+			"total_price":      quote.TotalPrice,
+			"delivery_fee":     quote.DeliveryFee,
+			"service_charge":   quote.ServiceCharge,
+			"discount":         quote.Discount,
+			"loyalty_discount": quote.LoyaltyDiscount,
+			"grand_total":      quote.GrandTotal,
+			"promotion":        applied,
+			"points":           redemption,
+		})
+	}
+}
+
+func handleAdminGetPromoReport(promoService *promo.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report, err := promoService.Report()
+		if err != nil {
+			respondPromoError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+func handleAdminCreatePromo(promoService *promo.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req promo.CampaignRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		campaign, err := req.Campaign()
+		if err != nil {
+			respondPromoError(c, err)
+			return
+		}
+		if err := promoService.CreateCampaign(campaign); err != nil {
+			respondPromoError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, campaign)
+	}
+}
+
+func handleAdminGetPromo(promoService *promo.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid campaign ID"})
+			return
+		}
+
+		campaign, err := promoService.GetCampaign(id)
+		if err != nil {
+			respondPromoError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, campaign)
+	}
+}
+
+func handleAdminUpdatePromo(promoService *promo.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid campaign ID"})
+			return
+		}
+
+		var req promo.CampaignRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		campaign, err := req.Campaign()
+		if err != nil {
+			respondPromoError(c, err)
+			return
+		}
+		campaign.ID = id
+		if err := promoService.UpdateCampaign(campaign); err != nil {
+			respondPromoError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, campaign)
+	}
+}
+
+func handleAdminGetPromoRedemptions(promoService *promo.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid campaign ID"})
+			return
+		}
+		limit, offset := parseLimitOffset(c, 50, 200)
+
+		redemptions, err := promoService.ListRedemptions(id, limit, offset)
+		if err != nil {
+			respondPromoError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, redemptions)
+	}
+}
+
+func respondPromoError(c *gin.Context, err error) {
+	switch {
+	case promo.IsRuleError(err):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, promo.ErrCampaignNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, promo.ErrDuplicateCode), errors.Is(err, promo.ErrAlreadyRedeemed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, promo.ErrInvalidCampaign):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("ERROR: Promotion request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Promotion request failed"})
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/reconciliation"
+)
+
+// Admin Payment Reconciliation Handlers
+
+func handleAdminRunPaymentReconciliation(reconciliationService *reconciliation.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		run, err := reconciliationService.Run(reconciliation.TriggerAdmin)
+		if err != nil {
+			respondReconciliationError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, run)
+	}
+}
+
+func handleAdminListReconciliationRuns(reconciliationService *reconciliation.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, offset := parseLimitOffset(c, 50, 200)
+		runs, err := reconciliationService.ListRuns(limit, offset)
+		if err != nil {
+			respondReconciliationError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, runs)
+	}
+}
+
+func handleAdminGetReconciliationRun(reconciliationService *reconciliation.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+			return
+		}
+
+		run, err := reconciliationService.GetRun(id)
+		if err != nil {
+			respondReconciliationError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, run)
+	}
+}
+
+func handleAdminListReconciliationIssues(reconciliationService *reconciliation.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, offset := parseLimitOffset(c, 50, 200)
+		status := reconciliation.IssueStatus(c.DefaultQuery("status", string(reconciliation.IssueOpen)))
+		issues, err := reconciliationService.ListIssues(status, limit, offset)
+		if err != nil {
+			respondReconciliationError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, issues)
+	}
+}
+
+func handleAdminResolveReconciliationIssue(reconciliationService *reconciliation.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid issue ID"})
+			return
+		}
+
+		var req reconciliation.ResolveRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		adminID := c.MustGet("admin_id").(uuid.UUID)
+		if err := reconciliationService.ResolveIssue(id, adminID, req.Note); err != nil {
+			respondReconciliationError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Issue resolved"})
+	}
+}
+
+// handleAdminImportStatement takes a PawaPay or DPO settlement statement CSV uploaded as
+// "file" and reconciles it. The period it covers defaults to the dates on its lines and
+// can be set with "from" and "to".
+func handleAdminImportStatement(reconciliationService *reconciliation.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No statement file uploaded"})
+			return
+		}
+
+		var from, to *time.Time
+		if raw := c.PostForm("from"); raw != "" {
+			t, _, err := parseListDate(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date: " + raw})
+				return
+			}
+			from = &t
+		}
+		if raw := c.PostForm("to"); raw != "" {
+			t, _, err := parseListDate(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date: " + raw})
+				return
+			}
+			to = &t
+		}
+
+		src, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not read file"})
+			return
+		}
+		defer src.Close()
+
+		adminID := c.MustGet("admin_id").(uuid.UUID)
+		statement, err := reconciliationService.ImportStatement(c.PostForm("provider"), file.Filename, src, from, to, adminID)
+		if err != nil {
+			respondReconciliationError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, statement)
+	}
+}
+
+func handleAdminListStatements(reconciliationService *reconciliation.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, offset := parseLimitOffset(c, 50, 200)
+		statements, err := reconciliationService.ListStatements(limit, offset)
+		if err != nil {
+			respondReconciliationError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, statements)
+	}
+}
+
+// handleAdminGetStatement returns a statement's reconciliation report, as a CSV download
+// with ?format=csv
+func handleAdminGetStatement(reconciliationService *reconciliation.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid statement ID"})
+			return
+		}
+
+		statement, err := reconciliationService.GetStatement(id)
+		if err != nil {
+			respondReconciliationError(c, err)
+			return
+		}
+
+		if c.Query("format") != "csv" {
+			c.JSON(http.StatusOK, statement)
+			return
+		}
+		filename := fmt.Sprintf("reconciliation-%s-%s.csv", statement.Provider, statement.CreatedAt.Format("2006-01-02"))
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		if err := reconciliation.WriteStatementCSV(c.Writer, statement); err != nil {
+			log.Printf("ERROR: Failed to write statement report %s: %v", id, err)
+		}
+	}
+}
+
+func respondReconciliationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, reconciliation.ErrRunNotFound), errors.Is(err, reconciliation.ErrIssueNotFound),
+		errors.Is(err, reconciliation.ErrStatementNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, reconciliation.ErrInvalidStatement), errors.Is(err, reconciliation.ErrUnsupportedProvider):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("ERROR: Reconciliation request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Reconciliation request failed"})
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/referral"
+)
+
+// attributeSignUp links a newly created account to its referrer and remembers the
+// device it signed up from. Sign-up has already succeeded, so failures are only logged.
+func attributeSignUp(referralService *referral.Service, userID uuid.UUID, code, deviceID string) {
+	if err := referralService.RecordDevice(userID, deviceID); err != nil {
+		log.Printf("ERROR: Failed to record device for user %s: %v", userID, err)
+	}
+	if code == "" {
+		return
+	}
+	if _, err := referralService.Attribute(userID, code); err != nil {
+		log.Printf("ERROR: Failed to attribute referral for user %s: %v", userID, err)
+	}
+}
+
+// rewardReferral pays out the customer's referral if this delivery qualifies it
+func rewardReferral(referralService *referral.Service, customerID uuid.UUID) {
+	result, err := referralService.RewardFor(customerID)
+	if err != nil {
+		log.Printf("ERROR: Failed to check referral reward for user %s: %v", customerID, err)
+		return
+	}
+	if result.Rewarded > 0 {
+		log.Printf("🎁 Referral rewarded for user %s", customerID)
+	}
+}
+
+// handleGetReferralDashboard returns the customer's share code and their referrals
+func handleGetReferralDashboard(referralService *referral.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uuid.UUID)
+		dashboard, err := referralService.GetDashboard(userID)
+		if err != nil {
+			respondReferralError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, dashboard)
+	}
+}
+
+func handleAdminListReferrals(referralService *referral.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, offset := parseLimitOffset(c, 50, 200)
+		referrals, err := referralService.ListReferrals(referral.Status(c.Query("status")), limit, offset)
+		if err != nil {
+			respondReferralError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, referrals)
+	}
+}
+
+func handleAdminRunReferralRewards(referralService *referral.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := referralService.RewardQualified()
+		if err != nil {
+			respondReferralError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func respondReferralError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, referral.ErrInvalidCode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, referral.ErrSelfReferral), errors.Is(err, referral.ErrAlreadyReferred):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("ERROR: Referral request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Referral request failed"})
+	}
+}
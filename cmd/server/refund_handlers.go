@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/order"
+	"github.com/yakumwamba/lpg-delivery-system/internal/refund"
+)
+
+// Refund Handlers
+
+// handleRequestRefund lets a customer ask for all or part of an order's mobile money
+// payment back. Small refunds go to PawaPay straight away; larger ones wait for an admin.
+func handleRequestRefund(orderService *order.Service, refundService *refund.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		var req refund.CreateRefundRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userID := c.MustGet("userID").(uuid.UUID)
+		o, err := orderService.GetOrderByID(orderID)
+		if err != nil || o.UserID != userID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+
+		r, err := refundService.Request(orderID, req, userID, refund.RequesterCustomer)
+		if err != nil {
+			respondRefundError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, r)
+	}
+}
+
+// handleGetOrderRefunds lists the refunds of one of the customer's orders
+func handleGetOrderRefunds(orderService *order.Service, refundService *refund.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		userID := c.MustGet("userID").(uuid.UUID)
+		o, err := orderService.GetOrderByID(orderID)
+		if err != nil || o.UserID != userID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+
+		refunds, err := refundService.ListForOrder(orderID)
+		if err != nil {
+			respondRefundError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, refunds)
+	}
+}
+
+// Admin Refund Handlers
+
+// handleAdminRequestRefund raises a refund on a customer's behalf, such as for support.
+// It still needs a second admin's approval when above the threshold.
+func handleAdminRequestRefund(refundService *refund.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		var req refund.CreateRefundRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		adminID := c.MustGet("admin_id").(uuid.UUID)
+		r, err := refundService.Request(orderID, req, adminID, refund.RequesterAdmin)
+		if err != nil {
+			respondRefundError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, r)
+	}
+}
+
+func handleAdminListRefunds(refundService *refund.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, offset := parseLimitOffset(c, 50, 200)
+		refunds, err := refundService.List(refund.Status(c.Query("status")), limit, offset)
+		if err != nil {
+			respondRefundError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, refunds)
+	}
+}
+
+func handleAdminGetRefund(refundService *refund.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid refund ID"})
+			return
+		}
+
+		r, err := refundService.Get(id)
+		if err != nil {
+			respondRefundError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, r)
+	}
+}
+
+// handleAdminReviewRefund approves or rejects a refund waiting for approval
+func handleAdminReviewRefund(refundService *refund.Service, approve bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid refund ID"})
+			return
+		}
+
+		var req refund.ReviewRequest
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		adminID := c.MustGet("admin_id").(uuid.UUID)
+		review := refundService.Reject
+		if approve {
+			review = refundService.Approve
+		}
+		r, err := review(id, adminID, req.Note)
+		if err != nil {
+			respondRefundError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, r)
+	}
+}
+
+func handleAdminResubmitRefund(refundService *refund.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid refund ID"})
+			return
+		}
+
+		r, err := refundService.Resubmit(id)
+		if err != nil {
+			respondRefundError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, r)
+	}
+}
+
+// handleAdminGetRefundReport settles collections and refunds per gateway for a period,
+// the last 30 days by default. Dates accept the same formats as the order listings.
+func handleAdminGetRefundReport(refundService *refund.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		to := time.Now()
+		from := to.AddDate(0, 0, -30)
+		if raw := c.Query("from"); raw != "" {
+			t, _, err := parseListDate(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date: " + raw})
+				return
+			}
+			from = t
+		}
+		if raw := c.Query("to"); raw != "" {
+			t, dateOnly, err := parseListDate(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date: " + raw})
+				return
+			}
+			if dateOnly {
+				t = t.AddDate(0, 0, 1)
+			}
+			to = t
+		}
+
+		report, err := refundService.Report(from, to)
+		if err != nil {
+			respondRefundError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+func respondRefundError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, refund.ErrRefundNotFound), errors.Is(err, refund.ErrNothingToRefund):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, refund.ErrInvalidAmount), errors.Is(err, refund.ErrExceedsRefundable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, refund.ErrNotAwaitingApproval), errors.Is(err, refund.ErrNotProcessing),
+		errors.Is(err, refund.ErrAlreadyRefunded):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, refund.ErrSelfApproval):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		log.Printf("ERROR: Refund request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Refund request failed"})
+	}
+}
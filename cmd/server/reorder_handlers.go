@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/business"
+	"github.com/yakumwamba/lpg-delivery-system/internal/order"
+	"github.com/yakumwamba/lpg-delivery-system/internal/promo"
+	"github.com/yakumwamba/lpg-delivery-system/pkg/realtime"
+)
+
+type reorderRequest struct {
+	Quantity        int        `json:"quantity" binding:"omitempty,gt=0"`
+	PaymentMethod   string     `json:"payment_method"`
+	DeliveryAddress string     `json:"delivery_address"`
+	DeliverySiteID  *uuid.UUID `json:"delivery_site_id"`
+	PromoCode       string     `json:"promo_code"`
+}
+
+// handleReorder repeats one of the customer's previous orders at current prices.
+// With ?preview=true the prefilled order is returned without being placed.
+func handleReorder(orderService *order.Service, businessService *business.Service, promoService *promo.Service, hub *realtime.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uuid.UUID)
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		var req reorderRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		overrides := order.ReorderOverrides{
+			Quantity:        req.Quantity,
+			DeliveryAddress: req.DeliveryAddress,
+			DeliverySiteID:  req.DeliverySiteID,
+		}
+		if req.PaymentMethod != "" {
+			overrides.PaymentMethod, err = order.ParsePaymentMethod(req.PaymentMethod)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		draft, err := orderService.BuildReorder(userID, orderID, overrides)
+		if err != nil {
+			respondReorderError(c, err)
+			return
+		}
+
+		draft.Order.PromoCode = req.PromoCode
+		promotion, err := applyPromotion(promoService, draft.Order)
+		if err != nil {
+			respondPromoError(c, err)
+			return
+		}
+
+		if c.Query("preview") == "true" {
+			c.JSON(http.StatusOK, draft)
+			return
+		}
+
+		createdOrder, err := saveOrder(orderService, businessService, promoService, promotion, draft.Order)
+		if err != nil {
+			log.Printf("ERROR: Failed to create reorder of %s: %v", orderID, err)
+			if promo.IsRuleError(err) || errors.Is(err, promo.ErrAlreadyRedeemed) {
+				respondPromoError(c, err)
+				return
+			}
+			if draft.Order.PaymentMethod == order.PaymentMethodOnAccount {
+				respondBusinessError(c, err)
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
+			return
+		}
+		draft.Order = createdOrder
+
+		hub.BroadcastOrderCreated(createdOrder.ID.String(), createdOrder.UserID.String(), gin.H{
+			"order_id":      createdOrder.ID,
+			"status":        createdOrder.Status,
+			"cylinder_type": createdOrder.CylinderType,
+			"quantity":      createdOrder.Quantity,
+			"grand_total":   createdOrder.GrandTotal,
+		})
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Order created successfully",
+			"order":   createdOrder,
+			"reorder": draft,
+		})
+	}
+}
+
+// handleGetSuggestedOrder returns a prefilled next order from the customer's history and preferences
+func handleGetSuggestedOrder(orderService *order.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uuid.UUID)
+
+		draft, err := orderService.SuggestNextOrder(userID)
+		if err != nil {
+			respondReorderError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, draft)
+	}
+}
+
+func respondReorderError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, order.ErrOrderNotFound), errors.Is(err, order.ErrNoOrderHistory):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, order.ErrNoProviderAvailable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("ERROR: Failed to build reorder: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare order"})
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/review"
+)
+
+// Review Handlers
+
+func handleSubmitOrderRating(reviewService *review.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		var req review.OrderRatingRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userID, _ := c.Get("userID")
+		reviews, err := reviewService.SubmitOrderRating(userID.(uuid.UUID), orderID, &req)
+		if err != nil {
+			switch {
+			case errors.Is(err, review.ErrAlreadyRated):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			case errors.Is(err, review.ErrOrderNotRateable):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit rating"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Rating submitted successfully",
+			"reviews": reviews,
+		})
+	}
+}
+
+func handleGetOrderRating(reviewService *review.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		reviews, err := reviewService.GetOrderReviews(orderID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rating"})
+			return
+		}
+
+		userID, _ := c.Get("userID")
+		for _, r := range reviews {
+			if r.CustomerID != userID.(uuid.UUID) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to view this rating"})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"reviews":      reviews,
+			"rated":        len(reviews) > 0,
+			"allowed_tags": gin.H{"provider": review.AllowedTags(review.SubjectTypeProvider), "courier": review.AllowedTags(review.SubjectTypeCourier)},
+		})
+	}
+}
+
+func handleGetProviderReviews(reviewService *review.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		providerID, err := uuid.Parse(c.Param("provider_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid provider ID"})
+			return
+		}
+
+		limit, offset := parseLimitOffset(c, 20, 100)
+
+		reviews, err := reviewService.GetPublicReviews(providerID, limit, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reviews"})
+			return
+		}
+
+		summary, err := reviewService.GetRatingSummary(providerID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Provider not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"summary": summary,
+			"reviews": reviews,
+		})
+	}
+}
+
+// Admin review moderation handlers
+
+func handleGetReviewsForModeration(reviewService *review.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := review.ModerationStatus(c.DefaultQuery("status", string(review.ModerationStatusPending)))
+		limit, offset := parseLimitOffset(c, 20, 100)
+
+		reviews, err := reviewService.GetReviewsByStatus(status, limit, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reviews"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"reviews": reviews})
+	}
+}
+
+func handleModerateReview(reviewService *review.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reviewID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+			return
+		}
+
+		var req struct {
+			Action string `json:"action" binding:"required"`
+			Note   string `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var status review.ModerationStatus
+		switch req.Action {
+		case "approve":
+			status = review.ModerationStatusApproved
+		case "reject":
+			status = review.ModerationStatusRejected
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Action must be 'approve' or 'reject'"})
+			return
+		}
+
+		if err := reviewService.ModerateReview(reviewID, status, req.Note); err != nil {
+			if errors.Is(err, review.ErrReviewNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to moderate review"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Review moderated successfully"})
+	}
+}
+
+// parseLimitOffset reads limit/offset query parameters, falling back to defaults on bad input
+func parseLimitOffset(c *gin.Context, defaultLimit, maxLimit int) (int, int) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultLimit)))
+	if err != nil || limit <= 0 || limit > maxLimit {
+		limit = defaultLimit
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	return limit, offset
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/usage"
+)
+
+// handleGetUsagePrediction returns when the customer's gas is expected to run out
+func handleGetUsagePrediction(usageService *usage.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uuid.UUID)
+
+		prediction, err := usageService.Predict(userID)
+		if err != nil {
+			if errors.Is(err, usage.ErrNoDeliveredOrders) {
+				profile, err := usageService.GetProfile(userID)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				c.JSON(http.StatusOK, gin.H{"profile": profile, "prediction": nil})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"profile":      prediction.Profile,
+			"prediction":   prediction,
+			"reorder_link": usageService.ReorderLink(prediction.LastOrderID),
+		})
+	}
+}
+
+func handleUpdateUsageProfile(usageService *usage.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uuid.UUID)
+
+		var req usage.UpdateProfileRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		profile, err := usageService.UpdateProfile(userID, &req)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, profile)
+	}
+}
+
+// handleAdminRunRefillReminders runs the daily reminder job on demand
+func handleAdminRunRefillReminders(usageService *usage.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sent, err := usageService.SendDueReminders(time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"sent": sent})
+	}
+}
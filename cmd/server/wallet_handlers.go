@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+	"github.com/yakumwamba/lpg-delivery-system/internal/pawapay"
+	"github.com/yakumwamba/lpg-delivery-system/internal/payment"
+	"github.com/yakumwamba/lpg-delivery-system/internal/phone"
+	"github.com/yakumwamba/lpg-delivery-system/internal/user"
+	"github.com/yakumwamba/lpg-delivery-system/internal/wallet"
+	"github.com/yakumwamba/lpg-delivery-system/pkg/realtime"
+)
+
+// Customer Wallet Handlers
+
+func handleGetWallet(walletService *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uuid.UUID)
+		w, err := walletService.GetWallet(userID)
+		if err != nil {
+			respondWalletError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, w)
+	}
+}
+
+// handleWalletTopUp starts a mobile money deposit into the wallet, charged to the
+// customer's own number unless another is given
+func handleWalletTopUp(walletService *wallet.Service, userService *user.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uuid.UUID)
+
+		var req wallet.TopUpRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if req.PhoneNumber == "" {
+			customer, err := userService.GetUserByID(userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load customer"})
+				return
+			}
+			req.PhoneNumber = customer.PhoneNumber
+		}
+		if req.PhoneNumber == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "phone_number is required"})
+			return
+		}
+
+		topUp, err := walletService.TopUp(userID, &req)
+		if err != nil {
+			respondWalletError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"top_up": topUp})
+	}
+}
+
+func handleGetWalletTopUp(walletService *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uuid.UUID)
+		topUpID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid top-up ID"})
+			return
+		}
+
+		topUp, err := walletService.GetTopUp(userID, topUpID)
+		if err != nil {
+			respondWalletError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"top_up": topUp})
+	}
+}
+
+func handleGetWalletStatement(walletService *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		writeWalletStatement(c, walletService, c.MustGet("userID").(uuid.UUID))
+	}
+}
+
+// handlePayOrderWithWallet pays all or part of an order from the customer's wallet
+func handlePayOrderWithWallet(walletService *wallet.Service, paymentService *payment.Service, hub *realtime.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uuid.UUID)
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		var req wallet.SpendRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		paid, err := walletService.PayOrder(userID, orderID, &req)
+		if err != nil {
+			respondWalletError(c, err)
+			return
+		}
+
+		if paid.OrderPaid {
+			// Dispatch follows the same event as a gateway payment completing
+			paymentService.WalletPaid(orderID, paid.Transaction.ID, money.Kwacha(-paid.Transaction.Amount))
+			hub.BroadcastOrderUpdated(orderID.String(), userID.String(), gin.H{
+				"order_id":       orderID,
+				"payment_status": "paid",
+				"wallet_paid":    paid.WalletPaid,
+			})
+		}
+
+		c.JSON(http.StatusOK, paid)
+	}
+}
+
+func handleAdminCreditWallet(walletService *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		var req wallet.CreditRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tx, err := walletService.Credit(userID, &req, c.MustGet("admin_id").(uuid.UUID))
+		if err != nil {
+			respondWalletError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"transaction": tx})
+	}
+}
+
+func handleAdminGetWalletStatement(walletService *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		writeWalletStatement(c, walletService, userID)
+	}
+}
+
+func handleAdminCheckWalletIntegrity(walletService *wallet.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report, err := walletService.CheckIntegrity()
+		if err != nil {
+			respondWalletError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// writeWalletStatement reads the from/to period, which accepts the same dates as the
+// order listings, and responds with the statement for it
+func writeWalletStatement(c *gin.Context, walletService *wallet.Service, userID uuid.UUID) {
+	var from, to *time.Time
+	if raw := c.Query("from"); raw != "" {
+		t, _, err := parseListDate(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date: " + raw})
+			return
+		}
+		from = &t
+	}
+	if raw := c.Query("to"); raw != "" {
+		t, dateOnly, err := parseListDate(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date: " + raw})
+			return
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		to = &t
+	}
+	limit, offset := parseLimitOffset(c, 50, 200)
+
+	statement, err := walletService.GetStatement(userID, from, to, limit, offset)
+	if err != nil {
+		respondWalletError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, statement)
+}
+
+func respondWalletError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, wallet.ErrTopUpNotFound), errors.Is(err, wallet.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, wallet.ErrInvalidAmount), errors.Is(err, wallet.ErrTopUpTooLarge),
+		errors.Is(err, wallet.ErrRefundExceedsOrder), errors.Is(err, phone.ErrInvalidNumber),
+		errors.Is(err, phone.ErrUnknownOperator), errors.Is(err, pawapay.ErrUnsupportedOperator):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, pawapay.ErrProviderUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	case errors.Is(err, wallet.ErrInsufficientFunds), errors.Is(err, wallet.ErrOrderNotPayable),
+		errors.Is(err, wallet.ErrOrderAlreadyPaid), errors.Is(err, wallet.ErrDuplicateReference):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("ERROR: Wallet request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Wallet request failed"})
+	}
+}
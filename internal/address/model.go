@@ -0,0 +1,207 @@
+package address
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Address is a structured delivery address. In the compounds street names and house
+// numbers are often missing, so the area, a landmark and the map pin carry most weight.
+type Address struct {
+	Area        string  `json:"area"`
+	Street      string  `json:"street,omitempty"`
+	HouseNumber string  `json:"house_number,omitempty"`
+	Landmark    string  `json:"landmark,omitempty"`
+	Notes       string  `json:"notes,omitempty"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+}
+
+// Format renders the address as a single line for places that only show free text
+func (a Address) Format() string {
+	var parts []string
+	street := strings.TrimSpace(strings.TrimSpace(a.HouseNumber) + " " + strings.TrimSpace(a.Street))
+	if street != "" {
+		parts = append(parts, street)
+	}
+	if a.Area != "" {
+		parts = append(parts, a.Area)
+	}
+	line := strings.Join(parts, ", ")
+	if a.Landmark != "" {
+		if line != "" {
+			line += " "
+		}
+		line += "(near " + a.Landmark + ")"
+	}
+	return line
+}
+
+// Value stores the address as JSON
+func (a Address) Value() (driver.Value, error) {
+	raw, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+// Scan reads an address stored as JSON
+func (a *Address) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	default:
+		return fmt.Errorf("cannot scan %T into Address", src)
+	}
+}
+
+// Zone is an area we deliver to, approximated by a circle around its centre
+type Zone struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	RadiusKm  float64   `json:"radius_km"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ZoneRequest struct {
+	Name      string  `json:"name" binding:"required"`
+	Latitude  float64 `json:"latitude" binding:"required,gte=-90,lte=90"`
+	Longitude float64 `json:"longitude" binding:"required,gte=-180,lte=180"`
+	RadiusKm  float64 `json:"radius_km" binding:"required,gt=0"`
+	Active    *bool   `json:"active"`
+}
+
+// Zone builds a zone from the request, active unless the request says otherwise
+func (r ZoneRequest) Zone() *Zone {
+	return &Zone{
+		Name:      strings.TrimSpace(r.Name),
+		Latitude:  r.Latitude,
+		Longitude: r.Longitude,
+		RadiusKm:  r.RadiusKm,
+		Active:    r.Active == nil || *r.Active,
+	}
+}
+
+// Contains reports whether the point lies inside the zone
+func (z Zone) Contains(latitude, longitude float64) bool {
+	return DistanceKm(z.Latitude, z.Longitude, latitude, longitude) <= z.RadiusKm
+}
+
+// Validation is the outcome of checking an address against the served zones
+type Validation struct {
+	Zone        *Zone `json:"zone"`
+	AreaMatched bool  `json:"area_matched"`
+}
+
+// SavedAddress is one labelled entry in a customer's address book
+type SavedAddress struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Label     string    `json:"label"`
+	Address   Address   `json:"address"`
+	Formatted string    `json:"formatted"`
+	IsDefault bool      `json:"is_default"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type SavedAddressRequest struct {
+	Label     string  `json:"label" binding:"required,max=50"`
+	Address   Address `json:"address" binding:"required"`
+	IsDefault bool    `json:"is_default"`
+}
+
+// MaxSavedAddresses caps the size of one customer's address book
+const MaxSavedAddresses = 20
+
+var (
+	ErrSavedAddressNotFound = errors.New("saved address not found")
+	ErrTooManyAddresses     = errors.New("address book is full")
+	ErrDuplicateLabel       = errors.New("an address with this label already exists")
+)
+
+var (
+	ErrMissingArea        = errors.New("area or compound is required")
+	ErrMissingPin         = errors.New("map pin is required")
+	ErrOutsideServiceArea = errors.New("map pin is outside the areas we deliver to")
+	ErrAreaMismatch       = errors.New("map pin is too far from the area entered")
+	ErrZoneNotFound       = errors.New("service zone not found")
+)
+
+// AreaToleranceKm is how far beyond a named area's zone a pin may fall and still be
+// accepted, since area boundaries are loose and pins are often dropped by the road
+const AreaToleranceKm = 2.0
+
+// Check validates an address against the given zones. The pin must fall inside an active
+// zone and, when the typed area names a zone, lie within plausible distance of that zone.
+func Check(a *Address, zones []Zone) (*Validation, error) {
+	a.Area = strings.TrimSpace(a.Area)
+	if a.Area == "" {
+		return nil, ErrMissingArea
+	}
+	if a.Latitude == 0 && a.Longitude == 0 {
+		return nil, ErrMissingPin
+	}
+	if a.Latitude < -90 || a.Latitude > 90 || a.Longitude < -180 || a.Longitude > 180 {
+		return nil, ErrMissingPin
+	}
+
+	var containing, named *Zone
+	for i := range zones {
+		z := &zones[i]
+		if !z.Active {
+			continue
+		}
+		if named == nil && normalizeArea(z.Name) == normalizeArea(a.Area) {
+			named = z
+		}
+		if containing == nil && z.Contains(a.Latitude, a.Longitude) {
+			containing = z
+		}
+	}
+
+	if named != nil {
+		if DistanceKm(named.Latitude, named.Longitude, a.Latitude, a.Longitude) > named.RadiusKm+AreaToleranceKm {
+			return nil, ErrAreaMismatch
+		}
+		return &Validation{Zone: named, AreaMatched: true}, nil
+	}
+	if containing == nil {
+		return nil, ErrOutsideServiceArea
+	}
+	return &Validation{Zone: containing}, nil
+}
+
+// normalizeArea makes "Kalingalinga Compound" and "kalingalinga" compare equal
+func normalizeArea(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, suffix := range []string{" compound", " township", " area"} {
+		s = strings.TrimSuffix(s, suffix)
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// DistanceKm is the great-circle distance between two points in kilometres
+func DistanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371.0
+
+	dLat := (lat2 - lat1) * math.Pi / 180
+	dLon := (lon2 - lon1) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*math.Pi/180)*math.Cos(lat2*math.Pi/180)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return earthRadiusKm * 2 * math.Atan2(math.Sqrt(h), math.Sqrt(1-h))
+}
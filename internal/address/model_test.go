@@ -0,0 +1,54 @@
+package address
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testZones = []Zone{
+	{Name: "Kalingalinga", Latitude: -15.4110, Longitude: 28.3150, RadiusKm: 2, Active: true},
+	{Name: "Matero", Latitude: -15.3750, Longitude: 28.2500, RadiusKm: 3, Active: true},
+	{Name: "Chongwe", Latitude: -15.3290, Longitude: 28.6820, RadiusKm: 5, Active: false},
+}
+
+func TestCheckAcceptsPinInsideNamedArea(t *testing.T) {
+	a := &Address{Area: " Kalingalinga Compound ", Landmark: "blue church", Latitude: -15.4120, Longitude: 28.3160}
+
+	v, err := Check(a, testZones)
+	require.NoError(t, err)
+	assert.True(t, v.AreaMatched)
+	assert.Equal(t, "Kalingalinga", v.Zone.Name)
+}
+
+func TestCheckRejectsPinFarFromTypedArea(t *testing.T) {
+	// Pin in Matero while the customer typed Kalingalinga
+	a := &Address{Area: "Kalingalinga", Latitude: -15.3750, Longitude: 28.2500}
+
+	_, err := Check(a, testZones)
+	assert.ErrorIs(t, err, ErrAreaMismatch)
+}
+
+func TestCheckFallsBackToContainingZoneForUnknownArea(t *testing.T) {
+	a := &Address{Area: "Behind Matero market", Latitude: -15.3760, Longitude: 28.2510}
+
+	v, err := Check(a, testZones)
+	require.NoError(t, err)
+	assert.False(t, v.AreaMatched)
+	assert.Equal(t, "Matero", v.Zone.Name)
+}
+
+func TestCheckRejectsPinOutsideActiveZones(t *testing.T) {
+	_, err := Check(&Address{Area: "Chongwe", Latitude: -15.3290, Longitude: 28.6820}, testZones)
+	assert.ErrorIs(t, err, ErrOutsideServiceArea)
+
+	_, err = Check(&Address{Area: "Matero"}, testZones)
+	assert.ErrorIs(t, err, ErrMissingPin)
+}
+
+func TestFormat(t *testing.T) {
+	a := Address{Area: "Kalingalinga", Street: "Mutandwa Road", HouseNumber: "12", Landmark: "the blue church"}
+	assert.Equal(t, "12 Mutandwa Road, Kalingalinga (near the blue church)", a.Format())
+	assert.Equal(t, "Matero", Address{Area: "Matero"}.Format())
+}
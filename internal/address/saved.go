@@ -0,0 +1,247 @@
+package address
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const savedAddressColumns = `id, user_id, label, address, is_default, created_at, updated_at`
+
+func scanSavedAddress(row interface{ Scan(...interface{}) error }) (*SavedAddress, error) {
+	var sa SavedAddress
+	var idStr, userIDStr string
+	if err := row.Scan(&idStr, &userIDStr, &sa.Label, &sa.Address, &sa.IsDefault, &sa.CreatedAt, &sa.UpdatedAt); err != nil {
+		return nil, err
+	}
+	sa.ID, _ = uuid.Parse(idStr)
+	sa.UserID, _ = uuid.Parse(userIDStr)
+	sa.Formatted = sa.Address.Format()
+	return &sa, nil
+}
+
+// ListSaved returns a customer's saved addresses, default first
+func (s *Service) ListSaved(userID uuid.UUID) ([]SavedAddress, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+savedAddressColumns+`
+		FROM customer_addresses
+		WHERE user_id = $1
+		ORDER BY is_default DESC, label
+	`, userID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list saved addresses: %w", err)
+	}
+	defer rows.Close()
+
+	addresses := []SavedAddress{}
+	for rows.Next() {
+		sa, err := scanSavedAddress(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan saved address: %w", err)
+		}
+		addresses = append(addresses, *sa)
+	}
+
+	return addresses, rows.Err()
+}
+
+// GetSaved returns one of the customer's saved addresses
+func (s *Service) GetSaved(userID, addressID uuid.UUID) (*SavedAddress, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sa, err := scanSavedAddress(s.db.QueryRowContext(ctx, `
+		SELECT `+savedAddressColumns+`
+		FROM customer_addresses
+		WHERE id = $1 AND user_id = $2
+	`, addressID.String(), userID.String()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSavedAddressNotFound
+		}
+		return nil, fmt.Errorf("failed to get saved address: %w", err)
+	}
+
+	return sa, nil
+}
+
+// GetDefault returns the customer's default address, or nil if the address book is empty
+func (s *Service) GetDefault(userID uuid.UUID) (*SavedAddress, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sa, err := scanSavedAddress(s.db.QueryRowContext(ctx, `
+		SELECT `+savedAddressColumns+`
+		FROM customer_addresses
+		WHERE user_id = $1 AND is_default
+	`, userID.String()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get default address: %w", err)
+	}
+
+	return sa, nil
+}
+
+// CreateSaved validates and adds an address to the customer's address book.
+// The first address saved becomes the default.
+func (s *Service) CreateSaved(userID uuid.UUID, req *SavedAddressRequest) (*SavedAddress, error) {
+	if _, err := s.Validate(&req.Address); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM customer_addresses WHERE user_id = $1`, userID.String()).Scan(&count); err != nil {
+		return nil, fmt.Errorf("failed to count saved addresses: %w", err)
+	}
+	if count >= MaxSavedAddresses {
+		return nil, ErrTooManyAddresses
+	}
+
+	isDefault := req.IsDefault || count == 0
+	if isDefault {
+		if err := s.clearDefault(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	sa, err := scanSavedAddress(s.db.QueryRowContext(ctx, `
+		INSERT INTO customer_addresses (user_id, label, address, is_default)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+savedAddressColumns,
+		userID.String(), strings.TrimSpace(req.Label), req.Address, isDefault))
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, ErrDuplicateLabel
+		}
+		return nil, fmt.Errorf("failed to save address: %w", err)
+	}
+
+	return sa, nil
+}
+
+// UpdateSaved replaces a saved address's label and location. Setting IsDefault makes it
+// the default; clearing it leaves the default unchanged.
+func (s *Service) UpdateSaved(userID, addressID uuid.UUID, req *SavedAddressRequest) (*SavedAddress, error) {
+	if _, err := s.Validate(&req.Address); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if req.IsDefault {
+		if _, err := s.GetSaved(userID, addressID); err != nil {
+			return nil, err
+		}
+		if err := s.clearDefault(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	sa, err := scanSavedAddress(s.db.QueryRowContext(ctx, `
+		UPDATE customer_addresses
+		SET label = $1, address = $2, is_default = is_default OR $3
+		WHERE id = $4 AND user_id = $5
+		RETURNING `+savedAddressColumns,
+		strings.TrimSpace(req.Label), req.Address, req.IsDefault, addressID.String(), userID.String()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSavedAddressNotFound
+		}
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, ErrDuplicateLabel
+		}
+		return nil, fmt.Errorf("failed to update saved address: %w", err)
+	}
+
+	return sa, nil
+}
+
+// SetDefault makes one of the customer's saved addresses their default
+func (s *Service) SetDefault(userID, addressID uuid.UUID) (*SavedAddress, error) {
+	if _, err := s.GetSaved(userID, addressID); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := s.clearDefault(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	sa, err := scanSavedAddress(s.db.QueryRowContext(ctx, `
+		UPDATE customer_addresses SET is_default = TRUE
+		WHERE id = $1 AND user_id = $2
+		RETURNING `+savedAddressColumns,
+		addressID.String(), userID.String()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSavedAddressNotFound
+		}
+		return nil, fmt.Errorf("failed to set default address: %w", err)
+	}
+
+	return sa, nil
+}
+
+// DeleteSaved removes a saved address. If it was the default, the most recently
+// updated remaining address takes its place.
+func (s *Service) DeleteSaved(userID, addressID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var wasDefault bool
+	err := s.db.QueryRowContext(ctx, `
+		DELETE FROM customer_addresses
+		WHERE id = $1 AND user_id = $2
+		RETURNING is_default
+	`, addressID.String(), userID.String()).Scan(&wasDefault)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrSavedAddressNotFound
+		}
+		return fmt.Errorf("failed to delete saved address: %w", err)
+	}
+
+	if wasDefault {
+		_, err = s.db.ExecContext(ctx, `
+			UPDATE customer_addresses SET is_default = TRUE
+			WHERE id = (
+				SELECT id FROM customer_addresses
+				WHERE user_id = $1
+				ORDER BY updated_at DESC
+				LIMIT 1
+			)
+		`, userID.String())
+		if err != nil {
+			return fmt.Errorf("failed to promote default address: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) clearDefault(ctx context.Context, userID uuid.UUID) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE customer_addresses SET is_default = FALSE
+		WHERE user_id = $1 AND is_default
+	`, userID.String())
+	if err != nil {
+		return fmt.Errorf("failed to clear default address: %w", err)
+	}
+	return nil
+}
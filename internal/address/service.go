@@ -0,0 +1,113 @@
+package address
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Service struct {
+	db *sql.DB
+}
+
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
+
+// Validate checks an address against the active service zones
+func (s *Service) Validate(a *Address) (*Validation, error) {
+	zones, err := s.ListZones(true)
+	if err != nil {
+		return nil, err
+	}
+	return Check(a, zones)
+}
+
+// ListZones returns the service zones, optionally only the active ones
+func (s *Service) ListZones(activeOnly bool) ([]Zone, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, name, latitude, longitude, radius_km, active, created_at, updated_at
+		FROM service_zones
+		WHERE active OR NOT $1
+		ORDER BY name
+	`, activeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service zones: %w", err)
+	}
+	defer rows.Close()
+
+	zones := []Zone{}
+	for rows.Next() {
+		var z Zone
+		var idStr string
+		if err := rows.Scan(&idStr, &z.Name, &z.Latitude, &z.Longitude, &z.RadiusKm, &z.Active, &z.CreatedAt, &z.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan service zone: %w", err)
+		}
+		z.ID, _ = uuid.Parse(idStr)
+		zones = append(zones, z)
+	}
+
+	return zones, rows.Err()
+}
+
+// CreateZone adds a service zone
+func (s *Service) CreateZone(z *Zone) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var idStr string
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO service_zones (name, latitude, longitude, radius_km, active)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at
+	`, z.Name, z.Latitude, z.Longitude, z.RadiusKm, z.Active).Scan(&idStr, &z.CreatedAt, &z.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create service zone: %w", err)
+	}
+	z.ID, _ = uuid.Parse(idStr)
+
+	return nil
+}
+
+// UpdateZone replaces a service zone's name, extent and active flag
+func (s *Service) UpdateZone(z *Zone) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE service_zones
+		SET name = $1, latitude = $2, longitude = $3, radius_km = $4, active = $5
+		WHERE id = $6
+		RETURNING created_at, updated_at
+	`, z.Name, z.Latitude, z.Longitude, z.RadiusKm, z.Active, z.ID.String()).Scan(&z.CreatedAt, &z.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrZoneNotFound
+		}
+		return fmt.Errorf("failed to update service zone: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteZone removes a service zone
+func (s *Service) DeleteZone(id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM service_zones WHERE id = $1`, id.String())
+	if err != nil {
+		return fmt.Errorf("failed to delete service zone: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrZoneNotFound
+	}
+
+	return nil
+}
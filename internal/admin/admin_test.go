@@ -8,6 +8,7 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
 )
 
 // Test database setup helper
@@ -47,7 +48,7 @@ func TestGetDashboardSummary(t *testing.T) {
 	assert.GreaterOrEqual(t, summary.ActiveUsers, 0)
 	assert.GreaterOrEqual(t, summary.ActiveProviders, 0)
 	assert.GreaterOrEqual(t, summary.ActiveCouriers, 0)
-	assert.GreaterOrEqual(t, summary.TotalRevenue, 0.0)
+	assert.GreaterOrEqual(t, summary.TotalRevenue, money.Amount(0))
 }
 
 // TestGetDailyAnalytics tests daily analytics retrieval
@@ -68,7 +69,7 @@ func TestGetDailyAnalytics(t *testing.T) {
 	if len(analytics) > 0 {
 		assert.NotEmpty(t, analytics[0].AnalyticsDate)
 		assert.GreaterOrEqual(t, analytics[0].TotalOrders, 0)
-		assert.GreaterOrEqual(t, analytics[0].TotalRevenue, 0.0)
+		assert.GreaterOrEqual(t, analytics[0].TotalRevenue, money.Amount(0))
 	}
 }
 
@@ -238,8 +239,8 @@ func TestGetProviderCylinderPricing(t *testing.T) {
 	// Verify pricing structure
 	for _, p := range pricing {
 		assert.NotEmpty(t, p.CylinderType)
-		assert.Greater(t, p.RefillPrice, 0.0)
-		assert.Greater(t, p.BuyPrice, 0.0)
+		assert.Greater(t, p.RefillPrice, money.Amount(0))
+		assert.Greater(t, p.BuyPrice, money.Amount(0))
 	}
 }
 
@@ -268,7 +269,7 @@ func TestGetProviderInventory(t *testing.T) {
 	for _, inv := range inventory {
 		assert.NotEmpty(t, inv.CylinderType)
 		assert.GreaterOrEqual(t, inv.Stock, 0)
-		assert.Greater(t, inv.Price, 0.0)
+		assert.Greater(t, inv.Price, money.Amount(0))
 	}
 }
 
@@ -294,7 +295,7 @@ func TestGetProviderMetrics(t *testing.T) {
 
 	if len(metrics) > 0 {
 		assert.GreaterOrEqual(t, metrics[0].OrdersCount, 0)
-		assert.GreaterOrEqual(t, metrics[0].Revenue, 0.0)
+		assert.GreaterOrEqual(t, metrics[0].Revenue, money.Amount(0))
 		assert.GreaterOrEqual(t, metrics[0].CompletedOrders, 0)
 	}
 }
@@ -329,8 +330,8 @@ func TestGetAvailableCylinderProviders(t *testing.T) {
 			// Verify cylinder info
 			for _, cyl := range p.Cylinders {
 				assert.NotEmpty(t, cyl.CylinderType)
-				assert.Greater(t, cyl.RefillPrice, 0.0)
-				assert.Greater(t, cyl.BuyPrice, 0.0)
+				assert.Greater(t, cyl.RefillPrice, money.Amount(0))
+				assert.Greater(t, cyl.BuyPrice, money.Amount(0))
 				assert.GreaterOrEqual(t, cyl.Stock, 0)
 			}
 		}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
 )
 
 // Dashboard Analytics Summary
@@ -13,7 +14,7 @@ type DashboardSummary struct {
 	ActiveCouriers       int     `json:"active_couriers"`
 	CompletedOrders      int     `json:"completed_orders"`
 	ActiveOrders         int     `json:"active_orders"`
-	TotalRevenue         float64 `json:"total_revenue"`
+	TotalRevenue         money.Amount `json:"total_revenue"`
 	AvgProviderRating    float64 `json:"avg_provider_rating"`
 	CreatedAt            time.Time `json:"created_at"`
 }
@@ -25,12 +26,12 @@ type DailyAnalytics struct {
 	TotalOrders           int       `json:"total_orders"`
 	CompletedOrders       int       `json:"completed_orders"`
 	PendingOrders         int       `json:"pending_orders"`
-	TotalRevenue          float64   `json:"total_revenue"`
+	TotalRevenue          money.Amount `json:"total_revenue"`
 	TotalTransactions     int       `json:"total_transactions"`
 	ActiveProviders       int       `json:"active_providers"`
 	ActiveCouriers        int       `json:"active_couriers"`
 	ActiveCustomers       int       `json:"active_customers"`
-	AvgOrderValue         float64   `json:"avg_order_value"`
+	AvgOrderValue         money.Amount `json:"avg_order_value"`
 	AvgDeliveryTimeMinutes int      `json:"avg_delivery_time_minutes"`
 	CreatedAt             time.Time `json:"created_at"`
 	UpdatedAt             time.Time `json:"updated_at"`
@@ -45,7 +46,7 @@ type ProviderStatus struct {
 	VerificationDate   *time.Time `json:"verification_date"`
 	AvgRating          float64   `json:"avg_rating"`
 	TotalOrders        int       `json:"total_orders"`
-	TotalRevenue       float64   `json:"total_revenue"`
+	TotalRevenue       money.Amount `json:"total_revenue"`
 	ResponseTimeMinutes *int     `json:"response_time_minutes"`
 	DeactivationReason *string   `json:"deactivation_reason"`
 	DeactivatedAt      *time.Time `json:"deactivated_at"`
@@ -62,7 +63,7 @@ type CourierStatus struct {
 	VerificationDate      *time.Time `json:"verification_date"`
 	AvgRating             float64   `json:"avg_rating"`
 	TotalDeliveries       int       `json:"total_deliveries"`
-	TotalEarnings         float64   `json:"total_earnings"`
+	TotalEarnings         money.Amount `json:"total_earnings"`
 	AvgDeliveryTimeMinutes *int     `json:"avg_delivery_time_minutes"`
 	IsAvailable           bool      `json:"is_available"`
 	LastLocationUpdate    *time.Time `json:"last_location_update"`
@@ -114,7 +115,7 @@ type ProviderMetrics struct {
 	ProviderID       uuid.UUID `json:"provider_id"`
 	MetricDate       time.Time `json:"metric_date"`
 	OrdersCount      int       `json:"orders_count"`
-	Revenue          float64   `json:"revenue"`
+	Revenue          money.Amount `json:"revenue"`
 	CompletedOrders  int       `json:"completed_orders"`
 	AvgRating        *float64  `json:"avg_rating"`
 	CreatedAt        time.Time `json:"created_at"`
@@ -132,7 +133,7 @@ type ProviderWithStatus struct {
 	IsVerified           bool      `json:"is_verified"`
 	AvgRating            float64   `json:"avg_rating"`
 	TotalOrders          int       `json:"total_orders"`
-	TotalRevenue         float64   `json:"total_revenue"`
+	TotalRevenue         money.Amount `json:"total_revenue"`
 	ResponseTimeMinutes  *int      `json:"response_time_minutes"`
 	CreatedAt            time.Time `json:"created_at"`
 }
@@ -149,7 +150,7 @@ type CourierWithStatus struct {
 	IsVerified           bool      `json:"is_verified"`
 	AvgRating            float64   `json:"avg_rating"`
 	TotalDeliveries      int       `json:"total_deliveries"`
-	TotalEarnings        float64   `json:"total_earnings"`
+	TotalEarnings        money.Amount `json:"total_earnings"`
 	IsAvailable          bool      `json:"is_available"`
 	CreatedAt            time.Time `json:"created_at"`
 }
@@ -190,8 +191,8 @@ type CylinderPricing struct {
 	ID           uuid.UUID `json:"id"`
 	ProviderID   uuid.UUID `json:"provider_id"`
 	CylinderType string    `json:"cylinder_type"`
-	RefillPrice  float64   `json:"refill_price"`
-	BuyPrice     float64   `json:"buy_price"`
+	RefillPrice  money.Amount `json:"refill_price"`
+	BuyPrice     money.Amount `json:"buy_price"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
@@ -201,7 +202,7 @@ type Inventory struct {
 	ProviderID   uuid.UUID `json:"provider_id"`
 	CylinderType string    `json:"cylinder_type"`
 	Stock        int       `json:"stock"`
-	Price        float64   `json:"price"`
+	Price        money.Amount `json:"price"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
@@ -217,7 +218,7 @@ type ProviderCylinderInfo struct {
 
 type ProviderCylinderDetail struct {
 	CylinderType string  `json:"cylinder_type"`
-	RefillPrice  float64 `json:"refill_price"`
-	BuyPrice     float64 `json:"buy_price"`
+	RefillPrice  money.Amount `json:"refill_price"`
+	BuyPrice     money.Amount `json:"buy_price"`
 	Stock        int     `json:"stock"`
 }
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
 )
 
 // Service handles all admin operations
@@ -22,16 +23,16 @@ func NewService(db *sql.DB) *Service {
 
 // DashboardStats holds dashboard statistics
 type DashboardStats struct {
-	TotalUsers      int     `json:"totalUsers"`
-	ActiveOrders    int     `json:"activeOrders"`
-	TotalRevenue    float64 `json:"totalRevenue"`
-	ActiveProviders int     `json:"activeProviders"`
+	TotalUsers      int          `json:"totalUsers"`
+	ActiveOrders    int          `json:"activeOrders"`
+	TotalRevenue    money.Amount `json:"totalRevenue"`
+	ActiveProviders int          `json:"activeProviders"`
 }
 
 // RevenueDataPoint represents a single data point in revenue analytics
 type RevenueDataPoint struct {
-	Date    string  `json:"date"`
-	Revenue float64 `json:"revenue"`
+	Date    string       `json:"date"`
+	Revenue money.Amount `json:"revenue"`
 }
 
 // OrderDataPoint represents a single data point in order analytics
@@ -69,10 +70,15 @@ func (s *Service) GetDashboardStats() (*DashboardStats, error) {
 		return nil, fmt.Errorf("failed to get active orders: %w", err)
 	}
 
-	// Get total revenue (sum of all delivered orders)
+	// Get total revenue (sum of all delivered orders, less what was refunded on them)
 	err = s.db.QueryRow(`
-		SELECT COALESCE(SUM(grand_total), 0) FROM orders
-		WHERE status = 'delivered'
+		SELECT COALESCE(SUM(o.grand_total), 0) - COALESCE((
+			SELECT SUM(r.amount) FROM refunds r
+			JOIN orders ro ON ro.id = r.order_id
+			WHERE r.status = 'completed' AND ro.status = 'delivered'
+		), 0)
+		FROM orders o
+		WHERE o.status = 'delivered'
 	`).Scan(&stats.TotalRevenue)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get total revenue: %w", err)
@@ -94,13 +100,19 @@ func (s *Service) GetDashboardStats() (*DashboardStats, error) {
 func (s *Service) GetRevenueAnalytics(days int) ([]RevenueDataPoint, error) {
 	query := `
 		SELECT
-			DATE(created_at)::TEXT as date,
-			COALESCE(SUM(grand_total), 0) as revenue
-		FROM orders
-		WHERE created_at >= NOW() - INTERVAL '1 day' * $1
-		AND status = 'delivered'
-		GROUP BY DATE(created_at)
-		ORDER BY DATE(created_at) ASC
+			DATE(o.created_at)::TEXT as date,
+			COALESCE(SUM(o.grand_total - COALESCE(r.refunded, 0)), 0) as revenue
+		FROM orders o
+		LEFT JOIN (
+			SELECT order_id, SUM(amount) AS refunded
+			FROM refunds
+			WHERE status = 'completed'
+			GROUP BY order_id
+		) r ON r.order_id = o.id
+		WHERE o.created_at >= NOW() - INTERVAL '1 day' * $1
+		AND o.status = 'delivered'
+		GROUP BY DATE(o.created_at)
+		ORDER BY DATE(o.created_at) ASC
 	`
 
 	rows, err := s.db.Query(query, days)
@@ -394,33 +406,33 @@ func (s *Service) GetAllCouriers(page, limit int, search, status string) ([]Cour
 
 // OrderListItem represents an order in the management list
 type OrderListItem struct {
-	ID               uuid.UUID  `json:"id"`
-	UserID           uuid.UUID  `json:"user_id"`
-	UserName         string     `json:"user_name"`
-	UserEmail        string     `json:"user_email"`
-	UserPhone        string     `json:"user_phone"`
-	ProviderID       *uuid.UUID `json:"provider_id"`
-	ProviderName     *string    `json:"provider_name"`
-	CourierID        *uuid.UUID `json:"courier_id"`
-	CourierName      *string    `json:"courier_name"`
-	Status           string     `json:"status"`
-	CourierStatus    string     `json:"courier_status"`
-	CylinderType     string     `json:"cylinder_type"`
-	Quantity         int        `json:"quantity"`
-	GrandTotal       float64    `json:"grand_total"`
-	PaymentStatus    string     `json:"payment_status"`
-	PaymentRef       *string    `json:"payment_ref"`
-	PaymentProv      *string    `json:"payment_provider"`
-	DeliveryAddress  string     `json:"delivery_address"`
-	DeliveryMethod   string     `json:"delivery_method"`
-	PaymentMethod    string     `json:"payment_method"`
-	DeliveryFee      float64    `json:"delivery_fee"`
-	ServiceCharge    float64    `json:"service_charge"`
-	CurrentLatitude  *float64   `json:"current_latitude"`
-	CurrentLongitude *float64   `json:"current_longitude"`
-	CurrentAddress   *string    `json:"current_address"`
-	CreatedAt        time.Time  `json:"created_at"`
-	UpdatedAt        time.Time  `json:"updated_at"`
+	ID               uuid.UUID    `json:"id"`
+	UserID           uuid.UUID    `json:"user_id"`
+	UserName         string       `json:"user_name"`
+	UserEmail        string       `json:"user_email"`
+	UserPhone        string       `json:"user_phone"`
+	ProviderID       *uuid.UUID   `json:"provider_id"`
+	ProviderName     *string      `json:"provider_name"`
+	CourierID        *uuid.UUID   `json:"courier_id"`
+	CourierName      *string      `json:"courier_name"`
+	Status           string       `json:"status"`
+	CourierStatus    string       `json:"courier_status"`
+	CylinderType     string       `json:"cylinder_type"`
+	Quantity         int          `json:"quantity"`
+	GrandTotal       money.Amount `json:"grand_total"`
+	PaymentStatus    string       `json:"payment_status"`
+	PaymentRef       *string      `json:"payment_ref"`
+	PaymentProv      *string      `json:"payment_provider"`
+	DeliveryAddress  string       `json:"delivery_address"`
+	DeliveryMethod   string       `json:"delivery_method"`
+	PaymentMethod    string       `json:"payment_method"`
+	DeliveryFee      money.Amount `json:"delivery_fee"`
+	ServiceCharge    money.Amount `json:"service_charge"`
+	CurrentLatitude  *float64     `json:"current_latitude"`
+	CurrentLongitude *float64     `json:"current_longitude"`
+	CurrentAddress   *string      `json:"current_address"`
+	CreatedAt        time.Time    `json:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at"`
 }
 
 // GetAllOrders fetches all orders with pagination and filtering
@@ -465,6 +477,7 @@ func (s *Service) GetAllOrders(page, limit int, search, status string) ([]OrderL
 		LEFT JOIN (
 			SELECT DISTINCT ON (order_id) order_id, transaction_ref, provider
 			FROM payments
+			WHERE purpose = 'order'
 			ORDER BY order_id, created_at DESC
 		) pay ON o.id = pay.order_id
 		WHERE 1=1
@@ -715,6 +728,7 @@ func (s *Service) GetOrderByID(orderID uuid.UUID) (*OrderListItem, error) {
 		LEFT JOIN (
 			SELECT DISTINCT ON (order_id) order_id, transaction_ref, provider
 			FROM payments
+			WHERE purpose = 'order'
 			ORDER BY order_id, created_at DESC
 		) pay ON o.id = pay.order_id
 		WHERE o.id = $1
@@ -5,11 +5,11 @@ import (
 	"fmt"
 	"math/rand"
 	"net/http"
-	"regexp"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"github.com/yakumwamba/lpg-delivery-system/internal/phone"
 	"github.com/yakumwamba/lpg-delivery-system/internal/user"
 )
 
@@ -37,7 +37,7 @@ func HandleVerifyCode(userService *user.Service, authService *Service) gin.Handl
 		logger = logger.WithField("phone_number", request.PhoneNumber)
 		logger.Info("Processing verification code request")
 
-		if !isValidZambianPhone(request.PhoneNumber) {
+		if !phone.IsMobile(request.PhoneNumber) {
 			logger.Warn("Invalid phone number format")
 			c.JSON(http.StatusBadRequest, gin.H{
 				"success": false,
@@ -161,7 +161,7 @@ func HandleSendCode(userService *user.Service, authService *Service, twilioServi
 		logger = logger.WithField("phone_number", request.PhoneNumber)
 		logger.Info("Processing send code request")
 
-		// if !isValidZambianPhone(request.PhoneNumber) {
+		// if !phone.IsMobile(request.PhoneNumber) {
 		// 	logger.Warn("Invalid phone number format")
 		// 	c.JSON(http.StatusBadRequest, gin.H{
 		// 		"success": false,
@@ -205,14 +205,6 @@ func generateRandomCode() int {
 	return rand.Intn(900000) + 100000
 }
 
-func isValidZambianPhone(phone string) bool {
-	// Remove spaces and special characters
-	cleanNumber := regexp.MustCompile(`[^\d]`).ReplaceAllString(phone, "")
-	// Check if it has 10 digits and starts with 0
-	re := regexp.MustCompile(`^0[967][0-9]{8}$`)
-	return re.MatchString(cleanNumber)
-}
-
 // You'll need to implement these functions with your preferred storage solution (e.g., Redis)
 func storeVerificationCode(phoneNumber, code string) error {
 	// Store code with expiration (e.g., 10 minutes)
@@ -58,3 +58,16 @@ func (t *TwilioClient) SendVerificationCode(phoneNumber string) error {
 
 	return nil
 }
+
+// SendSMS sends a plain text message, used for notifications outside the verification flow
+func (t *TwilioClient) SendSMS(to, body string) error {
+	params := &twilioApi.CreateMessageParams{}
+	params.SetTo(to)
+	params.SetFrom(t.fromNumber)
+	params.SetBody(body)
+
+	if _, err := t.client.Api.CreateMessage(params); err != nil {
+		return fmt.Errorf("failed to send SMS: %w", err)
+	}
+	return nil
+}
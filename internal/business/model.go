@@ -0,0 +1,116 @@
+package business
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AccountStatus string
+type MemberRole string
+type StatementStatus string
+
+const (
+	AccountStatusActive    AccountStatus = "active"
+	AccountStatusSuspended AccountStatus = "suspended"
+)
+
+const (
+	// MemberRoleAdmin can manage members and delivery sites as well as order
+	MemberRoleAdmin   MemberRole = "admin"
+	MemberRoleOrderer MemberRole = "orderer"
+)
+
+const (
+	StatementStatusOpen StatementStatus = "open"
+	StatementStatusPaid StatementStatus = "paid"
+)
+
+// Account is an organisation ordering on credit, such as a restaurant, school or lodge
+type Account struct {
+	ID               uuid.UUID     `json:"id"`
+	Name             string        `json:"name"`
+	TPIN             string        `json:"tpin,omitempty"`
+	BillingEmail     string        `json:"billing_email,omitempty"`
+	BillingPhone     string        `json:"billing_phone,omitempty"`
+	CreditLimit      float64       `json:"credit_limit"`
+	PaymentTermsDays int           `json:"payment_terms_days"`
+	Status           AccountStatus `json:"status"`
+	Outstanding      float64       `json:"outstanding"`
+	AvailableCredit  float64       `json:"available_credit"`
+	CreatedAt        time.Time     `json:"created_at"`
+	UpdatedAt        time.Time     `json:"updated_at"`
+}
+
+type CreateAccountRequest struct {
+	Name             string    `json:"name" binding:"required"`
+	TPIN             string    `json:"tpin"`
+	BillingEmail     string    `json:"billing_email"`
+	BillingPhone     string    `json:"billing_phone"`
+	CreditLimit      float64   `json:"credit_limit" binding:"gte=0"`
+	PaymentTermsDays int       `json:"payment_terms_days" binding:"gte=0"`
+	OwnerUserID      uuid.UUID `json:"owner_user_id" binding:"required"`
+}
+
+type UpdateTermsRequest struct {
+	CreditLimit      *float64       `json:"credit_limit" binding:"omitempty,gte=0"`
+	PaymentTermsDays *int           `json:"payment_terms_days" binding:"omitempty,gte=0"`
+	Status           *AccountStatus `json:"status" binding:"omitempty,oneof=active suspended"`
+}
+
+// Member is a user authorised to order on an account
+type Member struct {
+	AccountID uuid.UUID  `json:"account_id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	Name      string     `json:"name"`
+	Phone     string     `json:"phone"`
+	Role      MemberRole `json:"role"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Site is a delivery location belonging to an account
+type Site struct {
+	ID           uuid.UUID `json:"id"`
+	AccountID    uuid.UUID `json:"account_id"`
+	Name         string    `json:"name" binding:"required"`
+	Address      string    `json:"address" binding:"required"`
+	Latitude     *float64  `json:"latitude,omitempty"`
+	Longitude    *float64  `json:"longitude,omitempty"`
+	ContactName  string    `json:"contact_name,omitempty"`
+	ContactPhone string    `json:"contact_phone,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Statement aggregates an account's on-account orders over one calendar month
+type Statement struct {
+	ID          uuid.UUID       `json:"id"`
+	AccountID   uuid.UUID       `json:"account_id"`
+	PeriodStart time.Time       `json:"period_start"`
+	PeriodEnd   time.Time       `json:"period_end"`
+	OrderCount  int             `json:"order_count"`
+	TotalAmount float64         `json:"total_amount"`
+	DueDate     time.Time       `json:"due_date"`
+	Status      StatementStatus `json:"status"`
+	PaidAt      *time.Time      `json:"paid_at,omitempty"`
+	Lines       []StatementLine `json:"lines,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// StatementLine is one order on a statement
+type StatementLine struct {
+	OrderID      uuid.UUID `json:"order_id"`
+	OrderedBy    string    `json:"ordered_by"`
+	SiteName     string    `json:"site_name,omitempty"`
+	CylinderType string    `json:"cylinder_type"`
+	Quantity     int       `json:"quantity"`
+	GrandTotal   float64   `json:"grand_total"`
+	Status       string    `json:"status"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// statementPeriod returns the first day of the month containing t and the first day of the next month
+func statementPeriod(t time.Time) (time.Time, time.Time) {
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, 0)
+}
@@ -0,0 +1,697 @@
+package business
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAccountNotFound     = errors.New("business account not found")
+	ErrNotMember           = errors.New("user is not authorised on a business account")
+	ErrNotAccountAdmin     = errors.New("only account admins can do this")
+	ErrAlreadyMember       = errors.New("user already belongs to a business account")
+	ErrSiteNotFound        = errors.New("delivery site not found")
+	ErrStatementNotFound   = errors.New("statement not found")
+	ErrAccountSuspended    = errors.New("business account is suspended")
+	ErrCreditLimitExceeded = errors.New("order would exceed the account's credit limit")
+)
+
+// unsettledOrders picks out, among orders aliased o, the on-account orders not yet
+// settled, which are what counts against the credit limit
+const unsettledOrders = `o.payment_method = 'on_account' AND o.payment_status <> 'paid' AND o.status <> 'rejected'`
+
+// outstandingQuery sums an account's unsettled orders
+const outstandingQuery = `
+	SELECT COALESCE(SUM(o.grand_total), 0) FROM orders o
+	WHERE o.business_account_id = $1 AND ` + unsettledOrders
+
+type Service struct {
+	db *sql.DB
+}
+
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
+
+// CreateAccount opens a business account with the given user as its first admin
+func (s *Service) CreateAccount(req *CreateAccountRequest) (*Account, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if req.PaymentTermsDays == 0 {
+		req.PaymentTermsDays = 30
+	}
+
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM business_account_members WHERE user_id = $1)`, req.OwnerUserID.String()).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check membership: %w", err)
+	}
+	if exists {
+		return nil, ErrAlreadyMember
+	}
+
+	var idStr string
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO business_accounts (name, tpin, billing_email, billing_phone, credit_limit, payment_terms_days)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), $5, $6)
+		RETURNING id
+	`, req.Name, req.TPIN, req.BillingEmail, req.BillingPhone, req.CreditLimit, req.PaymentTermsDays).Scan(&idStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create business account: %w", err)
+	}
+
+	accountID, _ := uuid.Parse(idStr)
+	if err := s.addMember(ctx, accountID, req.OwnerUserID, MemberRoleAdmin); err != nil {
+		return nil, err
+	}
+
+	return s.GetAccount(accountID)
+}
+
+// GetAccount returns an account with its current outstanding balance and available credit
+func (s *Service) GetAccount(accountID uuid.UUID) (*Account, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT id, name, COALESCE(tpin, ''), COALESCE(billing_email, ''), COALESCE(billing_phone, ''),
+			credit_limit, payment_terms_days, status, created_at, updated_at
+		FROM business_accounts
+		WHERE id = $1
+	`
+
+	a, err := scanAccount(s.db.QueryRowContext(ctx, query, accountID.String()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAccountNotFound
+		}
+		return nil, fmt.Errorf("failed to get business account: %w", err)
+	}
+
+	if err := s.db.QueryRowContext(ctx, outstandingQuery, accountID.String()).Scan(&a.Outstanding); err != nil {
+		return nil, fmt.Errorf("failed to get outstanding balance: %w", err)
+	}
+	a.AvailableCredit = a.CreditLimit - a.Outstanding
+
+	return a, nil
+}
+
+// ListAccounts returns all business accounts for the admin dashboard
+func (s *Service) ListAccounts(limit, offset int) ([]Account, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT a.id, a.name, COALESCE(a.tpin, ''), COALESCE(a.billing_email, ''), COALESCE(a.billing_phone, ''),
+			a.credit_limit, a.payment_terms_days, a.status, a.created_at, a.updated_at,
+			COALESCE((
+				SELECT SUM(o.grand_total) FROM orders o
+				WHERE o.business_account_id = a.id AND ` + unsettledOrders + `
+			), 0)
+		FROM business_accounts a
+		ORDER BY a.name
+		LIMIT $1 OFFSET $2
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list business accounts: %w", err)
+	}
+	defer rows.Close()
+
+	accounts := []Account{}
+	for rows.Next() {
+		var a Account
+		var idStr string
+		if err := rows.Scan(&idStr, &a.Name, &a.TPIN, &a.BillingEmail, &a.BillingPhone,
+			&a.CreditLimit, &a.PaymentTermsDays, &a.Status, &a.CreatedAt, &a.UpdatedAt, &a.Outstanding); err != nil {
+			return nil, fmt.Errorf("failed to scan business account: %w", err)
+		}
+		a.ID, _ = uuid.Parse(idStr)
+		a.AvailableCredit = a.CreditLimit - a.Outstanding
+		accounts = append(accounts, a)
+	}
+
+	return accounts, rows.Err()
+}
+
+// UpdateTerms changes an account's credit limit, payment terms or status
+func (s *Service) UpdateTerms(accountID uuid.UUID, req *UpdateTermsRequest) (*Account, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var sets []string
+	var args []interface{}
+	if req.CreditLimit != nil {
+		args = append(args, *req.CreditLimit)
+		sets = append(sets, fmt.Sprintf("credit_limit = $%d", len(args)))
+	}
+	if req.PaymentTermsDays != nil {
+		args = append(args, *req.PaymentTermsDays)
+		sets = append(sets, fmt.Sprintf("payment_terms_days = $%d", len(args)))
+	}
+	if req.Status != nil {
+		args = append(args, *req.Status)
+		sets = append(sets, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if len(sets) == 0 {
+		return s.GetAccount(accountID)
+	}
+
+	args = append(args, accountID.String())
+	query := fmt.Sprintf(`UPDATE business_accounts SET %s WHERE id = $%d`, strings.Join(sets, ", "), len(args))
+
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update business account: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return nil, ErrAccountNotFound
+	}
+
+	return s.GetAccount(accountID)
+}
+
+// GetMembership returns the account the user orders for and their role on it
+func (s *Service) GetMembership(userID uuid.UUID) (uuid.UUID, MemberRole, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var accountIDStr string
+	var role MemberRole
+	err := s.db.QueryRowContext(ctx,
+		`SELECT account_id, role FROM business_account_members WHERE user_id = $1`,
+		userID.String(),
+	).Scan(&accountIDStr, &role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return uuid.Nil, "", ErrNotMember
+		}
+		return uuid.Nil, "", fmt.Errorf("failed to get membership: %w", err)
+	}
+
+	accountID, _ := uuid.Parse(accountIDStr)
+	return accountID, role, nil
+}
+
+// requireAdmin returns the account the user administers
+func (s *Service) requireAdmin(userID uuid.UUID) (uuid.UUID, error) {
+	accountID, role, err := s.GetMembership(userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if role != MemberRoleAdmin {
+		return uuid.Nil, ErrNotAccountAdmin
+	}
+	return accountID, nil
+}
+
+// ListMembers returns the users authorised on an account
+func (s *Service) ListMembers(accountID uuid.UUID) ([]Member, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT m.account_id, m.user_id, u.name, u.phone_number, m.role, m.created_at
+		FROM business_account_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.account_id = $1
+		ORDER BY m.created_at
+	`, accountID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []Member{}
+	for rows.Next() {
+		var m Member
+		var accountIDStr, userIDStr string
+		if err := rows.Scan(&accountIDStr, &userIDStr, &m.Name, &m.Phone, &m.Role, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan member: %w", err)
+		}
+		m.AccountID, _ = uuid.Parse(accountIDStr)
+		m.UserID, _ = uuid.Parse(userIDStr)
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+// AddMember authorises another customer, looked up by phone number, to order on the admin's account
+func (s *Service) AddMember(adminID uuid.UUID, phoneNumber string, role MemberRole) ([]Member, error) {
+	accountID, err := s.requireAdmin(adminID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var userIDStr string
+	err = s.db.QueryRowContext(ctx,
+		`SELECT id FROM users WHERE phone_number = $1 AND user_type = 'customer'`,
+		phoneNumber,
+	).Scan(&userIDStr)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no customer found with phone number %s", phoneNumber)
+		}
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	userID, _ := uuid.Parse(userIDStr)
+	if err := s.addMember(ctx, accountID, userID, role); err != nil {
+		return nil, err
+	}
+
+	return s.ListMembers(accountID)
+}
+
+func (s *Service) addMember(ctx context.Context, accountID, userID uuid.UUID, role MemberRole) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO business_account_members (account_id, user_id, role) VALUES ($1, $2, $3)`,
+		accountID.String(), userID.String(), role,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return ErrAlreadyMember
+		}
+		return fmt.Errorf("failed to add member: %w", err)
+	}
+	return nil
+}
+
+// RemoveMember revokes a user's authorisation on the admin's account
+func (s *Service) RemoveMember(adminID, userID uuid.UUID) error {
+	accountID, err := s.requireAdmin(adminID)
+	if err != nil {
+		return err
+	}
+	if adminID == userID {
+		return errors.New("admins cannot remove themselves")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx,
+		`DELETE FROM business_account_members WHERE account_id = $1 AND user_id = $2`,
+		accountID.String(), userID.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrNotMember
+	}
+
+	return nil
+}
+
+// ListSites returns an account's delivery sites
+func (s *Service) ListSites(accountID uuid.UUID) ([]Site, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, account_id, name, address, latitude, longitude,
+			COALESCE(contact_name, ''), COALESCE(contact_phone, ''), created_at, updated_at
+		FROM business_sites
+		WHERE account_id = $1
+		ORDER BY name
+	`, accountID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sites: %w", err)
+	}
+	defer rows.Close()
+
+	sites := []Site{}
+	for rows.Next() {
+		site, err := scanSite(rows)
+		if err != nil {
+			return nil, err
+		}
+		sites = append(sites, *site)
+	}
+
+	return sites, rows.Err()
+}
+
+// AddSite registers a delivery site on the admin's account
+func (s *Service) AddSite(adminID uuid.UUID, site *Site) (*Site, error) {
+	accountID, err := s.requireAdmin(adminID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	site.AccountID = accountID
+	var idStr string
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO business_sites (account_id, name, address, latitude, longitude, contact_name, contact_phone)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''))
+		RETURNING id, created_at, updated_at
+	`, accountID.String(), site.Name, site.Address, site.Latitude, site.Longitude,
+		site.ContactName, site.ContactPhone,
+	).Scan(&idStr, &site.CreatedAt, &site.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add site: %w", err)
+	}
+	site.ID, _ = uuid.Parse(idStr)
+
+	return site, nil
+}
+
+// DeleteSite removes a delivery site from the admin's account. Past orders keep their address.
+func (s *Service) DeleteSite(adminID, siteID uuid.UUID) error {
+	accountID, err := s.requireAdmin(adminID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx,
+		`DELETE FROM business_sites WHERE id = $1 AND account_id = $2`,
+		siteID.String(), accountID.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete site: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrSiteNotFound
+	}
+
+	return nil
+}
+
+// AuthorizeOnAccountOrder checks that the user may place an on-account order of the given
+// amount and calls save with the account to bill and, when siteID is set, the site to
+// deliver to. The account stays locked until save returns, and save writes the order in
+// the same transaction, so concurrent orders can't both fit in the same credit.
+func (s *Service) AuthorizeOnAccountOrder(userID uuid.UUID, siteID *uuid.UUID, amount float64, save func(tx *sql.Tx, account *Account, site *Site) error) error {
+	accountID, _, err := s.GetMembership(userID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	account, err := scanAccount(tx.QueryRowContext(ctx, `
+		SELECT id, name, COALESCE(tpin, ''), COALESCE(billing_email, ''), COALESCE(billing_phone, ''),
+			credit_limit, payment_terms_days, status, created_at, updated_at
+		FROM business_accounts
+		WHERE id = $1
+		FOR UPDATE
+	`, accountID.String()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrAccountNotFound
+		}
+		return fmt.Errorf("failed to get business account: %w", err)
+	}
+	if err := tx.QueryRowContext(ctx, outstandingQuery, accountID.String()).Scan(&account.Outstanding); err != nil {
+		return fmt.Errorf("failed to get outstanding balance: %w", err)
+	}
+	account.AvailableCredit = account.CreditLimit - account.Outstanding
+
+	if account.Status != AccountStatusActive {
+		return ErrAccountSuspended
+	}
+	if account.Outstanding+amount > account.CreditLimit {
+		return fmt.Errorf("%w: K%.2f available, order total K%.2f", ErrCreditLimitExceeded, account.AvailableCredit, amount)
+	}
+
+	var site *Site
+	if siteID != nil {
+		site, err = scanSite(tx.QueryRowContext(ctx, `
+			SELECT id, account_id, name, address, latitude, longitude,
+				COALESCE(contact_name, ''), COALESCE(contact_phone, ''), created_at, updated_at
+			FROM business_sites
+			WHERE id = $1 AND account_id = $2
+		`, siteID.String(), accountID.String()))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrSiteNotFound
+			}
+			return err
+		}
+	}
+
+	if err := save(tx, account, site); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit on-account order: %w", err)
+	}
+	return nil
+}
+
+// GenerateStatement builds or refreshes the statement for the calendar month containing period.
+// Paid statements are left untouched.
+func (s *Service) GenerateStatement(accountID uuid.UUID, period time.Time) (*Statement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	start, end := statementPeriod(period)
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO business_statements (account_id, period_start, period_end, order_count, total_amount, due_date)
+		SELECT a.id, $2::date, $3::date - 1, COUNT(o.id), COALESCE(SUM(o.grand_total), 0),
+			$3::date - 1 + a.payment_terms_days
+		FROM business_accounts a
+		LEFT JOIN orders o ON o.business_account_id = a.id AND o.payment_method = 'on_account'
+			AND o.status <> 'rejected' AND o.created_at >= $2 AND o.created_at < $3
+		WHERE a.id = $1
+		GROUP BY a.id, a.payment_terms_days
+		ON CONFLICT (account_id, period_start) DO UPDATE
+		SET order_count = EXCLUDED.order_count, total_amount = EXCLUDED.total_amount, due_date = EXCLUDED.due_date
+		WHERE business_statements.status = 'open'
+	`, accountID.String(), start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate statement: %w", err)
+	}
+
+	var idStr string
+	err = s.db.QueryRowContext(ctx,
+		`SELECT id FROM business_statements WHERE account_id = $1 AND period_start = $2`,
+		accountID.String(), start,
+	).Scan(&idStr)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAccountNotFound
+		}
+		return nil, fmt.Errorf("failed to get statement: %w", err)
+	}
+
+	statementID, _ := uuid.Parse(idStr)
+	return s.GetStatement(statementID)
+}
+
+// GenerateMonthlyStatements builds the statement for the given month for every active account
+func (s *Service) GenerateMonthlyStatements(period time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id FROM business_accounts WHERE status = 'active'`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list business accounts: %w", err)
+	}
+
+	var accountIDs []uuid.UUID
+	for rows.Next() {
+		var idStr string
+		if err := rows.Scan(&idStr); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan business account: %w", err)
+		}
+		id, _ := uuid.Parse(idStr)
+		accountIDs = append(accountIDs, id)
+	}
+	rows.Close()
+
+	for i, id := range accountIDs {
+		if _, err := s.GenerateStatement(id, period); err != nil {
+			return i, fmt.Errorf("statement for account %s: %w", id, err)
+		}
+	}
+
+	return len(accountIDs), nil
+}
+
+// ListStatements returns an account's statements, newest first
+func (s *Service) ListStatements(accountID uuid.UUID) ([]Statement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, account_id, period_start, period_end, order_count, total_amount, due_date, status, paid_at, created_at
+		FROM business_statements
+		WHERE account_id = $1
+		ORDER BY period_start DESC
+	`, accountID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list statements: %w", err)
+	}
+	defer rows.Close()
+
+	statements := []Statement{}
+	for rows.Next() {
+		st, err := scanStatement(rows)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, *st)
+	}
+
+	return statements, rows.Err()
+}
+
+// GetStatement returns a statement with one line per order it covers
+func (s *Service) GetStatement(statementID uuid.UUID) (*Statement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	st, err := scanStatement(s.db.QueryRowContext(ctx, `
+		SELECT id, account_id, period_start, period_end, order_count, total_amount, due_date, status, paid_at, created_at
+		FROM business_statements
+		WHERE id = $1
+	`, statementID.String()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrStatementNotFound
+		}
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT o.id, u.name, COALESCE(bs.name, ''), o.cylinder_type, o.quantity, o.grand_total, o.status, o.created_at
+		FROM orders o
+		JOIN users u ON u.id = o.user_id
+		LEFT JOIN business_sites bs ON bs.id = o.delivery_site_id
+		WHERE o.business_account_id = $1 AND o.payment_method = 'on_account' AND o.status <> 'rejected'
+			AND o.created_at >= $2 AND o.created_at < $3
+		ORDER BY o.created_at
+	`, st.AccountID.String(), st.PeriodStart, st.PeriodEnd.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statement lines: %w", err)
+	}
+	defer rows.Close()
+
+	st.Lines = []StatementLine{}
+	for rows.Next() {
+		var line StatementLine
+		var orderIDStr string
+		if err := rows.Scan(&orderIDStr, &line.OrderedBy, &line.SiteName, &line.CylinderType,
+			&line.Quantity, &line.GrandTotal, &line.Status, &line.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan statement line: %w", err)
+		}
+		line.OrderID, _ = uuid.Parse(orderIDStr)
+		st.Lines = append(st.Lines, line)
+	}
+
+	return st, rows.Err()
+}
+
+// MarkStatementPaid settles a statement and every order on it, freeing up the account's credit
+func (s *Service) MarkStatementPaid(statementID uuid.UUID) (*Statement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var accountIDStr string
+	var start, end time.Time
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE business_statements SET status = 'paid', paid_at = $1
+		WHERE id = $2 AND status = 'open'
+		RETURNING account_id, period_start, period_end
+	`, time.Now(), statementID.String()).Scan(&accountIDStr, &start, &end)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrStatementNotFound
+		}
+		return nil, fmt.Errorf("failed to mark statement paid: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE orders SET payment_status = 'paid', updated_at = $1
+		WHERE business_account_id = $2 AND payment_method = 'on_account' AND status <> 'rejected'
+			AND created_at >= $3 AND created_at < $4
+	`, time.Now(), accountIDStr, start, end.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to settle statement orders: %w", err)
+	}
+
+	return s.GetStatement(statementID)
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAccount(row rowScanner) (*Account, error) {
+	var a Account
+	var idStr string
+	if err := row.Scan(&idStr, &a.Name, &a.TPIN, &a.BillingEmail, &a.BillingPhone,
+		&a.CreditLimit, &a.PaymentTermsDays, &a.Status, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		return nil, err
+	}
+	a.ID, _ = uuid.Parse(idStr)
+	return &a, nil
+}
+
+func scanSite(row rowScanner) (*Site, error) {
+	var site Site
+	var idStr, accountIDStr string
+	var lat, lng sql.NullFloat64
+	if err := row.Scan(&idStr, &accountIDStr, &site.Name, &site.Address, &lat, &lng,
+		&site.ContactName, &site.ContactPhone, &site.CreatedAt, &site.UpdatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan site: %w", err)
+	}
+	site.ID, _ = uuid.Parse(idStr)
+	site.AccountID, _ = uuid.Parse(accountIDStr)
+	if lat.Valid && lng.Valid {
+		site.Latitude = &lat.Float64
+		site.Longitude = &lng.Float64
+	}
+	return &site, nil
+}
+
+func scanStatement(row rowScanner) (*Statement, error) {
+	var st Statement
+	var idStr, accountIDStr string
+	var paidAt sql.NullTime
+	if err := row.Scan(&idStr, &accountIDStr, &st.PeriodStart, &st.PeriodEnd, &st.OrderCount,
+		&st.TotalAmount, &st.DueDate, &st.Status, &paidAt, &st.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan statement: %w", err)
+	}
+	st.ID, _ = uuid.Parse(idStr)
+	st.AccountID, _ = uuid.Parse(accountIDStr)
+	if paidAt.Valid {
+		st.PaidAt = &paidAt.Time
+	}
+	return &st, nil
+}
@@ -0,0 +1,82 @@
+package cash
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EntryType string
+type HandedTo string
+type RemittanceStatus string
+
+const (
+	EntryTypeCollection EntryType = "collection"
+	EntryTypeRemittance EntryType = "remittance"
+)
+
+const (
+	HandedToProvider HandedTo = "provider"
+	HandedToPlatform HandedTo = "platform"
+)
+
+const (
+	RemittanceStatusPending   RemittanceStatus = "pending"
+	RemittanceStatusConfirmed RemittanceStatus = "confirmed"
+	RemittanceStatusDisputed  RemittanceStatus = "disputed"
+)
+
+// LedgerEntry is one movement of a courier's cash float. Collections are positive,
+// confirmed remittances negative.
+type LedgerEntry struct {
+	ID           uuid.UUID  `json:"id"`
+	CourierID    uuid.UUID  `json:"courier_id"`
+	EntryType    EntryType  `json:"entry_type"`
+	OrderID      *uuid.UUID `json:"order_id,omitempty"`
+	RemittanceID *uuid.UUID `json:"remittance_id,omitempty"`
+	Amount       float64    `json:"amount"`
+	AmountDue    *float64   `json:"amount_due,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// Remittance is cash a courier hands over to a provider or the platform.
+// It only leaves the courier's float once the recipient confirms it.
+type Remittance struct {
+	ID          uuid.UUID        `json:"id"`
+	CourierID   uuid.UUID        `json:"courier_id"`
+	CourierName string           `json:"courier_name,omitempty"`
+	Amount      float64          `json:"amount"`
+	HandedTo    HandedTo         `json:"handed_to"`
+	RecipientID *uuid.UUID       `json:"recipient_id,omitempty"`
+	Reference   string           `json:"reference,omitempty"`
+	Status      RemittanceStatus `json:"status"`
+	ResolvedAt  *time.Time       `json:"resolved_at,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
+
+type RemittanceRequest struct {
+	Amount      float64    `json:"amount" binding:"required,gt=0"`
+	HandedTo    HandedTo   `json:"handed_to" binding:"required,oneof=provider platform"`
+	RecipientID *uuid.UUID `json:"recipient_id"`
+	Reference   string     `json:"reference"`
+}
+
+// CourierFloat is the cash a courier currently holds
+type CourierFloat struct {
+	CourierID          uuid.UUID     `json:"courier_id"`
+	Balance            float64       `json:"balance"`
+	CollectedToday     float64       `json:"collected_today"`
+	PendingRemittances float64       `json:"pending_remittances"`
+	Entries            []LedgerEntry `json:"entries"`
+}
+
+// CourierCashSummary is one row of the admin outstanding cash report
+type CourierCashSummary struct {
+	CourierID          uuid.UUID  `json:"courier_id"`
+	Name               string     `json:"name"`
+	Phone              string     `json:"phone"`
+	Balance            float64    `json:"balance"`
+	CollectedToday     float64    `json:"collected_today"`
+	PendingRemittances float64    `json:"pending_remittances"`
+	LastRemittanceAt   *time.Time `json:"last_remittance_at,omitempty"`
+}
@@ -0,0 +1,392 @@
+package cash
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrOrderNotFound        = errors.New("order not found")
+	ErrInvalidAmount        = errors.New("amount cannot be negative")
+	ErrOrderNotCollectable  = errors.New("cash cannot be collected for this order yet")
+	ErrNotCashOrder         = errors.New("order is not cash on delivery")
+	ErrNotAssignedCourier   = errors.New("order is not assigned to this courier")
+	ErrAlreadyCollected     = errors.New("cash has already been recorded for this order")
+	ErrInsufficientFloat    = errors.New("remittance exceeds the cash held")
+	ErrInvalidRecipient     = errors.New("invalid remittance recipient")
+	ErrRemittanceNotPending = errors.New("remittance is no longer pending")
+)
+
+type Service struct {
+	db *sql.DB
+}
+
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
+
+// RecordCollection records the cash a courier collected at delivery. The order is marked
+// paid when the full amount was collected; a short collection is kept for reconciliation.
+// The entry and the order's payment status are saved together, so a failure leaves
+// nothing recorded and the collection can be retried.
+func (s *Service) RecordCollection(courierID, orderID uuid.UUID, amount float64) (*LedgerEntry, error) {
+	if amount < 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var paymentMethod, status string
+	var assignedCourier sql.NullString
+	var grandTotal float64
+	err = tx.QueryRowContext(ctx,
+		`SELECT payment_method, status, courier_id, grand_total FROM orders WHERE id = $1 FOR UPDATE`,
+		orderID.String(),
+	).Scan(&paymentMethod, &status, &assignedCourier, &grandTotal)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	if paymentMethod != "cash" {
+		return nil, ErrNotCashOrder
+	}
+	if !assignedCourier.Valid || assignedCourier.String != courierID.String() {
+		return nil, ErrNotAssignedCourier
+	}
+	if status == "rejected" || status == "pending" {
+		return nil, fmt.Errorf("%w: order is %s", ErrOrderNotCollectable, status)
+	}
+
+	entry := &LedgerEntry{
+		CourierID: courierID,
+		EntryType: EntryTypeCollection,
+		OrderID:   &orderID,
+		Amount:    amount,
+		AmountDue: &grandTotal,
+	}
+
+	var idStr string
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO courier_cash_ledger (courier_id, entry_type, order_id, amount, amount_due)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, courierID.String(), EntryTypeCollection, orderID.String(), amount, grandTotal).Scan(&idStr, &entry.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, ErrAlreadyCollected
+		}
+		return nil, fmt.Errorf("failed to record collection: %w", err)
+	}
+	entry.ID, _ = uuid.Parse(idStr)
+
+	if amount >= grandTotal {
+		_, err = tx.ExecContext(ctx,
+			`UPDATE orders SET payment_status = 'paid', updated_at = $1 WHERE id = $2`,
+			time.Now(), orderID.String(),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to mark order paid: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit collection: %w", err)
+	}
+	return entry, nil
+}
+
+// GetFloat returns the cash a courier holds and their most recent ledger entries
+func (s *Service) GetFloat(courierID uuid.UUID, limit, offset int) (*CourierFloat, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	f := &CourierFloat{CourierID: courierID}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(SUM(amount), 0),
+			COALESCE(SUM(amount) FILTER (WHERE entry_type = 'collection' AND created_at >= date_trunc('day', CURRENT_TIMESTAMP)), 0),
+			COALESCE((SELECT SUM(r.amount) FROM cash_remittances r WHERE r.courier_id = $1 AND r.status = 'pending'), 0)
+		FROM courier_cash_ledger
+		WHERE courier_id = $1
+	`, courierID.String()).Scan(&f.Balance, &f.CollectedToday, &f.PendingRemittances)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cash float: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, courier_id, entry_type, order_id, remittance_id, amount, amount_due, created_at
+		FROM courier_cash_ledger
+		WHERE courier_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, courierID.String(), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ledger: %w", err)
+	}
+	defer rows.Close()
+
+	f.Entries = []LedgerEntry{}
+	for rows.Next() {
+		var e LedgerEntry
+		var idStr, courierIDStr string
+		var orderIDStr, remittanceIDStr sql.NullString
+		var amountDue sql.NullFloat64
+		if err := rows.Scan(&idStr, &courierIDStr, &e.EntryType, &orderIDStr, &remittanceIDStr,
+			&e.Amount, &amountDue, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan ledger entry: %w", err)
+		}
+		e.ID, _ = uuid.Parse(idStr)
+		e.CourierID, _ = uuid.Parse(courierIDStr)
+		if orderIDStr.Valid {
+			parsed, _ := uuid.Parse(orderIDStr.String)
+			e.OrderID = &parsed
+		}
+		if remittanceIDStr.Valid {
+			parsed, _ := uuid.Parse(remittanceIDStr.String)
+			e.RemittanceID = &parsed
+		}
+		if amountDue.Valid {
+			e.AmountDue = &amountDue.Float64
+		}
+		f.Entries = append(f.Entries, e)
+	}
+
+	return f, rows.Err()
+}
+
+// CreateRemittance records a handover the courier is making. Remittances awaiting
+// confirmation count against the float so the same cash cannot be handed over twice.
+func (s *Service) CreateRemittance(courierID uuid.UUID, req *RemittanceRequest) (*Remittance, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	switch req.HandedTo {
+	case HandedToProvider:
+		if req.RecipientID == nil {
+			return nil, fmt.Errorf("%w: provider handovers need a recipient_id", ErrInvalidRecipient)
+		}
+		var isProvider bool
+		err := s.db.QueryRowContext(ctx,
+			`SELECT EXISTS(SELECT 1 FROM users WHERE id = $1 AND user_type = 'provider')`,
+			req.RecipientID.String(),
+		).Scan(&isProvider)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check recipient: %w", err)
+		}
+		if !isProvider {
+			return nil, fmt.Errorf("%w: recipient is not a provider", ErrInvalidRecipient)
+		}
+	case HandedToPlatform:
+		req.RecipientID = nil
+	default:
+		return nil, ErrInvalidRecipient
+	}
+
+	f, err := s.GetFloat(courierID, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if req.Amount > f.Balance-f.PendingRemittances {
+		return nil, fmt.Errorf("%w: K%.2f available", ErrInsufficientFloat, f.Balance-f.PendingRemittances)
+	}
+
+	var recipientIDStr sql.NullString
+	if req.RecipientID != nil {
+		recipientIDStr = sql.NullString{String: req.RecipientID.String(), Valid: true}
+	}
+
+	r := &Remittance{
+		CourierID:   courierID,
+		Amount:      req.Amount,
+		HandedTo:    req.HandedTo,
+		RecipientID: req.RecipientID,
+		Reference:   req.Reference,
+		Status:      RemittanceStatusPending,
+	}
+
+	var idStr string
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO cash_remittances (courier_id, amount, handed_to, recipient_id, reference)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+		RETURNING id, created_at
+	`, courierID.String(), req.Amount, req.HandedTo, recipientIDStr, req.Reference).Scan(&idStr, &r.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create remittance: %w", err)
+	}
+	r.ID, _ = uuid.Parse(idStr)
+
+	return r, nil
+}
+
+// ListRemittances returns remittances filtered by courier, recipient and status; nil filters match all
+func (s *Service) ListRemittances(courierID, recipientID *uuid.UUID, status RemittanceStatus, limit, offset int) ([]Remittance, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conditions := []string{"1 = 1"}
+	var args []interface{}
+	if courierID != nil {
+		args = append(args, courierID.String())
+		conditions = append(conditions, fmt.Sprintf("r.courier_id = $%d", len(args)))
+	}
+	if recipientID != nil {
+		args = append(args, recipientID.String())
+		conditions = append(conditions, fmt.Sprintf("r.recipient_id = $%d", len(args)))
+	}
+	if status != "" {
+		args = append(args, status)
+		conditions = append(conditions, fmt.Sprintf("r.status = $%d", len(args)))
+	}
+	args = append(args, limit, offset)
+
+	query := fmt.Sprintf(`
+		SELECT r.id, r.courier_id, u.name, r.amount, r.handed_to, r.recipient_id,
+			COALESCE(r.reference, ''), r.status, r.resolved_at, r.created_at
+		FROM cash_remittances r
+		JOIN users u ON u.id = r.courier_id
+		WHERE %s
+		ORDER BY r.created_at DESC
+		LIMIT $%d OFFSET $%d
+	`, strings.Join(conditions, " AND "), len(args)-1, len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remittances: %w", err)
+	}
+	defer rows.Close()
+
+	remittances := []Remittance{}
+	for rows.Next() {
+		var r Remittance
+		var idStr, courierIDStr string
+		var recipientIDStr sql.NullString
+		var resolvedAt sql.NullTime
+		if err := rows.Scan(&idStr, &courierIDStr, &r.CourierName, &r.Amount, &r.HandedTo, &recipientIDStr,
+			&r.Reference, &r.Status, &resolvedAt, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan remittance: %w", err)
+		}
+		r.ID, _ = uuid.Parse(idStr)
+		r.CourierID, _ = uuid.Parse(courierIDStr)
+		if recipientIDStr.Valid {
+			parsed, _ := uuid.Parse(recipientIDStr.String)
+			r.RecipientID = &parsed
+		}
+		if resolvedAt.Valid {
+			r.ResolvedAt = &resolvedAt.Time
+		}
+		remittances = append(remittances, r)
+	}
+
+	return remittances, rows.Err()
+}
+
+// ConfirmRemittance acknowledges receipt of a handover and draws it down from the courier's float.
+// Providers may only confirm handovers made to them; a nil providerID confirms as the platform.
+func (s *Service) ConfirmRemittance(remittanceID uuid.UUID, providerID *uuid.UUID) error {
+	return s.resolveRemittance(remittanceID, providerID, RemittanceStatusConfirmed)
+}
+
+// DisputeRemittance flags a handover the recipient says they did not receive in full
+func (s *Service) DisputeRemittance(remittanceID uuid.UUID, providerID *uuid.UUID) error {
+	return s.resolveRemittance(remittanceID, providerID, RemittanceStatusDisputed)
+}
+
+func (s *Service) resolveRemittance(remittanceID uuid.UUID, providerID *uuid.UUID, status RemittanceStatus) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE cash_remittances SET status = $1, resolved_at = $2
+		WHERE id = $3 AND status = 'pending' AND handed_to = 'platform'
+		RETURNING courier_id, amount
+	`
+	args := []interface{}{status, time.Now(), remittanceID.String()}
+	if providerID != nil {
+		query = `
+			UPDATE cash_remittances SET status = $1, resolved_at = $2
+			WHERE id = $3 AND status = 'pending' AND handed_to = 'provider' AND recipient_id = $4
+			RETURNING courier_id, amount
+		`
+		args = append(args, providerID.String())
+	}
+
+	var courierIDStr string
+	var amount float64
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&courierIDStr, &amount); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrRemittanceNotPending
+		}
+		return fmt.Errorf("failed to update remittance: %w", err)
+	}
+
+	if status != RemittanceStatusConfirmed {
+		return nil
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO courier_cash_ledger (courier_id, entry_type, remittance_id, amount)
+		VALUES ($1, $2, $3, $4)
+	`, courierIDStr, EntryTypeRemittance, remittanceID.String(), -amount)
+	if err != nil {
+		return fmt.Errorf("failed to record remittance in ledger: %w", err)
+	}
+
+	return nil
+}
+
+// OutstandingReport lists every courier holding cash, largest float first
+func (s *Service) OutstandingReport() ([]CourierCashSummary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT u.id, u.name, u.phone_number,
+			SUM(l.amount),
+			COALESCE(SUM(l.amount) FILTER (WHERE l.entry_type = 'collection' AND l.created_at >= date_trunc('day', CURRENT_TIMESTAMP)), 0),
+			COALESCE((SELECT SUM(r.amount) FROM cash_remittances r WHERE r.courier_id = u.id AND r.status = 'pending'), 0),
+			MAX(l.created_at) FILTER (WHERE l.entry_type = 'remittance')
+		FROM courier_cash_ledger l
+		JOIN users u ON u.id = l.courier_id
+		GROUP BY u.id, u.name, u.phone_number
+		HAVING SUM(l.amount) <> 0
+		ORDER BY SUM(l.amount) DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get outstanding cash: %w", err)
+	}
+	defer rows.Close()
+
+	report := []CourierCashSummary{}
+	for rows.Next() {
+		var row CourierCashSummary
+		var idStr string
+		var lastRemittance sql.NullTime
+		if err := rows.Scan(&idStr, &row.Name, &row.Phone, &row.Balance, &row.CollectedToday,
+			&row.PendingRemittances, &lastRemittance); err != nil {
+			return nil, fmt.Errorf("failed to scan cash summary: %w", err)
+		}
+		row.CourierID, _ = uuid.Parse(idStr)
+		if lastRemittance.Valid {
+			row.LastRemittanceAt = &lastRemittance.Time
+		}
+		report = append(report, row)
+	}
+
+	return report, rows.Err()
+}
@@ -0,0 +1,149 @@
+package cash
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yakumwamba/lpg-delivery-system/pkg/database"
+)
+
+// setupTestDB connects to the database in TEST_DATABASE_URL and creates the schema
+func setupTestDB(t *testing.T) *sql.DB {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	pool, err := database.ConnectPostgres(dbURL)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	require.NoError(t, database.InitPostgresSchema(pool))
+
+	return database.GetStdDB(pool)
+}
+
+// createUser adds a user of the given type and removes it, with its orders, when the
+// test ends
+func createUser(t *testing.T, db *sql.DB, userType string) uuid.UUID {
+	id := uuid.New()
+	_, err := db.Exec(`
+		INSERT INTO users (id, password, name, phone_number, user_type) VALUES ($1, 'x', $2, $3, $4)
+	`, id.String(), "Test "+userType, fmt.Sprintf("+2609%s", id.String()[:8]), userType)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Exec(`DELETE FROM users WHERE id = $1`, id.String()) })
+	return id
+}
+
+// createOrder adds a K332.50 order assigned to the courier
+func createOrder(t *testing.T, db *sql.DB, customerID, providerID, courierID uuid.UUID, status, paymentMethod string) uuid.UUID {
+	id := uuid.New()
+	_, err := db.Exec(`
+		INSERT INTO orders (
+			id, user_id, provider_id, courier_id, status, cylinder_type, quantity, price_per_unit,
+			total_price, delivery_fee, service_charge, grand_total, delivery_address, delivery_method,
+			payment_method, payment_status
+		) VALUES ($1, $2, $3, $4, $5, '6KG', 1, 300, 300, 25, 7.50, 332.50, 'Plot 1, Kabulonga', 'delivery',
+			$6, 'pending')
+	`, id.String(), customerID.String(), providerID.String(), courierID.String(), status, paymentMethod)
+	require.NoError(t, err)
+	return id
+}
+
+func paymentStatus(t *testing.T, db *sql.DB, orderID uuid.UUID) string {
+	var status string
+	require.NoError(t, db.QueryRow(`SELECT payment_status FROM orders WHERE id = $1`, orderID.String()).Scan(&status))
+	return status
+}
+
+func TestRecordCollection(t *testing.T) {
+	db := setupTestDB(t)
+	s := NewService(db)
+
+	customerID := createUser(t, db, "customer")
+	providerID := createUser(t, db, "provider")
+	courierID := createUser(t, db, "courier")
+
+	t.Run("not a cash order", func(t *testing.T) {
+		orderID := createOrder(t, db, customerID, providerID, courierID, "delivered", "mobile_money")
+		_, err := s.RecordCollection(courierID, orderID, 332.50)
+		assert.ErrorIs(t, err, ErrNotCashOrder)
+	})
+
+	t.Run("another courier", func(t *testing.T) {
+		orderID := createOrder(t, db, customerID, providerID, courierID, "delivered", "cash")
+		_, err := s.RecordCollection(uuid.New(), orderID, 332.50)
+		assert.ErrorIs(t, err, ErrNotAssignedCourier)
+	})
+
+	t.Run("pending order", func(t *testing.T) {
+		orderID := createOrder(t, db, customerID, providerID, courierID, "pending", "cash")
+		_, err := s.RecordCollection(courierID, orderID, 332.50)
+		assert.ErrorIs(t, err, ErrOrderNotCollectable)
+		assert.Equal(t, "pending", paymentStatus(t, db, orderID))
+	})
+
+	t.Run("negative amount", func(t *testing.T) {
+		orderID := createOrder(t, db, customerID, providerID, courierID, "delivered", "cash")
+		_, err := s.RecordCollection(courierID, orderID, -1)
+		assert.ErrorIs(t, err, ErrInvalidAmount)
+	})
+
+	t.Run("full amount marks the order paid", func(t *testing.T) {
+		orderID := createOrder(t, db, customerID, providerID, courierID, "delivered", "cash")
+		entry, err := s.RecordCollection(courierID, orderID, 332.50)
+		require.NoError(t, err)
+		assert.Equal(t, EntryTypeCollection, entry.EntryType)
+		require.NotNil(t, entry.AmountDue)
+		assert.InDelta(t, 332.50, *entry.AmountDue, 0.001)
+		assert.Equal(t, "paid", paymentStatus(t, db, orderID))
+
+		_, err = s.RecordCollection(courierID, orderID, 332.50)
+		assert.ErrorIs(t, err, ErrAlreadyCollected)
+	})
+
+	t.Run("short amount leaves the order unpaid", func(t *testing.T) {
+		orderID := createOrder(t, db, customerID, providerID, courierID, "in-transit", "cash")
+		_, err := s.RecordCollection(courierID, orderID, 300)
+		require.NoError(t, err)
+		assert.Equal(t, "pending", paymentStatus(t, db, orderID))
+	})
+}
+
+func TestCreateRemittance(t *testing.T) {
+	db := setupTestDB(t)
+	s := NewService(db)
+
+	customerID := createUser(t, db, "customer")
+	providerID := createUser(t, db, "provider")
+	courierID := createUser(t, db, "courier")
+
+	orderID := createOrder(t, db, customerID, providerID, courierID, "delivered", "cash")
+	_, err := s.RecordCollection(courierID, orderID, 332.50)
+	require.NoError(t, err)
+
+	f, err := s.GetFloat(courierID, 10, 0)
+	require.NoError(t, err)
+	assert.InDelta(t, 332.50, f.Balance, 0.001)
+	assert.Len(t, f.Entries, 1)
+
+	_, err = s.CreateRemittance(courierID, &RemittanceRequest{Amount: 100, HandedTo: HandedToProvider})
+	assert.ErrorIs(t, err, ErrInvalidRecipient)
+	_, err = s.CreateRemittance(courierID, &RemittanceRequest{Amount: 100, HandedTo: HandedToProvider, RecipientID: &customerID})
+	assert.ErrorIs(t, err, ErrInvalidRecipient)
+
+	r, err := s.CreateRemittance(courierID, &RemittanceRequest{Amount: 300, HandedTo: HandedToProvider, RecipientID: &providerID})
+	require.NoError(t, err)
+	assert.Equal(t, RemittanceStatusPending, r.Status)
+
+	// The pending handover still counts against the float
+	_, err = s.CreateRemittance(courierID, &RemittanceRequest{Amount: 50, HandedTo: HandedToPlatform})
+	assert.ErrorIs(t, err, ErrInsufficientFloat)
+}
@@ -0,0 +1,110 @@
+package chat
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ParticipantRole string
+
+const (
+	RoleCustomer ParticipantRole = "customer"
+	RoleProvider ParticipantRole = "provider"
+	RoleCourier  ParticipantRole = "courier"
+)
+
+// ClosureGracePeriod is how long an order chat stays open after the order is delivered
+const ClosureGracePeriod = 2 * time.Hour
+
+// MaxMessageLength caps the text of a single chat message
+const MaxMessageLength = 1000
+
+// Message is a single chat message within an order
+type Message struct {
+	ID            uuid.UUID       `json:"id"`
+	OrderID       uuid.UUID       `json:"order_id"`
+	SenderID      uuid.UUID       `json:"sender_id"`
+	SenderRole    ParticipantRole `json:"sender_role"`
+	Body          string          `json:"body,omitempty"`
+	TemplateKey   string          `json:"template_key,omitempty"`
+	AttachmentURL string          `json:"attachment_url,omitempty"`
+	ReadBy        []uuid.UUID     `json:"read_by"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// Participants are the users allowed to chat on an order
+type Participants struct {
+	OrderID     uuid.UUID
+	CustomerID  uuid.UUID
+	ProviderID  *uuid.UUID
+	CourierID   *uuid.UUID
+	OrderStatus string
+	UpdatedAt   time.Time
+}
+
+// QuickReply is a predefined message participants can send with one tap
+type QuickReply struct {
+	Key  string `json:"key"`
+	Text string `json:"text"`
+}
+
+var quickReplies = map[ParticipantRole][]QuickReply{
+	RoleCourier: {
+		{Key: "at_gate", Text: "I'm at the gate"},
+		{Key: "arriving_soon", Text: "I'll arrive in about 5 minutes"},
+		{Key: "cant_find_address", Text: "I can't find the address, please share a landmark"},
+		{Key: "picked_up", Text: "I've picked up your gas and I'm on my way"},
+	},
+	RoleCustomer: {
+		{Key: "coming_out", Text: "I'm coming out now"},
+		{Key: "gate_open", Text: "The gate is open, please come in"},
+		{Key: "call_on_arrival", Text: "Please message me when you arrive"},
+	},
+	RoleProvider: {
+		{Key: "preparing", Text: "Your order is being prepared"},
+		{Key: "ready_for_pickup", Text: "The order is ready for pickup"},
+	},
+}
+
+// QuickReplies returns the quick-reply templates available to a participant role
+func QuickReplies(role ParticipantRole) []QuickReply {
+	return quickReplies[role]
+}
+
+// RoleOf returns the role a user plays on the order, or false if they are not a participant
+func (p *Participants) RoleOf(userID uuid.UUID) (ParticipantRole, bool) {
+	switch {
+	case userID == p.CustomerID:
+		return RoleCustomer, true
+	case p.ProviderID != nil && userID == *p.ProviderID:
+		return RoleProvider, true
+	case p.CourierID != nil && userID == *p.CourierID:
+		return RoleCourier, true
+	}
+	return "", false
+}
+
+// UserIDs returns every participant of the order
+func (p *Participants) UserIDs() []uuid.UUID {
+	ids := []uuid.UUID{p.CustomerID}
+	if p.ProviderID != nil {
+		ids = append(ids, *p.ProviderID)
+	}
+	if p.CourierID != nil {
+		ids = append(ids, *p.CourierID)
+	}
+	return ids
+}
+
+// IsClosed reports whether the chat no longer accepts messages.
+// Chats close when an order is rejected, or once the grace period after delivery has passed.
+func (p *Participants) IsClosed(now time.Time) bool {
+	switch p.OrderStatus {
+	case "rejected":
+		return true
+	case "delivered":
+		return now.Sub(p.UpdatedAt) > ClosureGracePeriod
+	}
+	return false
+}
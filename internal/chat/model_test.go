@@ -0,0 +1,65 @@
+package chat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleOf(t *testing.T) {
+	customerID, providerID, courierID := uuid.New(), uuid.New(), uuid.New()
+	p := &Participants{CustomerID: customerID, ProviderID: &providerID, CourierID: &courierID}
+
+	tests := []struct {
+		name   string
+		userID uuid.UUID
+		role   ParticipantRole
+		ok     bool
+	}{
+		{"customer", customerID, RoleCustomer, true},
+		{"provider", providerID, RoleProvider, true},
+		{"courier", courierID, RoleCourier, true},
+		{"stranger", uuid.New(), "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role, ok := p.RoleOf(tt.userID)
+			assert.Equal(t, tt.role, role)
+			assert.Equal(t, tt.ok, ok)
+		})
+	}
+
+	// No courier assigned yet: nobody is let in as the courier
+	unassigned := &Participants{CustomerID: customerID, ProviderID: &providerID}
+	_, ok := unassigned.RoleOf(courierID)
+	assert.False(t, ok)
+	assert.Len(t, unassigned.UserIDs(), 2)
+}
+
+func TestIsClosed(t *testing.T) {
+	now := time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		status    string
+		updatedAt time.Time
+		closed    bool
+	}{
+		{"pending", "pending", now.Add(-48 * time.Hour), false},
+		{"in transit", "in-transit", now.Add(-5 * time.Hour), false},
+		{"rejected just now", "rejected", now, true},
+		{"delivered within the grace period", "delivered", now.Add(-ClosureGracePeriod + time.Minute), false},
+		{"delivered exactly at the grace period", "delivered", now.Add(-ClosureGracePeriod), false},
+		{"delivered after the grace period", "delivered", now.Add(-ClosureGracePeriod - time.Minute), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Participants{OrderStatus: tt.status, UpdatedAt: tt.updatedAt}
+			assert.Equal(t, tt.closed, p.IsClosed(now))
+		})
+	}
+}
@@ -0,0 +1,389 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotParticipant  = errors.New("user is not a participant in this order")
+	ErrChatClosed      = errors.New("chat for this order is closed")
+	ErrInvalidMessage  = errors.New("invalid message")
+	ErrMessageNotFound = errors.New("message not found")
+	ErrOrderNotFound   = errors.New("order not found")
+)
+
+// maxAttachmentSize is the largest image accepted in a chat (5MB)
+const maxAttachmentSize = 5 << 20
+
+var attachmentDirectory = filepath.Join("uploads", "chat")
+
+var allowedAttachmentTypes = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".webp": true,
+}
+
+// attachmentExtensions maps the content types sniffed from an upload to the extension it is stored under
+var attachmentExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+type Service struct {
+	db *sql.DB
+}
+
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
+
+// GetParticipants loads the customer, provider and courier of an order
+func (s *Service) GetParticipants(orderID uuid.UUID) (*Participants, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	p := &Participants{OrderID: orderID}
+	var customerIDStr string
+	var providerIDStr, courierIDStr sql.NullString
+
+	err := s.db.QueryRowContext(ctx,
+		`SELECT user_id, provider_id, courier_id, status, updated_at FROM orders WHERE id = $1`,
+		orderID.String(),
+	).Scan(&customerIDStr, &providerIDStr, &courierIDStr, &p.OrderStatus, &p.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("failed to get order participants: %w", err)
+	}
+
+	p.CustomerID, _ = uuid.Parse(customerIDStr)
+	if providerIDStr.Valid {
+		parsed, _ := uuid.Parse(providerIDStr.String)
+		p.ProviderID = &parsed
+	}
+	if courierIDStr.Valid {
+		parsed, _ := uuid.Parse(courierIDStr.String)
+		p.CourierID = &parsed
+	}
+
+	return p, nil
+}
+
+// authorize checks that the user takes part in the order and returns their role
+func (s *Service) authorize(orderID, userID uuid.UUID) (*Participants, ParticipantRole, error) {
+	p, err := s.GetParticipants(orderID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	role, ok := p.RoleOf(userID)
+	if !ok {
+		return nil, "", ErrNotParticipant
+	}
+
+	return p, role, nil
+}
+
+// SendMessage posts a text or quick-reply message to the order chat
+func (s *Service) SendMessage(orderID, senderID uuid.UUID, body, templateKey string) (*Message, *Participants, error) {
+	p, role, err := s.authorize(orderID, senderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.IsClosed(time.Now()) {
+		return nil, nil, ErrChatClosed
+	}
+
+	if templateKey != "" {
+		text, ok := quickReplyText(role, templateKey)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: unknown quick reply %q", ErrInvalidMessage, templateKey)
+		}
+		body = text
+	}
+
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, nil, fmt.Errorf("%w: message body is required", ErrInvalidMessage)
+	}
+	if len(body) > MaxMessageLength {
+		return nil, nil, fmt.Errorf("%w: message must be at most %d characters", ErrInvalidMessage, MaxMessageLength)
+	}
+
+	msg := &Message{
+		ID:          uuid.New(),
+		OrderID:     orderID,
+		SenderID:    senderID,
+		SenderRole:  role,
+		Body:        body,
+		TemplateKey: templateKey,
+		ReadBy:      []uuid.UUID{},
+		CreatedAt:   time.Now(),
+	}
+
+	if err := s.insertMessage(msg, ""); err != nil {
+		return nil, nil, err
+	}
+
+	return msg, p, nil
+}
+
+// SendAttachment stores an image sent to the order chat, with an optional caption
+func (s *Service) SendAttachment(orderID, senderID uuid.UUID, src io.Reader, fileHeader *multipart.FileHeader, caption string) (*Message, *Participants, error) {
+	p, role, err := s.authorize(orderID, senderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.IsClosed(time.Now()) {
+		return nil, nil, ErrChatClosed
+	}
+
+	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	if !allowedAttachmentTypes[ext] {
+		return nil, nil, fmt.Errorf("%w: only jpg, png and webp images are allowed", ErrInvalidMessage)
+	}
+	if fileHeader.Size > maxAttachmentSize {
+		return nil, nil, fmt.Errorf("%w: image must be at most 5MB", ErrInvalidMessage)
+	}
+
+	caption = strings.TrimSpace(caption)
+	if len(caption) > MaxMessageLength {
+		return nil, nil, fmt.Errorf("%w: caption must be at most %d characters", ErrInvalidMessage, MaxMessageLength)
+	}
+
+	// The file name is chosen by the client, so check the bytes are really an image too
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, nil, fmt.Errorf("%w: could not read image", ErrInvalidMessage)
+	}
+	head = head[:n]
+	ext, ok := attachmentExtension(head)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: only jpg, png and webp images are allowed", ErrInvalidMessage)
+	}
+
+	if err := os.MkdirAll(attachmentDirectory, 0755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create attachment directory: %w", err)
+	}
+
+	msg := &Message{
+		ID:         uuid.New(),
+		OrderID:    orderID,
+		SenderID:   senderID,
+		SenderRole: role,
+		Body:       caption,
+		ReadBy:     []uuid.UUID{},
+		CreatedAt:  time.Now(),
+	}
+
+	path := filepath.Join(attachmentDirectory, fmt.Sprintf("%s_%s%s", orderID.String(), msg.ID.String(), ext))
+	dst, err := os.Create(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create attachment file: %w", err)
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, io.MultiReader(bytes.NewReader(head), src)); err != nil {
+		os.Remove(path)
+		return nil, nil, fmt.Errorf("failed to store attachment: %w", err)
+	}
+
+	if err := s.insertMessage(msg, path); err != nil {
+		os.Remove(path)
+		return nil, nil, err
+	}
+	msg.AttachmentURL = attachmentURL(orderID, msg.ID)
+
+	return msg, p, nil
+}
+
+func (s *Service) insertMessage(msg *Message, attachmentPath string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO order_messages (id, order_id, sender_id, sender_role, body, template_key, attachment_path, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		msg.ID.String(), msg.OrderID.String(), msg.SenderID.String(), msg.SenderRole,
+		msg.Body, nullIfEmpty(msg.TemplateKey), nullIfEmpty(attachmentPath), msg.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save message: %w", err)
+	}
+
+	return nil
+}
+
+// ListMessages returns the order chat for a participant, oldest first
+func (s *Service) ListMessages(orderID, userID uuid.UUID) ([]Message, *Participants, error) {
+	p, _, err := s.authorize(orderID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	messages, err := s.GetOrderMessages(orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return messages, p, nil
+}
+
+// GetOrderMessages returns the full chat history of an order (used for dispute review)
+func (s *Service) GetOrderMessages(orderID uuid.UUID) ([]Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT m.id, m.sender_id, m.sender_role, COALESCE(m.body, ''), COALESCE(m.template_key, ''),
+			m.attachment_path IS NOT NULL, m.created_at,
+			COALESCE((SELECT string_agg(r.user_id::text, ',') FROM order_message_reads r WHERE r.message_id = m.id), '')
+		FROM order_messages m
+		WHERE m.order_id = $1
+		ORDER BY m.created_at ASC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, orderID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []Message{}
+	for rows.Next() {
+		msg := Message{OrderID: orderID, ReadBy: []uuid.UUID{}}
+		var idStr, senderIDStr, readBy string
+		var hasAttachment bool
+
+		err := rows.Scan(&idStr, &senderIDStr, &msg.SenderRole, &msg.Body, &msg.TemplateKey, &hasAttachment, &msg.CreatedAt, &readBy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+
+		msg.ID, _ = uuid.Parse(idStr)
+		msg.SenderID, _ = uuid.Parse(senderIDStr)
+		if hasAttachment {
+			msg.AttachmentURL = attachmentURL(orderID, msg.ID)
+		}
+		if readBy != "" {
+			for _, id := range strings.Split(readBy, ",") {
+				parsed, _ := uuid.Parse(id)
+				msg.ReadBy = append(msg.ReadBy, parsed)
+			}
+		}
+
+		messages = append(messages, msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+// MarkRead records that the user has read every message sent to them on the order so far
+func (s *Service) MarkRead(orderID, userID uuid.UUID) (int64, *Participants, error) {
+	p, _, err := s.authorize(orderID, userID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO order_message_reads (message_id, user_id, read_at)
+		SELECT id, $2, $3 FROM order_messages
+		WHERE order_id = $1 AND sender_id <> $2
+		ON CONFLICT (message_id, user_id) DO NOTHING
+	`
+
+	result, err := s.db.ExecContext(ctx, query, orderID.String(), userID.String(), time.Now())
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to mark messages as read: %w", err)
+	}
+
+	marked, _ := result.RowsAffected()
+	return marked, p, nil
+}
+
+// GetAttachmentPath returns where an attachment is stored, after checking the user may see it
+func (s *Service) GetAttachmentPath(orderID, messageID, userID uuid.UUID) (string, error) {
+	if _, _, err := s.authorize(orderID, userID); err != nil {
+		return "", err
+	}
+
+	return s.attachmentPath(orderID, messageID)
+}
+
+// GetAttachmentPathForAdmin returns where an attachment is stored, without participant checks
+func (s *Service) GetAttachmentPathForAdmin(orderID, messageID uuid.UUID) (string, error) {
+	return s.attachmentPath(orderID, messageID)
+}
+
+func (s *Service) attachmentPath(orderID, messageID uuid.UUID) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var path sql.NullString
+	err := s.db.QueryRowContext(ctx,
+		`SELECT attachment_path FROM order_messages WHERE id = $1 AND order_id = $2`,
+		messageID.String(), orderID.String(),
+	).Scan(&path)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrMessageNotFound
+		}
+		return "", fmt.Errorf("failed to get attachment: %w", err)
+	}
+	if !path.Valid {
+		return "", ErrMessageNotFound
+	}
+
+	return path.String, nil
+}
+
+// attachmentExtension returns the extension to store an upload under, judged from its
+// first bytes, or false when they are not a supported image
+func attachmentExtension(head []byte) (string, bool) {
+	ext, ok := attachmentExtensions[http.DetectContentType(head)]
+	return ext, ok
+}
+
+func quickReplyText(role ParticipantRole, key string) (string, bool) {
+	for _, q := range quickReplies[role] {
+		if q.Key == key {
+			return q.Text, true
+		}
+	}
+	return "", false
+}
+
+func attachmentURL(orderID, messageID uuid.UUID) string {
+	return fmt.Sprintf("/orders/%s/messages/%s/attachment", orderID.String(), messageID.String())
+}
+
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,31 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachmentExtension(t *testing.T) {
+	tests := []struct {
+		name string
+		head []byte
+		ext  string
+		ok   bool
+	}{
+		{"jpeg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"), ".jpg", true},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), ".png", true},
+		{"webp", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), ".webp", true},
+		{"html renamed to .png", []byte("<html><script>alert(1)</script></html>"), "", false},
+		{"gif", []byte("GIF89a\x01\x00\x01\x00"), "", false},
+		{"empty", []byte{}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ext, ok := attachmentExtension(tt.head)
+			assert.Equal(t, tt.ext, ext)
+			assert.Equal(t, tt.ok, ok)
+		})
+	}
+}
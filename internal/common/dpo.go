@@ -14,9 +14,13 @@ import (
 	"time"
 )
 
+// Defaults used when the client is created without its own, pointing at DPO's test account
 const (
 	baseURL      = "https://secure.3gdirectpay.com/API/v6/"
 	companyToken = "8D3DA73D-9D7F-4E09-96D4-3D44E7A83EA3"
+
+	// PaymentURL is DPO's hosted checkout page; the transaction token is appended
+	PaymentURL = "https://secure.3gdirectpay.com/payv2.php?ID="
 )
 
 type Client struct {
@@ -25,11 +29,17 @@ type Client struct {
 	HTTPClient   *http.Client
 }
 
-func NewClient(companyToken, baseURL string) *Client {
+func NewClient(token, url string) *Client {
+	if token == "" {
+		token = companyToken
+	}
+	if url == "" {
+		url = baseURL
+	}
 	return &Client{
-		CompanyToken: companyToken,
-		BaseURL:      baseURL,
-		HTTPClient:   &http.Client{},
+		CompanyToken: token,
+		BaseURL:      url,
+		HTTPClient:   &http.Client{Timeout: 30 * time.Second},
 	}
 }
 
@@ -42,13 +52,13 @@ type CreateTokenRequest struct {
 }
 
 type Transaction struct {
-	PaymentAmount    float64 `xml:"PaymentAmount"`
-	PaymentCurrency  string  `xml:"PaymentCurrency"`
-	CompanyRef       string  `xml:"CompanyRef"`
-	RedirectURL      string  `xml:"RedirectURL"`
-	BackURL          string  `xml:"BackURL"`
-	CompanyRefUnique int     `xml:"CompanyRefUnique"` // Changed to int
-	PTL              int     `xml:"PTL"`
+	PaymentAmount    string `xml:"PaymentAmount"` // Kwacha with two decimals, e.g. 250.50
+	PaymentCurrency  string `xml:"PaymentCurrency"`
+	CompanyRef       string `xml:"CompanyRef"`
+	RedirectURL      string `xml:"RedirectURL"`
+	BackURL          string `xml:"BackURL"`
+	CompanyRefUnique int    `xml:"CompanyRefUnique"` // Changed to int
+	PTL              int    `xml:"PTL"`
 }
 type Service struct {
 	ServiceType        int    `xml:"ServiceType"`
@@ -126,15 +136,15 @@ type API3GResponse struct {
 }
 
 func (c *Client) CreateToken(req *CreateTokenRequest) (*CreateTokenResponse, error) {
-	req.CompanyToken = companyToken
+	req.CompanyToken = c.CompanyToken
 	req.Request = "createToken"
-	fmt.Println("Redirect url %s", req.Transaction.RedirectURL)
+	log.Printf("DPO createToken redirect url %s", req.Transaction.RedirectURL)
 	xmlData, err := xml.MarshalIndent(req, "", "  ")
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling request: %w", err)
 	}
 
-	resp, err := c.HTTPClient.Post(baseURL, "application/xml", bytes.NewBuffer(xmlData))
+	resp, err := c.HTTPClient.Post(c.BaseURL, "application/xml", bytes.NewBuffer(xmlData))
 	if err != nil {
 		return nil, fmt.Errorf("error sending request: %w", err)
 	}
@@ -162,7 +172,7 @@ func (c *Client) CreateToken(req *CreateTokenRequest) (*CreateTokenResponse, err
 
 // VerifyToken sends a request to verify a transaction token
 func (c *Client) VerifyToken(req *VerifyTokenRequest) (*VerifyTokenResponse, error) {
-	req.CompanyToken = companyToken
+	req.CompanyToken = c.CompanyToken
 	req.Request = "verifyToken"
 
 	xmlData, err := xml.MarshalIndent(req, "", "  ")
@@ -170,7 +180,7 @@ func (c *Client) VerifyToken(req *VerifyTokenRequest) (*VerifyTokenResponse, err
 		return nil, fmt.Errorf("error marshaling request: %w", err)
 	}
 
-	resp, err := c.HTTPClient.Post(baseURL, "application/xml", bytes.NewBuffer(xmlData))
+	resp, err := c.HTTPClient.Post(c.BaseURL, "application/xml", bytes.NewBuffer(xmlData))
 	if err != nil {
 		return nil, fmt.Errorf("error sending request: %w", err)
 	}
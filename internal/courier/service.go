@@ -157,6 +157,79 @@ func (s *Service) AssignCourierToOrder(orderID, courierID uuid.UUID) error {
 	return nil
 }
 
+// AutoAssign gives a paid order to the nearest courier, preferring those with fewer active
+// orders and better ratings. It returns the courier, or nil when the order already has one
+// or no courier has a location.
+func (s *Service) AutoAssign(orderID uuid.UUID) (*uuid.UUID, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	// 1. Get provider location from the order
+	var providerLat, providerLng sql.NullFloat64
+	providerQuery := `
+		SELECT u.latitude, u.longitude
+		FROM orders o
+		JOIN users u ON o.provider_id = u.id
+		WHERE o.id = $1 AND o.courier_id IS NULL AND o.status NOT IN ('rejected', 'cancelled')
+	`
+	err := s.db.QueryRowContext(ctx, providerQuery, orderID.String()).Scan(&providerLat, &providerLng)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get provider location: %w", err)
+	}
+
+	// Providers without a location are treated as being in central Lusaka
+	if !providerLat.Valid || !providerLng.Valid {
+		providerLat.Float64 = -15.4167
+		providerLng.Float64 = 28.2833
+	}
+
+	// 2. Find best available courier
+	courierQuery := `
+		SELECT 
+			u.id,
+			(SELECT COUNT(*) FROM orders WHERE courier_id = u.id AND status IN ('accepted', 'in-transit')) as active_orders
+		FROM users u
+		WHERE u.user_type = 'courier'
+			AND u.latitude IS NOT NULL
+			AND u.longitude IS NOT NULL
+		ORDER BY 
+			-- Distance-based ordering (rough approximation)
+			SQRT(POWER(u.latitude - $1, 2) + POWER(u.longitude - $2, 2)) ASC,
+			active_orders ASC,
+			COALESCE(u.rating, 0) DESC
+		LIMIT 1
+	`
+	var courierIDStr string
+	var activeOrders int
+	err = s.db.QueryRowContext(ctx, courierQuery, providerLat.Float64, providerLng.Float64).Scan(&courierIDStr, &activeOrders)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find best courier: %w", err)
+	}
+
+	// 3. Assign courier to order
+	assignQuery := `
+		UPDATE orders
+		SET courier_id = $1, courier_status = 'pending', status = 'accepted', updated_at = $2
+		WHERE id = $3 AND courier_id IS NULL
+	`
+	result, err := s.db.ExecContext(ctx, assignQuery, courierIDStr, time.Now(), orderID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to assign courier: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+
+	courierID, _ := uuid.Parse(courierIDStr)
+	return &courierID, nil
+}
+
 // GetCourierByID retrieves courier details by ID
 func (s *Service) GetCourierByID(courierID uuid.UUID) (*Courier, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
 )
 
 type Service struct {
@@ -41,8 +42,8 @@ type InventoryItem struct {
 	ID            uuid.UUID    `json:"id"`
 	ProviderID    uuid.UUID    `json:"provider_id"`
 	CylinderType  CylinderType `json:"cylinder_type"`
-	RefillPrice   float64      `json:"refill_price"`
-	BuyPrice      float64      `json:"buy_price"`
+	RefillPrice   money.Amount `json:"refill_price"`
+	BuyPrice      money.Amount `json:"buy_price"`
 	StockQuantity int          `json:"stock_quantity"`
 	CreatedAt     time.Time    `json:"created_at"`
 	UpdatedAt     time.Time    `json:"updated_at"`
@@ -85,7 +86,7 @@ func (s *Service) GetProviderInventory(providerID uuid.UUID) ([]InventoryItem, e
 	defer cancel()
 
 	query := `
-		SELECT id, provider_id, cylinder_type, refill_price, buy_price, 0, created_at, updated_at
+		SELECT id, provider_id, cylinder_type, refill_price, buy_price, COALESCE(stock_quantity, 0), created_at, updated_at
 		FROM cylinder_pricing
 		WHERE provider_id = $1
 		ORDER BY cylinder_type
@@ -113,13 +114,13 @@ func (s *Service) GetProviderInventory(providerID uuid.UUID) ([]InventoryItem, e
 	return items, rows.Err()
 }
 
-func (s *Service) GetCylinderPrice(providerID uuid.UUID, cylinderType CylinderType) (float64, error) {
+func (s *Service) GetCylinderPrice(providerID uuid.UUID, cylinderType CylinderType) (money.Amount, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	trimmedCylinderType := strings.TrimSpace(string(cylinderType))
 
-	var refillPrice, buyPrice float64
+	var refillPrice, buyPrice money.Amount
 
 	query := `
 		SELECT refill_price, buy_price
@@ -129,7 +130,7 @@ func (s *Service) GetCylinderPrice(providerID uuid.UUID, cylinderType CylinderTy
 
 	err := s.db.QueryRowContext(ctx, query, providerID.String(), trimmedCylinderType).Scan(&refillPrice, &buyPrice)
 
-	log.Printf("Cylinder Type: %s, Refill Price: %.2f, Buy Price: %.2f. Provider ID: %s",
+	log.Printf("Cylinder Type: %s, Refill Price: %s, Buy Price: %s. Provider ID: %s",
 		trimmedCylinderType, refillPrice, buyPrice, providerID.String())
 
 	if err != nil {
@@ -154,10 +155,78 @@ func (s *Service) UpdateStock(providerID uuid.UUID, cylinderType CylinderType, q
 
 	query := `
 		UPDATE cylinder_pricing
-		SET updated_at = $1
-		WHERE provider_id = $2 AND cylinder_type = $3
+		SET stock_quantity = $1, updated_at = $2
+		WHERE provider_id = $3 AND cylinder_type = $4
 	`
 
-	_, err := s.db.ExecContext(ctx, query, time.Now(), providerID.String(), cylinderType)
+	_, err := s.db.ExecContext(ctx, query, quantity, time.Now(), providerID.String(), cylinderType)
 	return err
 }
+
+// Offer is a provider's current price and stock for one cylinder type
+type Offer struct {
+	ProviderID    uuid.UUID    `json:"provider_id"`
+	CylinderType  CylinderType `json:"cylinder_type"`
+	Price         money.Amount `json:"price"`
+	StockQuantity int          `json:"stock_quantity"`
+}
+
+// offerPrice picks the refill price, falling back to the buy price, as GetCylinderPrice does
+const offerPrice = `CASE WHEN cp.refill_price > 0 THEN cp.refill_price ELSE cp.buy_price END`
+
+// GetOffer returns the provider's current price and stock for a cylinder type
+func (s *Service) GetOffer(providerID uuid.UUID, cylinderType CylinderType) (*Offer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	offer := &Offer{ProviderID: providerID, CylinderType: cylinderType}
+	query := `
+		SELECT ` + offerPrice + `, COALESCE(cp.stock_quantity, 0)
+		FROM cylinder_pricing cp
+		WHERE cp.provider_id = $1 AND cp.cylinder_type = $2
+	`
+
+	err := s.db.QueryRowContext(ctx, query, providerID.String(), strings.TrimSpace(string(cylinderType))).Scan(&offer.Price, &offer.StockQuantity)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("cylinder type %s not offered by provider %s", cylinderType, providerID)
+		}
+		return nil, fmt.Errorf("failed to get offer: %w", err)
+	}
+
+	return offer, nil
+}
+
+// FindAlternativeOffer finds another provider with the cylinder type priced and in stock,
+// nearest to the given location when one is known and then by rating
+func (s *Service) FindAlternativeOffer(cylinderType CylinderType, quantity int, excludeProviderID uuid.UUID, latitude, longitude *float64) (*Offer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT cp.provider_id, ` + offerPrice + `, cp.stock_quantity
+		FROM cylinder_pricing cp
+		JOIN users u ON u.id = cp.provider_id AND u.user_type = 'provider'
+		WHERE cp.cylinder_type = $1 AND cp.stock_quantity >= $2 AND cp.provider_id <> $3
+			AND (cp.refill_price > 0 OR cp.buy_price > 0)
+		ORDER BY
+			CASE WHEN $4::float8 IS NULL OR u.latitude IS NULL THEN 0
+				ELSE power(u.latitude - $4::float8, 2) + power(u.longitude - $5::float8, 2) END,
+			COALESCE(u.rating_average, 0) DESC
+		LIMIT 1
+	`
+
+	offer := &Offer{CylinderType: cylinderType}
+	var providerIDStr string
+	err := s.db.QueryRowContext(ctx, query, strings.TrimSpace(string(cylinderType)), quantity, excludeProviderID.String(), latitude, longitude).
+		Scan(&providerIDStr, &offer.Price, &offer.StockQuantity)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find alternative provider: %w", err)
+	}
+	offer.ProviderID, _ = uuid.Parse(providerIDStr)
+
+	return offer, nil
+}
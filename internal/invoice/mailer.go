@@ -0,0 +1,87 @@
+package invoice
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+)
+
+// MailConfig holds the SMTP settings used to email invoices
+type MailConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Attachment is a file attached to an outgoing email
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Mailer sends HTML emails with attachments over SMTP
+type Mailer struct {
+	config MailConfig
+}
+
+// NewMailer returns nil when no SMTP host is configured, which disables email delivery
+func NewMailer(config MailConfig) *Mailer {
+	if config.Host == "" {
+		return nil
+	}
+	if config.Port == "" {
+		config.Port = "587"
+	}
+	return &Mailer{config: config}
+}
+
+func (m *Mailer) Send(to, subject, htmlBody string, attachments ...Attachment) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	fmt.Fprintf(&body, "From: %s\r\n", m.config.From)
+	fmt.Fprintf(&body, "To: %s\r\n", to)
+	fmt.Fprintf(&body, "Subject: %s\r\n", subject)
+	body.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&body, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", writer.Boundary())
+
+	part, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/html; charset=utf-8"}})
+	if err != nil {
+		return err
+	}
+	part.Write([]byte(htmlBody))
+
+	for _, a := range attachments {
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {a.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", a.Filename)},
+		})
+		if err != nil {
+			return err
+		}
+		encoded := base64.StdEncoding.EncodeToString(a.Data)
+		for len(encoded) > 76 {
+			part.Write([]byte(encoded[:76] + "\r\n"))
+			encoded = encoded[76:]
+		}
+		part.Write([]byte(encoded + "\r\n"))
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.config.Username != "" {
+		auth = smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+	}
+
+	return smtp.SendMail(m.config.Host+":"+m.config.Port, auth, m.config.From, []string{to}, body.Bytes())
+}
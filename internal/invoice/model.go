@@ -0,0 +1,58 @@
+package invoice
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// VATRate is the Zambian standard VAT rate. Order prices are VAT-inclusive,
+// so the VAT on an invoice is carved out of the grand total rather than added to it.
+const VATRate = 0.16
+
+// Party is the seller or buyer block printed on an invoice
+type Party struct {
+	ID    uuid.UUID `json:"id"`
+	Name  string    `json:"name"`
+	Phone string    `json:"phone"`
+	Email string    `json:"email,omitempty"`
+	TPIN  string    `json:"tpin,omitempty"`
+}
+
+// LineItem is one billed line of an order. Amounts include VAT.
+type LineItem struct {
+	Description string  `json:"description"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	Amount      float64 `json:"amount"`
+}
+
+// Invoice is the tax invoice issued for a paid order
+type Invoice struct {
+	ID               uuid.UUID  `json:"id"`
+	Number           string     `json:"invoice_number"`
+	OrderID          uuid.UUID  `json:"order_id"`
+	Provider         Party      `json:"provider"`
+	Customer         Party      `json:"customer"`
+	DeliveryAddress  string     `json:"delivery_address"`
+	LineItems        []LineItem `json:"line_items"`
+	Subtotal         float64    `json:"subtotal"`
+	VATRate          float64    `json:"vat_rate"`
+	VATAmount        float64    `json:"vat_amount"`
+	Total            float64    `json:"total"`
+	PaymentMethod    string     `json:"payment_method"`
+	PaymentReference string     `json:"payment_reference,omitempty"`
+	ShareToken       string     `json:"-"`
+	IssuedAt         time.Time  `json:"issued_at"`
+}
+
+// vatBreakdown splits a VAT-inclusive total into its net and VAT parts
+func vatBreakdown(total float64) (subtotal, vat float64) {
+	vat = roundCents(total * VATRate / (1 + VATRate))
+	return roundCents(total - vat), vat
+}
+
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,217 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+var htmlTemplate = template.Must(template.New("invoice").Funcs(template.FuncMap{
+	"money": formatMoney,
+	"date":  func(inv *Invoice) string { return inv.IssuedAt.Format("02 Jan 2006") },
+	"pct":   func(rate float64) string { return fmt.Sprintf("%.0f%%", rate*100) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Tax Invoice {{.Number}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; color: #222; max-width: 720px; margin: 24px auto; }
+table { width: 100%; border-collapse: collapse; }
+th, td { padding: 6px 8px; text-align: left; }
+.items th { border-bottom: 2px solid #222; }
+.items td { border-bottom: 1px solid #ddd; }
+.num { text-align: right; }
+.totals td { border: none; }
+.parties td { vertical-align: top; width: 50%; }
+</style>
+</head>
+<body>
+<h1>Tax Invoice</h1>
+<p>Invoice number: <strong>{{.Number}}</strong><br>
+Date: {{date .}}<br>
+Order: {{.OrderID}}</p>
+<table class="parties">
+<tr>
+<td><strong>From</strong><br>{{.Provider.Name}}<br>{{.Provider.Phone}}{{if .Provider.Email}}<br>{{.Provider.Email}}{{end}}{{if .Provider.TPIN}}<br>TPIN: {{.Provider.TPIN}}{{end}}</td>
+<td><strong>Bill to</strong><br>{{.Customer.Name}}<br>{{.Customer.Phone}}{{if .Customer.Email}}<br>{{.Customer.Email}}{{end}}{{if .Customer.TPIN}}<br>TPIN: {{.Customer.TPIN}}{{end}}<br>{{.DeliveryAddress}}</td>
+</tr>
+</table>
+<table class="items">
+<tr><th>Description</th><th class="num">Qty</th><th class="num">Unit price</th><th class="num">Amount</th></tr>
+{{range .LineItems}}<tr><td>{{.Description}}</td><td class="num">{{.Quantity}}</td><td class="num">{{money .UnitPrice}}</td><td class="num">{{money .Amount}}</td></tr>
+{{end}}</table>
+<table class="totals">
+<tr><td class="num">Subtotal (excl. VAT)</td><td class="num">{{money .Subtotal}}</td></tr>
+<tr><td class="num">VAT {{pct .VATRate}}</td><td class="num">{{money .VATAmount}}</td></tr>
+<tr><td class="num"><strong>Total</strong></td><td class="num"><strong>{{money .Total}}</strong></td></tr>
+</table>
+<p>Paid via {{.PaymentMethod}}{{if .PaymentReference}} &middot; Reference {{.PaymentReference}}{{end}}</p>
+</body>
+</html>
+`))
+
+// RenderHTML writes the invoice as a standalone HTML page
+func RenderHTML(w io.Writer, inv *Invoice) error {
+	if err := htmlTemplate.Execute(w, inv); err != nil {
+		return fmt.Errorf("failed to render invoice: %w", err)
+	}
+	return nil
+}
+
+// RenderPDF lays the invoice out on a single A4 page using the built-in Helvetica fonts
+func RenderPDF(inv *Invoice) []byte {
+	p := &pdfPage{y: 800}
+
+	p.text(50, 20, true, "TAX INVOICE")
+	p.newline(30)
+	p.text(50, 10, false, "Invoice number: "+inv.Number)
+	p.newline(14)
+	p.text(50, 10, false, "Date: "+inv.IssuedAt.Format("02 Jan 2006"))
+	p.newline(14)
+	p.text(50, 10, false, "Order: "+inv.OrderID.String())
+	p.newline(28)
+
+	from := partyLines(inv.Provider)
+	to := append(partyLines(inv.Customer), inv.DeliveryAddress)
+	p.text(50, 10, true, "From")
+	p.text(300, 10, true, "Bill to")
+	for i := 0; i < len(from) || i < len(to); i++ {
+		p.newline(14)
+		if i < len(from) {
+			p.text(50, 10, false, from[i])
+		}
+		if i < len(to) {
+			p.text(300, 10, false, to[i])
+		}
+	}
+	p.newline(32)
+
+	p.text(50, 10, true, "Description")
+	p.text(330, 10, true, "Qty")
+	p.text(380, 10, true, "Unit price")
+	p.text(480, 10, true, "Amount")
+	p.newline(6)
+	p.rule(50, 545)
+	for _, item := range inv.LineItems {
+		p.newline(16)
+		p.text(50, 10, false, item.Description)
+		p.text(330, 10, false, fmt.Sprintf("%d", item.Quantity))
+		p.text(380, 10, false, formatMoney(item.UnitPrice))
+		p.text(480, 10, false, formatMoney(item.Amount))
+	}
+	p.newline(8)
+	p.rule(50, 545)
+	p.newline(18)
+
+	p.text(330, 10, false, "Subtotal (excl. VAT)")
+	p.text(480, 10, false, formatMoney(inv.Subtotal))
+	p.newline(14)
+	p.text(330, 10, false, fmt.Sprintf("VAT %.0f%%", inv.VATRate*100))
+	p.text(480, 10, false, formatMoney(inv.VATAmount))
+	p.newline(16)
+	p.text(330, 11, true, "Total")
+	p.text(480, 11, true, formatMoney(inv.Total))
+	p.newline(32)
+
+	payment := "Paid via " + inv.PaymentMethod
+	if inv.PaymentReference != "" {
+		payment += " - Reference " + inv.PaymentReference
+	}
+	p.text(50, 10, false, payment)
+
+	return p.document()
+}
+
+func partyLines(party Party) []string {
+	lines := []string{party.Name, party.Phone}
+	if party.Email != "" {
+		lines = append(lines, party.Email)
+	}
+	if party.TPIN != "" {
+		lines = append(lines, "TPIN: "+party.TPIN)
+	}
+	return lines
+}
+
+// formatMoney prints kwacha amounts, with discounts as -K20.00
+func formatMoney(v float64) string {
+	if v < 0 {
+		return fmt.Sprintf("-K%.2f", -v)
+	}
+	return fmt.Sprintf("K%.2f", v)
+}
+
+// pdfPage accumulates the content stream of a single-page PDF
+type pdfPage struct {
+	content bytes.Buffer
+	y       float64
+}
+
+func (p *pdfPage) newline(step float64) {
+	p.y -= step
+}
+
+func (p *pdfPage) text(x, size float64, bold bool, s string) {
+	if s == "" {
+		return
+	}
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&p.content, "BT /%s %.0f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, p.y, pdfEscape(s))
+}
+
+func (p *pdfPage) rule(x1, x2 float64) {
+	fmt.Fprintf(&p.content, "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, p.y, x2, p.y)
+}
+
+// document wraps the content stream in the objects, cross-reference table and trailer of a PDF file
+func (p *pdfPage) document() []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Resources << /Font << /F1 4 0 R /F2 5 0 R >> >> /Contents 6 0 R >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.content.Len(), p.content.String()),
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return out.Bytes()
+}
+
+// pdfEscape escapes a string for a PDF literal, replacing characters outside
+// printable ASCII since the standard fonts carry no Unicode mapping
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 126:
+			b.WriteRune('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
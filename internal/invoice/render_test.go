@@ -0,0 +1,85 @@
+package invoice
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sampleInvoice() *Invoice {
+	subtotal, vat := vatBreakdown(315)
+	return &Invoice{
+		Number:    "INV-000042",
+		OrderID:   uuid.New(),
+		Provider:  Party{Name: "Lusaka Gas (Ltd)", Phone: "+260971234567", TPIN: "1001234567"},
+		Customer:  Party{Name: "Mwila Banda", Phone: "+260961234567"},
+		LineItems: []LineItem{{Description: "LPG refill - 9KG cylinder", Quantity: 1, UnitPrice: 300, Amount: 300}},
+		Subtotal:  subtotal,
+		VATRate:   VATRate,
+		VATAmount: vat,
+		Total:     315,
+		IssuedAt:  time.Date(2025, 5, 4, 9, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestVATBreakdown(t *testing.T) {
+	subtotal, vat := vatBreakdown(116)
+	assert.Equal(t, 100.0, subtotal)
+	assert.Equal(t, 16.0, vat)
+
+	subtotal, vat = vatBreakdown(315)
+	assert.Equal(t, 315.0, subtotal+vat)
+}
+
+func TestLineItemsWithDiscounts(t *testing.T) {
+	d := &orderDetails{
+		cylinderType: "9KG", quantity: 1, pricePerUnit: 300, totalPrice: 300,
+		deliveryFee: 10, serviceCharge: 15, discount: 20, loyaltyDiscount: 5, grandTotal: 300,
+	}
+	items := d.lineItems()
+	require.Len(t, items, 5)
+	assert.Equal(t, LineItem{Description: "Discount", Quantity: 1, UnitPrice: -20, Amount: -20}, items[3])
+	assert.Equal(t, LineItem{Description: "Loyalty points", Quantity: 1, UnitPrice: -5, Amount: -5}, items[4])
+
+	var sum float64
+	for _, item := range items {
+		sum += item.Amount
+	}
+	assert.Equal(t, d.grandTotal, sum)
+
+	// Orders without discounts keep their three lines
+	d.discount, d.loyaltyDiscount, d.grandTotal = 0, 0, 325
+	assert.Len(t, d.lineItems(), 3)
+}
+
+func TestRenderPDF(t *testing.T) {
+	pdf := RenderPDF(sampleInvoice())
+
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4")))
+	assert.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+	assert.Contains(t, string(pdf), "(Invoice number: INV-000042)")
+	assert.Contains(t, string(pdf), `Lusaka Gas \(Ltd\)`)
+}
+
+func TestRenderHTML(t *testing.T) {
+	var out strings.Builder
+	require.NoError(t, RenderHTML(&out, sampleInvoice()))
+
+	assert.Contains(t, out.String(), "INV-000042")
+	assert.Contains(t, out.String(), "TPIN: 1001234567")
+	assert.Contains(t, out.String(), "K315.00")
+
+	// Discounts print as negative amounts
+	inv := sampleInvoice()
+	inv.LineItems = append(inv.LineItems, LineItem{Description: "Discount", Quantity: 1, UnitPrice: -20, Amount: -20})
+	out.Reset()
+	require.NoError(t, RenderHTML(&out, inv))
+	assert.Contains(t, out.String(), "<td>Discount</td>")
+	assert.Contains(t, out.String(), "-K20.00")
+	assert.Contains(t, string(RenderPDF(inv)), "(-K20.00)")
+}
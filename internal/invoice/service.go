@@ -0,0 +1,305 @@
+package invoice
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrOrderNotFound    = errors.New("order not found")
+	ErrOrderNotPaid     = errors.New("order has not been paid")
+	ErrInvoiceNotFound  = errors.New("invoice not found")
+	ErrDeliveryDisabled = errors.New("invoice delivery channel is not configured")
+)
+
+// SMSSender sends a plain text SMS. The Twilio client satisfies it.
+type SMSSender interface {
+	SendSMS(to, body string) error
+}
+
+type Service struct {
+	db            *sql.DB
+	sms           SMSSender
+	mailer        *Mailer
+	publicBaseURL string
+}
+
+// NewService creates the invoice service. sms and mailer may be nil, in which case
+// the matching delivery channel reports ErrDeliveryDisabled.
+func NewService(db *sql.DB, sms SMSSender, mailer *Mailer, publicBaseURL string) *Service {
+	return &Service{
+		db:            db,
+		sms:           sms,
+		mailer:        mailer,
+		publicBaseURL: strings.TrimRight(publicBaseURL, "/"),
+	}
+}
+
+// orderDetails is everything on an order that ends up on its invoice
+type orderDetails struct {
+	customer         Party
+	provider         Party
+	cylinderType     string
+	quantity         int
+	pricePerUnit     float64
+	totalPrice       float64
+	deliveryFee      float64
+	serviceCharge    float64
+	discount         float64
+	loyaltyDiscount  float64
+	grandTotal       float64
+	deliveryAddress  string
+	paymentMethod    string
+	paymentStatus    string
+	paymentReference string
+}
+
+func (s *Service) getOrderDetails(ctx context.Context, orderID uuid.UUID) (*orderDetails, error) {
+	query := `
+		SELECT o.user_id, o.provider_id, o.cylinder_type, o.quantity, o.price_per_unit, o.total_price,
+			o.delivery_fee, o.service_charge, o.discount, o.loyalty_discount, o.grand_total, o.delivery_address,
+			COALESCE(o.payment_method, ''), o.payment_status,
+			cu.name, cu.phone_number, COALESCE(cu.email, ''), COALESCE(cu.tpin, ''),
+			COALESCE(pr.name, ''), COALESCE(pr.phone_number, ''), COALESCE(pr.email, ''), COALESCE(pr.tpin, ''),
+			COALESCE((
+				SELECT p.transaction_ref FROM payments p
+				WHERE p.order_id = o.id AND p.status = 'completed' AND p.purpose = 'order'
+				ORDER BY p.updated_at DESC LIMIT 1
+			), '')
+		FROM orders o
+		JOIN users cu ON cu.id = o.user_id
+		LEFT JOIN users pr ON pr.id = o.provider_id
+		WHERE o.id = $1
+	`
+
+	var d orderDetails
+	var customerIDStr string
+	var providerIDStr sql.NullString
+	err := s.db.QueryRowContext(ctx, query, orderID.String()).Scan(
+		&customerIDStr, &providerIDStr, &d.cylinderType, &d.quantity, &d.pricePerUnit, &d.totalPrice,
+		&d.deliveryFee, &d.serviceCharge, &d.discount, &d.loyaltyDiscount, &d.grandTotal, &d.deliveryAddress,
+		&d.paymentMethod, &d.paymentStatus,
+		&d.customer.Name, &d.customer.Phone, &d.customer.Email, &d.customer.TPIN,
+		&d.provider.Name, &d.provider.Phone, &d.provider.Email, &d.provider.TPIN,
+		&d.paymentReference,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	d.customer.ID, _ = uuid.Parse(customerIDStr)
+	if providerIDStr.Valid {
+		d.provider.ID, _ = uuid.Parse(providerIDStr.String)
+	}
+
+	return &d, nil
+}
+
+// lineItems breaks an order into the billed lines printed on its invoice. Promotions and
+// loyalty points are negative lines, so the lines add up to the total.
+func (d *orderDetails) lineItems() []LineItem {
+	items := []LineItem{{
+		Description: fmt.Sprintf("LPG refill - %s cylinder", d.cylinderType),
+		Quantity:    d.quantity,
+		UnitPrice:   d.pricePerUnit,
+		Amount:      d.totalPrice,
+	}}
+	if d.deliveryFee > 0 {
+		items = append(items, LineItem{Description: "Delivery fee", Quantity: 1, UnitPrice: d.deliveryFee, Amount: d.deliveryFee})
+	}
+	if d.serviceCharge > 0 {
+		items = append(items, LineItem{Description: "Service charge", Quantity: 1, UnitPrice: d.serviceCharge, Amount: d.serviceCharge})
+	}
+	if d.discount > 0 {
+		items = append(items, LineItem{Description: "Discount", Quantity: 1, UnitPrice: -d.discount, Amount: -d.discount})
+	}
+	if d.loyaltyDiscount > 0 {
+		items = append(items, LineItem{Description: "Loyalty points", Quantity: 1, UnitPrice: -d.loyaltyDiscount, Amount: -d.loyaltyDiscount})
+	}
+	return items
+}
+
+// GetInvoice returns the invoice for a paid order, issuing it with the next sequential
+// number on first request. Later calls return the same number and totals.
+func (s *Service) GetInvoice(orderID uuid.UUID) (*Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	d, err := s.getOrderDetails(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issue(ctx, orderID, d)
+}
+
+// GetCustomerInvoice returns the invoice for one of the customer's own paid orders
+func (s *Service) GetCustomerInvoice(orderID, customerID uuid.UUID) (*Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	d, err := s.getOrderDetails(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if d.customer.ID != customerID {
+		return nil, ErrOrderNotFound
+	}
+
+	return s.issue(ctx, orderID, d)
+}
+
+// GetInvoiceByShareToken resolves the unguessable token used in SMS and email links
+func (s *Service) GetInvoiceByShareToken(token string) (*Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var orderIDStr string
+	err := s.db.QueryRowContext(ctx, `SELECT order_id FROM invoices WHERE share_token = $1`, token).Scan(&orderIDStr)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvoiceNotFound
+		}
+		return nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+
+	orderID, _ := uuid.Parse(orderIDStr)
+	d, err := s.getOrderDetails(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issue(ctx, orderID, d)
+}
+
+func (s *Service) issue(ctx context.Context, orderID uuid.UUID, d *orderDetails) (*Invoice, error) {
+	if d.paymentStatus != "paid" {
+		return nil, ErrOrderNotPaid
+	}
+
+	subtotal, vat := vatBreakdown(d.grandTotal)
+	token, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// nextval runs before ON CONFLICT is checked, so a racing insert would burn a number.
+	// Locking the order first makes a concurrent request wait and then find the row,
+	// so the sequence is only drawn for an invoice that is actually saved.
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM orders WHERE id = $1 FOR UPDATE`, orderID.String()); err != nil {
+		return nil, fmt.Errorf("failed to lock order: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO invoices (order_id, invoice_number, share_token, subtotal, vat_amount, total, payment_reference)
+		SELECT $1, 'INV-' || LPAD(nextval('invoice_number_seq')::text, 6, '0'), $2, $3, $4, $5, $6
+		WHERE NOT EXISTS (SELECT 1 FROM invoices WHERE order_id = $1)
+	`, orderID.String(), token, subtotal, vat, d.grandTotal, d.paymentReference)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue invoice: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit invoice: %w", err)
+	}
+
+	inv := &Invoice{
+		OrderID:         orderID,
+		Provider:        d.provider,
+		Customer:        d.customer,
+		DeliveryAddress: d.deliveryAddress,
+		LineItems:       d.lineItems(),
+		VATRate:         VATRate,
+		PaymentMethod:   d.paymentMethod,
+	}
+
+	var idStr string
+	err = s.db.QueryRowContext(ctx, `
+		SELECT id, invoice_number, share_token, subtotal, vat_amount, total,
+			COALESCE(payment_reference, ''), issued_at
+		FROM invoices WHERE order_id = $1
+	`, orderID.String()).Scan(
+		&idStr, &inv.Number, &inv.ShareToken, &inv.Subtotal, &inv.VATAmount, &inv.Total,
+		&inv.PaymentReference, &inv.IssuedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load invoice: %w", err)
+	}
+	inv.ID, _ = uuid.Parse(idStr)
+
+	return inv, nil
+}
+
+// ShareURL is the public link to an invoice, sent by SMS and email
+func (s *Service) ShareURL(inv *Invoice) string {
+	return fmt.Sprintf("%s/invoices/%s", s.publicBaseURL, inv.ShareToken)
+}
+
+// SendBySMS texts the invoice link to the given phone number, or the customer's if empty
+func (s *Service) SendBySMS(inv *Invoice, phone string) error {
+	if s.sms == nil {
+		return ErrDeliveryDisabled
+	}
+	if phone == "" {
+		phone = inv.Customer.Phone
+	}
+
+	body := fmt.Sprintf("ZamGas invoice %s for K%.2f: %s", inv.Number, inv.Total, s.ShareURL(inv))
+	if err := s.sms.SendSMS(phone, body); err != nil {
+		return fmt.Errorf("failed to send invoice SMS: %w", err)
+	}
+	return nil
+}
+
+// SendByEmail emails the invoice as HTML with the PDF attached, to the given address
+// or the customer's if empty
+func (s *Service) SendByEmail(inv *Invoice, email string) error {
+	if s.mailer == nil {
+		return ErrDeliveryDisabled
+	}
+	if email == "" {
+		email = inv.Customer.Email
+	}
+	if email == "" {
+		return errors.New("no email address for invoice delivery")
+	}
+
+	var html strings.Builder
+	if err := RenderHTML(&html, inv); err != nil {
+		return err
+	}
+
+	attachment := Attachment{
+		Filename:    inv.Number + ".pdf",
+		ContentType: "application/pdf",
+		Data:        RenderPDF(inv),
+	}
+	subject := fmt.Sprintf("Your ZamGas invoice %s", inv.Number)
+	if err := s.mailer.Send(email, subject, html.String(), attachment); err != nil {
+		return fmt.Errorf("failed to email invoice: %w", err)
+	}
+	return nil
+}
+
+func newShareToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate share token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
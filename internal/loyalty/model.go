@@ -0,0 +1,202 @@
+package loyalty
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TransactionType string
+type Tier string
+type Target string
+
+const (
+	TransactionEarn   TransactionType = "earn"
+	TransactionRedeem TransactionType = "redeem"
+	TransactionExpire TransactionType = "expire"
+	// TransactionRestore returns points redeemed on an order that was then rejected
+	TransactionRestore TransactionType = "restore"
+	// TransactionReverse takes back points earned on an order that was later refunded
+	TransactionReverse TransactionType = "reverse"
+)
+
+const (
+	TierBronze Tier = "bronze"
+	TierSilver Tier = "silver"
+	TierGold   Tier = "gold"
+)
+
+// Targets points can be redeemed against at order time
+const (
+	TargetDeliveryFee Target = "delivery_fee"
+	TargetProduct     Target = "product"
+)
+
+const (
+	// PointsPerKwacha is the base earn rate on the grand total of a delivered order
+	PointsPerKwacha = 1.0
+	// PointValue is what one point takes off an order, in kwacha
+	PointValue = 0.10
+	// MinRedeemPoints is the smallest redemption accepted
+	MinRedeemPoints = 50
+	// PointsValidFor is how long earned points last before they expire
+	PointsValidFor = 365 * 24 * time.Hour
+	// TierWindow is the period whose earnings decide the customer's tier
+	TierWindow = 365 * 24 * time.Hour
+	// ExpiryNoticeWindow is how far ahead the profile warns about expiring points
+	ExpiryNoticeWindow = 30 * 24 * time.Hour
+)
+
+// tierLevel is the points a customer must earn within TierWindow to reach a tier, and
+// the earn rate multiplier it brings
+type tierLevel struct {
+	Tier       Tier
+	MinPoints  int
+	Multiplier float64
+}
+
+// tierLevels is ordered from the lowest tier up
+var tierLevels = []tierLevel{
+	{TierBronze, 0, 1.0},
+	{TierSilver, 1000, 1.25},
+	{TierGold, 3000, 1.5},
+}
+
+var (
+	ErrInsufficientPoints = errors.New("not enough loyalty points")
+	ErrBelowMinimum       = errors.New("redemption is below the minimum number of points")
+	ErrInvalidTarget      = errors.New("points can only be redeemed against delivery_fee or product")
+	ErrNothingToRedeem    = errors.New("nothing on the order to redeem points against")
+	ErrAlreadyRecorded    = errors.New("points were already recorded for this order")
+)
+
+// Account is a customer's points balance and standing
+type Account struct {
+	UserID           uuid.UUID  `json:"user_id"`
+	Balance          int        `json:"balance"`
+	BalanceValue     float64    `json:"balance_value"`
+	Tier             Tier       `json:"tier"`
+	Multiplier       float64    `json:"multiplier"`
+	EarnedInWindow   int        `json:"earned_last_12_months"`
+	NextTier         Tier       `json:"next_tier,omitempty"`
+	PointsToNextTier int        `json:"points_to_next_tier,omitempty"`
+	ExpiringPoints   int        `json:"expiring_points"`
+	ExpiringBy       *time.Time `json:"expiring_by,omitempty"`
+}
+
+// Transaction is one line in the points ledger. Points are positive when earned or
+// restored and negative when redeemed or expired.
+type Transaction struct {
+	ID           uuid.UUID       `json:"id"`
+	UserID       uuid.UUID       `json:"user_id"`
+	Type         TransactionType `json:"type"`
+	Points       int             `json:"points"`
+	BalanceAfter int             `json:"balance_after"`
+	OrderID      *uuid.UUID      `json:"order_id,omitempty"`
+	// Amount is the order total points were earned on, or the discount they bought
+	Amount    float64    `json:"amount"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Note      string     `json:"note,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Redemption is the discount a number of points buys on an order
+type Redemption struct {
+	Points int     `json:"points"`
+	Amount float64 `json:"amount"`
+	Target Target  `json:"target"`
+}
+
+// ExpiryResult is what one expiry run did
+type ExpiryResult struct {
+	Accounts int `json:"accounts"`
+	Points   int `json:"points"`
+}
+
+// tierFor returns the tier reached by the points earned within TierWindow
+func tierFor(earned int) tierLevel {
+	level := tierLevels[0]
+	for _, l := range tierLevels {
+		if earned >= l.MinPoints {
+			level = l
+		}
+	}
+	return level
+}
+
+// nextTier returns the tier above the one reached, if any
+func nextTier(earned int) (tierLevel, bool) {
+	for _, l := range tierLevels {
+		if earned < l.MinPoints {
+			return l, true
+		}
+	}
+	return tierLevel{}, false
+}
+
+// EarnPoints works out the points for an order total at the multiplier of the customer's tier
+func EarnPoints(total, multiplier float64) int {
+	if total <= 0 {
+		return 0
+	}
+	return int(math.Floor(total*PointsPerKwacha*multiplier + 1e-9))
+}
+
+// Quote prices a redemption against the part of the order it targets. Points beyond
+// what the target costs are not taken.
+func Quote(points, balance int, target Target, productAmount, deliveryFee float64) (*Redemption, error) {
+	if points < MinRedeemPoints {
+		return nil, ErrBelowMinimum
+	}
+	if points > balance {
+		return nil, ErrInsufficientPoints
+	}
+
+	var limit float64
+	switch target {
+	case TargetDeliveryFee:
+		limit = deliveryFee
+	case TargetProduct:
+		limit = productAmount
+	default:
+		return nil, ErrInvalidTarget
+	}
+	if limit <= 0 {
+		return nil, ErrNothingToRedeem
+	}
+
+	if max := int(math.Ceil(limit/PointValue - 1e-9)); points > max {
+		points = max
+	}
+	amount := math.Min(math.Round(float64(points)*PointValue*100)/100, limit)
+
+	return &Redemption{Points: points, Amount: amount, Target: target}, nil
+}
+
+// expiredPoints is how many points should have expired by now. Redemptions use the
+// oldest points first, so whatever of the expired earnings hasn't already been spent or
+// expired is still in the balance and now lapses.
+func expiredPoints(earnedExpired, consumed int) int {
+	if earnedExpired > consumed {
+		return earnedExpired - consumed
+	}
+	return 0
+}
+
+// reversePoints is how many more of the points earned on an order's total to take back
+// once refunded of it has been refunded, given how many were taken back already
+func reversePoints(earned int, total, refunded float64, reversed int) int {
+	if earned <= 0 || total <= 0 || refunded <= 0 {
+		return 0
+	}
+	if refunded > total {
+		refunded = total
+	}
+	due := int(math.Round(float64(earned)*refunded/total)) - reversed
+	if due < 0 {
+		return 0
+	}
+	return due
+}
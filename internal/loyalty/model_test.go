@@ -0,0 +1,62 @@
+package loyalty
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTiers(t *testing.T) {
+	assert.Equal(t, TierBronze, tierFor(0).Tier)
+	assert.Equal(t, TierSilver, tierFor(1000).Tier)
+	assert.Equal(t, TierGold, tierFor(5000).Tier)
+
+	next, ok := nextTier(400)
+	assert.True(t, ok)
+	assert.Equal(t, TierSilver, next.Tier)
+	_, ok = nextTier(3000)
+	assert.False(t, ok)
+
+	assert.Equal(t, 262, EarnPoints(210, tierFor(1200).Multiplier))
+	assert.Equal(t, 0, EarnPoints(0, 1))
+}
+
+func TestQuote(t *testing.T) {
+	r, err := Quote(60, 500, TargetDeliveryFee, 200, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 60, r.Points)
+	assert.InDelta(t, 6.0, r.Amount, 0.001)
+
+	// Points beyond the delivery fee are left in the balance
+	r, err = Quote(400, 500, TargetDeliveryFee, 200, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 100, r.Points)
+	assert.InDelta(t, 10.0, r.Amount, 0.001)
+
+	_, err = Quote(20, 500, TargetProduct, 200, 10)
+	assert.ErrorIs(t, err, ErrBelowMinimum)
+	_, err = Quote(600, 500, TargetProduct, 200, 10)
+	assert.ErrorIs(t, err, ErrInsufficientPoints)
+	_, err = Quote(100, 500, "service_charge", 200, 10)
+	assert.ErrorIs(t, err, ErrInvalidTarget)
+	_, err = Quote(100, 500, TargetDeliveryFee, 200, 0)
+	assert.ErrorIs(t, err, ErrNothingToRedeem)
+}
+
+func TestExpiredPoints(t *testing.T) {
+	// 300 points earned last year, 120 of them already redeemed
+	assert.Equal(t, 180, expiredPoints(300, 120))
+	// Redemptions used up everything that has expired
+	assert.Equal(t, 0, expiredPoints(300, 450))
+}
+
+func TestReversePoints(t *testing.T) {
+	// Half of a K200 order refunded takes back half of the 250 points it earned
+	assert.Equal(t, 125, reversePoints(250, 200, 100, 0))
+	// The rest of it refunded later takes back only what is left
+	assert.Equal(t, 125, reversePoints(250, 200, 200, 125))
+	assert.Equal(t, 0, reversePoints(250, 200, 200, 250))
+	// Refunds beyond the total the points were earned on take back no more
+	assert.Equal(t, 250, reversePoints(250, 200, 260, 0))
+	assert.Equal(t, 0, reversePoints(0, 200, 200, 0))
+}
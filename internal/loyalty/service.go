@@ -0,0 +1,378 @@
+package loyalty
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Service struct {
+	db *sql.DB
+}
+
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
+
+// post records a ledger line and moves the points balance in a single statement.
+// Debits that would take the balance below zero are refused.
+func (s *Service) post(ctx context.Context, userID uuid.UUID, t TransactionType, points int, orderID *uuid.UUID, amount float64, expiresAt *time.Time, note string) (*Transaction, error) {
+	var orderIDArg interface{}
+	if orderID != nil {
+		orderIDArg = orderID.String()
+	}
+
+	tx := &Transaction{
+		UserID:    userID,
+		Type:      t,
+		Points:    points,
+		OrderID:   orderID,
+		Amount:    amount,
+		ExpiresAt: expiresAt,
+		Note:      note,
+	}
+
+	var idStr string
+	err := s.db.QueryRowContext(ctx, `
+		WITH a AS (
+			INSERT INTO loyalty_accounts (user_id, balance) VALUES ($1, $2::integer)
+			ON CONFLICT (user_id) DO UPDATE SET balance = loyalty_accounts.balance + EXCLUDED.balance
+			WHERE loyalty_accounts.balance + EXCLUDED.balance >= 0
+			RETURNING balance
+		)
+		INSERT INTO loyalty_transactions (user_id, type, points, balance_after, order_id, amount, expires_at, note)
+		SELECT $1, $3, $2::integer, a.balance, $4::uuid, $5, $6, NULLIF($7, '') FROM a
+		RETURNING id, balance_after, created_at
+	`, userID.String(), points, t, orderIDArg, amount, expiresAt, note,
+	).Scan(&idStr, &tx.BalanceAfter, &tx.CreatedAt)
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows, strings.Contains(err.Error(), "violates check constraint"):
+			return nil, ErrInsufficientPoints
+		case strings.Contains(err.Error(), "duplicate key"):
+			return nil, ErrAlreadyRecorded
+		}
+		return nil, fmt.Errorf("failed to post loyalty transaction: %w", err)
+	}
+	tx.ID, _ = uuid.Parse(idStr)
+
+	return tx, nil
+}
+
+// GetAccount returns the customer's balance, tier and points about to expire
+func (s *Service) GetAccount(userID uuid.UUID) (*Account, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	noticeBy := now.Add(ExpiryNoticeWindow)
+
+	a := &Account{UserID: userID}
+	var earnedExpiring, consumed int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT
+			COALESCE((SELECT balance FROM loyalty_accounts WHERE user_id = $1), 0),
+			COALESCE(SUM(points) FILTER (WHERE type = 'earn' AND created_at >= $2), 0),
+			COALESCE(SUM(points) FILTER (WHERE type = 'earn' AND expires_at <= $3), 0),
+			COALESCE(-SUM(points) FILTER (WHERE type <> 'earn'), 0)
+		FROM loyalty_transactions
+		WHERE user_id = $1
+	`, userID.String(), now.Add(-TierWindow), noticeBy).Scan(&a.Balance, &a.EarnedInWindow, &earnedExpiring, &consumed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loyalty account: %w", err)
+	}
+
+	level := tierFor(a.EarnedInWindow)
+	a.Tier, a.Multiplier = level.Tier, level.Multiplier
+	if next, ok := nextTier(a.EarnedInWindow); ok {
+		a.NextTier, a.PointsToNextTier = next.Tier, next.MinPoints-a.EarnedInWindow
+	}
+	a.BalanceValue = float64(a.Balance) * PointValue
+	if a.ExpiringPoints = expiredPoints(earnedExpiring, consumed); a.ExpiringPoints > 0 {
+		a.ExpiringBy = &noticeBy
+	}
+
+	return a, nil
+}
+
+// ListTransactions returns the customer's points ledger, newest first
+func (s *Service) ListTransactions(userID uuid.UUID, limit, offset int) ([]Transaction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id, type, points, balance_after, order_id, amount, expires_at, COALESCE(note, ''), created_at
+		FROM loyalty_transactions
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, userID.String(), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list loyalty transactions: %w", err)
+	}
+	defer rows.Close()
+
+	transactions := []Transaction{}
+	for rows.Next() {
+		var t Transaction
+		var idStr, userIDStr string
+		var orderIDStr sql.NullString
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&idStr, &userIDStr, &t.Type, &t.Points, &t.BalanceAfter, &orderIDStr,
+			&t.Amount, &expiresAt, &t.Note, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan loyalty transaction: %w", err)
+		}
+		t.ID, _ = uuid.Parse(idStr)
+		t.UserID, _ = uuid.Parse(userIDStr)
+		if orderIDStr.Valid {
+			parsed, _ := uuid.Parse(orderIDStr.String)
+			t.OrderID = &parsed
+		}
+		if expiresAt.Valid {
+			t.ExpiresAt = &expiresAt.Time
+		}
+		transactions = append(transactions, t)
+	}
+
+	return transactions, rows.Err()
+}
+
+// refundedColumn selects what has been refunded on the order aliased o, through PawaPay or
+// to the customer's wallet
+const refundedColumn = `COALESCE((
+		SELECT SUM(amount) FROM refunds WHERE order_id = o.id AND status = 'completed'
+	), 0) + COALESCE((
+		SELECT SUM(amount) FROM wallet_transactions WHERE order_id = o.id AND type = 'refund'
+	), 0)`
+
+// EarnForOrder credits the points for a delivered, paid order at the customer's current
+// tier. Orders that aren't both yet earn nothing, and money already refunded earns nothing.
+func (s *Service) EarnForOrder(orderID uuid.UUID) (*Transaction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var userIDStr, status, paymentStatus string
+	var total float64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT o.user_id, o.status, o.payment_status, o.grand_total - `+refundedColumn+`
+		FROM orders o WHERE o.id = $1
+	`, orderID.String()).Scan(&userIDStr, &status, &paymentStatus, &total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	if status != "delivered" || paymentStatus != "paid" {
+		return nil, nil
+	}
+	userID, _ := uuid.Parse(userIDStr)
+
+	var earned int
+	err = s.db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(points), 0) FROM loyalty_transactions
+		WHERE user_id = $1 AND type IN ('earn', 'reverse') AND created_at >= $2
+	`, userIDStr, time.Now().Add(-TierWindow)).Scan(&earned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get points earned: %w", err)
+	}
+
+	level := tierFor(earned)
+	points := EarnPoints(total, level.Multiplier)
+	if points <= 0 {
+		return nil, nil
+	}
+
+	expiresAt := time.Now().Add(PointsValidFor)
+	return s.post(ctx, userID, TransactionEarn, points, &orderID, total, &expiresAt,
+		fmt.Sprintf("Earned at %s tier", level.Tier))
+}
+
+// AwardDelivered earns points for paid orders delivered or updated since the given time
+// that haven't earned yet, catching any missed when their status changed
+func (s *Service) AwardDelivered(since time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT o.id FROM orders o
+		WHERE o.status = 'delivered' AND o.payment_status = 'paid' AND o.updated_at >= $1
+			AND NOT EXISTS (
+				SELECT 1 FROM loyalty_transactions t WHERE t.type = 'earn' AND t.order_id = o.id
+			)
+	`, since)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find delivered orders: %w", err)
+	}
+
+	var orderIDs []uuid.UUID
+	for rows.Next() {
+		var idStr string
+		if err := rows.Scan(&idStr); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan order: %w", err)
+		}
+		id, _ := uuid.Parse(idStr)
+		orderIDs = append(orderIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	awarded := 0
+	for _, id := range orderIDs {
+		t, err := s.EarnForOrder(id)
+		if err != nil {
+			log.Printf("ERROR: Failed to award loyalty points for order %s: %v", id, err)
+			continue
+		}
+		if t != nil {
+			awarded++
+		}
+	}
+	return awarded, nil
+}
+
+// QuoteRedemption prices a redemption against the customer's balance
+func (s *Service) QuoteRedemption(userID uuid.UUID, points int, target Target, productAmount, deliveryFee float64) (*Redemption, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var balance int
+	err := s.db.QueryRowContext(ctx,
+		`SELECT COALESCE((SELECT balance FROM loyalty_accounts WHERE user_id = $1), 0)`, userID.String(),
+	).Scan(&balance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get points balance: %w", err)
+	}
+
+	return Quote(points, balance, target, productAmount, deliveryFee)
+}
+
+// Redeem spends points on an order. It runs before the order is saved, so the order ID
+// is recorded without a foreign key.
+func (s *Service) Redeem(userID, orderID uuid.UUID, r *Redemption) (*Transaction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return s.post(ctx, userID, TransactionRedeem, -r.Points, &orderID, r.Amount, nil,
+		"Redeemed against "+strings.ReplaceAll(string(r.Target), "_", " "))
+}
+
+// Restore gives back the points redeemed on an order that was rejected or never saved.
+// Orders without a redemption are left alone.
+func (s *Service) Restore(orderID uuid.UUID) (*Transaction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var userIDStr string
+	var points int
+	var amount float64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT user_id, -points, amount FROM loyalty_transactions
+		WHERE type = 'redeem' AND order_id = $1
+	`, orderID.String()).Scan(&userIDStr, &points, &amount)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get redemption: %w", err)
+	}
+	userID, _ := uuid.Parse(userIDStr)
+
+	t, err := s.post(ctx, userID, TransactionRestore, points, &orderID, amount, nil, "Order was not completed")
+	if err == ErrAlreadyRecorded {
+		return nil, nil
+	}
+	return t, err
+}
+
+// ReverseForRefund takes back the share of an order's earned points that matches what
+// has been refunded on it so far. Points the customer has already spent can't be taken
+// back, so the reversal stops at their balance.
+func (s *Service) ReverseForRefund(orderID uuid.UUID) (*Transaction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var userIDStr string
+	var earned, reversed, balance int
+	var total, refunded float64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT t.user_id, t.points, t.amount,
+			COALESCE((SELECT -SUM(points) FROM loyalty_transactions
+				WHERE type = 'reverse' AND order_id = t.order_id), 0),
+			`+refundedColumn+`,
+			COALESCE((SELECT balance FROM loyalty_accounts WHERE user_id = t.user_id), 0)
+		FROM loyalty_transactions t
+		JOIN orders o ON o.id = t.order_id
+		WHERE t.type = 'earn' AND t.order_id = $1
+	`, orderID.String()).Scan(&userIDStr, &earned, &total, &reversed, &refunded, &balance)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get points earned on order: %w", err)
+	}
+	userID, _ := uuid.Parse(userIDStr)
+
+	points := reversePoints(earned, total, refunded, reversed)
+	if points > balance {
+		points = balance
+	}
+	if points <= 0 {
+		return nil, nil
+	}
+	return s.post(ctx, userID, TransactionReverse, -points, &orderID, refunded, nil, "Order was refunded")
+}
+
+// ExpirePoints lapses earned points past their expiry date that haven't been redeemed
+func (s *Service) ExpirePoints(now time.Time) (*ExpiryResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT user_id,
+			COALESCE(SUM(points) FILTER (WHERE type = 'earn' AND expires_at <= $1), 0),
+			COALESCE(-SUM(points) FILTER (WHERE type <> 'earn'), 0)
+		FROM loyalty_transactions
+		GROUP BY user_id
+		HAVING COALESCE(SUM(points) FILTER (WHERE type = 'earn' AND expires_at <= $1), 0) >
+			COALESCE(-SUM(points) FILTER (WHERE type <> 'earn'), 0)
+	`, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find expiring points: %w", err)
+	}
+
+	due := map[uuid.UUID]int{}
+	for rows.Next() {
+		var userIDStr string
+		var earnedExpired, consumed int
+		if err := rows.Scan(&userIDStr, &earnedExpired, &consumed); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan expiring points: %w", err)
+		}
+		userID, _ := uuid.Parse(userIDStr)
+		due[userID] = expiredPoints(earnedExpired, consumed)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := &ExpiryResult{}
+	for userID, points := range due {
+		if points <= 0 {
+			continue
+		}
+		if _, err := s.post(ctx, userID, TransactionExpire, -points, nil, 0, nil, "Points expired"); err != nil {
+			log.Printf("ERROR: Failed to expire loyalty points for user %s: %v", userID, err)
+			continue
+		}
+		result.Accounts++
+		result.Points += points
+	}
+	return result, nil
+}
@@ -0,0 +1,257 @@
+// Package money keeps amounts as whole ngwee, the kwacha's minor unit, so that prices,
+// fees and settlements add up exactly. Amounts still read and write JSON as kwacha
+// numbers, the way clients have always sent and received them.
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type Currency string
+
+// ZMW is the Zambian kwacha, the currency every order is priced and paid in
+const ZMW Currency = "ZMW"
+
+var (
+	ErrInvalidAmount = errors.New("invalid money amount")
+	ErrNoParts       = errors.New("cannot split an amount into no parts")
+)
+
+// Amount is a sum of money in ngwee (1/100 of a kwacha)
+type Amount int64
+
+// Money is an amount in a particular currency, as sent to payment gateways
+type Money struct {
+	Amount   Amount   `json:"amount"`
+	Currency Currency `json:"currency"`
+}
+
+// New pairs an amount with its currency
+func New(amount Amount, currency Currency) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// String formats the money the way gateways expect it, e.g. ZMW 250.50
+func (m Money) String() string {
+	return string(m.Currency) + " " + m.Amount.String()
+}
+
+// Ngwee returns an amount of n ngwee
+func Ngwee(n int64) Amount {
+	return Amount(n)
+}
+
+// Kwacha converts a kwacha value to an amount, rounding half a ngwee away from zero.
+// It is for values that are still float64, such as rates and legacy fields.
+func Kwacha(k float64) Amount {
+	if math.IsNaN(k) || math.IsInf(k, 0) {
+		return 0
+	}
+	// Going through the shortest decimal form rounds 1.005 up, as written, rather than
+	// down as its binary value would
+	a, err := Parse(strconv.FormatFloat(k, 'f', -1, 64))
+	if err != nil {
+		return Amount(math.Round(k * 100))
+	}
+	return a
+}
+
+// Parse reads a decimal kwacha amount such as "250", "250.5" or "-3.10". Digits past
+// the ngwee are rounded half away from zero.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	for _, part := range []string{whole, frac} {
+		for _, r := range part {
+			if r < '0' || r > '9' {
+				return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+			}
+		}
+	}
+
+	kwacha, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || kwacha > math.MaxInt64/100-1 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	frac += "000"
+	ngwee, _ := strconv.ParseInt(frac[:2], 10, 64)
+	if frac[2] >= '5' {
+		ngwee++
+	}
+
+	a := Amount(kwacha*100 + ngwee)
+	if negative {
+		a = -a
+	}
+	return a, nil
+}
+
+// Ngwee returns the amount as a whole number of ngwee
+func (a Amount) Ngwee() int64 {
+	return int64(a)
+}
+
+// Kwacha returns the amount in kwacha, for the places that still work in float64
+func (a Amount) Kwacha() float64 {
+	return float64(a) / 100
+}
+
+// String formats the amount in kwacha with two decimals, e.g. 250.50
+func (a Amount) String() string {
+	sign := ""
+	n := int64(a)
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, n/100, n%100)
+}
+
+// Times multiplies the amount by a whole quantity
+func (a Amount) Times(n int) Amount {
+	return a * Amount(n)
+}
+
+// MulRate applies a rate such as a 5% service charge, rounding half a ngwee away from zero
+func (a Amount) MulRate(rate float64) Amount {
+	return Amount(math.Round(float64(a) * rate))
+}
+
+// Split divides the amount into n parts that differ by at most a ngwee and add back up
+// to it exactly. Earlier parts take the leftover ngwee.
+func (a Amount) Split(n int) ([]Amount, error) {
+	if n <= 0 {
+		return nil, ErrNoParts
+	}
+	weights := make([]int64, n)
+	for i := range weights {
+		weights[i] = 1
+	}
+	return a.Allocate(weights...)
+}
+
+// Allocate divides the amount in proportion to weights, such as order lines by value, so
+// that the parts add back up to it exactly. Leftover ngwee go to the parts that lost the
+// most to rounding, earlier parts first.
+func (a Amount) Allocate(weights ...int64) ([]Amount, error) {
+	var total int64
+	for _, w := range weights {
+		if w < 0 {
+			return nil, fmt.Errorf("%w: negative weight", ErrInvalidAmount)
+		}
+		total += w
+	}
+	if len(weights) == 0 || total == 0 {
+		return nil, ErrNoParts
+	}
+
+	sign := Amount(1)
+	if a < 0 {
+		sign, a = -1, -a
+	}
+	parts := make([]Amount, len(weights))
+	remainders := make([]int64, len(weights))
+	allocated := Amount(0)
+	for i, w := range weights {
+		share := int64(a) * w
+		parts[i] = Amount(share / total)
+		remainders[i] = share % total
+		allocated += parts[i]
+	}
+	for left := a - allocated; left > 0; left-- {
+		best := 0
+		for i := range remainders {
+			if remainders[i] > remainders[best] {
+				best = i
+			}
+		}
+		parts[best]++
+		remainders[best] = -1
+	}
+	for i := range parts {
+		parts[i] *= sign
+	}
+	return parts, nil
+}
+
+// Min returns the smaller of two amounts
+func Min(a, b Amount) Amount {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Max returns the larger of two amounts
+func Max(a, b Amount) Amount {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// MarshalJSON writes the amount as a kwacha number, e.g. 250.5 as 250.50
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON reads a kwacha number, or a quoted one as PawaPay sends them
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	parsed, err := Parse(strings.Trim(s, `"`))
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Scan reads a NUMERIC kwacha column
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+		return nil
+	case int64:
+		*a = Amount(v * 100)
+		return nil
+	case float64:
+		*a = Kwacha(v)
+		return nil
+	case []byte:
+		return a.scanString(string(v))
+	case string:
+		return a.scanString(v)
+	}
+	return fmt.Errorf("%w: cannot scan %T", ErrInvalidAmount, src)
+}
+
+func (a *Amount) scanString(s string) error {
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Value writes the amount to a NUMERIC kwacha column as exact decimal text
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
@@ -0,0 +1,100 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]Amount{
+		"250":    25000,
+		"250.5":  25050,
+		"250.50": 25050,
+		".75":    75,
+		"-3.10":  -310,
+		"1.005":  101,
+		"1.004":  100,
+		" 12.3 ": 1230,
+	}
+	for in, want := range cases {
+		got, err := Parse(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"", ".", "12a", "1.2.3", "ZMW 10"} {
+		_, err := Parse(in)
+		assert.ErrorIs(t, err, ErrInvalidAmount, in)
+	}
+}
+
+func TestKwacha(t *testing.T) {
+	assert.Equal(t, Amount(101), Kwacha(1.005))
+	assert.Equal(t, Amount(30), Kwacha(0.1+0.2))
+	assert.Equal(t, Amount(-1250), Kwacha(-12.5))
+	assert.Equal(t, 12.5, Amount(1250).Kwacha())
+}
+
+func TestString(t *testing.T) {
+	assert.Equal(t, "250.50", Amount(25050).String())
+	assert.Equal(t, "0.05", Amount(5).String())
+	assert.Equal(t, "-0.05", Amount(-5).String())
+	assert.Equal(t, "ZMW 10.00", New(1000, ZMW).String())
+}
+
+func TestMulRate(t *testing.T) {
+	// A 5% service charge on K250.10 is 1250.5 ngwee, rounded up
+	assert.Equal(t, Amount(1251), Amount(25010).MulRate(0.05))
+	assert.Equal(t, Amount(3000), Amount(1500).Times(2))
+}
+
+func TestAllocate(t *testing.T) {
+	parts, err := Amount(1000).Split(3)
+	assert.NoError(t, err)
+	assert.Equal(t, []Amount{334, 333, 333}, parts)
+
+	parts, err = Amount(100).Allocate(1, 1, 1, 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []Amount{17, 17, 17, 16, 33}, parts)
+
+	parts, err = Amount(-1000).Split(3)
+	assert.NoError(t, err)
+	assert.Equal(t, []Amount{-334, -333, -333}, parts)
+
+	_, err = Amount(100).Split(0)
+	assert.ErrorIs(t, err, ErrNoParts)
+	_, err = Amount(100).Allocate(0, 0)
+	assert.ErrorIs(t, err, ErrNoParts)
+}
+
+func TestJSON(t *testing.T) {
+	var v struct {
+		Total Amount `json:"total"`
+		Fee   Amount `json:"fee"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(`{"total": 250.5, "fee": "10"}`), &v))
+	assert.Equal(t, Amount(25050), v.Total)
+	assert.Equal(t, Amount(1000), v.Fee)
+
+	out, err := json.Marshal(v)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"total": 250.5, "fee": 10}`, string(out))
+}
+
+func TestScan(t *testing.T) {
+	var a Amount
+	assert.NoError(t, a.Scan([]byte("99.95")))
+	assert.Equal(t, Amount(9995), a)
+	assert.NoError(t, a.Scan(12.3))
+	assert.Equal(t, Amount(1230), a)
+	assert.NoError(t, a.Scan(int64(7)))
+	assert.Equal(t, Amount(700), a)
+	assert.NoError(t, a.Scan(nil))
+	assert.Equal(t, Amount(0), a)
+
+	v, err := Amount(1230).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "12.30", v)
+}
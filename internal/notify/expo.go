@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const expoPushURL = "https://exp.host/--/api/v2/push/send"
+
+// PushMessage is a notification for one device
+type PushMessage struct {
+	To    string            `json:"to"`
+	Title string            `json:"title"`
+	Body  string            `json:"body"`
+	Data  map[string]string `json:"data,omitempty"`
+	Sound string            `json:"sound,omitempty"`
+}
+
+// ExpoClient sends push notifications to the mobile app through Expo's push service
+type ExpoClient struct {
+	HTTPClient  *http.Client
+	AccessToken string
+}
+
+func NewExpoClient(accessToken string) *ExpoClient {
+	return &ExpoClient{
+		HTTPClient:  &http.Client{Timeout: 15 * time.Second},
+		AccessToken: accessToken,
+	}
+}
+
+// IsExpoToken reports whether a device token looks like an Expo push token
+func IsExpoToken(token string) bool {
+	return strings.HasPrefix(token, "ExponentPushToken[") || strings.HasPrefix(token, "ExpoPushToken[")
+}
+
+// Send delivers one push message. Expo reports per-message errors in the response body
+// with a 200 status, so both are checked.
+func (c *ExpoClient) Send(msg PushMessage) error {
+	if !IsExpoToken(msg.To) {
+		return fmt.Errorf("invalid Expo push token")
+	}
+	if msg.Sound == "" {
+		msg.Sound = "default"
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, expoPushURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if c.AccessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.AccessToken)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("push request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("push response unreadable (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || result.Data.Status == "error" {
+		return fmt.Errorf("push rejected (status %d): %s", resp.StatusCode, result.Data.Message)
+	}
+
+	return nil
+}
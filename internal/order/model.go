@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/address"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
 )
 
 type OrderStatus string
@@ -25,6 +27,20 @@ const (
 	PaymentStatusFailed   PaymentStatus = "failed"
 	PaymentStatusRefunded PaymentStatus = "refunded"
 )
+
+type PaymentMethod string
+
+const (
+	PaymentMethodMobileMoney PaymentMethod = "mobile_money"
+	// PaymentMethodCard is paid on DPO's hosted card checkout page
+	PaymentMethodCard PaymentMethod = "card"
+	// PaymentMethodCash is paid to the courier at delivery and remitted later
+	PaymentMethodCash PaymentMethod = "cash"
+	// PaymentMethodOnAccount bills the order to the customer's business account
+	// instead of collecting payment at order time
+	PaymentMethodOnAccount PaymentMethod = "on_account"
+)
+
 const (
 	CylinderType3KG  CylinderType = "3KG"
 	CylinderType5KG  CylinderType = "5KG"
@@ -42,31 +58,86 @@ const (
 )
 
 type Order struct {
-	ID               uuid.UUID     `json:"id" db:"id"`
-	UserID           uuid.UUID     `json:"user_id" db:"user_id"`
-	ProviderID       *uuid.UUID    `json:"provider_id,omitempty" db:"provider_id"`
-	CourierID        *uuid.UUID    `json:"courier_id,omitempty" db:"courier_id"`
-	CourierName      string        `json:"courier_name,omitempty"`
-	CourierPhone     string        `json:"courier_phone,omitempty"`
-	Status           OrderStatus   `json:"status" db:"status"`
-	CourierStatus    string        `json:"courier_status" db:"courier_status"`
-	CylinderType     CylinderType  `json:"cylinder_type" db:"cylinder_type"`
-	Quantity         int           `json:"quantity" db:"quantity"`
-	PricePerUnit     float64       `json:"price_per_unit" db:"price_per_unit"`
-	TotalPrice       float64       `json:"total_price" db:"total_price"`
-	DeliveryFee      float64       `json:"delivery_fee" db:"delivery_fee"`
-	ServiceCharge    float64       `json:"service_charge" db:"service_charge"`
-	GrandTotal       float64       `json:"grand_total" db:"grand_total"`
-	DeliveryAddress  string        `json:"delivery_address" db:"delivery_address"`
-	DeliveryMethod   string        `json:"delivery_method" db:"delivery_method"`
-	PaymentMethod    string        `json:"payment_method" db:"payment_method"`
-	PaymentStatus    PaymentStatus `json:"payment_status" db:"payment_status"`
-	CurrentLatitude  *float64      `json:"current_latitude,omitempty" db:"current_latitude"`
-	CurrentLongitude *float64      `json:"current_longitude,omitempty" db:"current_longitude"`
-	CurrentAddress   *string       `json:"current_address,omitempty" db:"current_address"`
-	RideLink         string        `json:"ride_link" db:"ride_link"`
-	CreatedAt        time.Time     `json:"created_at" db:"created_at"`
-	UpdatedAt        time.Time     `json:"updated_at" db:"updated_at"`
+	ID            uuid.UUID    `json:"id" db:"id"`
+	UserID        uuid.UUID    `json:"user_id" db:"user_id"`
+	ProviderID    *uuid.UUID   `json:"provider_id,omitempty" db:"provider_id"`
+	CourierID     *uuid.UUID   `json:"courier_id,omitempty" db:"courier_id"`
+	CourierName   string       `json:"courier_name,omitempty"`
+	CourierPhone  string       `json:"courier_phone,omitempty"`
+	Status        OrderStatus  `json:"status" db:"status"`
+	CourierStatus string       `json:"courier_status" db:"courier_status"`
+	CylinderType  CylinderType `json:"cylinder_type" db:"cylinder_type"`
+	Quantity      int          `json:"quantity" db:"quantity"`
+	PricePerUnit  money.Amount `json:"price_per_unit" db:"price_per_unit"`
+	TotalPrice    money.Amount `json:"total_price" db:"total_price"`
+	DeliveryFee   money.Amount `json:"delivery_fee" db:"delivery_fee"`
+	ServiceCharge money.Amount `json:"service_charge" db:"service_charge"`
+	// Discount is taken off the total by a promo code or automatic campaign
+	Discount   money.Amount `json:"discount" db:"discount"`
+	PromoCode  string       `json:"promo_code,omitempty" db:"promo_code"`
+	CampaignID *uuid.UUID   `json:"campaign_id,omitempty" db:"campaign_id"`
+	// PointsRedeemed loyalty points were spent against PointsTarget for LoyaltyDiscount
+	PointsRedeemed  int          `json:"points_redeemed,omitempty" db:"points_redeemed"`
+	PointsTarget    string       `json:"points_target,omitempty" db:"points_target"`
+	LoyaltyDiscount money.Amount `json:"loyalty_discount" db:"loyalty_discount"`
+	GrandTotal      money.Amount `json:"grand_total" db:"grand_total"`
+	DeliveryAddress string       `json:"delivery_address" db:"delivery_address"`
+	DeliveryMethod  string       `json:"delivery_method" db:"delivery_method"`
+	DeliveryNotes   string       `json:"delivery_notes,omitempty" db:"delivery_notes"`
+	// StructuredAddress is the area, landmark and map pin behind DeliveryAddress, when the client sent one
+	StructuredAddress *address.Address `json:"structured_address,omitempty" db:"structured_address"`
+	// AddressID is the saved address the order was placed to, if any
+	AddressID     *uuid.UUID    `json:"address_id,omitempty" db:"address_id"`
+	PaymentMethod PaymentMethod `json:"payment_method" db:"payment_method"`
+	PaymentStatus PaymentStatus `json:"payment_status" db:"payment_status"`
+	// WalletPaid is the part of GrandTotal already paid from the customer's wallet
+	WalletPaid        money.Amount `json:"wallet_paid" db:"wallet_paid"`
+	CurrentLatitude   *float64     `json:"current_latitude,omitempty" db:"current_latitude"`
+	CurrentLongitude  *float64     `json:"current_longitude,omitempty" db:"current_longitude"`
+	CurrentAddress    *string      `json:"current_address,omitempty" db:"current_address"`
+	RideLink          string       `json:"ride_link" db:"ride_link"`
+	BusinessAccountID *uuid.UUID   `json:"business_account_id,omitempty" db:"business_account_id"`
+	DeliverySiteID    *uuid.UUID   `json:"delivery_site_id,omitempty" db:"delivery_site_id"`
+	CreatedAt         time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// Fees charged on top of the cylinder price
+const (
+	DefaultDeliveryFee money.Amount = 1000
+	ServiceChargeRate               = 0.05
+)
+
+// DefaultUnitPrice is charged per cylinder when the provider has no price listed for it
+const DefaultUnitPrice money.Amount = 10000
+
+// ApplyPricing fills in the order's price breakdown from the cylinder unit price. Any
+// discount already on the order is kept.
+func (o *Order) ApplyPricing(unitPrice money.Amount) {
+	o.PricePerUnit = unitPrice
+	o.TotalPrice = unitPrice.Times(o.Quantity)
+	o.DeliveryFee = DefaultDeliveryFee
+	o.ServiceCharge = o.TotalPrice.MulRate(ServiceChargeRate)
+	o.applyTotal()
+}
+
+// ApplyDiscount takes a discount off the order, never more than the cylinders and delivery cost
+func (o *Order) ApplyDiscount(amount money.Amount) {
+	o.Discount = amount
+	o.applyTotal()
+}
+
+// ApplyLoyalty takes the value of redeemed loyalty points off whatever the discount left
+func (o *Order) ApplyLoyalty(points int, amount money.Amount) {
+	o.PointsRedeemed = points
+	o.LoyaltyDiscount = amount
+	o.applyTotal()
+}
+
+func (o *Order) applyTotal() {
+	o.Discount = money.Max(0, money.Min(o.Discount, o.TotalPrice+o.DeliveryFee))
+	o.LoyaltyDiscount = money.Max(0, money.Min(o.LoyaltyDiscount, o.TotalPrice+o.DeliveryFee-o.Discount))
+	o.GrandTotal = o.TotalPrice + o.DeliveryFee + o.ServiceCharge - o.Discount - o.LoyaltyDiscount
 }
 
 type Location struct {
@@ -91,3 +162,20 @@ func StringToOrderStatus(s string) (OrderStatus, error) {
 		return "", fmt.Errorf("invalid order status: %s", s)
 	}
 }
+
+// ParsePaymentMethod validates a payment method, accepting the legacy "mobile-money"
+// spelling and defaulting to mobile money when none is given
+func ParsePaymentMethod(s string) (PaymentMethod, error) {
+	switch s {
+	case "", "mobile_money", "mobile-money":
+		return PaymentMethodMobileMoney, nil
+	case "card":
+		return PaymentMethodCard, nil
+	case "cash":
+		return PaymentMethodCash, nil
+	case "on_account":
+		return PaymentMethodOnAccount, nil
+	default:
+		return "", fmt.Errorf("invalid payment method: %s", s)
+	}
+}
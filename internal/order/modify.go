@@ -0,0 +1,345 @@
+package order
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/address"
+	"github.com/yakumwamba/lpg-delivery-system/internal/inventory"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+)
+
+var (
+	ErrOrderNotEditable    = errors.New("order can no longer be changed")
+	ErrOnlyAddressEditable = errors.New("only the delivery address and notes can be changed once the provider has accepted")
+	ErrNoChanges           = errors.New("no changes requested")
+	ErrCylinderUnavailable = errors.New("provider cannot supply this cylinder type and quantity")
+	ErrEmptyAddress        = errors.New("delivery address cannot be empty")
+	ErrDiscountedItems     = errors.New("quantity and cylinder type cannot be changed on an order with a promotion or loyalty points; cancel it and order again")
+)
+
+type AdjustmentType string
+type AdjustmentStatus string
+
+const (
+	AdjustmentNone   AdjustmentType = "none"
+	AdjustmentTopUp  AdjustmentType = "top_up"
+	AdjustmentRefund AdjustmentType = "refund"
+)
+
+const (
+	AdjustmentStatusNone      AdjustmentStatus = "none"
+	AdjustmentStatusPending   AdjustmentStatus = "pending"
+	AdjustmentStatusInitiated AdjustmentStatus = "initiated"
+	AdjustmentStatusCompleted AdjustmentStatus = "completed"
+	AdjustmentStatusFailed    AdjustmentStatus = "failed"
+)
+
+// ModifyOrderRequest holds the fields a customer wants to change; nil fields are left as they are
+type ModifyOrderRequest struct {
+	Quantity        *int          `json:"quantity" binding:"omitempty,gt=0"`
+	CylinderType    *CylinderType `json:"cylinder_type"`
+	DeliveryAddress *string       `json:"delivery_address"`
+	DeliveryNotes   *string       `json:"delivery_notes"`
+	// StructuredAddress replaces the delivery address; the caller validates it against the service zones
+	StructuredAddress *address.Address `json:"structured_address"`
+}
+
+// FieldChange is the before and after value of one edited field
+type FieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// OrderEdit is one entry in an order's edit history
+type OrderEdit struct {
+	ID               uuid.UUID              `json:"id"`
+	OrderID          uuid.UUID              `json:"order_id"`
+	EditedBy         uuid.UUID              `json:"edited_by"`
+	Changes          map[string]FieldChange `json:"changes"`
+	PreviousTotal    money.Amount           `json:"previous_total"`
+	NewTotal         money.Amount           `json:"new_total"`
+	AdjustmentType   AdjustmentType         `json:"adjustment_type"`
+	AdjustmentAmount money.Amount           `json:"adjustment_amount"`
+	AdjustmentStatus AdjustmentStatus       `json:"adjustment_status"`
+	AdjustmentRef    string                 `json:"adjustment_ref,omitempty"`
+	CreatedAt        time.Time              `json:"created_at"`
+
+	previousStatus OrderStatus
+}
+
+// PlanModification applies the requested changes to a copy of the customer's order and
+// reprices it, without saving anything. Everything can change while the order is pending;
+// once accepted only the address and notes can, and nothing after pickup.
+func (s *Service) PlanModification(userID, orderID uuid.UUID, req ModifyOrderRequest) (*Order, *OrderEdit, error) {
+	o, err := s.GetOrderByID(orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if o.UserID != userID {
+		return nil, nil, ErrOrderNotFound
+	}
+
+	changes := map[string]FieldChange{}
+	changesItems := (req.Quantity != nil && *req.Quantity != o.Quantity) ||
+		(req.CylinderType != nil && *req.CylinderType != o.CylinderType)
+
+	if err := checkEditable(o, changesItems); err != nil {
+		return nil, nil, err
+	}
+
+	edit := &OrderEdit{
+		OrderID:        o.ID,
+		EditedBy:       userID,
+		PreviousTotal:  o.GrandTotal,
+		previousStatus: o.Status,
+	}
+
+	if req.StructuredAddress != nil {
+		if o.StructuredAddress == nil || *o.StructuredAddress != *req.StructuredAddress {
+			changes["structured_address"] = FieldChange{From: o.StructuredAddress, To: req.StructuredAddress}
+			o.StructuredAddress = req.StructuredAddress
+		}
+		if req.DeliveryAddress == nil {
+			formatted := req.StructuredAddress.Format()
+			req.DeliveryAddress = &formatted
+		}
+		if req.DeliveryNotes == nil && req.StructuredAddress.Notes != "" {
+			req.DeliveryNotes = &req.StructuredAddress.Notes
+		}
+	}
+	if req.DeliveryAddress != nil {
+		deliveryAddress := strings.TrimSpace(*req.DeliveryAddress)
+		if deliveryAddress == "" {
+			return nil, nil, ErrEmptyAddress
+		}
+		if deliveryAddress != o.DeliveryAddress {
+			changes["delivery_address"] = FieldChange{From: o.DeliveryAddress, To: deliveryAddress}
+			o.DeliveryAddress = deliveryAddress
+		}
+	}
+	if req.DeliveryNotes != nil {
+		notes := strings.TrimSpace(*req.DeliveryNotes)
+		if notes != o.DeliveryNotes {
+			changes["delivery_notes"] = FieldChange{From: o.DeliveryNotes, To: notes}
+			o.DeliveryNotes = notes
+		}
+	}
+
+	if changesItems {
+		if req.Quantity != nil && *req.Quantity != o.Quantity {
+			changes["quantity"] = FieldChange{From: o.Quantity, To: *req.Quantity}
+			o.Quantity = *req.Quantity
+		}
+		if req.CylinderType != nil && *req.CylinderType != o.CylinderType {
+			changes["cylinder_type"] = FieldChange{From: o.CylinderType, To: *req.CylinderType}
+			o.CylinderType = *req.CylinderType
+		}
+		if err := s.repriceOrder(o, changes); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil, nil, ErrNoChanges
+	}
+
+	edit.Changes = changes
+	edit.NewTotal = o.GrandTotal
+	edit.AdjustmentType, edit.AdjustmentAmount = paymentAdjustment(o, edit.PreviousTotal)
+	edit.AdjustmentStatus = AdjustmentStatusNone
+	if edit.AdjustmentType != AdjustmentNone {
+		// A top-up is collected as a payment of its own, so the order stays paid for
+		// what was already collected while it is outstanding
+		edit.AdjustmentStatus = AdjustmentStatusPending
+	}
+
+	return o, edit, nil
+}
+
+// checkEditable reports why an order can't take the requested kind of change, if it can't.
+// A promotion's rules and the points redeemed were worked out on the original items, so
+// those can't change on a discounted order.
+func checkEditable(o *Order, changesItems bool) error {
+	switch o.Status {
+	case OrderStatusPending:
+	case OrderStatusAccepted:
+		if changesItems {
+			return ErrOnlyAddressEditable
+		}
+	default:
+		return ErrOrderNotEditable
+	}
+	if changesItems && (o.Discount > 0 || o.LoyaltyDiscount > 0) {
+		return ErrDiscountedItems
+	}
+	return nil
+}
+
+// repriceOrder prices the order at the provider's current rate and checks it has the stock
+func (s *Service) repriceOrder(o *Order, changes map[string]FieldChange) error {
+	if o.ProviderID == nil {
+		return ErrCylinderUnavailable
+	}
+
+	offer, err := s.inventoryService.GetOffer(*o.ProviderID, inventory.CylinderType(o.CylinderType))
+	if err != nil || offer.Price <= 0 || offer.StockQuantity < o.Quantity {
+		return ErrCylinderUnavailable
+	}
+
+	if offer.Price != o.PricePerUnit {
+		changes["price_per_unit"] = FieldChange{From: o.PricePerUnit, To: offer.Price}
+	}
+	o.ApplyPricing(offer.Price)
+
+	return nil
+}
+
+// paymentAdjustment works out whether a change in total needs money collected or returned.
+// Only mobile money orders that are already paid are adjusted; cash is collected at the
+// door and on-account orders are billed on the statement at their final total.
+func paymentAdjustment(o *Order, previousTotal money.Amount) (AdjustmentType, money.Amount) {
+	diff := o.GrandTotal - previousTotal
+	if diff == 0 || o.PaymentMethod != PaymentMethodMobileMoney || o.PaymentStatus != PaymentStatusPaid {
+		return AdjustmentNone, 0
+	}
+	if diff > 0 {
+		return AdjustmentTopUp, diff
+	}
+	return AdjustmentRefund, -diff
+}
+
+// SaveModification writes a planned modification and its history entry. It fails with
+// ErrOrderNotEditable if the order's status moved on since it was planned.
+func (s *Service) SaveModification(o *Order, edit *OrderEdit) error {
+	return s.saveModification(s.db, o, edit)
+}
+
+// SaveModificationTx is SaveModification as part of the caller's transaction
+func (s *Service) SaveModificationTx(tx *sql.Tx, o *Order, edit *OrderEdit) error {
+	return s.saveModification(tx, o, edit)
+}
+
+func (s *Service) saveModification(db dbtx, o *Order, edit *OrderEdit) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	changesJSON, err := json.Marshal(edit.Changes)
+	if err != nil {
+		return fmt.Errorf("failed to encode changes: %w", err)
+	}
+
+	o.UpdatedAt = time.Now()
+	result, err := db.ExecContext(ctx, `
+		UPDATE orders
+		SET quantity = $1, cylinder_type = $2, price_per_unit = $3, total_price = $4,
+			delivery_fee = $5, service_charge = $6, discount = $7, loyalty_discount = $8, grand_total = $9,
+			delivery_address = $10, delivery_notes = $11, structured_address = $12, payment_status = $13,
+			updated_at = $14
+		WHERE id = $15 AND status = $16
+	`, o.Quantity, o.CylinderType, o.PricePerUnit, o.TotalPrice,
+		o.DeliveryFee, o.ServiceCharge, o.Discount, o.LoyaltyDiscount, o.GrandTotal,
+		o.DeliveryAddress, o.DeliveryNotes, o.StructuredAddress, o.PaymentStatus,
+		o.UpdatedAt, o.ID.String(), edit.previousStatus)
+	if err != nil {
+		return fmt.Errorf("failed to update order: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrOrderNotEditable
+	}
+
+	var editIDStr string
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO order_edits (
+			order_id, edited_by, changes, previous_total, new_total,
+			adjustment_type, adjustment_amount, adjustment_status
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`, edit.OrderID.String(), edit.EditedBy.String(), changesJSON, edit.PreviousTotal, edit.NewTotal,
+		edit.AdjustmentType, edit.AdjustmentAmount, edit.AdjustmentStatus,
+	).Scan(&editIDStr, &edit.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record order edit: %w", err)
+	}
+	edit.ID, _ = uuid.Parse(editIDStr)
+
+	return nil
+}
+
+// UpdateEditAdjustment records the outcome of the top-up or refund an edit triggered
+func (s *Service) UpdateEditAdjustment(edit *OrderEdit, status AdjustmentStatus, reference string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE order_edits SET adjustment_status = $1, adjustment_ref = NULLIF($2, '')
+		WHERE id = $3
+	`, status, reference, edit.ID.String())
+	if err != nil {
+		return fmt.Errorf("failed to update edit adjustment: %w", err)
+	}
+
+	edit.AdjustmentStatus = status
+	edit.AdjustmentRef = reference
+	return nil
+}
+
+// SettleTopUp records the outcome of the top-up payment with the given reference on the
+// edit that started it. Only a top-up still waiting on the payer moves.
+func (s *Service) SettleTopUp(reference string, status AdjustmentStatus) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE order_edits SET adjustment_status = $1
+		WHERE adjustment_type = $2 AND adjustment_ref = $3 AND adjustment_status = $4
+	`, status, AdjustmentTopUp, reference, AdjustmentStatusInitiated)
+	if err != nil {
+		return fmt.Errorf("failed to settle top-up: %w", err)
+	}
+	return nil
+}
+
+// GetOrderEdits returns an order's edit history, oldest first
+func (s *Service) GetOrderEdits(orderID uuid.UUID) ([]OrderEdit, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, order_id, edited_by, changes, previous_total, new_total,
+			adjustment_type, adjustment_amount, adjustment_status, COALESCE(adjustment_ref, ''), created_at
+		FROM order_edits
+		WHERE order_id = $1
+		ORDER BY created_at
+	`, orderID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order edits: %w", err)
+	}
+	defer rows.Close()
+
+	edits := []OrderEdit{}
+	for rows.Next() {
+		var edit OrderEdit
+		var idStr, orderIDStr, editedByStr string
+		var changesJSON []byte
+		if err := rows.Scan(&idStr, &orderIDStr, &editedByStr, &changesJSON, &edit.PreviousTotal, &edit.NewTotal,
+			&edit.AdjustmentType, &edit.AdjustmentAmount, &edit.AdjustmentStatus, &edit.AdjustmentRef, &edit.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan order edit: %w", err)
+		}
+		edit.ID, _ = uuid.Parse(idStr)
+		edit.OrderID, _ = uuid.Parse(orderIDStr)
+		edit.EditedBy, _ = uuid.Parse(editedByStr)
+		if err := json.Unmarshal(changesJSON, &edit.Changes); err != nil {
+			return nil, fmt.Errorf("failed to decode order edit: %w", err)
+		}
+		edits = append(edits, edit)
+	}
+
+	return edits, rows.Err()
+}
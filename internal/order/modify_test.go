@@ -0,0 +1,46 @@
+package order
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+)
+
+func TestPaymentAdjustment(t *testing.T) {
+	paid := &Order{PaymentMethod: PaymentMethodMobileMoney, PaymentStatus: PaymentStatusPaid, GrandTotal: 32550}
+
+	kind, amount := paymentAdjustment(paid, 22025)
+	assert.Equal(t, AdjustmentTopUp, kind)
+	assert.Equal(t, money.Amount(10525), amount)
+
+	kind, amount = paymentAdjustment(paid, 43075)
+	assert.Equal(t, AdjustmentRefund, kind)
+	assert.Equal(t, money.Amount(10525), amount)
+
+	kind, _ = paymentAdjustment(paid, 32550)
+	assert.Equal(t, AdjustmentNone, kind)
+
+	unpaid := &Order{PaymentMethod: PaymentMethodMobileMoney, PaymentStatus: PaymentStatusPending, GrandTotal: 32550}
+	kind, _ = paymentAdjustment(unpaid, 22025)
+	assert.Equal(t, AdjustmentNone, kind)
+
+	cash := &Order{PaymentMethod: PaymentMethodCash, PaymentStatus: PaymentStatusPaid, GrandTotal: 32550}
+	kind, _ = paymentAdjustment(cash, 22025)
+	assert.Equal(t, AdjustmentNone, kind)
+}
+
+func TestCheckEditable(t *testing.T) {
+	pending := &Order{Status: OrderStatusPending}
+	assert.NoError(t, checkEditable(pending, true))
+	assert.NoError(t, checkEditable(&Order{Status: OrderStatusAccepted}, false))
+	assert.ErrorIs(t, checkEditable(&Order{Status: OrderStatusAccepted}, true), ErrOnlyAddressEditable)
+	assert.ErrorIs(t, checkEditable(&Order{Status: OrderStatusInTransit}, false), ErrOrderNotEditable)
+
+	// Discounted orders keep their items but can still be redirected
+	promoted := &Order{Status: OrderStatusPending, Discount: 2000}
+	assert.ErrorIs(t, checkEditable(promoted, true), ErrDiscountedItems)
+	assert.NoError(t, checkEditable(promoted, false))
+	withPoints := &Order{Status: OrderStatusPending, LoyaltyDiscount: 500}
+	assert.ErrorIs(t, checkEditable(withPoints, true), ErrDiscountedItems)
+}
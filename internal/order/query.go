@@ -0,0 +1,252 @@
+package order
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+)
+
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+// SortField is a column order listings can be sorted by
+type SortField string
+
+const (
+	SortByCreatedAt  SortField = "created_at"
+	SortByGrandTotal SortField = "grand_total"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidFilter = errors.New("invalid order filter")
+)
+
+// ListFilter narrows and pages an order listing. Zero values mean "no filter".
+type ListFilter struct {
+	Status        []OrderStatus
+	PaymentStatus PaymentStatus
+	CylinderType  CylinderType
+	From          *time.Time
+	To            *time.Time
+	Search        string
+	SortBy        SortField
+	Ascending     bool
+	Cursor        string
+	Limit         int
+}
+
+// OrderPage is one page of an order listing. NextCursor is empty on the last page.
+type OrderPage struct {
+	Orders     []Order `json:"orders"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// cursor marks the last row of a page: its sort key value and ID as a tie-breaker
+type cursor struct {
+	SortBy SortField
+	Value  string
+	ID     uuid.UUID
+}
+
+func (c cursor) encode() string {
+	raw := fmt.Sprintf("%s|%s|%s", c.SortBy, c.Value, c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), "|", 3)
+	if len(parts) != 3 {
+		return nil, ErrInvalidCursor
+	}
+
+	id, err := uuid.Parse(parts[2])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor{SortBy: SortField(parts[0]), Value: parts[1], ID: id}, nil
+}
+
+// cursorFor builds the cursor pointing after the given order
+func cursorFor(o Order, sortBy SortField) cursor {
+	value := o.CreatedAt.UTC().Format(time.RFC3339Nano)
+	if sortBy == SortByGrandTotal {
+		value = o.GrandTotal.String()
+	}
+	return cursor{SortBy: sortBy, Value: value, ID: o.ID}
+}
+
+func (f *ListFilter) normalize() error {
+	if f.Limit <= 0 {
+		f.Limit = DefaultListLimit
+	}
+	if f.Limit > MaxListLimit {
+		f.Limit = MaxListLimit
+	}
+
+	switch f.SortBy {
+	case "":
+		f.SortBy = SortByCreatedAt
+	case SortByCreatedAt, SortByGrandTotal:
+	default:
+		return fmt.Errorf("%w: unknown sort field %q", ErrInvalidFilter, f.SortBy)
+	}
+
+	if f.From != nil && f.To != nil && f.To.Before(*f.From) {
+		return fmt.Errorf("%w: date range end is before its start", ErrInvalidFilter)
+	}
+
+	return nil
+}
+
+// orderListQuery builds the SELECT shared by the customer, provider and courier listings
+type orderListQuery struct {
+	conditions []string
+	args       []interface{}
+}
+
+func (q *orderListQuery) where(condition string, args ...interface{}) {
+	for _, arg := range args {
+		q.args = append(q.args, arg)
+		condition = strings.Replace(condition, "?", fmt.Sprintf("$%d", len(q.args)), 1)
+	}
+	q.conditions = append(q.conditions, condition)
+}
+
+func buildOrderListQuery(ownerColumn string, ownerID uuid.UUID, f ListFilter) (string, []interface{}, error) {
+	q := &orderListQuery{}
+	q.where("o."+ownerColumn+" = ?", ownerID.String())
+
+	if len(f.Status) > 0 {
+		placeholders := make([]string, len(f.Status))
+		for i := range f.Status {
+			placeholders[i] = "?"
+		}
+		args := make([]interface{}, len(f.Status))
+		for i, status := range f.Status {
+			args[i] = status
+		}
+		q.where("o.status IN ("+strings.Join(placeholders, ", ")+")", args...)
+	}
+	if f.PaymentStatus != "" {
+		q.where("o.payment_status = ?", f.PaymentStatus)
+	}
+	if f.CylinderType != "" {
+		q.where("o.cylinder_type = ?", f.CylinderType)
+	}
+	if f.From != nil {
+		q.where("o.created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		q.where("o.created_at < ?", *f.To)
+	}
+	if search := strings.TrimSpace(f.Search); search != "" {
+		pattern := "%" + search + "%"
+		q.where("(o.delivery_address ILIKE ? OR CAST(o.id AS TEXT) ILIKE ?)", pattern, pattern)
+	}
+
+	sortColumn := "o." + string(f.SortBy)
+	direction, comparator := "DESC", "<"
+	if f.Ascending {
+		direction, comparator = "ASC", ">"
+	}
+
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		if c.SortBy != f.SortBy {
+			return "", nil, ErrInvalidCursor
+		}
+
+		var value interface{}
+		switch c.SortBy {
+		case SortByGrandTotal:
+			v, err := money.Parse(c.Value)
+			if err != nil {
+				return "", nil, ErrInvalidCursor
+			}
+			value = v
+		default:
+			v, err := time.Parse(time.RFC3339Nano, c.Value)
+			if err != nil {
+				return "", nil, ErrInvalidCursor
+			}
+			value = v
+		}
+		q.where(fmt.Sprintf("(%s, o.id) %s (?, ?)", sortColumn, comparator), value, c.ID.String())
+	}
+
+	// Fetch one extra row to know whether another page follows
+	q.args = append(q.args, f.Limit+1)
+	query := fmt.Sprintf(`
+		SELECT %s,
+			COALESCE(c.name, '') as courier_name, COALESCE(c.phone_number, '') as courier_phone
+		FROM orders o
+		LEFT JOIN users c ON o.courier_id = c.id
+		WHERE %s
+		ORDER BY %s %s, o.id %s
+		LIMIT $%d
+	`, orderColumns, strings.Join(q.conditions, " AND "), sortColumn, direction, direction, len(q.args))
+
+	return query, q.args, nil
+}
+
+// listOrders runs a filtered, paginated listing of the orders whose ownerColumn matches ownerID
+func (s *Service) listOrders(ownerColumn string, ownerID uuid.UUID, f ListFilter) (*OrderPage, error) {
+	if err := f.normalize(); err != nil {
+		return nil, err
+	}
+
+	query, args, err := buildOrderListQuery(ownerColumn, ownerID, f)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []Order{}
+	for rows.Next() {
+		var courierName, courierPhone string
+		order, err := scanOrder(rows, &courierName, &courierPhone)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		order.CourierName, order.CourierPhone = courierName, courierPhone
+		orders = append(orders, *order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating orders: %w", err)
+	}
+
+	page := &OrderPage{Orders: orders}
+	if len(orders) > f.Limit {
+		page.Orders = orders[:f.Limit]
+		page.NextCursor = cursorFor(page.Orders[f.Limit-1], f.SortBy).encode()
+	}
+
+	return page, nil
+}
@@ -0,0 +1,82 @@
+package order
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	o := Order{ID: uuid.New(), CreatedAt: time.Date(2025, 3, 1, 10, 30, 0, 123456000, time.UTC), GrandTotal: 31550}
+
+	for _, sortBy := range []SortField{SortByCreatedAt, SortByGrandTotal} {
+		encoded := cursorFor(o, sortBy).encode()
+		decoded, err := decodeCursor(encoded)
+		require.NoError(t, err)
+		assert.Equal(t, sortBy, decoded.SortBy)
+		assert.Equal(t, o.ID, decoded.ID)
+	}
+
+	_, err := decodeCursor("not-a-cursor")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestBuildOrderListQuery(t *testing.T) {
+	ownerID := uuid.New()
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	filter := ListFilter{
+		Status:        []OrderStatus{OrderStatusPending, OrderStatusAccepted},
+		PaymentStatus: PaymentStatusPaid,
+		From:          &from,
+		Search:        "Kabulonga",
+	}
+	require.NoError(t, filter.normalize())
+
+	query, args, err := buildOrderListQuery("provider_id", ownerID, filter)
+	require.NoError(t, err)
+
+	assert.Contains(t, query, "o.provider_id = $1")
+	assert.Contains(t, query, "o.status IN ($2, $3)")
+	assert.Contains(t, query, "o.payment_status = $4")
+	assert.Contains(t, query, "o.created_at >= $5")
+	assert.Contains(t, query, "ILIKE $6 OR CAST(o.id AS TEXT) ILIKE $7")
+	assert.Contains(t, query, "ORDER BY o.created_at DESC, o.id DESC")
+	assert.Contains(t, query, "LIMIT $8")
+	assert.Len(t, args, 8)
+	assert.Equal(t, DefaultListLimit+1, args[7])
+
+	// Listings read the same columns as a single order lookup
+	assert.Contains(t, query, orderColumns)
+	assert.Contains(t, query, "o.wallet_paid")
+}
+
+func TestBuildOrderListQueryCursor(t *testing.T) {
+	last := Order{ID: uuid.New(), GrandTotal: 12000}
+	filter := ListFilter{SortBy: SortByGrandTotal, Ascending: true, Cursor: cursorFor(last, SortByGrandTotal).encode()}
+	require.NoError(t, filter.normalize())
+
+	query, args, err := buildOrderListQuery("user_id", uuid.New(), filter)
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(query, "(o.grand_total, o.id) > ($2, $3)"))
+	assert.Equal(t, money.Amount(12000), args[1])
+
+	// A cursor issued for another sort order cannot be reused
+	filter.SortBy = SortByCreatedAt
+	_, _, err = buildOrderListQuery("user_id", uuid.New(), filter)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestListFilterNormalize(t *testing.T) {
+	f := ListFilter{Limit: 1000}
+	require.NoError(t, f.normalize())
+	assert.Equal(t, MaxListLimit, f.Limit)
+	assert.Equal(t, SortByCreatedAt, f.SortBy)
+
+	f = ListFilter{SortBy: "price"}
+	assert.ErrorIs(t, f.normalize(), ErrInvalidFilter)
+}
@@ -0,0 +1,302 @@
+package order
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/inventory"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+	"github.com/yakumwamba/lpg-delivery-system/internal/preferences"
+)
+
+var (
+	ErrNoOrderHistory      = errors.New("no previous order to base a suggestion on")
+	ErrNoProviderAvailable = errors.New("no provider currently has this cylinder in stock")
+)
+
+// suggestionWindow is how many recent orders are considered when picking the usual cylinder
+const suggestionWindow = 10
+
+// ReorderDraft is a prefilled order built from a customer's history, priced at current rates
+type ReorderDraft struct {
+	Order              *Order       `json:"order"`
+	SourceOrderID      *uuid.UUID   `json:"source_order_id,omitempty"`
+	OriginalProviderID *uuid.UUID   `json:"original_provider_id,omitempty"`
+	ProviderChanged    bool         `json:"provider_changed"`
+	PreviousUnitPrice  money.Amount `json:"previous_unit_price,omitempty"`
+	PriceChanged       bool         `json:"price_changed"`
+	Notes              []string     `json:"notes,omitempty"`
+}
+
+// ReorderOverrides are the fields a customer may change when repeating an order.
+// Zero values keep what the original order had.
+type ReorderOverrides struct {
+	Quantity        int
+	PaymentMethod   PaymentMethod
+	DeliveryAddress string
+	DeliverySiteID  *uuid.UUID
+}
+
+// BuildReorder prefills a new order from one of the customer's previous orders.
+// The price and stock are checked again and another provider is chosen if the
+// original one no longer has the cylinder.
+func (s *Service) BuildReorder(userID, sourceOrderID uuid.UUID, overrides ReorderOverrides) (*ReorderDraft, error) {
+	source, err := s.GetOrderByID(sourceOrderID)
+	if err != nil {
+		return nil, err
+	}
+	if source.UserID != userID {
+		return nil, ErrOrderNotFound
+	}
+
+	draft := draftFromOrder(source)
+	o := draft.Order
+	if overrides.Quantity > 0 {
+		o.Quantity = overrides.Quantity
+	}
+	if overrides.PaymentMethod != "" {
+		o.PaymentMethod = overrides.PaymentMethod
+	}
+	if overrides.DeliveryAddress != "" {
+		o.DeliveryAddress = overrides.DeliveryAddress
+		o.StructuredAddress = nil
+		o.AddressID = nil
+	}
+	if overrides.DeliverySiteID != nil {
+		o.DeliverySiteID = overrides.DeliverySiteID
+	}
+
+	prefs, err := s.userPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	latitude, longitude := draftLocation(o, prefs)
+	if err := s.priceDraft(draft, latitude, longitude); err != nil {
+		return nil, err
+	}
+
+	return draft, nil
+}
+
+// SuggestNextOrder proposes the customer's likely next order: their preferred cylinder,
+// or the one they order most, delivered to their default saved address by their last provider
+func (s *Service) SuggestNextOrder(userID uuid.UUID) (*ReorderDraft, error) {
+	prefs, err := s.userPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	last, err := s.lastOrder(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var cylinderType CylinderType
+	if prefs != nil && prefs.PreferredCylinderType != nil && *prefs.PreferredCylinderType != "" {
+		cylinderType = CylinderType(*prefs.PreferredCylinderType)
+	} else if cylinderType, err = s.usualCylinderType(userID); err != nil {
+		return nil, err
+	}
+	if last == nil && cylinderType == "" {
+		return nil, ErrNoOrderHistory
+	}
+
+	var draft *ReorderDraft
+	if last != nil {
+		draft = draftFromOrder(last)
+	} else {
+		draft = &ReorderDraft{Order: &Order{
+			UserID:         userID,
+			Quantity:       1,
+			DeliveryMethod: "delivery",
+			PaymentMethod:  PaymentMethodMobileMoney,
+			Status:         OrderStatusPending,
+			PaymentStatus:  PaymentStatusPending,
+		}}
+	}
+
+	o := draft.Order
+	if cylinderType != "" && cylinderType != o.CylinderType {
+		o.CylinderType = cylinderType
+		o.Quantity = 1
+	}
+	if o.DeliverySiteID == nil {
+		saved, err := s.addressService.GetDefault(userID)
+		if err != nil {
+			return nil, err
+		}
+		if saved != nil {
+			o.AddressID = &saved.ID
+			o.StructuredAddress = &saved.Address
+			o.DeliveryAddress = saved.Formatted
+		} else if prefs != nil && prefs.PreferredAddress != nil && *prefs.PreferredAddress != "" {
+			o.DeliveryAddress = *prefs.PreferredAddress
+		}
+	}
+
+	latitude, longitude := draftLocation(o, prefs)
+	if err := s.priceDraft(draft, latitude, longitude); err != nil {
+		return nil, err
+	}
+
+	return draft, nil
+}
+
+// draftFromOrder copies what is worth repeating from a previous order
+func draftFromOrder(source *Order) *ReorderDraft {
+	sourceID := source.ID
+	o := &Order{
+		UserID:            source.UserID,
+		ProviderID:        source.ProviderID,
+		CylinderType:      source.CylinderType,
+		Quantity:          source.Quantity,
+		DeliveryAddress:   source.DeliveryAddress,
+		DeliveryNotes:     source.DeliveryNotes,
+		StructuredAddress: source.StructuredAddress,
+		AddressID:         source.AddressID,
+		DeliveryMethod:    source.DeliveryMethod,
+		PaymentMethod:     source.PaymentMethod,
+		DeliverySiteID:    source.DeliverySiteID,
+		Status:            OrderStatusPending,
+		PaymentStatus:     PaymentStatusPending,
+	}
+	if o.PaymentMethod == "" {
+		o.PaymentMethod = PaymentMethodMobileMoney
+	}
+
+	return &ReorderDraft{
+		Order:              o,
+		SourceOrderID:      &sourceID,
+		OriginalProviderID: source.ProviderID,
+		PreviousUnitPrice:  source.PricePerUnit,
+	}
+}
+
+// draftLocation is where the draft will be delivered, used to pick a nearby provider
+func draftLocation(o *Order, prefs *preferences.UserPreferences) (*float64, *float64) {
+	if o.StructuredAddress != nil {
+		return &o.StructuredAddress.Latitude, &o.StructuredAddress.Longitude
+	}
+	if prefs != nil {
+		return prefs.PreferredLatitude, prefs.PreferredLongitude
+	}
+	return nil, nil
+}
+
+// priceDraft prices the draft at the provider's current rate, switching to the provider
+// with stock nearest the delivery location when the original provider cannot fill it
+func (s *Service) priceDraft(draft *ReorderDraft, latitude, longitude *float64) error {
+	o := draft.Order
+	cylinderType := inventory.CylinderType(o.CylinderType)
+
+	if o.ProviderID != nil {
+		offer, err := s.inventoryService.GetOffer(*o.ProviderID, cylinderType)
+		switch {
+		case err != nil || offer.Price <= 0:
+			draft.Notes = append(draft.Notes, fmt.Sprintf("Your previous provider no longer offers %s cylinders", o.CylinderType))
+		case offer.StockQuantity < o.Quantity:
+			draft.Notes = append(draft.Notes, fmt.Sprintf("Your previous provider only has %d %s cylinders in stock", offer.StockQuantity, o.CylinderType))
+		default:
+			s.applyDraftPrice(draft, offerUnitPrice(offer))
+			return nil
+		}
+	}
+
+	exclude := uuid.Nil
+	if o.ProviderID != nil {
+		exclude = *o.ProviderID
+	}
+	offer, err := s.inventoryService.FindAlternativeOffer(cylinderType, o.Quantity, exclude, latitude, longitude)
+	if err != nil {
+		return err
+	}
+	if offer == nil {
+		return ErrNoProviderAvailable
+	}
+
+	providerID := offer.ProviderID
+	o.ProviderID = &providerID
+	if draft.OriginalProviderID != nil {
+		draft.ProviderChanged = true
+		draft.Notes = append(draft.Notes, "A nearby provider with stock has been selected instead")
+	}
+	s.applyDraftPrice(draft, offerUnitPrice(offer))
+
+	return nil
+}
+
+func (s *Service) applyDraftPrice(draft *ReorderDraft, unitPrice money.Amount) {
+	draft.Order.ApplyPricing(unitPrice)
+	if draft.PreviousUnitPrice > 0 && draft.PreviousUnitPrice != unitPrice {
+		draft.PriceChanged = true
+		draft.Notes = append(draft.Notes, fmt.Sprintf("The price per cylinder is now %s (was %s)", unitPrice, draft.PreviousUnitPrice))
+	}
+}
+
+func (s *Service) userPreferences(userID uuid.UUID) (*preferences.UserPreferences, error) {
+	prefs, err := s.preferencesService.GetUserPreferences(userID)
+	if err != nil {
+		if errors.Is(err, preferences.ErrPreferencesNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return prefs, nil
+}
+
+// lastOrder returns the customer's most recent order that was not rejected, or nil
+func (s *Service) lastOrder(userID uuid.UUID) (*Order, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var orderIDStr string
+	query := `
+		SELECT id FROM orders
+		WHERE user_id = $1 AND status <> 'rejected'
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+	err := s.db.QueryRowContext(ctx, query, userID.String()).Scan(&orderIDStr)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get last order: %w", err)
+	}
+
+	orderID, _ := uuid.Parse(orderIDStr)
+	return s.GetOrderByID(orderID)
+}
+
+// usualCylinderType returns the cylinder type the customer ordered most among their recent orders
+func (s *Service) usualCylinderType(userID uuid.UUID) (CylinderType, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var cylinderType CylinderType
+	query := `
+		SELECT cylinder_type
+		FROM (
+			SELECT cylinder_type, created_at FROM orders
+			WHERE user_id = $1 AND status <> 'rejected'
+			ORDER BY created_at DESC
+			LIMIT $2
+		) recent
+		GROUP BY cylinder_type
+		ORDER BY COUNT(*) DESC, MAX(created_at) DESC
+		LIMIT 1
+	`
+	err := s.db.QueryRowContext(ctx, query, userID.String(), suggestionWindow).Scan(&cylinderType)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get usual cylinder type: %w", err)
+	}
+
+	return cylinderType, nil
+}
@@ -0,0 +1,44 @@
+package order
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/yakumwamba/lpg-delivery-system/internal/inventory"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+)
+
+func TestDraftFromOrderResetsStatusAndKeepsPreviousPrice(t *testing.T) {
+	providerID := uuid.New()
+	source := &Order{
+		ID:              uuid.New(),
+		UserID:          uuid.New(),
+		ProviderID:      &providerID,
+		Status:          OrderStatusDelivered,
+		PaymentStatus:   PaymentStatusPaid,
+		CylinderType:    CylinderType12KG,
+		Quantity:        2,
+		PricePerUnit:    30000,
+		DeliveryAddress: "Plot 12, Kabulonga",
+	}
+
+	draft := draftFromOrder(source)
+
+	assert.Equal(t, OrderStatusPending, draft.Order.Status)
+	assert.Equal(t, PaymentStatusPending, draft.Order.PaymentStatus)
+	assert.Equal(t, PaymentMethodMobileMoney, draft.Order.PaymentMethod)
+	assert.Equal(t, source.ID, *draft.SourceOrderID)
+	assert.Equal(t, 2, draft.Order.Quantity)
+
+	(&Service{}).applyDraftPrice(draft, 32000)
+	assert.True(t, draft.PriceChanged)
+	assert.Equal(t, money.Amount(64000), draft.Order.TotalPrice)
+	assert.Equal(t, money.Amount(64000+1000+3200), draft.Order.GrandTotal)
+}
+
+func TestOfferUnitPrice(t *testing.T) {
+	assert.Equal(t, money.Amount(32000), offerUnitPrice(&inventory.Offer{Price: 32000}))
+	assert.Equal(t, DefaultUnitPrice, offerUnitPrice(&inventory.Offer{}))
+	assert.Equal(t, DefaultUnitPrice, offerUnitPrice(nil))
+}
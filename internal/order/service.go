@@ -8,23 +8,98 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/address"
 	"github.com/yakumwamba/lpg-delivery-system/internal/courier"
+	"github.com/yakumwamba/lpg-delivery-system/internal/inventory"
 	"github.com/yakumwamba/lpg-delivery-system/internal/location"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+	"github.com/yakumwamba/lpg-delivery-system/internal/preferences"
 )
 
+var ErrOrderNotFound = errors.New("order not found")
+
 type Service struct {
-	db             *sql.DB
-	courierService *courier.Service
+	db                 *sql.DB
+	courierService     *courier.Service
+	inventoryService   *inventory.Service
+	preferencesService *preferences.Service
+	addressService     *address.Service
 }
 
 func NewService(db *sql.DB) *Service {
 	return &Service{
-		db:             db,
-		courierService: courier.NewService(db),
+		db:                 db,
+		courierService:     courier.NewService(db),
+		inventoryService:   inventory.NewService(db),
+		preferencesService: preferences.NewService(db),
+		addressService:     address.NewService(db),
+	}
+}
+
+// dbtx is what both *sql.DB and *sql.Tx offer, so writes can join a caller's transaction
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// UnitPrice is what one cylinder of the type costs from the provider: its listed price, or
+// DefaultUnitPrice when it has none. Every way of placing or quoting an order prices
+// through it so the same cylinder costs the same everywhere.
+func (s *Service) UnitPrice(providerID *uuid.UUID, cylinderType CylinderType) money.Amount {
+	if providerID == nil {
+		return DefaultUnitPrice
+	}
+	offer, err := s.inventoryService.GetOffer(*providerID, inventory.CylinderType(cylinderType))
+	if err != nil {
+		return DefaultUnitPrice
+	}
+	return offerUnitPrice(offer)
+}
+
+// offerUnitPrice is the per-cylinder price to charge for a provider's inventory offer
+func offerUnitPrice(offer *inventory.Offer) money.Amount {
+	if offer == nil || offer.Price <= 0 {
+		return DefaultUnitPrice
 	}
+	return offer.Price
 }
 
 func (s *Service) CreateOrder(order *Order) (*Order, error) {
+	return s.createOrder(s.db, order)
+}
+
+// CreateOrderTx saves an order as part of the caller's transaction, such as the one
+// holding a business account's credit while an on-account order is placed
+func (s *Service) CreateOrderTx(tx *sql.Tx, order *Order) (*Order, error) {
+	return s.createOrder(tx, order)
+}
+
+// CreateOrderWith saves an order and runs then in the same transaction, such as to count
+// the order against a promotion's caps. If then fails the order is not saved.
+func (s *Service) CreateOrderWith(order *Order, then func(tx *sql.Tx, created *Order) error) (*Order, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	created, err := s.createOrder(tx, order)
+	if err != nil {
+		return nil, err
+	}
+	if err := then(tx, created); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit order: %w", err)
+	}
+	return created, nil
+}
+
+func (s *Service) createOrder(db dbtx, order *Order) (*Order, error) {
 	// Validate order
 	if err := s.validateOrder(order); err != nil {
 		return nil, fmt.Errorf("invalid order: %v", err)
@@ -47,8 +122,10 @@ func (s *Service) CreateOrder(order *Order) (*Order, error) {
 			quantity, price_per_unit, total_price, delivery_fee, service_charge,
 			grand_total, delivery_address, delivery_method, payment_method,
 			payment_status, current_latitude, current_longitude, current_address,
-			ride_link, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)
+			ride_link, business_account_id, delivery_site_id, delivery_notes, structured_address,
+			address_id, discount, promo_code, campaign_id, points_redeemed, points_target, loyalty_discount,
+			created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, NULLIF($28, ''), $29, $30, NULLIF($31, ''), $32, $33, $34)
 		RETURNING id, created_at, updated_at
 	`
 
@@ -62,14 +139,36 @@ func (s *Service) CreateOrder(order *Order) (*Order, error) {
 		courierIDStr = sql.NullString{String: order.CourierID.String(), Valid: true}
 	}
 
+	var businessAccountIDStr sql.NullString
+	if order.BusinessAccountID != nil {
+		businessAccountIDStr = sql.NullString{String: order.BusinessAccountID.String(), Valid: true}
+	}
+
+	var deliverySiteIDStr sql.NullString
+	if order.DeliverySiteID != nil {
+		deliverySiteIDStr = sql.NullString{String: order.DeliverySiteID.String(), Valid: true}
+	}
+
+	var addressIDStr sql.NullString
+	if order.AddressID != nil {
+		addressIDStr = sql.NullString{String: order.AddressID.String(), Valid: true}
+	}
+
+	var campaignIDStr sql.NullString
+	if order.CampaignID != nil {
+		campaignIDStr = sql.NullString{String: order.CampaignID.String(), Valid: true}
+	}
+
 	var orderIDStr string
-	err := s.db.QueryRowContext(ctx, query,
+	err := db.QueryRowContext(ctx, query,
 		order.ID.String(), order.UserID.String(), providerIDStr, courierIDStr, order.Status, order.CourierStatus,
 		order.CylinderType, order.Quantity, order.PricePerUnit, order.TotalPrice,
 		order.DeliveryFee, order.ServiceCharge, order.GrandTotal, order.DeliveryAddress,
 		order.DeliveryMethod, order.PaymentMethod, order.PaymentStatus,
 		order.CurrentLatitude, order.CurrentLongitude, order.CurrentAddress,
-		order.RideLink, order.CreatedAt, order.UpdatedAt,
+		order.RideLink, businessAccountIDStr, deliverySiteIDStr, order.DeliveryNotes, order.StructuredAddress,
+		addressIDStr, order.Discount, order.PromoCode, campaignIDStr, order.PointsRedeemed, order.PointsTarget,
+		order.LoyaltyDiscount, order.CreatedAt, order.UpdatedAt,
 	).Scan(&orderIDStr, &order.CreatedAt, &order.UpdatedAt)
 
 	if err == nil {
@@ -106,68 +205,9 @@ func (s *Service) validateOrder(order *Order) error {
 	return nil
 }
 
-func (s *Service) GetUserOrders(userID uuid.UUID) ([]Order, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	query := `
-		SELECT o.id, o.user_id, o.provider_id, o.courier_id, 
-			COALESCE(c.name, '') as courier_name, COALESCE(c.phone_number, '') as courier_phone,
-			o.status, COALESCE(o.courier_status, 'pending'), o.cylinder_type,
-			o.quantity, o.price_per_unit, o.total_price, o.delivery_fee, o.service_charge,
-			o.grand_total, o.delivery_address, o.delivery_method, o.payment_method,
-			o.payment_status, o.current_latitude, o.current_longitude, COALESCE(o.current_address, ''),
-			COALESCE(o.ride_link, ''), o.created_at, o.updated_at
-		FROM orders o
-		LEFT JOIN users c ON o.courier_id = c.id
-		WHERE o.user_id = $1
-		ORDER BY o.created_at DESC
-	`
-
-	rows, err := s.db.QueryContext(ctx, query, userID.String())
-	if err != nil {
-		return nil, fmt.Errorf("failed to get user orders: %w", err)
-	}
-	defer rows.Close()
-
-	var orders []Order
-	for rows.Next() {
-		var order Order
-		var orderIDStr, userIDStr string
-		var providerIDStr, courierIDStr sql.NullString
-
-		err := rows.Scan(
-			&orderIDStr, &userIDStr, &providerIDStr, &courierIDStr,
-			&order.CourierName, &order.CourierPhone,
-			&order.Status, &order.CourierStatus, &order.CylinderType, &order.Quantity, &order.PricePerUnit,
-			&order.TotalPrice, &order.DeliveryFee, &order.ServiceCharge, &order.GrandTotal,
-			&order.DeliveryAddress, &order.DeliveryMethod, &order.PaymentMethod,
-			&order.PaymentStatus, &order.CurrentLatitude, &order.CurrentLongitude,
-			&order.CurrentAddress, &order.RideLink, &order.CreatedAt, &order.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan order: %w", err)
-		}
-
-		order.ID, _ = uuid.Parse(orderIDStr)
-		order.UserID, _ = uuid.Parse(userIDStr)
-		if providerIDStr.Valid {
-			parsed, _ := uuid.Parse(providerIDStr.String)
-			order.ProviderID = &parsed
-		}
-		if courierIDStr.Valid {
-			parsed, _ := uuid.Parse(courierIDStr.String)
-			order.CourierID = &parsed
-		}
-
-		orders = append(orders, order)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating orders: %w", err)
-	}
-
-	return orders, nil
+// GetUserOrders lists a customer's orders, newest first unless the filter says otherwise
+func (s *Service) GetUserOrders(userID uuid.UUID, filter ListFilter) (*OrderPage, error) {
+	return s.listOrders("user_id", userID, filter)
 }
 
 func (s *Service) GetAllProviders() ([]uuid.UUID, error) {
@@ -195,60 +235,9 @@ func (s *Service) GetAllProviders() ([]uuid.UUID, error) {
 	return providerIDs, nil
 }
 
-func (s *Service) GetProviderOrders(providerID uuid.UUID) ([]Order, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	query := `
-		SELECT id, user_id, provider_id, courier_id, status, COALESCE(courier_status, 'pending'), cylinder_type,
-			quantity, price_per_unit, total_price, delivery_fee, service_charge,
-			grand_total, delivery_address, delivery_method, payment_method,
-			payment_status, current_latitude, current_longitude, current_address,
-			ride_link, created_at, updated_at
-		FROM orders
-		WHERE provider_id = $1
-		ORDER BY created_at DESC
-	`
-
-	rows, err := s.db.QueryContext(ctx, query, providerID.String())
-	if err != nil {
-		return nil, fmt.Errorf("failed to get provider orders: %w", err)
-	}
-	defer rows.Close()
-
-	var orders []Order
-	for rows.Next() {
-		var order Order
-		var orderIDStr, userIDStr string
-		var providerIDStr, courierIDStr sql.NullString
-
-		err := rows.Scan(
-			&orderIDStr, &userIDStr, &providerIDStr, &courierIDStr,
-			&order.Status, &order.CourierStatus, &order.CylinderType, &order.Quantity, &order.PricePerUnit,
-			&order.TotalPrice, &order.DeliveryFee, &order.ServiceCharge, &order.GrandTotal,
-			&order.DeliveryAddress, &order.DeliveryMethod, &order.PaymentMethod,
-			&order.PaymentStatus, &order.CurrentLatitude, &order.CurrentLongitude,
-			&order.CurrentAddress, &order.RideLink, &order.CreatedAt, &order.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan order: %w", err)
-		}
-
-		order.ID, _ = uuid.Parse(orderIDStr)
-		order.UserID, _ = uuid.Parse(userIDStr)
-		if providerIDStr.Valid {
-			parsed, _ := uuid.Parse(providerIDStr.String)
-			order.ProviderID = &parsed
-		}
-		if courierIDStr.Valid {
-			parsed, _ := uuid.Parse(courierIDStr.String)
-			order.CourierID = &parsed
-		}
-
-		orders = append(orders, order)
-	}
-
-	return orders, nil
+// GetProviderOrders lists the orders placed with a provider
+func (s *Service) GetProviderOrders(providerID uuid.UUID, filter ListFilter) (*OrderPage, error) {
+	return s.listOrders("provider_id", providerID, filter)
 }
 
 func (s *Service) AcceptOrder(providerID uuid.UUID, orderID uuid.UUID) error {
@@ -300,114 +289,91 @@ func (s *Service) RejectOrder(orderID uuid.UUID) error {
 	return s.updateOrderStatus(orderID, OrderStatusRejected)
 }
 
-func (s *Service) GetCourierOrders(courierID uuid.UUID) ([]Order, error) {
+// GetCourierOrders lists the orders assigned to a courier
+func (s *Service) GetCourierOrders(courierID uuid.UUID, filter ListFilter) (*OrderPage, error) {
+	return s.listOrders("courier_id", courierID, filter)
+}
+
+func (s *Service) UpdateOrderStatus(orderID uuid.UUID, status OrderStatus, courierID uuid.UUID) error {
+	return s.updateOrderStatus(orderID, status)
+}
+
+func (s *Service) GetOrderByID(orderID uuid.UUID) (*Order, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	query := `
-		SELECT id, user_id, provider_id, courier_id, status, COALESCE(courier_status, 'pending'), cylinder_type,
-			quantity, price_per_unit, total_price, delivery_fee, service_charge,
-			grand_total, delivery_address, delivery_method, payment_method,
-			payment_status, current_latitude, current_longitude, COALESCE(current_address, ''),
-			COALESCE(ride_link, ''), created_at, updated_at
-		FROM orders
-		WHERE courier_id = $1
-		ORDER BY created_at DESC
-	`
+	query := `SELECT ` + orderColumns + ` FROM orders o WHERE o.id = $1`
 
-	rows, err := s.db.QueryContext(ctx, query, courierID.String())
+	order, err := scanOrder(s.db.QueryRowContext(ctx, query, orderID.String()))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get courier orders: %w", err)
-	}
-	defer rows.Close()
-
-	var orders []Order
-	for rows.Next() {
-		var order Order
-		var orderIDStr, userIDStr string
-		var providerIDStr, courierIDStr sql.NullString
-
-		err := rows.Scan(
-			&orderIDStr, &userIDStr, &providerIDStr, &courierIDStr,
-			&order.Status, &order.CourierStatus, &order.CylinderType, &order.Quantity, &order.PricePerUnit,
-			&order.TotalPrice, &order.DeliveryFee, &order.ServiceCharge, &order.GrandTotal,
-			&order.DeliveryAddress, &order.DeliveryMethod, &order.PaymentMethod,
-			&order.PaymentStatus, &order.CurrentLatitude, &order.CurrentLongitude,
-			&order.CurrentAddress, &order.RideLink, &order.CreatedAt, &order.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan order: %w", err)
-		}
-
-		order.ID, _ = uuid.Parse(orderIDStr)
-		order.UserID, _ = uuid.Parse(userIDStr)
-		if providerIDStr.Valid {
-			parsed, _ := uuid.Parse(providerIDStr.String)
-			order.ProviderID = &parsed
-		}
-		if courierIDStr.Valid {
-			parsed, _ := uuid.Parse(courierIDStr.String)
-			order.CourierID = &parsed
+		if err == sql.ErrNoRows {
+			return nil, ErrOrderNotFound
 		}
-
-		orders = append(orders, order)
+		return nil, fmt.Errorf("failed to get order: %w", err)
 	}
 
-	return orders, nil
+	return order, nil
 }
 
-func (s *Service) UpdateOrderStatus(orderID uuid.UUID, status OrderStatus, courierID uuid.UUID) error {
-	return s.updateOrderStatus(orderID, status)
+// orderColumns are the columns of orders (aliased o) that scanOrder reads, so single
+// order lookups and listings fill in the same fields
+const orderColumns = `
+	o.id, o.user_id, o.provider_id, o.courier_id, o.status, COALESCE(o.courier_status, 'pending'),
+	o.cylinder_type, o.quantity, o.price_per_unit, o.total_price, o.delivery_fee, o.service_charge,
+	o.grand_total, o.delivery_address, o.delivery_method, o.payment_method,
+	o.payment_status, o.current_latitude, o.current_longitude, COALESCE(o.current_address, ''),
+	COALESCE(o.ride_link, ''), o.business_account_id, o.delivery_site_id, COALESCE(o.delivery_notes, ''),
+	o.structured_address, o.address_id, o.wallet_paid, o.discount, COALESCE(o.promo_code, ''), o.campaign_id,
+	o.points_redeemed, COALESCE(o.points_target, ''), o.loyalty_discount, o.created_at, o.updated_at
+`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
 }
 
-func (s *Service) GetOrderByID(orderID uuid.UUID) (*Order, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
+// scanOrder reads a row selected with orderColumns. Any extra destinations are scanned
+// from the columns that follow them.
+func scanOrder(row rowScanner, extra ...interface{}) (*Order, error) {
 	var order Order
-	query := `
-		SELECT id, user_id, provider_id, courier_id, status, COALESCE(courier_status, 'pending'), cylinder_type,
-			quantity, price_per_unit, total_price, delivery_fee, service_charge,
-			grand_total, delivery_address, delivery_method, payment_method,
-			payment_status, current_latitude, current_longitude, COALESCE(current_address, ''),
-			COALESCE(ride_link, ''), created_at, updated_at
-		FROM orders
-		WHERE id = $1
-	`
-
 	var orderIDStr, userIDStr string
-	var providerIDStr, courierIDStr sql.NullString
+	var providerIDStr, courierIDStr, accountIDStr, siteIDStr, addressIDStr, campaignIDStr sql.NullString
+	var structuredAddress []byte
 
-	err := s.db.QueryRowContext(ctx, query, orderID.String()).Scan(
+	dest := []interface{}{
 		&orderIDStr, &userIDStr, &providerIDStr, &courierIDStr,
 		&order.Status, &order.CourierStatus, &order.CylinderType, &order.Quantity, &order.PricePerUnit,
 		&order.TotalPrice, &order.DeliveryFee, &order.ServiceCharge, &order.GrandTotal,
 		&order.DeliveryAddress, &order.DeliveryMethod, &order.PaymentMethod,
 		&order.PaymentStatus, &order.CurrentLatitude, &order.CurrentLongitude,
-		&order.CurrentAddress, &order.RideLink, &order.CreatedAt, &order.UpdatedAt,
-	)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("order not found")
-		}
-		return nil, fmt.Errorf("failed to get order: %w", err)
+		&order.CurrentAddress, &order.RideLink, &accountIDStr, &siteIDStr, &order.DeliveryNotes,
+		&structuredAddress, &addressIDStr, &order.WalletPaid, &order.Discount, &order.PromoCode, &campaignIDStr,
+		&order.PointsRedeemed, &order.PointsTarget, &order.LoyaltyDiscount, &order.CreatedAt, &order.UpdatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
 	}
 
 	order.ID, _ = uuid.Parse(orderIDStr)
 	order.UserID, _ = uuid.Parse(userIDStr)
-	if providerIDStr.Valid {
-		parsed, _ := uuid.Parse(providerIDStr.String)
-		order.ProviderID = &parsed
-	}
-	if courierIDStr.Valid {
-		parsed, _ := uuid.Parse(courierIDStr.String)
-		order.CourierID = &parsed
-	}
+	order.ProviderID = parseNullUUID(providerIDStr)
+	order.CourierID = parseNullUUID(courierIDStr)
+	order.BusinessAccountID = parseNullUUID(accountIDStr)
+	order.DeliverySiteID = parseNullUUID(siteIDStr)
+	order.AddressID = parseNullUUID(addressIDStr)
+	order.CampaignID = parseNullUUID(campaignIDStr)
+	order.StructuredAddress = decodeStructuredAddress(structuredAddress)
 
 	return &order, nil
 }
 
+func parseNullUUID(s sql.NullString) *uuid.UUID {
+	if !s.Valid {
+		return nil
+	}
+	parsed, _ := uuid.Parse(s.String)
+	return &parsed
+}
+
 // Helper function to update order status
 func (s *Service) updateOrderStatus(orderID uuid.UUID, status OrderStatus) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -463,6 +429,56 @@ func (s *Service) UpdateOrderPaymentStatus(orderID uuid.UUID, status PaymentStat
 	return nil
 }
 
+// MarkPaid records a completed payment on an order and reports whether it moved the
+// order to paid. Rejected and refunded orders, orders already paid, and orders whose
+// wallet spend and completed payments don't yet cover the total are left alone, so a
+// late success on an abandoned attempt can't revive an order that was turned down.
+func (s *Service) MarkPaid(orderID uuid.UUID) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE orders
+		SET payment_status = $1, updated_at = $2
+		WHERE id = $3 AND status <> 'rejected' AND payment_status NOT IN ('paid', 'refunded')
+			AND wallet_paid + COALESCE((
+				SELECT SUM(amount) FROM payments WHERE order_id = orders.id AND status = 'completed'
+			), 0) >= grand_total
+	`
+
+	result, err := s.db.ExecContext(ctx, query, PaymentStatusPaid, time.Now(), orderID.String())
+	if err != nil {
+		return false, fmt.Errorf("failed to update payment status: %w", err)
+	}
+
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// MarkPaymentFailed records a failed payment attempt on an order. An order that is
+// already paid or refunded, or has another attempt that completed, is left alone, so a
+// late failure from an abandoned attempt can't undo a payment that went through.
+func (s *Service) MarkPaymentFailed(orderID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE orders
+		SET payment_status = $1, updated_at = $2
+		WHERE id = $3 AND payment_status NOT IN ('paid', 'refunded')
+			AND NOT EXISTS (
+				SELECT 1 FROM payments WHERE order_id = orders.id AND status = 'completed'
+			)
+	`
+
+	_, err := s.db.ExecContext(ctx, query, PaymentStatusFailed, time.Now(), orderID.String())
+	if err != nil {
+		return fmt.Errorf("failed to update payment status: %w", err)
+	}
+
+	return nil
+}
+
 func (s *Service) AcceptCourierAssignment(orderID uuid.UUID, courierID uuid.UUID) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -584,3 +600,16 @@ func (s *Service) getProviderLocation(providerID uuid.UUID) (*float64, *float64,
 
 	return &lat.Float64, &lng.Float64, nil
 }
+
+// decodeStructuredAddress reads the JSONB structured_address column, which is NULL for
+// orders placed with a free-text address only
+func decodeStructuredAddress(raw []byte) *address.Address {
+	if len(raw) == 0 {
+		return nil
+	}
+	var a address.Address
+	if err := a.Scan(raw); err != nil {
+		return nil
+	}
+	return &a
+}
@@ -5,6 +5,7 @@ package pawapay
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,6 +14,8 @@ import (
 
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+	"github.com/yakumwamba/lpg-delivery-system/internal/phone"
 )
 
 type Client struct {
@@ -35,6 +38,34 @@ func NewClient(baseURL, token, apiKey, callbackURL string) *Client {
 	}
 }
 
+// PawaPay provider codes for the Zambian mobile money operators
+var providerCodes = map[phone.Operator]string{
+	phone.OperatorAirtel: "AIRTEL_OAPI_ZMB",
+	phone.OperatorMTN:    "MTN_MOMO_ZMB",
+	phone.OperatorZamtel: "ZAMTEL_ZMB",
+}
+
+// Provider availability statuses reported by PawaPay
+const (
+	AvailabilityOperational = "OPERATIONAL"
+	AvailabilityDelayed     = "DELAYED"
+	AvailabilityClosed      = "CLOSED"
+)
+
+var (
+	ErrUnsupportedOperator = errors.New("mobile money operator is not supported")
+	ErrProviderUnavailable = errors.New("mobile money operator is temporarily unavailable, please try again later")
+)
+
+// ProviderCode maps a mobile network operator to its PawaPay provider code
+func ProviderCode(op phone.Operator) (string, error) {
+	code, ok := providerCodes[op]
+	if !ok {
+		return "", ErrUnsupportedOperator
+	}
+	return code, nil
+}
+
 // Common types
 type Amount struct {
 	Value    string `json:"value"`
@@ -140,83 +171,116 @@ type PayoutResponse struct {
 	Status   string `json:"status"`
 }
 
-// Refund types
+// Refund returns all or part of a completed deposit to the payer. RefundID is chosen by
+// us, so resubmitting the same refund after a lost response is ignored as a duplicate.
 type Refund struct {
-	RefundID    string     `json:"refund_id"`
-	DepositID   string     `json:"deposit_id"`
-	Amount      Amount     `json:"amount"`
-	Reason      string     `json:"reason"`
-	CallbackURL string     `json:"callback_url,omitempty"`
-	Metadata    []Metadata `json:"metadata,omitempty"`
+	RefundID  string     `json:"refundId"`
+	DepositID string     `json:"depositId"`
+	Amount    string     `json:"amount"`
+	Currency  string     `json:"currency"`
+	Metadata  []Metadata `json:"metadata,omitempty"`
 }
 
 type RefundResponse struct {
-	RefundID string `json:"refund_id"`
-	Status   string `json:"status"`
+	RefundID        string           `json:"refundId"`
+	Status          string           `json:"status"` // ACCEPTED, REJECTED or DUPLICATE_IGNORED
+	Created         string           `json:"created,omitempty"`
+	RejectionReason *RejectionReason `json:"rejectionReason,omitempty"`
 }
 
-// Session types
+// PaymentSession asks PawaPay to host a deposit on its payment page, where the payer
+// picks their operator and enters their own number. The deposit is created under
+// DepositID once the payer confirms, and reported by the usual deposit callback.
 type PaymentSession struct {
-	SessionID   string    `json:"session_id"`
-	Amount      Amount    `json:"amount"`
-	ReturnURL   string    `json:"return_url"`
-	Description string    `json:"description"`
-	ExpiresAt   time.Time `json:"expires_at"`
+	DepositID       string        `json:"depositId"`
+	ReturnURL       string        `json:"returnUrl"`
+	AmountDetails   AmountDetails `json:"amountDetails"`
+	Country         string        `json:"country"`
+	PhoneNumber     string        `json:"phoneNumber,omitempty"`
+	CustomerMessage string        `json:"customerMessage,omitempty"`
+	Reason          string        `json:"reason,omitempty"`
+	Metadata        []Metadata    `json:"metadata,omitempty"`
+}
+
+type AmountDetails struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
 }
 
 type SessionResponse struct {
-	SessionID   string `json:"session_id"`
-	RedirectURL string `json:"redirect_url"`
+	RedirectURL string `json:"redirectUrl"`
 }
 
-// Client methods
+// CreatePaymentSession creates a payment page session and returns the page to send the
+// payer to. PawaPay sends the payer back to ReturnURL with the depositId when they are done.
 func (c *Client) CreatePaymentSession(session PaymentSession) (*SessionResponse, error) {
-	resp, err := c.sendRequest("POST", "/v1/widget/sessions", session)
+	resp, err := c.sendRequest("POST", "/paymentpage", session)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to create payment page session: %w", err)
 	}
 	defer resp.Body.Close()
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
 	var result SessionResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || json.Unmarshal(body, &result) != nil || result.RedirectURL == "" {
+		log.WithFields(log.Fields{
+			"statusCode": resp.StatusCode,
+			"response":   string(body),
+		}).Error("PawaPay payment page session failed")
+		return nil, fmt.Errorf("payment page session rejected (status %d): %s", resp.StatusCode, string(body))
 	}
 	return &result, nil
 }
 
-func (c *Client) InitiateDeposit(orderID string, amount float64, phoneNumber string) (*DepositResponse, error) {
+// InitiateDeposit collects a payment from a mobile money wallet. The operator is detected
+// from the number's prefix unless the customer chose one (operator may be empty), and
+// the deposit is refused up front when PawaPay reports that operator closed.
+func (c *Client) InitiateDeposit(orderID string, amount money.Money, phoneNumber, operator string) (*DepositResponse, error) {
 	logger := log.WithFields(log.Fields{
 		"method":      "InitiateDeposit",
 		"orderID":     orderID,
-		"amount":      amount,
+		"amount":      amount.String(),
 		"phoneNumber": phoneNumber,
 	})
 
-	// Clean phone number - remove + prefix and any non-numeric characters
 	// PawaPay V2 requires only digits: 260773962307 (not +260773962307)
-	cleanPhone := strings.TrimPrefix(phoneNumber, "+")
-	cleanPhone = strings.Map(func(r rune) rune {
-		if r >= '0' && r <= '9' {
-			return r
-		}
-		return -1 // Remove non-numeric characters
-	}, cleanPhone)
+	e164, err := phone.Normalize(phoneNumber)
+	if err != nil {
+		return nil, err
+	}
+	cleanPhone := strings.TrimPrefix(e164, "+")
+
+	op, err := phone.ResolveOperator(e164, operator)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := ProviderCode(op)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.CheckProvider(provider, "DEPOSIT"); err != nil {
+		return nil, err
+	}
 
 	logger.WithFields(log.Fields{
-		"originalPhone": phoneNumber,
-		"cleanedPhone":  cleanPhone,
-	}).Info("Cleaned phone number for PawaPay V2")
+		"cleanedPhone": cleanPhone,
+		"operator":     op,
+	}).Info("Resolved mobile money operator for PawaPay V2")
 
 	// V2 API structure - simplified, no correspondent, country, timestamps needed
 	deposit := Deposit{
 		DepositID: uuid.New().String(),
-		Amount:    fmt.Sprintf("%.2f", amount),
-		Currency:  "ZMW",
+		Amount:    amount.Amount.String(),
+		Currency:  string(amount.Currency),
 		Payer: Payer{
 			Type: "MMO", // Mobile Money Operator
 			AccountDetails: AccountDetails{
 				PhoneNumber: cleanPhone,
-				Provider:    "AIRTEL_OAPI_ZMB", // Airtel Zambia provider code
+				Provider:    provider,
 			},
 		},
 		// CallbackURL: c.CallbackURL, // Removed: PawaPay V2 API returns UNSUPPORTED_PARAMETER for this field in body
@@ -349,6 +413,11 @@ func (c *Client) InitiateRefund(refund Refund) (*RefundResponse, error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("refund request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
 	var result RefundResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, err
@@ -422,6 +491,63 @@ func (c *Client) GetPaymentStatus(paymentType, id string) (*DepositStatusRespons
 	return response, nil
 }
 
+// ProviderAvailability is one country's entry in PawaPay's availability report
+type ProviderAvailability struct {
+	Country   string `json:"country"`
+	Providers []struct {
+		Provider       string `json:"provider"`
+		OperationTypes []struct {
+			OperationType string `json:"operationType"`
+			Status        string `json:"status"`
+		} `json:"operationTypes"`
+	} `json:"providers"`
+}
+
+// GetAvailability returns the status of each Zambian provider for an operation type
+// (DEPOSIT, PAYOUT or REFUND)
+func (c *Client) GetAvailability(operationType string) (map[string]string, error) {
+	resp, err := c.sendRequest("GET", "/availability?country=ZMB&operationType="+operationType, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("availability check returned status %d", resp.StatusCode)
+	}
+
+	var countries []ProviderAvailability
+	if err := json.NewDecoder(resp.Body).Decode(&countries); err != nil {
+		return nil, fmt.Errorf("failed to decode availability: %w", err)
+	}
+
+	statuses := map[string]string{}
+	for _, country := range countries {
+		for _, p := range country.Providers {
+			for _, op := range p.OperationTypes {
+				if op.OperationType == operationType {
+					statuses[p.Provider] = op.Status
+				}
+			}
+		}
+	}
+	return statuses, nil
+}
+
+// CheckProvider refuses an operation on a provider PawaPay reports as closed. If the
+// availability check itself fails the operation is allowed to go ahead.
+func (c *Client) CheckProvider(provider, operationType string) error {
+	statuses, err := c.GetAvailability(operationType)
+	if err != nil {
+		log.WithError(err).WithField("provider", provider).Warn("Could not check provider availability")
+		return nil
+	}
+	if statuses[provider] == AvailabilityClosed {
+		return ErrProviderUnavailable
+	}
+	return nil
+}
+
 func (c *Client) IsOperational(phoneNumber string) bool {
 	resp, err := c.sendRequest("GET", "/status", nil)
 	if err != nil {
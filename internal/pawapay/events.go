@@ -0,0 +1,226 @@
+package pawapay
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// EventType is the kind of PawaPay operation a callback reports on
+type EventType string
+
+const (
+	EventDeposit EventType = "deposit"
+	EventPayout  EventType = "payout"
+	EventRefund  EventType = "refund"
+)
+
+var (
+	ErrInvalidEvent  = errors.New("callback payload is not a valid PawaPay event")
+	ErrEventNotFound = errors.New("payment event not found")
+)
+
+// Event is a callback as PawaPay sent it, with how far we got processing it
+type Event struct {
+	ID                uuid.UUID       `json:"id"`
+	Provider          string          `json:"provider"`
+	Type              EventType       `json:"type"`
+	EventKey          string          `json:"event_key"`
+	Reference         string          `json:"reference"`
+	Status            string          `json:"status"`
+	Payload           json.RawMessage `json:"payload"`
+	SignatureVerified bool            `json:"signature_verified"`
+	Duplicates        int             `json:"duplicates"`
+	Attempts          int             `json:"attempts"`
+	LastError         string          `json:"last_error,omitempty"`
+	ProcessedAt       *time.Time      `json:"processed_at,omitempty"`
+	ReceivedAt        time.Time       `json:"received_at"`
+}
+
+// identify reads the operation ID and status from a callback. PawaPay sends no event ID,
+// so an event is identified by the operation and the status it reports.
+func identify(eventType EventType, payload []byte) (reference, status string, err error) {
+	var fields struct {
+		DepositID string `json:"depositId"`
+		PayoutID  string `json:"payoutId"`
+		RefundID  string `json:"refundId"`
+		Status    string `json:"status"`
+	}
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+	switch eventType {
+	case EventDeposit:
+		reference = fields.DepositID
+	case EventPayout:
+		reference = fields.PayoutID
+	case EventRefund:
+		reference = fields.RefundID
+	default:
+		return "", "", fmt.Errorf("%w: unknown type %q", ErrInvalidEvent, eventType)
+	}
+	if reference == "" || fields.Status == "" {
+		return "", "", fmt.Errorf("%w: %s ID and status are required", ErrInvalidEvent, eventType)
+	}
+	return reference, strings.ToUpper(fields.Status), nil
+}
+
+// Receive stores a callback and processes it. A callback whose outcome has already been
+// processed is only counted, so PawaPay's retries have no further effect.
+func (h *CallbackHandler) Receive(eventType EventType, payload []byte, verified bool) (*Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	reference, status, err := identify(eventType, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	row := h.db.QueryRowContext(ctx, `
+		INSERT INTO payment_events (type, event_key, reference, status, payload, signature_verified)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (provider, type, event_key) DO UPDATE
+			SET duplicates = payment_events.duplicates + 1,
+				signature_verified = payment_events.signature_verified OR EXCLUDED.signature_verified
+		RETURNING `+eventColumns,
+		eventType, reference+":"+status, reference, status, string(payload), verified,
+	)
+	event, err := scanEvent(row)
+	if err != nil {
+		return nil, err
+	}
+
+	if event.ProcessedAt != nil {
+		log.WithFields(log.Fields{
+			"type":      eventType,
+			"reference": reference,
+			"status":    status,
+		}).Info("Ignoring duplicate PawaPay callback")
+		return event, nil
+	}
+	return event, h.process(ctx, event)
+}
+
+// Replay processes a stored callback again, whether or not it succeeded before
+func (h *CallbackHandler) Replay(id uuid.UUID) (*Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	event, err := h.GetEvent(id)
+	if err != nil {
+		return nil, err
+	}
+	return event, h.process(ctx, event)
+}
+
+// process applies a stored callback and records the outcome on the event
+func (h *CallbackHandler) process(ctx context.Context, event *Event) error {
+	var err error
+	switch event.Type {
+	case EventDeposit:
+		err = h.HandleDepositCallback(event.Payload)
+	case EventPayout:
+		err = h.HandlePayoutCallback(event.Payload)
+	case EventRefund:
+		err = h.HandleRefundCallback(event.Payload)
+	}
+
+	event.Attempts++
+	if err != nil {
+		event.LastError = err.Error()
+		if _, dbErr := h.db.ExecContext(ctx,
+			`UPDATE payment_events SET attempts = attempts + 1, last_error = $1 WHERE id = $2`,
+			event.LastError, event.ID.String(),
+		); dbErr != nil {
+			log.WithError(dbErr).Error("Failed to record payment event failure")
+		}
+		return err
+	}
+
+	now := time.Now()
+	event.ProcessedAt, event.LastError = &now, ""
+	if _, err := h.db.ExecContext(ctx,
+		`UPDATE payment_events SET attempts = attempts + 1, last_error = NULL, processed_at = $1 WHERE id = $2`,
+		now, event.ID.String(),
+	); err != nil {
+		return fmt.Errorf("failed to mark payment event processed: %w", err)
+	}
+	return nil
+}
+
+// GetEvent returns one stored callback
+func (h *CallbackHandler) GetEvent(id uuid.UUID) (*Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	event, err := scanEvent(h.db.QueryRowContext(ctx,
+		`SELECT `+eventColumns+` FROM payment_events WHERE id = $1`, id.String(),
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrEventNotFound
+	}
+	return event, err
+}
+
+// ListEvents returns stored callbacks, newest first. Empty filters match everything.
+func (h *CallbackHandler) ListEvents(eventType EventType, reference string, limit, offset int) ([]Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT `+eventColumns+`
+		FROM payment_events
+		WHERE ($1 = '' OR type = $1) AND ($2 = '' OR reference = $2)
+		ORDER BY received_at DESC
+		LIMIT $3 OFFSET $4
+	`, string(eventType), reference, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payment events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *event)
+	}
+	return events, rows.Err()
+}
+
+const eventColumns = `id, provider, type, event_key, reference, status, payload, signature_verified,
+	duplicates, attempts, COALESCE(last_error, ''), processed_at, received_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanEvent(row rowScanner) (*Event, error) {
+	var event Event
+	var idStr string
+	var payload []byte
+	var processedAt sql.NullTime
+	if err := row.Scan(&idStr, &event.Provider, &event.Type, &event.EventKey, &event.Reference,
+		&event.Status, &payload, &event.SignatureVerified, &event.Duplicates, &event.Attempts,
+		&event.LastError, &processedAt, &event.ReceivedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan payment event: %w", err)
+	}
+	event.ID, _ = uuid.Parse(idStr)
+	event.Payload = payload
+	if processedAt.Valid {
+		event.ProcessedAt = &processedAt.Time
+	}
+	return &event, nil
+}
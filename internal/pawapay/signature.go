@@ -0,0 +1,282 @@
+package pawapay
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMissingSignature = errors.New("callback is not signed")
+	ErrDigestMismatch   = errors.New("callback body does not match its content digest")
+	ErrInvalidSignature = errors.New("callback signature is invalid")
+)
+
+// UnsignedCallbacksAllowed reports whether callbacks may be accepted without a signature
+// for a client using apiURL. Only PawaPay's sandbox and simulators on the local machine
+// qualify; real money needs signed callbacks.
+func UnsignedCallbacksAllowed(apiURL string) bool {
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	return strings.HasSuffix("."+host, ".sandbox.pawapay.io") || host == "localhost" || net.ParseIP(host).IsLoopback()
+}
+
+// Verifier checks the HTTP message signature (RFC 9421) and Content-Digest that PawaPay
+// attaches to signed callbacks
+type Verifier struct {
+	key crypto.PublicKey
+}
+
+// NewVerifier parses PawaPay's PEM encoded public key. Literal "\n" sequences are accepted
+// so the key can be passed in a single line environment variable.
+func NewVerifier(publicKeyPEM string) (*Verifier, error) {
+	block, _ := pem.Decode([]byte(strings.ReplaceAll(publicKeyPEM, `\n`, "\n")))
+	if block == nil {
+		return nil, errors.New("no PEM block found in public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+	return &Verifier{key: key}, nil
+}
+
+// Verify checks that body is what PawaPay signed for the request r
+func (v *Verifier) Verify(r *http.Request, body []byte) error {
+	input, sig := r.Header.Get("Signature-Input"), r.Header.Get("Signature")
+	if input == "" || sig == "" {
+		return ErrMissingSignature
+	}
+	if err := verifyDigest(r.Header.Get("Content-Digest"), body); err != nil {
+		return err
+	}
+
+	label, components, params, err := parseSignatureInput(input)
+	if err != nil {
+		return err
+	}
+	if !covers(components, "content-digest") {
+		return fmt.Errorf("%w: content-digest is not covered", ErrInvalidSignature)
+	}
+	if expires, ok := paramValue(params, "expires"); ok {
+		if ts, err := strconv.ParseInt(expires, 10, 64); err != nil || time.Now().Unix() > ts {
+			return fmt.Errorf("%w: signature has expired", ErrInvalidSignature)
+		}
+	}
+
+	signature, err := signatureValue(sig, label)
+	if err != nil {
+		return err
+	}
+	base, err := signatureBase(r, components, params)
+	if err != nil {
+		return err
+	}
+	alg, _ := paramValue(params, "alg")
+	return v.check(alg, []byte(base), signature)
+}
+
+func (v *Verifier) check(alg string, base, signature []byte) error {
+	switch key := v.key.(type) {
+	case *ecdsa.PublicKey:
+		var h hash.Hash
+		switch alg {
+		case "ecdsa-p256-sha256", "":
+			h = sha256.New()
+		case "ecdsa-p384-sha384":
+			h = sha512.New384()
+		default:
+			return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidSignature, alg)
+		}
+		h.Write(base)
+		digest := h.Sum(nil)
+
+		// RFC 9421 sends r||s; fall back to ASN.1 for signers that use it
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) == 2*size {
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			if ecdsa.Verify(key, digest, r, s) {
+				return nil
+			}
+		} else if ecdsa.VerifyASN1(key, digest, signature) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		switch alg {
+		case "rsa-pss-sha512":
+			digest := sha512.Sum512(base)
+			if rsa.VerifyPSS(key, crypto.SHA512, digest[:], signature, nil) == nil {
+				return nil
+			}
+		case "rsa-v1_5-sha256", "":
+			digest := sha256.Sum256(base)
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+				return nil
+			}
+		default:
+			return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidSignature, alg)
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// verifyDigest checks a Content-Digest header (RFC 9530) such as sha-512=:base64:
+func verifyDigest(header string, body []byte) error {
+	if header == "" {
+		return fmt.Errorf("%w: Content-Digest header is missing", ErrDigestMismatch)
+	}
+	for _, entry := range strings.Split(header, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		want, err := base64.StdEncoding.DecodeString(strings.Trim(value, ":"))
+		if err != nil {
+			return ErrDigestMismatch
+		}
+		var got []byte
+		switch strings.ToLower(name) {
+		case "sha-256":
+			sum := sha256.Sum256(body)
+			got = sum[:]
+		case "sha-512":
+			sum := sha512.Sum512(body)
+			got = sum[:]
+		default:
+			continue
+		}
+		if string(got) != string(want) {
+			return ErrDigestMismatch
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: no supported digest algorithm", ErrDigestMismatch)
+}
+
+// parseSignatureInput splits a header such as
+// sig-pp=("@method" "@path" "content-digest");alg="ecdsa-p256-sha256";created=1700000000
+// into its label, covered components and the raw parameters that are signed
+func parseSignatureInput(header string) (label string, components []string, params string, err error) {
+	label, rest, ok := strings.Cut(strings.TrimSpace(header), "=")
+	if !ok || !strings.HasPrefix(rest, "(") {
+		return "", nil, "", fmt.Errorf("%w: malformed Signature-Input", ErrInvalidSignature)
+	}
+	end := strings.Index(rest, ")")
+	if end < 0 {
+		return "", nil, "", fmt.Errorf("%w: malformed Signature-Input", ErrInvalidSignature)
+	}
+	for _, c := range strings.Fields(rest[1:end]) {
+		components = append(components, strings.Trim(c, `"`))
+	}
+	// Only the first signature is used when several are sent
+	if i := strings.Index(rest, ", "); i > end {
+		rest = rest[:i]
+	}
+	return label, components, rest, nil
+}
+
+// signatureValue finds the signature for label in a Signature header
+func signatureValue(header, label string) ([]byte, error) {
+	for _, entry := range strings.Split(header, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || name != label {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(strings.Trim(value, ":"))
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed signature", ErrInvalidSignature)
+		}
+		return sig, nil
+	}
+	return nil, ErrMissingSignature
+}
+
+// signatureBase rebuilds the string PawaPay signed from the covered components
+func signatureBase(r *http.Request, components []string, params string) (string, error) {
+	var b strings.Builder
+	for _, c := range components {
+		var value string
+		switch c {
+		case "@method":
+			value = r.Method
+		case "@authority":
+			value = strings.ToLower(r.Host)
+		case "@path":
+			value = r.URL.Path
+		case "@query":
+			value = "?" + r.URL.RawQuery
+		case "@scheme":
+			value = scheme(r)
+		case "@target-uri":
+			value = scheme(r) + "://" + r.Host + r.URL.RequestURI()
+		default:
+			if strings.HasPrefix(c, "@") {
+				return "", fmt.Errorf("%w: unsupported component %s", ErrInvalidSignature, c)
+			}
+			values := r.Header.Values(c)
+			if len(values) == 0 {
+				return "", fmt.Errorf("%w: signed header %s is missing", ErrInvalidSignature, c)
+			}
+			for i := range values {
+				values[i] = strings.TrimSpace(values[i])
+			}
+			value = strings.Join(values, ", ")
+		}
+		fmt.Fprintf(&b, "%q: %s\n", c, value)
+	}
+	fmt.Fprintf(&b, "%q: %s", "@signature-params", params)
+	return b.String(), nil
+}
+
+func scheme(r *http.Request) string {
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		return proto
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+func covers(components []string, name string) bool {
+	for _, c := range components {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+func paramValue(params, name string) (string, bool) {
+	for _, p := range strings.Split(params, ";")[1:] {
+		k, v, ok := strings.Cut(p, "=")
+		if ok && strings.TrimSpace(k) == name {
+			return strings.Trim(v, `"`), true
+		}
+	}
+	return "", false
+}
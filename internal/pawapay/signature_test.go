@@ -0,0 +1,72 @@
+package pawapay
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifier(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	keyPEM := strings.ReplaceAll(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), "\n", `\n`)
+
+	verifier, err := NewVerifier(keyPEM)
+	require.NoError(t, err)
+
+	body := `{"depositId":"d-1","status":"COMPLETED","amount":"150.00"}`
+	req := httptest.NewRequest("POST", "http://api.example.com/pawapay/callback/deposits", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	sum := sha512.Sum512([]byte(body))
+	req.Header.Set("Content-Digest", "sha-512=:"+base64.StdEncoding.EncodeToString(sum[:])+":")
+
+	params := `("@method" "@authority" "@path" "content-digest" "content-type");alg="ecdsa-p256-sha256";keyid="HTTP_EC_P256_KEY:1";created=1700000000`
+	base := "\"@method\": POST\n" +
+		"\"@authority\": api.example.com\n" +
+		"\"@path\": /pawapay/callback/deposits\n" +
+		"\"content-digest\": " + req.Header.Get("Content-Digest") + "\n" +
+		"\"content-type\": application/json\n" +
+		"\"@signature-params\": " + params
+	digest := sha256.Sum256([]byte(base))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+	sig := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	req.Header.Set("Signature-Input", "sig-pp="+params)
+	req.Header.Set("Signature", "sig-pp=:"+base64.StdEncoding.EncodeToString(sig)+":")
+
+	assert.NoError(t, verifier.Verify(req, []byte(body)))
+
+	// The body was swapped for one marking another deposit completed
+	assert.ErrorIs(t, verifier.Verify(req, []byte(`{"depositId":"d-2","status":"COMPLETED"}`)), ErrDigestMismatch)
+
+	// The digest was recomputed for a forged body but the signature no longer matches
+	forged := `{"depositId":"d-1","status":"FAILED"}`
+	sum = sha512.Sum512([]byte(forged))
+	req.Header.Set("Content-Digest", "sha-512=:"+base64.StdEncoding.EncodeToString(sum[:])+":")
+	assert.ErrorIs(t, verifier.Verify(req, []byte(forged)), ErrInvalidSignature)
+
+	unsigned := httptest.NewRequest("POST", "/pawapay/callback/deposits", strings.NewReader(body))
+	assert.ErrorIs(t, verifier.Verify(unsigned, []byte(body)), ErrMissingSignature)
+}
+
+func TestUnsignedCallbacksAllowed(t *testing.T) {
+	assert.True(t, UnsignedCallbacksAllowed("https://api.sandbox.pawapay.io/"))
+	assert.True(t, UnsignedCallbacksAllowed("http://localhost:9090"))
+	assert.True(t, UnsignedCallbacksAllowed("http://127.0.0.1:9090/"))
+	assert.False(t, UnsignedCallbacksAllowed("https://api.pawapay.io/"))
+	assert.False(t, UnsignedCallbacksAllowed("https://api.sandbox.pawapay.io.example.com/"))
+	assert.False(t, UnsignedCallbacksAllowed("://bad"))
+}
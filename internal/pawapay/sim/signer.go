@@ -0,0 +1,56 @@
+package sim
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Sign adds the Content-Digest and RFC 9421 signature headers PawaPay puts on callbacks,
+// covering the same components, so pawapay.Verifier checks them as it would the real ones
+func Sign(req *http.Request, body []byte, key *ecdsa.PrivateKey) error {
+	sum := sha512.Sum512(body)
+	req.Header.Set("Content-Digest", "sha-512=:"+base64.StdEncoding.EncodeToString(sum[:])+":")
+
+	params := fmt.Sprintf(`("@method" "@authority" "@path" "content-digest" "content-type");alg="ecdsa-p256-sha256";keyid="HTTP_EC_P256_KEY:1";created=%d`,
+		time.Now().Unix())
+	base := strings.Join([]string{
+		`"@method": ` + req.Method,
+		`"@authority": ` + strings.ToLower(req.Host),
+		`"@path": ` + req.URL.Path,
+		`"content-digest": ` + req.Header.Get("Content-Digest"),
+		`"content-type": ` + req.Header.Get("Content-Type"),
+		`"@signature-params": ` + params,
+	}, "\n")
+
+	digest := sha256.Sum256([]byte(base))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return fmt.Errorf("failed to sign callback: %w", err)
+	}
+	size := (key.Curve.Params().BitSize + 7) / 8
+	sig := append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+
+	req.Header.Set("Signature-Input", "sig-pp="+params)
+	req.Header.Set("Signature", "sig-pp=:"+base64.StdEncoding.EncodeToString(sig)+":")
+	return nil
+}
+
+// PublicKeyPEM encodes the key's public half for PAWAPAY_CALLBACK_PUBLIC_KEY, on one
+// line with literal "\n" separators
+func PublicKeyPEM(key *ecdsa.PrivateKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	block := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	return strings.ReplaceAll(strings.TrimSpace(string(block)), "\n", `\n`), nil
+}
@@ -0,0 +1,809 @@
+// Package sim is a stand-in for the PawaPay V2 API that runs locally, so payment flows
+// can be exercised without the sandbox or a phone to approve prompts on. It serves the
+// endpoints pawapay.Client uses, settles each payment with a scripted outcome and posts
+// the callbacks to our server the way PawaPay would.
+package sim
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+	"github.com/yakumwamba/lpg-delivery-system/internal/pawapay"
+	"github.com/yakumwamba/lpg-delivery-system/internal/phone"
+)
+
+// Outcome is how the simulator settles a deposit, payout or refund
+type Outcome string
+
+const (
+	// OutcomeComplete completes the payment and sends one callback
+	OutcomeComplete Outcome = "complete"
+	// OutcomeFail fails the payment, as when the payer declines or has no balance
+	OutcomeFail Outcome = "fail"
+	// OutcomeReject refuses the request outright; no callback follows
+	OutcomeReject Outcome = "reject"
+	// OutcomeTimeout leaves the payment SUBMITTED and never calls back, like a payer who
+	// ignores the prompt
+	OutcomeTimeout Outcome = "timeout"
+	// OutcomeDuplicate completes the payment and sends its callback twice
+	OutcomeDuplicate Outcome = "duplicate"
+	// OutcomeLate completes the payment straight away but only calls back after LateDelay,
+	// so the status endpoint knows before our server does
+	OutcomeLate Outcome = "late"
+)
+
+var ErrUnknownOutcome = errors.New("unknown simulated outcome")
+
+// ParseOutcome reads an outcome name such as "fail"
+func ParseOutcome(s string) (Outcome, error) {
+	switch o := Outcome(strings.ToLower(strings.TrimSpace(s))); o {
+	case OutcomeComplete, OutcomeFail, OutcomeReject, OutcomeTimeout, OutcomeDuplicate, OutcomeLate:
+		return o, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownOutcome, s)
+}
+
+// Kinds of transaction, named after their API paths
+const (
+	KindDeposit = "deposits"
+	KindPayout  = "payouts"
+	KindRefund  = "refunds"
+)
+
+// Transaction is a payment the simulator has accepted
+type Transaction struct {
+	Kind      string    `json:"kind"`
+	ID        string    `json:"id"`
+	DepositID string    `json:"depositId,omitempty"` // the deposit a refund returns
+	Amount    string    `json:"amount"`
+	Currency  string    `json:"currency"`
+	Phone     string    `json:"phoneNumber,omitempty"`
+	Provider  string    `json:"provider,omitempty"`
+	Status    string    `json:"status"`
+	Outcome   Outcome   `json:"outcome"`
+	Callbacks int       `json:"callbacks"` // callbacks delivered so far
+	Created   time.Time `json:"created"`
+}
+
+// Config sets up a simulator
+type Config struct {
+	// CallbackURL is the base our callback routes hang off, e.g.
+	// http://localhost:8080/pawapay/callback. Callbacks go to /deposits, /payouts and
+	// /refunds beneath it. No callbacks are sent when it is empty.
+	CallbackURL string
+	// Token, when set, must be sent as the Bearer token on API requests
+	Token string
+	// SigningKey, when set, signs callbacks the way PawaPay does so they pass our
+	// signature check. Its public key goes in PAWAPAY_CALLBACK_PUBLIC_KEY.
+	SigningKey *ecdsa.PrivateKey
+	// Default is the outcome for payments nothing else is scripted for; complete if empty
+	Default Outcome
+	// Phones scripts outcomes by the payer's or recipient's number, digits only
+	Phones map[string]Outcome
+	// CallbackDelay is how long a payment stays pending before it settles
+	CallbackDelay time.Duration
+	// LateDelay is how long a late callback is held back after the payment settles
+	LateDelay time.Duration
+	// HTTPClient posts the callbacks
+	HTTPClient *http.Client
+}
+
+// Server is the simulated PawaPay API. It is an http.Handler, so it can be served by
+// cmd/pawapay-sim or wrapped in an httptest.Server.
+type Server struct {
+	cfg Config
+	mux *http.ServeMux
+
+	mu           sync.Mutex
+	next         []Outcome
+	phones       map[string]Outcome
+	transactions map[string]*Transaction // by kind and ID
+	sessions     map[string]pawapay.PaymentSession
+	availability map[string]string // provider to status
+	pending      sync.WaitGroup
+}
+
+// NewServer creates a simulator with every Zambian provider operational
+func NewServer(cfg Config) *Server {
+	if cfg.Default == "" {
+		cfg.Default = OutcomeComplete
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	s := &Server{cfg: cfg, mux: http.NewServeMux()}
+	s.Reset()
+
+	s.mux.HandleFunc("POST /v2/deposits", s.authorized(s.handleDeposit))
+	s.mux.HandleFunc("POST /v2/payouts", s.authorized(s.handlePayout))
+	s.mux.HandleFunc("POST /v2/refunds", s.authorized(s.handleRefund))
+	s.mux.HandleFunc("GET /v2/{kind}/{id}", s.authorized(s.handleGetStatus))
+	s.mux.HandleFunc("POST /v2/paymentpage", s.authorized(s.handleCreatePaymentPage))
+	s.mux.HandleFunc("GET /v2/availability", s.authorized(s.handleAvailability))
+	s.mux.HandleFunc("GET /v2/status", s.authorized(s.handleStatus))
+
+	// The hosted payment page the payer is redirected to
+	s.mux.HandleFunc("GET /paymentpage/{depositId}", s.handlePaymentPage)
+
+	// Scripting endpoints for tests driving a standalone simulator
+	s.mux.HandleFunc("POST /sim/next", s.handleScriptNext)
+	s.mux.HandleFunc("PUT /sim/phones/{phone}", s.handleScriptPhone)
+	s.mux.HandleFunc("DELETE /sim/phones/{phone}", s.handleScriptPhone)
+	s.mux.HandleFunc("PUT /sim/availability/{provider}", s.handleSetAvailability)
+	s.mux.HandleFunc("GET /sim/transactions", s.handleListTransactions)
+	s.mux.HandleFunc("POST /sim/transactions/{kind}/{id}/callback", s.handleResendCallback)
+	s.mux.HandleFunc("POST /sim/reset", func(w http.ResponseWriter, r *http.Request) {
+		s.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Reset forgets every transaction and script except the configured phone outcomes
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next = nil
+	s.phones = map[string]Outcome{}
+	for number, o := range s.cfg.Phones {
+		s.phones[number] = o
+	}
+	s.transactions = map[string]*Transaction{}
+	s.sessions = map[string]pawapay.PaymentSession{}
+	s.availability = map[string]string{}
+	for _, op := range []phone.Operator{phone.OperatorAirtel, phone.OperatorMTN, phone.OperatorZamtel} {
+		provider, _ := pawapay.ProviderCode(op)
+		s.availability[provider] = pawapay.AvailabilityOperational
+	}
+}
+
+// Script queues outcomes for the next payments, whatever their number
+func (s *Server) Script(outcomes ...Outcome) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next = append(s.next, outcomes...)
+}
+
+// ScriptPhone settles every payment to or from a number with an outcome. An empty
+// outcome removes the script.
+func (s *Server) ScriptPhone(number string, o Outcome) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	number = digits(number)
+	if o == "" {
+		delete(s.phones, number)
+		return
+	}
+	s.phones[number] = o
+}
+
+// SetAvailability reports a provider as OPERATIONAL, DELAYED or CLOSED
+func (s *Server) SetAvailability(provider, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.availability[provider] = status
+}
+
+// Transaction returns a copy of a transaction, or false if there is none
+func (s *Server) Transaction(kind, id string) (Transaction, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.transactions[kind+"/"+id]
+	if !ok {
+		return Transaction{}, false
+	}
+	return *t, true
+}
+
+// Transactions returns copies of every transaction, oldest first
+func (s *Server) Transactions() []Transaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]Transaction, 0, len(s.transactions))
+	for _, t := range s.transactions {
+		list = append(list, *t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.Before(list[j].Created) })
+	return list
+}
+
+// Wait blocks until every scheduled settlement and callback has been delivered
+func (s *Server) Wait() {
+	s.pending.Wait()
+}
+
+// outcomeFor picks the outcome of a new payment: queued outcomes first, then the
+// number's script, then the default. The caller holds s.mu.
+func (s *Server) outcomeFor(number string) Outcome {
+	if len(s.next) > 0 {
+		o := s.next[0]
+		s.next = s.next[1:]
+		return o
+	}
+	if o, ok := s.phones[digits(number)]; ok {
+		return o
+	}
+	return s.cfg.Default
+}
+
+// accept records a new transaction and schedules its settlement, or reports that the ID
+// was already used. The caller holds s.mu.
+func (s *Server) accept(t *Transaction) (duplicate bool) {
+	key := t.Kind + "/" + t.ID
+	if _, ok := s.transactions[key]; ok {
+		return true
+	}
+	t.Created = time.Now().UTC()
+	t.Status = "ACCEPTED"
+	if t.Outcome == OutcomeReject {
+		t.Status = "REJECTED"
+	}
+	s.transactions[key] = t
+	if t.Outcome != OutcomeReject {
+		s.schedule(t.Kind, t.ID)
+	}
+	return false
+}
+
+// schedule settles a transaction after CallbackDelay and delivers its callbacks
+func (s *Server) schedule(kind, id string) {
+	s.pending.Add(1)
+	time.AfterFunc(s.cfg.CallbackDelay, func() {
+		defer s.pending.Done()
+
+		s.mu.Lock()
+		t := s.transactions[kind+"/"+id]
+		if t == nil {
+			s.mu.Unlock()
+			return
+		}
+		switch t.Outcome {
+		case OutcomeTimeout:
+			t.Status = "SUBMITTED"
+		case OutcomeFail:
+			t.Status = "FAILED"
+		default:
+			t.Status = "COMPLETED"
+		}
+		outcome := t.Outcome
+		s.mu.Unlock()
+
+		switch outcome {
+		case OutcomeTimeout:
+		case OutcomeLate:
+			time.Sleep(s.cfg.LateDelay)
+			s.deliver(kind, id)
+		case OutcomeDuplicate:
+			s.deliver(kind, id)
+			s.deliver(kind, id)
+		default:
+			s.deliver(kind, id)
+		}
+	})
+}
+
+// deliver posts a transaction's current state to our callback route for its kind
+func (s *Server) deliver(kind, id string) error {
+	s.mu.Lock()
+	t, ok := s.transactions[kind+"/"+id]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("no %s transaction %s", kind, id)
+	}
+	payload := callbackPayload(t)
+	s.mu.Unlock()
+
+	if s.cfg.CallbackURL == "" {
+		return nil
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", strings.TrimRight(s.cfg.CallbackURL, "/")+"/"+kind, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.cfg.SigningKey != nil {
+		if err := Sign(req, body, s.cfg.SigningKey); err != nil {
+			return err
+		}
+	}
+
+	resp, err := s.cfg.HTTPClient.Do(req)
+	if err != nil {
+		log.Printf("pawapay-sim: %s callback for %s failed: %v", kind, id, err)
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	s.mu.Lock()
+	t.Callbacks++
+	s.mu.Unlock()
+	if resp.StatusCode >= 300 {
+		log.Printf("pawapay-sim: %s callback for %s returned %d", kind, id, resp.StatusCode)
+		return fmt.Errorf("callback returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// callbackPayload builds the callback body PawaPay sends for a transaction
+func callbackPayload(t *Transaction) interface{} {
+	failure := failureReason(t)
+	created := t.Created.Format(time.RFC3339)
+
+	switch t.Kind {
+	case KindPayout:
+		return pawapay.PayoutCallback{PayoutID: t.ID, Status: t.Status, Amount: t.Amount, Currency: t.Currency, Created: created, FailureReason: failure}
+	case KindRefund:
+		return pawapay.RefundCallback{RefundID: t.ID, DepositID: t.DepositID, Status: t.Status, Amount: t.Amount, Currency: t.Currency, FailureReason: failure}
+	}
+	cb := pawapay.DepositCallback{
+		DepositID:     t.ID,
+		Status:        t.Status,
+		Amount:        t.Amount,
+		Currency:      t.Currency,
+		Created:       created,
+		FailureReason: failure,
+		Correspondent: t.Provider,
+	}
+	if t.Status == "COMPLETED" {
+		cb.DepositedAmount = t.Amount
+	}
+	return cb
+}
+
+// API handlers
+
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.cfg.Token {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"errorMessage": "Invalid API token"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) handleDeposit(w http.ResponseWriter, r *http.Request) {
+	var req pawapay.Deposit
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.DepositID == "" {
+		writeJSON(w, http.StatusBadRequest, rejected("depositId", req.DepositID, "INVALID_INPUT", "Request body is not a valid deposit"))
+		return
+	}
+	number := req.Payer.AccountDetails.PhoneNumber
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.transactions[KindDeposit+"/"+req.DepositID]; ok {
+		writeJSON(w, http.StatusOK, map[string]string{"depositId": req.DepositID, "status": "DUPLICATE_IGNORED"})
+		return
+	}
+	if code, msg := s.validate(req.Amount, req.Currency, number, req.Payer.AccountDetails.Provider); code != "" {
+		writeJSON(w, http.StatusOK, rejected("depositId", req.DepositID, code, msg))
+		return
+	}
+	t := &Transaction{
+		Kind:     KindDeposit,
+		ID:       req.DepositID,
+		Amount:   req.Amount,
+		Currency: req.Currency,
+		Phone:    number,
+		Provider: req.Payer.AccountDetails.Provider,
+		Outcome:  s.outcomeFor(number),
+	}
+	s.respondAccepted(w, "depositId", t)
+}
+
+func (s *Server) handlePayout(w http.ResponseWriter, r *http.Request) {
+	var req pawapay.Payout
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.PayoutID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"payout_id": req.PayoutID, "status": "REJECTED"})
+		return
+	}
+	number := req.Recipient.Address.Value
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := &Transaction{
+		Kind:     KindPayout,
+		ID:       req.PayoutID,
+		Amount:   req.Amount.Value,
+		Currency: req.Amount.Currency,
+		Phone:    number,
+		Provider: req.Correspondent,
+		Outcome:  s.outcomeFor(number),
+	}
+	status := "DUPLICATE_IGNORED"
+	if !s.accept(t) {
+		status = t.Status
+	}
+	writeJSON(w, http.StatusOK, pawapay.PayoutResponse{PayoutID: req.PayoutID, Status: status})
+}
+
+func (s *Server) handleRefund(w http.ResponseWriter, r *http.Request) {
+	var req pawapay.Refund
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefundID == "" {
+		writeJSON(w, http.StatusBadRequest, rejected("refundId", req.RefundID, "INVALID_INPUT", "Request body is not a valid refund"))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.transactions[KindRefund+"/"+req.RefundID]; ok {
+		writeJSON(w, http.StatusOK, map[string]string{"refundId": req.RefundID, "status": "DUPLICATE_IGNORED"})
+		return
+	}
+	deposit, ok := s.transactions[KindDeposit+"/"+req.DepositID]
+	if !ok || deposit.Status != "COMPLETED" {
+		writeJSON(w, http.StatusOK, rejected("refundId", req.RefundID, "DEPOSIT_NOT_FOUND", "No completed deposit with that depositId"))
+		return
+	}
+	if code, msg := s.validateRefund(deposit, req); code != "" {
+		writeJSON(w, http.StatusOK, rejected("refundId", req.RefundID, code, msg))
+		return
+	}
+	t := &Transaction{
+		Kind:      KindRefund,
+		ID:        req.RefundID,
+		DepositID: req.DepositID,
+		Amount:    req.Amount,
+		Currency:  req.Currency,
+		Phone:     deposit.Phone,
+		Provider:  deposit.Provider,
+		Outcome:   s.outcomeFor(deposit.Phone),
+	}
+	s.respondAccepted(w, "refundId", t)
+}
+
+// respondAccepted accepts a deposit or refund and answers the way PawaPay V2 does. The
+// caller holds s.mu.
+func (s *Server) respondAccepted(w http.ResponseWriter, idField string, t *Transaction) {
+	if s.accept(t) {
+		writeJSON(w, http.StatusOK, map[string]string{idField: t.ID, "status": "DUPLICATE_IGNORED"})
+		return
+	}
+	if t.Status == "REJECTED" {
+		writeJSON(w, http.StatusOK, rejected(idField, t.ID, "PAYMENT_NOT_APPROVED", "Simulated rejection"))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{idField: t.ID, "status": t.Status, "created": t.Created.Format(time.RFC3339)})
+}
+
+// validate checks a deposit the way PawaPay does before accepting it. The caller holds s.mu.
+func (s *Server) validate(amount, currency, number, provider string) (code, message string) {
+	if a, err := money.Parse(amount); err != nil || a <= 0 {
+		return "INVALID_AMOUNT", "Amount must be a positive decimal"
+	}
+	if currency != string(money.ZMW) {
+		return "INVALID_CURRENCY", "Only ZMW is supported"
+	}
+	status, ok := s.availability[provider]
+	if !ok {
+		return "INVALID_PROVIDER", "Unknown provider " + provider
+	}
+	if status == pawapay.AvailabilityClosed {
+		return "PROVIDER_TEMPORARILY_UNAVAILABLE", "Provider is closed"
+	}
+	if n := digits(number); n != number || !strings.HasPrefix(n, "260") || len(n) != 12 {
+		return "INVALID_PHONE_NUMBER", "Phone number must be 12 digits starting with 260"
+	}
+	return "", ""
+}
+
+// validateRefund keeps refunds of a deposit within what it collected. The caller holds s.mu.
+func (s *Server) validateRefund(deposit *Transaction, req pawapay.Refund) (code, message string) {
+	amount, err := money.Parse(req.Amount)
+	if err != nil || amount <= 0 {
+		return "INVALID_AMOUNT", "Amount must be a positive decimal"
+	}
+	if req.Currency != deposit.Currency {
+		return "INVALID_CURRENCY", "Refund currency must match the deposit"
+	}
+	paid, _ := money.Parse(deposit.Amount)
+	refunded := money.Amount(0)
+	for _, t := range s.transactions {
+		if t.Kind == KindRefund && t.DepositID == deposit.ID && t.Status != "FAILED" && t.Status != "REJECTED" {
+			a, _ := money.Parse(t.Amount)
+			refunded += a
+		}
+	}
+	if refunded+amount > paid {
+		return "AMOUNT_TOO_LARGE", "Refunds would exceed the deposited amount"
+	}
+	return "", ""
+}
+
+func (s *Server) handleGetStatus(w http.ResponseWriter, r *http.Request) {
+	kind, id := r.PathValue("kind"), r.PathValue("id")
+	s.mu.Lock()
+	t, ok := s.transactions[kind+"/"+id]
+	var data map[string]interface{}
+	if ok {
+		data = statusData(t)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "NOT_FOUND"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "FOUND", "data": data})
+}
+
+// statusData is the data of a status check, in the shape of DepositStatusResponse with
+// the refund and payout IDs alongside
+func statusData(t *Transaction) map[string]interface{} {
+	data := map[string]interface{}{
+		"status":   t.Status,
+		"amount":   t.Amount,
+		"currency": t.Currency,
+		"created":  t.Created.Format(time.RFC3339),
+		"country":  "ZMB",
+	}
+	switch t.Kind {
+	case KindDeposit:
+		data["depositId"] = t.ID
+		data["payer"] = map[string]interface{}{
+			"type":           "MMO",
+			"accountDetails": map[string]string{"phoneNumber": t.Phone, "provider": t.Provider},
+		}
+	case KindRefund:
+		data["refundId"] = t.ID
+		data["depositId"] = t.DepositID
+	case KindPayout:
+		data["payoutId"] = t.ID
+	}
+	if failure := failureReason(t); failure != nil {
+		data["failureReason"] = failure
+	}
+	return data
+}
+
+// failureReason explains a failed transaction, or is nil if it has not failed
+func failureReason(t *Transaction) *pawapay.FailureReason {
+	if t.Status != "FAILED" {
+		return nil
+	}
+	if t.Kind == KindDeposit {
+		return &pawapay.FailureReason{FailureCode: "INSUFFICIENT_BALANCE", FailureMessage: "Simulated failure: the payer does not have enough funds"}
+	}
+	return &pawapay.FailureReason{FailureCode: "RECIPIENT_NOT_FOUND", FailureMessage: "Simulated failure"}
+}
+
+func (s *Server) handleCreatePaymentPage(w http.ResponseWriter, r *http.Request) {
+	var req pawapay.PaymentSession
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.DepositID == "" || req.ReturnURL == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"errorMessage": "depositId and returnUrl are required"})
+		return
+	}
+	if a, err := money.Parse(req.AmountDetails.Amount); err != nil || a <= 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"errorMessage": "amountDetails.amount must be a positive decimal"})
+		return
+	}
+
+	s.mu.Lock()
+	s.sessions[req.DepositID] = req
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, pawapay.SessionResponse{
+		RedirectURL: "http://" + r.Host + "/paymentpage/" + url.PathEscape(req.DepositID),
+	})
+}
+
+// handlePaymentPage stands in for the payer paying on PawaPay's hosted page. The deposit
+// is made as soon as the page is opened and the payer is sent straight back to the
+// return URL. The number the session was created with can be overridden with ?phone=,
+// and the outcome with ?outcome=.
+func (s *Server) handlePaymentPage(w http.ResponseWriter, r *http.Request) {
+	depositID := r.PathValue("depositId")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[depositID]
+	if !ok {
+		http.Error(w, "payment page session not found", http.StatusNotFound)
+		return
+	}
+
+	number := session.PhoneNumber
+	if q := r.URL.Query().Get("phone"); q != "" {
+		number = q
+	}
+	if number == "" {
+		http.Error(w, "no phone number on the session; pass ?phone=", http.StatusBadRequest)
+		return
+	}
+	e164, err := phone.Normalize(number)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	op, err := phone.DetectOperator(e164)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	provider, err := pawapay.ProviderCode(op)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	outcome := s.outcomeFor(e164)
+	if q := r.URL.Query().Get("outcome"); q != "" {
+		if outcome, err = ParseOutcome(q); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	s.accept(&Transaction{
+		Kind:     KindDeposit,
+		ID:       depositID,
+		Amount:   session.AmountDetails.Amount,
+		Currency: session.AmountDetails.Currency,
+		Phone:    strings.TrimPrefix(e164, "+"),
+		Provider: provider,
+		Outcome:  outcome,
+	})
+
+	returnURL, err := url.Parse(session.ReturnURL)
+	if err != nil {
+		http.Error(w, "invalid return URL", http.StatusBadRequest)
+		return
+	}
+	query := returnURL.Query()
+	query.Set("depositId", depositID)
+	returnURL.RawQuery = query.Encode()
+	http.Redirect(w, r, returnURL.String(), http.StatusFound)
+}
+
+func (s *Server) handleAvailability(w http.ResponseWriter, r *http.Request) {
+	type operation struct {
+		OperationType string `json:"operationType"`
+		Status        string `json:"status"`
+	}
+	type provider struct {
+		Provider       string      `json:"provider"`
+		OperationTypes []operation `json:"operationTypes"`
+	}
+
+	s.mu.Lock()
+	providers := make([]provider, 0, len(s.availability))
+	for code, status := range s.availability {
+		p := provider{Provider: code}
+		for _, op := range []string{"DEPOSIT", "PAYOUT", "REFUND"} {
+			p.OperationTypes = append(p.OperationTypes, operation{OperationType: op, Status: status})
+		}
+		providers = append(providers, p)
+	}
+	s.mu.Unlock()
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Provider < providers[j].Provider })
+
+	writeJSON(w, http.StatusOK, []map[string]interface{}{{"country": "ZMB", "providers": providers}})
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]bool{"operational": true})
+}
+
+// Scripting handlers
+
+func (s *Server) handleScriptNext(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Outcomes []string `json:"outcomes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	outcomes := make([]Outcome, 0, len(req.Outcomes))
+	for _, raw := range req.Outcomes {
+		o, err := ParseOutcome(raw)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		outcomes = append(outcomes, o)
+	}
+	s.Script(outcomes...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleScriptPhone(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		s.ScriptPhone(r.PathValue("phone"), "")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	var req struct {
+		Outcome string `json:"outcome"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	o, err := ParseOutcome(req.Outcome)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.ScriptPhone(r.PathValue("phone"), o)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleSetAvailability(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch req.Status {
+	case pawapay.AvailabilityOperational, pawapay.AvailabilityDelayed, pawapay.AvailabilityClosed:
+	default:
+		http.Error(w, "status must be OPERATIONAL, DELAYED or CLOSED", http.StatusBadRequest)
+		return
+	}
+	s.SetAvailability(r.PathValue("provider"), req.Status)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListTransactions(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Transactions())
+}
+
+// handleResendCallback delivers a transaction's callback again, for replaying one by hand
+func (s *Server) handleResendCallback(w http.ResponseWriter, r *http.Request) {
+	if err := s.deliver(r.PathValue("kind"), r.PathValue("id")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func rejected(idField, id, code, message string) map[string]interface{} {
+	return map[string]interface{}{
+		idField:  id,
+		"status": "REJECTED",
+		"rejectionReason": pawapay.RejectionReason{
+			RejectionCode:    code,
+			RejectionMessage: message,
+		},
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
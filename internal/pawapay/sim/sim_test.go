@@ -0,0 +1,207 @@
+package sim
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+	"github.com/yakumwamba/lpg-delivery-system/internal/pawapay"
+)
+
+// receiver stands in for our callback routes, checking signatures as they do
+type receiver struct {
+	mu       sync.Mutex
+	received map[string][]pawapay.DepositCallback
+	refunds  []pawapay.RefundCallback
+}
+
+func newHarness(t *testing.T, lateDelay time.Duration) (*Server, *pawapay.Client, *receiver) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	publicKey, err := PublicKeyPEM(key)
+	require.NoError(t, err)
+	verifier, err := pawapay.NewVerifier(publicKey)
+	require.NoError(t, err)
+
+	rec := &receiver{received: map[string][]pawapay.DepositCallback{}}
+	callbacks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := verifier.Verify(r, body); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		switch r.URL.Path {
+		case "/pawapay/callback/deposits":
+			var cb pawapay.DepositCallback
+			json.Unmarshal(body, &cb)
+			rec.received[cb.DepositID] = append(rec.received[cb.DepositID], cb)
+		case "/pawapay/callback/refunds":
+			var cb pawapay.RefundCallback
+			json.Unmarshal(body, &cb)
+			rec.refunds = append(rec.refunds, cb)
+		}
+	}))
+	t.Cleanup(callbacks.Close)
+
+	srv := NewServer(Config{
+		CallbackURL: callbacks.URL + "/pawapay/callback",
+		Token:       "test-token",
+		SigningKey:  key,
+		LateDelay:   lateDelay,
+	})
+	api := httptest.NewServer(srv)
+	t.Cleanup(api.Close)
+	return srv, pawapay.NewClient(api.URL, "test-token", "test-token", ""), rec
+}
+
+func (r *receiver) deposits(id string) []pawapay.DepositCallback {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.received[id]
+}
+
+func TestDepositOutcomes(t *testing.T) {
+	srv, client, rec := newHarness(t, 0)
+	amount := money.New(15000, money.ZMW)
+
+	dep, err := client.InitiateDeposit("order-1", amount, "0971234567", "")
+	require.NoError(t, err)
+	assert.Equal(t, "ACCEPTED", dep.Status)
+	srv.Wait()
+	callbacks := rec.deposits(dep.DepositID)
+	require.Len(t, callbacks, 1)
+	assert.Equal(t, "COMPLETED", callbacks[0].Status)
+	assert.Equal(t, "150.00", callbacks[0].Amount)
+	assert.Equal(t, "AIRTEL_OAPI_ZMB", callbacks[0].Correspondent)
+
+	status, err := client.GetPaymentStatus("deposits", dep.DepositID)
+	require.NoError(t, err)
+	assert.Equal(t, "COMPLETED", status.Status)
+
+	srv.Script(OutcomeFail, OutcomeDuplicate, OutcomeTimeout)
+	failed, err := client.InitiateDeposit("order-2", amount, "0961234567", "")
+	require.NoError(t, err)
+	duplicated, err := client.InitiateDeposit("order-3", amount, "0961234567", "")
+	require.NoError(t, err)
+	abandoned, err := client.InitiateDeposit("order-4", amount, "0961234567", "")
+	require.NoError(t, err)
+	srv.Wait()
+
+	require.Len(t, rec.deposits(failed.DepositID), 1)
+	assert.Equal(t, "FAILED", rec.deposits(failed.DepositID)[0].Status)
+	assert.Equal(t, "INSUFFICIENT_BALANCE", rec.deposits(failed.DepositID)[0].FailureReason.FailureCode)
+	assert.Len(t, rec.deposits(duplicated.DepositID), 2)
+	assert.Empty(t, rec.deposits(abandoned.DepositID))
+	status, err = client.GetPaymentStatus("deposits", abandoned.DepositID)
+	require.NoError(t, err)
+	assert.Equal(t, "SUBMITTED", status.Status)
+
+	// Scripted numbers are matched however they were written
+	srv.ScriptPhone("+260 95 1234567", OutcomeReject)
+	_, err = client.InitiateDeposit("order-5", amount, "0951234567", "")
+	assert.ErrorContains(t, err, "PAYMENT_NOT_APPROVED")
+
+	status, err = client.GetPaymentStatus("deposits", uuid.NewString())
+	require.NoError(t, err)
+	assert.Equal(t, "NOT_FOUND", status.Status)
+}
+
+func TestLateCallback(t *testing.T) {
+	srv, client, rec := newHarness(t, 300*time.Millisecond)
+	srv.Script(OutcomeLate)
+
+	dep, err := client.InitiateDeposit("order-1", money.New(5000, money.ZMW), "0971234567", "")
+	require.NoError(t, err)
+
+	// PawaPay already reports the deposit completed while its callback is held back
+	assert.Eventually(t, func() bool {
+		status, err := client.GetPaymentStatus("deposits", dep.DepositID)
+		return err == nil && status.Status == "COMPLETED"
+	}, time.Second, 10*time.Millisecond)
+	assert.Empty(t, rec.deposits(dep.DepositID))
+
+	srv.Wait()
+	assert.Len(t, rec.deposits(dep.DepositID), 1)
+}
+
+func TestRefunds(t *testing.T) {
+	srv, client, rec := newHarness(t, 0)
+	dep, err := client.InitiateDeposit("order-1", money.New(20000, money.ZMW), "0971234567", "")
+	require.NoError(t, err)
+	srv.Wait()
+
+	refund := pawapay.Refund{RefundID: uuid.NewString(), DepositID: dep.DepositID, Amount: "150.00", Currency: "ZMW"}
+	resp, err := client.InitiateRefund(refund)
+	require.NoError(t, err)
+	assert.Equal(t, "ACCEPTED", resp.Status)
+
+	again, err := client.InitiateRefund(refund)
+	require.NoError(t, err)
+	assert.Equal(t, "DUPLICATE_IGNORED", again.Status)
+
+	// Only K50 of the deposit is left to refund
+	over, err := client.InitiateRefund(pawapay.Refund{RefundID: uuid.NewString(), DepositID: dep.DepositID, Amount: "60.00", Currency: "ZMW"})
+	require.NoError(t, err)
+	assert.Equal(t, "REJECTED", over.Status)
+	assert.Equal(t, "AMOUNT_TOO_LARGE", over.RejectionReason.RejectionCode)
+
+	srv.Wait()
+	require.Len(t, rec.refunds, 1)
+	assert.Equal(t, dep.DepositID, rec.refunds[0].DepositID)
+	assert.Equal(t, "COMPLETED", rec.refunds[0].Status)
+}
+
+func TestClosedProvider(t *testing.T) {
+	srv, client, _ := newHarness(t, 0)
+	srv.SetAvailability("MTN_MOMO_ZMB", pawapay.AvailabilityClosed)
+
+	_, err := client.InitiateDeposit("order-1", money.New(5000, money.ZMW), "0961234567", "")
+	assert.ErrorIs(t, err, pawapay.ErrProviderUnavailable)
+	assert.Empty(t, srv.Transactions())
+}
+
+func TestPaymentPage(t *testing.T) {
+	srv, client, rec := newHarness(t, 0)
+	depositID := uuid.NewString()
+	session, err := client.CreatePaymentSession(pawapay.PaymentSession{
+		DepositID:     depositID,
+		ReturnURL:     "http://localhost:8080/payments/pawapay/return?source=app",
+		AmountDetails: pawapay.AmountDetails{Amount: "120.00", Currency: "ZMW"},
+		Country:       "ZMB",
+		PhoneNumber:   "260971234567",
+	})
+	require.NoError(t, err)
+
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := noRedirect.Get(session.RedirectURL + "?outcome=fail")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "http://localhost:8080/payments/pawapay/return?depositId="+depositID+"&source=app", resp.Header.Get("Location"))
+
+	srv.Wait()
+	require.Len(t, rec.deposits(depositID), 1)
+	assert.Equal(t, "FAILED", rec.deposits(depositID)[0].Status)
+}
+
+func TestRequiresToken(t *testing.T) {
+	srv := NewServer(Config{Token: "secret"})
+	req := httptest.NewRequest("GET", "/v2/status", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
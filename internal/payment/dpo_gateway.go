@@ -0,0 +1,80 @@
+package payment
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yakumwamba/lpg-delivery-system/internal/common"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+)
+
+// dpoGateway takes card payments on DPO's hosted checkout page. The customer is sent to
+// the page and comes back to returnURL, where the payment is verified; payments whose
+// customer never comes back are verified by the background poll.
+type dpoGateway struct {
+	client      *common.Client
+	returnURL   string
+	serviceType int
+}
+
+// NewDPOGateway wraps a DPO client as a payment gateway. serviceType is the DPO service
+// configured for the account.
+func NewDPOGateway(client *common.Client, returnURL string, serviceType int) Gateway {
+	return &dpoGateway{client: client, returnURL: returnURL, serviceType: serviceType}
+}
+
+func (g *dpoGateway) Name() string {
+	return ProviderDPO
+}
+
+func (g *dpoGateway) Initiate(req Request) (*Initiation, error) {
+	resp, err := g.client.CreateToken(&common.CreateTokenRequest{
+		Transaction: common.Transaction{
+			PaymentAmount:   req.Amount.String(),
+			PaymentCurrency: string(money.ZMW),
+			CompanyRef:      req.OrderID.String(),
+			RedirectURL:     g.returnURL,
+			BackURL:         g.returnURL,
+			// Several attempts may be made for the same order
+			CompanyRefUnique: 0,
+			PTL:              dpoPaymentTimeLimit,
+		},
+		Services: common.Services{Service: []common.Service{{
+			ServiceType:        g.serviceType,
+			ServiceDescription: "LPG order " + req.OrderID.String(),
+			ServiceDate:        time.Now().Format("2006/01/02 15:04"),
+		}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("DPO checkout failed: %w", err)
+	}
+	expiresAt := time.Now().Add(dpoPaymentTimeLimit * time.Hour)
+	return &Initiation{
+		Reference:   resp.TransToken,
+		Status:      PaymentStatusPending,
+		RedirectURL: common.PaymentURL + resp.TransToken,
+		ExpiresAt:   &expiresAt,
+	}, nil
+}
+
+func (g *dpoGateway) Status(reference string) (PaymentStatus, error) {
+	resp, err := g.client.VerifyToken(&common.VerifyTokenRequest{TransactionToken: reference})
+	if err != nil {
+		return "", fmt.Errorf("DPO verification failed: %w", err)
+	}
+	return dpoStatus(resp.Result), nil
+}
+
+// dpoPaymentTimeLimit is how many hours the customer has to pay on DPO's page
+const dpoPaymentTimeLimit = 1
+
+// dpoStatus maps a DPO verifyToken result code onto the payment state machine
+func dpoStatus(result string) PaymentStatus {
+	switch result {
+	case "000", "002": // paid, overpaid
+		return PaymentStatusCompleted
+	case "901", "903", "904": // declined, time limit expired, cancelled
+		return PaymentStatusFailed
+	}
+	return PaymentStatusPending
+}
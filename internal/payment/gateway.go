@@ -0,0 +1,130 @@
+package payment
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+	"github.com/yakumwamba/lpg-delivery-system/internal/pawapay"
+)
+
+// Gateway is a payment provider that collects money for orders. The service records each
+// attempt in payments and drives its status; gateways only talk to the provider.
+type Gateway interface {
+	// Name is stored as payments.provider
+	Name() string
+	// Initiate starts collecting the amount and returns the provider's reference for it
+	Initiate(req Request) (*Initiation, error)
+	// Status asks the provider for the current status of an attempt it started
+	Status(reference string) (PaymentStatus, error)
+}
+
+// Request is what a gateway needs to start collecting a payment
+type Request struct {
+	OrderID     uuid.UUID
+	Amount      money.Amount
+	PhoneNumber string
+	// Operator optionally overrides the mobile network detected from the phone number
+	Operator string
+	// Hosted asks for the gateway's own payment page, where the payer enters their
+	// details, instead of collecting from PhoneNumber directly
+	Hosted bool
+	// Purpose defaults to PurposeOrder
+	Purpose Purpose
+}
+
+// Initiation is a gateway's answer to a new payment attempt
+type Initiation struct {
+	Reference string
+	Status    PaymentStatus
+	// RedirectURL is where the payer completes the payment, if the gateway hosts a page
+	RedirectURL string
+	// ExpiresAt is when a hosted page stops accepting the payment
+	ExpiresAt *time.Time
+}
+
+// pawaPayGateway collects mobile money deposits through PawaPay, either by prompting the
+// payer's phone directly or on PawaPay's hosted payment page
+type pawaPayGateway struct {
+	client    *pawapay.Client
+	returnURL string
+}
+
+// NewPawaPayGateway wraps a PawaPay client as a payment gateway. Payers on the hosted
+// payment page are sent back to returnURL.
+func NewPawaPayGateway(client *pawapay.Client, returnURL string) Gateway {
+	return &pawaPayGateway{client: client, returnURL: returnURL}
+}
+
+func (g *pawaPayGateway) Name() string {
+	return ProviderPawaPay
+}
+
+func (g *pawaPayGateway) Initiate(req Request) (*Initiation, error) {
+	if req.Hosted {
+		return g.initiatePage(req)
+	}
+
+	resp, err := g.client.InitiateDeposit(req.OrderID.String(), money.New(req.Amount, money.ZMW), req.PhoneNumber, req.Operator)
+	if err != nil {
+		return nil, fmt.Errorf("PawaPay deposit failed: %w", err)
+	}
+	if resp.Status == "REJECTED" && resp.RejectionReason != nil {
+		return nil, fmt.Errorf("deposit rejected: %s - %s",
+			resp.RejectionReason.RejectionCode, resp.RejectionReason.RejectionMessage)
+	}
+	return &Initiation{Reference: resp.DepositID, Status: pawaPayStatus(resp.Status)}, nil
+}
+
+// initiatePage opens a payment page session. The deposit ID is chosen here so the payment
+// record is linked to the deposit the payer eventually makes.
+func (g *pawaPayGateway) initiatePage(req Request) (*Initiation, error) {
+	if g.returnURL == "" {
+		return nil, fmt.Errorf("%w: PawaPay payment page return URL", ErrUnknownGateway)
+	}
+
+	depositID := uuid.New().String()
+	resp, err := g.client.CreatePaymentSession(pawapay.PaymentSession{
+		DepositID: depositID,
+		ReturnURL: g.returnURL,
+		AmountDetails: pawapay.AmountDetails{
+			Amount:   req.Amount.String(),
+			Currency: string(money.ZMW),
+		},
+		Country:     "ZMB",
+		PhoneNumber: req.PhoneNumber,
+		Reason:      "LPG order",
+		Metadata:    []pawapay.Metadata{{FieldName: "orderId", Value: req.OrderID.String()}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("PawaPay payment page failed: %w", err)
+	}
+
+	expiresAt := time.Now().Add(PaymentPageTTL)
+	return &Initiation{
+		Reference:   depositID,
+		Status:      PaymentStatusPending,
+		RedirectURL: resp.RedirectURL,
+		ExpiresAt:   &expiresAt,
+	}, nil
+}
+
+func (g *pawaPayGateway) Status(reference string) (PaymentStatus, error) {
+	resp, err := g.client.GetPaymentStatus("deposits", reference)
+	if err != nil {
+		return "", err
+	}
+	return pawaPayStatus(resp.Status), nil
+}
+
+// pawaPayStatus maps a PawaPay deposit status onto the payment state machine
+func pawaPayStatus(status string) PaymentStatus {
+	switch status {
+	case "COMPLETED":
+		return PaymentStatusCompleted
+	case "FAILED", "REJECTED":
+		return PaymentStatusFailed
+	}
+	return PaymentStatusPending
+}
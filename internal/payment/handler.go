@@ -2,6 +2,7 @@ package payment
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"net/http"
 
@@ -9,6 +10,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+	"github.com/yakumwamba/lpg-delivery-system/internal/pawapay"
+	"github.com/yakumwamba/lpg-delivery-system/internal/phone"
 )
 
 type Handler struct {
@@ -47,9 +51,10 @@ func (h *Handler) InitiateDepositHandler() gin.HandlerFunc {
 		logger.WithField("body", string(bodyBytes)).Debug("Raw request body")
 
 		var request struct {
-			OrderID     string  `json:"order_id"`
-			Amount      float64 `json:"amount"`
-			PhoneNumber string  `json:"phone_number"`
+			OrderID     string       `json:"order_id"`
+			Amount      money.Amount `json:"amount"`
+			PhoneNumber string       `json:"phone_number"`
+			Operator    string       `json:"operator"`
 		}
 
 		if err := c.ShouldBindJSON(&request); err != nil {
@@ -79,10 +84,18 @@ func (h *Handler) InitiateDepositHandler() gin.HandlerFunc {
 
 		logger.WithField("orderID", orderID.String()).Debug("Parsed order ID to UUID")
 
-		payment, err := h.service.InitiateDeposit(orderID, request.Amount, request.PhoneNumber)
+		payment, err := h.service.InitiateDeposit(orderID, request.Amount, request.PhoneNumber, request.Operator)
 		if err != nil {
 			logger.WithError(err).Error("Failed to initiate deposit")
-			c.JSON(http.StatusInternalServerError, gin.H{
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, phone.ErrInvalidNumber), errors.Is(err, phone.ErrUnknownOperator),
+				errors.Is(err, pawapay.ErrUnsupportedOperator):
+				status = http.StatusBadRequest
+			case errors.Is(err, pawapay.ErrProviderUnavailable):
+				status = http.StatusServiceUnavailable
+			}
+			c.JSON(status, gin.H{
 				"error":   "failed to initiate deposit",
 				"details": err.Error(),
 			})
@@ -117,23 +130,3 @@ func (h *Handler) CheckDepositStatusHandler(c *gin.Context) {
 		"status": status,
 	})
 }
-func (h *Handler) DepositCallbackHandler() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var callback struct {
-			DepositID string `json:"deposit_id"`
-			Status    string `json:"status"`
-		}
-
-		if err := c.ShouldBindJSON(&callback); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid callback data"})
-			return
-		}
-
-		if err := h.service.HandleDepositCallback(callback.DepositID, callback.Status); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		c.JSON(http.StatusOK, gin.H{"message": "callback processed successfully"})
-	}
-}
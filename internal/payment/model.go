@@ -1,9 +1,11 @@
 package payment
 
 import (
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
 )
 
 type PaymentStatus string
@@ -14,14 +16,111 @@ const (
 	PaymentStatusFailed    PaymentStatus = "failed"
 )
 
+// CanTransition reports whether a payment attempt may move from s to next. Completed is
+// final, a failure can still be overtaken by a late completion, and nothing returns to
+// pending. Gateways report outcomes late and out of order, so every status change goes
+// through here.
+func (s PaymentStatus) CanTransition(next PaymentStatus) bool {
+	switch s {
+	case PaymentStatusPending:
+		return next == PaymentStatusCompleted || next == PaymentStatusFailed
+	case PaymentStatusFailed:
+		return next == PaymentStatusCompleted
+	}
+	return false
+}
+
+// Payment providers
+const (
+	ProviderPawaPay = "pawapay"
+	ProviderDPO     = "dpo"
+	// ProviderWallet is the customer's wallet; it has no gateway and no payments row
+	ProviderWallet = "wallet"
+)
+
+// Purpose is what a payment attempt collects for
+type Purpose string
+
+const (
+	// PurposeOrder pays the order as it was placed
+	PurposeOrder Purpose = "order"
+	// PurposeAdjustment tops up a paid order whose total went up when it was edited.
+	// The order stays paid for what was already collected while it is outstanding.
+	PurposeAdjustment Purpose = "adjustment"
+)
+
+var (
+	ErrPaymentNotFound = errors.New("payment not found")
+	ErrUnknownGateway  = errors.New("payment gateway not configured")
+	ErrNoGateway       = errors.New("payment method is not paid through a gateway")
+	ErrAttemptPending  = errors.New("a payment for this order is already in progress")
+)
+
+// GatewayFor returns the gateway that collects payment for an order's payment method.
+// Cash and on-account orders are not paid through a gateway.
+func GatewayFor(paymentMethod string) (string, error) {
+	switch paymentMethod {
+	case "mobile_money":
+		return ProviderPawaPay, nil
+	case "card":
+		return ProviderDPO, nil
+	}
+	return "", ErrNoGateway
+}
+
 type Payment struct {
 	ID             uuid.UUID     `json:"id" db:"id"`
 	OrderID        uuid.UUID     `json:"order_id" db:"order_id"`
-	Amount         float64       `json:"amount" db:"amount"`
+	Amount         money.Amount  `json:"amount" db:"amount"`
 	Status         PaymentStatus `json:"status" db:"status"`
 	Provider       string        `json:"provider" db:"provider"`
 	PhoneNumber    string        `json:"phone_number" db:"phone_number"`
 	TransactionRef string        `json:"transaction_ref,omitempty" db:"transaction_ref"`
-	CreatedAt      time.Time     `json:"created_at" db:"created_at"`
-	UpdatedAt      time.Time     `json:"updated_at" db:"updated_at"`
+	Purpose        Purpose       `json:"purpose" db:"purpose"`
+	// RedirectURL is the hosted page the payer completes the payment on, for gateways
+	// that have one. It is only set on a newly started payment.
+	RedirectURL       string     `json:"redirect_url,omitempty" db:"-"`
+	CheckoutExpiresAt *time.Time `json:"checkout_expires_at,omitempty" db:"checkout_expires_at"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// PaymentPageTTL is how long a payer has on a hosted payment page before the attempt is
+// treated as abandoned
+const PaymentPageTTL = time.Hour
+
+// EventType names a payment domain event
+type EventType string
+
+const (
+	EventPaymentCompleted EventType = "payment.completed"
+	EventPaymentFailed    EventType = "payment.failed"
+)
+
+// Event is published when a payment attempt reaches a final status
+type Event struct {
+	Type      EventType     `json:"type"`
+	PaymentID uuid.UUID     `json:"payment_id"`
+	OrderID   uuid.UUID     `json:"order_id"`
+	Provider  string        `json:"provider"`
+	Reference string        `json:"reference"`
+	Purpose   Purpose       `json:"purpose"`
+	Amount    money.Amount  `json:"amount"`
+	Status    PaymentStatus `json:"status"`
+	Reason    string        `json:"reason,omitempty"`
+}
+
+// Subscriber reacts to a payment event. Subscribers run in the order they were added;
+// an error is logged and does not stop the others.
+type Subscriber func(Event) error
+
+// eventFor returns the event announcing a payment reaching status, if any
+func eventFor(status PaymentStatus) (EventType, bool) {
+	switch status {
+	case PaymentStatusCompleted:
+		return EventPaymentCompleted, true
+	case PaymentStatusFailed:
+		return EventPaymentFailed, true
+	}
+	return "", false
 }
@@ -0,0 +1,71 @@
+package payment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransition(t *testing.T) {
+	assert.True(t, PaymentStatusPending.CanTransition(PaymentStatusCompleted))
+	assert.True(t, PaymentStatusPending.CanTransition(PaymentStatusFailed))
+	assert.True(t, PaymentStatusFailed.CanTransition(PaymentStatusCompleted))
+
+	// A late FAILED callback must not undo a completed payment
+	assert.False(t, PaymentStatusCompleted.CanTransition(PaymentStatusFailed))
+	assert.False(t, PaymentStatusCompleted.CanTransition(PaymentStatusPending))
+	assert.False(t, PaymentStatusFailed.CanTransition(PaymentStatusPending))
+	assert.False(t, PaymentStatusPending.CanTransition(PaymentStatusPending))
+}
+
+func TestPawaPayStatus(t *testing.T) {
+	assert.Equal(t, PaymentStatusCompleted, pawaPayStatus("COMPLETED"))
+	assert.Equal(t, PaymentStatusFailed, pawaPayStatus("FAILED"))
+	assert.Equal(t, PaymentStatusFailed, pawaPayStatus("REJECTED"))
+	assert.Equal(t, PaymentStatusPending, pawaPayStatus("ACCEPTED"))
+	assert.Equal(t, PaymentStatusPending, pawaPayStatus("SUBMITTED"))
+}
+
+func TestPublish(t *testing.T) {
+	s := &Service{subscribers: map[EventType][]Subscriber{}}
+	var got []string
+	s.Subscribe(EventPaymentCompleted, func(e Event) error {
+		got = append(got, "order")
+		return assert.AnError
+	})
+	s.Subscribe(EventPaymentCompleted, func(e Event) error {
+		got = append(got, "dispatch")
+		return nil
+	})
+	s.Subscribe(EventPaymentFailed, func(e Event) error {
+		got = append(got, "failed")
+		return nil
+	})
+
+	// A failing subscriber doesn't stop the ones after it
+	s.publish(Event{Type: EventPaymentCompleted})
+	assert.Equal(t, []string{"order", "dispatch"}, got)
+}
+
+func TestDPOStatus(t *testing.T) {
+	assert.Equal(t, PaymentStatusCompleted, dpoStatus("000"))
+	assert.Equal(t, PaymentStatusCompleted, dpoStatus("002"))
+	assert.Equal(t, PaymentStatusFailed, dpoStatus("901"))
+	assert.Equal(t, PaymentStatusFailed, dpoStatus("904"))
+	// Not yet paid, or waiting on the bank
+	assert.Equal(t, PaymentStatusPending, dpoStatus("900"))
+	assert.Equal(t, PaymentStatusPending, dpoStatus("003"))
+}
+
+func TestGatewayFor(t *testing.T) {
+	provider, err := GatewayFor("mobile_money")
+	assert.NoError(t, err)
+	assert.Equal(t, ProviderPawaPay, provider)
+
+	provider, err = GatewayFor("card")
+	assert.NoError(t, err)
+	assert.Equal(t, ProviderDPO, provider)
+
+	_, err = GatewayFor("cash")
+	assert.ErrorIs(t, err, ErrNoGateway)
+}
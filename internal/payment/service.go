@@ -5,148 +5,344 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/yakumwamba/lpg-delivery-system/internal/order"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
 	"github.com/yakumwamba/lpg-delivery-system/internal/pawapay"
-	"github.com/yakumwamba/lpg-delivery-system/internal/user"
 )
 
+// Service records payment attempts for orders across every configured gateway and moves
+// them through one state machine. Order and dispatch logic react to the outcome by
+// subscribing to payment events rather than being called from here.
 type Service struct {
-	db           *sql.DB
-	orderService *order.Service
-	userService  *user.Service
-	pawaPay      *pawapay.Client
+	db          *sql.DB
+	pawaPay     *pawapay.Client
+	gateways    map[string]Gateway
+	subscribers map[EventType][]Subscriber
 }
 
-func NewService(db *sql.DB, orderService *order.Service, userService *user.Service, pawaPay *pawapay.Client) *Service {
+// NewService creates the payment service. Gateways are added with RegisterGateway.
+func NewService(db *sql.DB, pawaPay *pawapay.Client) *Service {
 	return &Service{
-		db:           db,
-		orderService: orderService,
-		userService:  userService,
-		pawaPay:      pawaPay,
+		db:          db,
+		pawaPay:     pawaPay,
+		gateways:    map[string]Gateway{},
+		subscribers: map[EventType][]Subscriber{},
 	}
 }
 
-// InitiateDeposit initiates a deposit using PawaPay
-func (s *Service) InitiateDeposit(orderID uuid.UUID, amount float64, phoneNumber string) (*Payment, error) {
-	fmt.Printf("[PaymentService] Initiating deposit - OrderID: %s, Amount: %.2f, Phone: %s\n", orderID.String(), amount, phoneNumber)
+// RegisterGateway makes a gateway available under its name. It is meant to be called
+// while the server is being set up.
+func (s *Service) RegisterGateway(g Gateway) {
+	s.gateways[g.Name()] = g
+}
 
-	// Send deposit request to PawaPay
-	depositResponse, err := s.pawaPay.InitiateDeposit(orderID.String(), amount, phoneNumber)
-	if err != nil {
-		fmt.Printf("[PaymentService] PawaPay error: %v\n", err)
-		return nil, fmt.Errorf("PawaPay deposit failed: %w", err)
+// Subscribe adds a subscriber for an event type. It is meant to be called while the
+// server is being set up.
+func (s *Service) Subscribe(eventType EventType, fn Subscriber) {
+	s.subscribers[eventType] = append(s.subscribers[eventType], fn)
+}
+
+func (s *Service) publish(event Event) {
+	for _, fn := range s.subscribers[event.Type] {
+		if err := fn(event); err != nil {
+			log.Printf("ERROR: %s subscriber failed for payment %s: %v", event.Type, event.PaymentID, err)
+		}
 	}
+}
 
-	fmt.Printf("[PaymentService] Deposit response: %+v\n", depositResponse)
+// Initiate starts a payment attempt for an order through the named gateway and records it
+// as pending
+func (s *Service) Initiate(provider string, req Request) (*Payment, error) {
+	gateway, ok := s.gateways[provider]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownGateway, provider)
+	}
+
+	fmt.Printf("[PaymentService] Initiating %s payment - OrderID: %s, Amount: %s\n", provider, req.OrderID.String(), req.Amount)
 
-	// Check if deposit was rejected
-	if depositResponse.Status == "REJECTED" && depositResponse.RejectionReason != nil {
-		return nil, fmt.Errorf("deposit rejected: %s - %s",
-			depositResponse.RejectionReason.RejectionCode,
-			depositResponse.RejectionReason.RejectionMessage)
+	initiation, err := gateway.Initiate(req)
+	if err != nil {
+		fmt.Printf("[PaymentService] %s error: %v\n", provider, err)
+		return nil, err
 	}
 
 	// Save payment record
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	purpose := req.Purpose
+	if purpose == "" {
+		purpose = PurposeOrder
+	}
 	payment := &Payment{
-		ID:             uuid.New(),
-		OrderID:        orderID,
-		Amount:         amount,
-		Status:         PaymentStatusPending,
-		Provider:       "pawapay",
-		PhoneNumber:    phoneNumber,
-		TransactionRef: depositResponse.DepositID,
-		CreatedAt:      time.Now(),
-		UpdatedAt:      time.Now(),
+		ID:                uuid.New(),
+		OrderID:           req.OrderID,
+		Amount:            req.Amount,
+		Status:            PaymentStatusPending,
+		Provider:          provider,
+		PhoneNumber:       req.PhoneNumber,
+		TransactionRef:    initiation.Reference,
+		Purpose:           purpose,
+		RedirectURL:       initiation.RedirectURL,
+		CheckoutExpiresAt: initiation.ExpiresAt,
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
 	}
 
 	query := `
 		INSERT INTO payments (
 			id, order_id, amount, status, provider, phone_number,
-			transaction_ref, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			transaction_ref, purpose, checkout_expires_at, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 		RETURNING id, created_at, updated_at
 	`
 
 	var paymentIDStr string
 	err = s.db.QueryRowContext(ctx, query,
 		payment.ID.String(), payment.OrderID.String(), payment.Amount, payment.Status,
-		payment.Provider, payment.PhoneNumber, payment.TransactionRef,
-		payment.CreatedAt, payment.UpdatedAt,
+		payment.Provider, payment.PhoneNumber, payment.TransactionRef, payment.Purpose,
+		payment.CheckoutExpiresAt, payment.CreatedAt, payment.UpdatedAt,
 	).Scan(&paymentIDStr, &payment.CreatedAt, &payment.UpdatedAt)
-
-	if err == nil {
-		payment.ID, _ = uuid.Parse(paymentIDStr)
-	}
-
 	if err != nil {
 		fmt.Printf("[PaymentService] Database error: %v\n", err)
 		return nil, fmt.Errorf("failed to save payment: %w", err)
 	}
+	payment.ID, _ = uuid.Parse(paymentIDStr)
+
+	// Some gateways settle synchronously
+	if initiation.Status != PaymentStatusPending {
+		if updated, err := s.Transition(provider, payment.TransactionRef, initiation.Status, ""); err == nil {
+			updated.RedirectURL = payment.RedirectURL
+			payment = updated
+		}
+	}
 
 	fmt.Printf("[PaymentService] Payment saved successfully - ID: %s, TransactionRef: %s\n", payment.ID.String(), payment.TransactionRef)
 	return payment, nil
 }
 
-// HandleDepositCallback handles the callback from PawaPay
-func (s *Service) HandleDepositCallback(depositID string, status string) error {
-	// Update payment status based on callback
-	var paymentStatus PaymentStatus
-	var orderStatus order.PaymentStatus
+// InitiateDeposit initiates a deposit using PawaPay. operator optionally overrides the
+// mobile network detected from the phone number.
+func (s *Service) InitiateDeposit(orderID uuid.UUID, amount money.Amount, phoneNumber, operator string) (*Payment, error) {
+	return s.Initiate(ProviderPawaPay, Request{
+		OrderID:     orderID,
+		Amount:      amount,
+		PhoneNumber: phoneNumber,
+		Operator:    operator,
+	})
+}
+
+// InitiateTopUp collects the difference on a paid order whose total went up when it
+// was edited, as a PawaPay deposit of its own
+func (s *Service) InitiateTopUp(orderID uuid.UUID, amount money.Amount, phoneNumber string) (*Payment, error) {
+	return s.Initiate(ProviderPawaPay, Request{
+		OrderID:     orderID,
+		Amount:      amount,
+		PhoneNumber: phoneNumber,
+		Purpose:     PurposeAdjustment,
+	})
+}
+
+// Transition moves a payment attempt to next if the state machine allows it and publishes
+// the matching event. A change that isn't allowed, such as a late failure for a completed
+// payment, leaves the payment as it is.
+func (s *Service) Transition(provider, reference string, next PaymentStatus, reason string) (*Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	payment, err := s.getPayment(provider, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	if !payment.Status.CanTransition(next) {
+		if payment.Status != next {
+			log.Printf("Ignoring %s payment %s moving from %s to %s", provider, reference, payment.Status, next)
+		}
+		return payment, nil
+	}
+
+	// Only the caller that moves the payment off its current status publishes the event
+	now := time.Now()
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE payments SET status = $1, updated_at = $2 WHERE id = $3 AND status = $4`,
+		next, now, payment.ID.String(), payment.Status,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update payment status: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return payment, nil
+	}
+	payment.Status, payment.UpdatedAt = next, now
+
+	if eventType, ok := eventFor(next); ok {
+		s.publish(Event{
+			Type:      eventType,
+			PaymentID: payment.ID,
+			OrderID:   payment.OrderID,
+			Provider:  provider,
+			Reference: reference,
+			Purpose:   payment.Purpose,
+			Amount:    payment.Amount,
+			Status:    next,
+			Reason:    reason,
+		})
+	}
+	return payment, nil
+}
+
+// WalletPaid announces an order settled from the customer's wallet, so it gets the same
+// handling as an order paid through a gateway. transactionID is the wallet transaction
+// that finished paying it.
+func (s *Service) WalletPaid(orderID, transactionID uuid.UUID, amount money.Amount) {
+	s.publish(Event{
+		Type:      EventPaymentCompleted,
+		PaymentID: transactionID,
+		OrderID:   orderID,
+		Provider:  ProviderWallet,
+		Reference: transactionID.String(),
+		Purpose:   PurposeOrder,
+		Amount:    amount,
+		Status:    PaymentStatusCompleted,
+	})
+}
 
-	switch status {
-	case "COMPLETED":
-		paymentStatus = PaymentStatusCompleted
-		orderStatus = order.PaymentStatusPaid
-	case "FAILED":
-		paymentStatus = PaymentStatusFailed
-		orderStatus = order.PaymentStatusFailed
-	default:
-		return errors.New("invalid deposit status")
+// Verify asks the gateway for the outcome of a payment attempt and applies it
+func (s *Service) Verify(provider, reference string) (*Payment, error) {
+	gateway, ok := s.gateways[provider]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownGateway, provider)
+	}
+	status, err := gateway.Status(reference)
+	if err != nil {
+		return nil, err
 	}
+	if status == PaymentStatusPending {
+		return s.getPayment(provider, reference)
+	}
+	return s.Transition(provider, reference, status, "")
+}
 
-	// Update payment record
+// VerifyPending verifies the provider's payments that have been pending for longer than
+// olderThan, for payers who never came back from a hosted payment page. It returns how
+// many were checked and how many were settled.
+func (s *Service) VerifyPending(provider string, olderThan time.Duration) (checked, settled int, err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// 1. Get associated order ID from payments table
-	var orderIDStr string
-	queryGetOrder := `SELECT order_id FROM payments WHERE transaction_ref = $1`
-	err := s.db.QueryRowContext(ctx, queryGetOrder, depositID).Scan(&orderIDStr)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT transaction_ref
+		FROM payments
+		WHERE provider = $1 AND status = 'pending' AND transaction_ref IS NOT NULL AND created_at < $2
+		ORDER BY created_at
+		LIMIT 200
+	`, provider, time.Now().Add(-olderThan))
 	if err != nil {
-		fmt.Printf("[PaymentService] Warning: Could not find order for transaction %s: %v\n", depositID, err)
-		// Proceed to update payment table anyway, but we can't update order
+		return 0, 0, fmt.Errorf("failed to list pending payments: %w", err)
+	}
+	var refs []string
+	for rows.Next() {
+		var ref string
+		if err := rows.Scan(&ref); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("failed to scan pending payment: %w", err)
+		}
+		refs = append(refs, ref)
 	}
+	rows.Close()
 
-	// 2. Update payments table
-	query := `
-		UPDATE payments
-		SET status = $1, updated_at = $2
-		WHERE transaction_ref = $3
-	`
+	for _, ref := range refs {
+		checked++
+		payment, err := s.Verify(provider, ref)
+		if err != nil {
+			log.Printf("ERROR: Failed to verify %s payment %s: %v", provider, ref, err)
+			continue
+		}
+		if payment.Status != PaymentStatusPending {
+			settled++
+		}
+	}
+	return checked, settled, nil
+}
+
+// HandleDepositCallback applies a PawaPay deposit outcome to the order payment it belongs
+// to. It is registered as a deposit hook and reports whether the deposit was an order
+// payment.
+func (s *Service) HandleDepositCallback(callback pawapay.DepositCallback) (bool, error) {
+	var reason string
+	if callback.FailureReason != nil {
+		reason = callback.FailureReason.FailureCode + ": " + callback.FailureReason.FailureMessage
+	}
+	_, err := s.Transition(ProviderPawaPay, callback.DepositID, pawaPayStatus(callback.Status), reason)
+	if errors.Is(err, ErrPaymentNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// AmountOwed is what is left to pay on an order once its wallet spend and completed
+// payments, including any earlier checkout attempts that went through, are taken off
+func (s *Service) AmountOwed(orderID uuid.UUID) (money.Amount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	_, err = s.db.ExecContext(ctx, query, paymentStatus, time.Now(), depositID)
+	var owed money.Amount
+	err := s.db.QueryRowContext(ctx, `
+		SELECT o.grand_total - o.wallet_paid - COALESCE((
+			SELECT SUM(p.amount) FROM payments p WHERE p.order_id = o.id AND p.status = 'completed'
+		), 0)
+		FROM orders o WHERE o.id = $1
+	`, orderID.String()).Scan(&owed)
 	if err != nil {
-		return fmt.Errorf("failed to update payment status: %w", err)
+		return 0, fmt.Errorf("failed to get amount owed: %w", err)
 	}
+	return owed, nil
+}
 
-	// 3. Update orders table if order found
-	if orderIDStr != "" {
-		orderID, _ := uuid.Parse(orderIDStr)
-		fmt.Printf("[PaymentService] Syncing order %s status to %s\n", orderIDStr, orderStatus)
+// OpenAttempt returns the order's payment attempt still waiting on the payer, if any,
+// after asking its gateway for the latest outcome. An attempt on a hosted page that has
+// expired can no longer be paid, so it is marked failed and not returned. Checkout must
+// not start a second attempt while one is open, or the payer could pay twice.
+func (s *Service) OpenAttempt(orderID uuid.UUID) (*Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	p, err := s.scanPayment(s.db.QueryRowContext(ctx, `
+		SELECT id, order_id, amount, status, provider, phone_number,
+			COALESCE(transaction_ref, ''), purpose, checkout_expires_at, created_at, updated_at
+		FROM payments
+		WHERE order_id = $1 AND purpose = 'order' AND status = 'pending'
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, orderID.String()))
+	if err != nil {
+		if errors.Is(err, ErrPaymentNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
 
-		if err := s.orderService.UpdateOrderPaymentStatus(orderID, orderStatus); err != nil {
-			fmt.Printf("[PaymentService] Failed to sync order status: %v\n", err)
-			// Don't error out the callback, as payment record is updated
+	if p.CheckoutExpiresAt != nil && time.Now().After(*p.CheckoutExpiresAt) {
+		if _, err := s.Transition(p.Provider, p.TransactionRef, PaymentStatusFailed, "checkout page expired"); err != nil {
+			return nil, err
 		}
+		return nil, nil
 	}
 
-	return nil
+	// If the gateway can't be reached the attempt is treated as still open
+	verified, err := s.Verify(p.Provider, p.TransactionRef)
+	if err != nil {
+		log.Printf("Could not verify %s payment %s: %v", p.Provider, p.TransactionRef, err)
+		return p, nil
+	}
+	if verified.Status != PaymentStatusPending {
+		return nil, nil
+	}
+	return verified, nil
 }
 
 // GetPaymentByOrderID gets a payment by order ID
@@ -154,29 +350,49 @@ func (s *Service) GetPaymentByOrderID(orderID uuid.UUID) (*Payment, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	var payment Payment
 	query := `
 		SELECT id, order_id, amount, status, provider, phone_number,
-			transaction_ref, created_at, updated_at
+			COALESCE(transaction_ref, ''), purpose, checkout_expires_at, created_at, updated_at
 		FROM payments
-		WHERE order_id = $1
+		WHERE order_id = $1 AND purpose = 'order'
 		ORDER BY created_at DESC
 		LIMIT 1
 	`
+	return s.scanPayment(s.db.QueryRowContext(ctx, query, orderID.String()))
+}
+
+func (s *Service) getPayment(provider, reference string) (*Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return s.scanPayment(s.db.QueryRowContext(ctx, `
+		SELECT id, order_id, amount, status, provider, phone_number,
+			COALESCE(transaction_ref, ''), purpose, checkout_expires_at, created_at, updated_at
+		FROM payments
+		WHERE provider = $1 AND transaction_ref = $2
+	`, provider, reference))
+}
 
+func (s *Service) scanPayment(row *sql.Row) (*Payment, error) {
+	var payment Payment
 	var paymentIDStr, orderIDStr string
-	err := s.db.QueryRowContext(ctx, query, orderID.String()).Scan(
+	var expiresAt sql.NullTime
+	err := row.Scan(
 		&paymentIDStr, &orderIDStr, &payment.Amount, &payment.Status,
-		&payment.Provider, &payment.PhoneNumber, &payment.TransactionRef,
-		&payment.CreatedAt, &payment.UpdatedAt,
+		&payment.Provider, &payment.PhoneNumber, &payment.TransactionRef, &payment.Purpose,
+		&expiresAt, &payment.CreatedAt, &payment.UpdatedAt,
 	)
-
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPaymentNotFound
+		}
 		return nil, fmt.Errorf("failed to get payment: %w", err)
 	}
 
 	payment.ID, _ = uuid.Parse(paymentIDStr)
 	payment.OrderID, _ = uuid.Parse(orderIDStr)
-
+	if expiresAt.Valid {
+		payment.CheckoutExpiresAt = &expiresAt.Time
+	}
 	return &payment, nil
 }
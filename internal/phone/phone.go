@@ -0,0 +1,100 @@
+// Package phone normalises Zambian phone numbers and works out which mobile network
+// operator they belong to.
+package phone
+
+import (
+	"errors"
+	"strings"
+)
+
+type Operator string
+
+const (
+	OperatorAirtel Operator = "airtel"
+	OperatorMTN    Operator = "mtn"
+	OperatorZamtel Operator = "zamtel"
+)
+
+// CountryCode is Zambia's international dialling code
+const CountryCode = "260"
+
+var (
+	ErrInvalidNumber   = errors.New("invalid Zambian phone number")
+	ErrUnknownOperator = errors.New("could not tell the mobile network from the phone number")
+)
+
+// prefixes maps the first two digits of a national mobile number to its operator
+var prefixes = map[string]Operator{
+	"97": OperatorAirtel,
+	"77": OperatorAirtel,
+	"57": OperatorAirtel,
+	"96": OperatorMTN,
+	"76": OperatorMTN,
+	"56": OperatorMTN,
+	"95": OperatorZamtel,
+	"75": OperatorZamtel,
+}
+
+// Normalize returns a phone number in E.164 form, e.g. +260977123456. It accepts local
+// (0977 123 456), national (977123456) and international (+260 977 123 456, 00260...)
+// formats with any spacing or punctuation.
+func Normalize(raw string) (string, error) {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, raw)
+
+	switch {
+	case strings.HasPrefix(digits, "00"+CountryCode):
+		digits = digits[5:]
+	case strings.HasPrefix(digits, CountryCode) && len(digits) == 12:
+		digits = digits[3:]
+	case strings.HasPrefix(digits, "0") && len(digits) == 10:
+		digits = digits[1:]
+	}
+
+	// National numbers are nine digits and never start with 0 or 1
+	if len(digits) != 9 || digits[0] < '2' {
+		return "", ErrInvalidNumber
+	}
+	return "+" + CountryCode + digits, nil
+}
+
+// DetectOperator works out the mobile network from a number's prefix
+func DetectOperator(raw string) (Operator, error) {
+	e164, err := Normalize(raw)
+	if err != nil {
+		return "", err
+	}
+	op, ok := prefixes[e164[4:6]]
+	if !ok {
+		return "", ErrUnknownOperator
+	}
+	return op, nil
+}
+
+// ParseOperator reads an operator chosen by the customer, e.g. "MTN" or "airtel"
+func ParseOperator(s string) (Operator, error) {
+	switch op := Operator(strings.ToLower(strings.TrimSpace(s))); op {
+	case OperatorAirtel, OperatorMTN, OperatorZamtel:
+		return op, nil
+	}
+	return "", ErrUnknownOperator
+}
+
+// ResolveOperator uses the customer's choice when given, since numbers can be ported
+// between networks, and otherwise detects the operator from the prefix
+func ResolveOperator(raw, override string) (Operator, error) {
+	if override != "" {
+		return ParseOperator(override)
+	}
+	return DetectOperator(raw)
+}
+
+// IsMobile reports whether the number is a valid Zambian mobile number on a known network
+func IsMobile(raw string) bool {
+	_, err := DetectOperator(raw)
+	return err == nil
+}
@@ -0,0 +1,59 @@
+package phone
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"0977123456", "+260977123456"},
+		{"0977 123 456", "+260977123456"},
+		{"977123456", "+260977123456"},
+		{"260977123456", "+260977123456"},
+		{"+260 96-612-3456", "+260966123456"},
+		{"00260955123456", "+260955123456"},
+		{"+260211123456", "+260211123456"},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.in)
+		assert.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+
+	for _, bad := range []string{"", "12345", "09771234567", "+26097712345", "0077123456"} {
+		_, err := Normalize(bad)
+		assert.ErrorIs(t, err, ErrInvalidNumber, bad)
+	}
+}
+
+func TestDetectOperator(t *testing.T) {
+	for in, want := range map[string]Operator{
+		"0977123456":    OperatorAirtel,
+		"+260771234567": OperatorAirtel,
+		"0966123456":    OperatorMTN,
+		"0761234567":    OperatorMTN,
+		"0955123456":    OperatorZamtel,
+	} {
+		got, err := DetectOperator(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	_, err := DetectOperator("0211123456")
+	assert.ErrorIs(t, err, ErrUnknownOperator)
+}
+
+func TestResolveOperator(t *testing.T) {
+	// A number ported from Airtel to MTN keeps its prefix
+	op, err := ResolveOperator("0977123456", "MTN")
+	assert.NoError(t, err)
+	assert.Equal(t, OperatorMTN, op)
+
+	_, err = ResolveOperator("0977123456", "vodafone")
+	assert.ErrorIs(t, err, ErrUnknownOperator)
+}
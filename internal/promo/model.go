@@ -0,0 +1,224 @@
+package promo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DiscountType string
+type Target string
+
+const (
+	DiscountPercentage DiscountType = "percentage"
+	DiscountFixed      DiscountType = "fixed"
+)
+
+const (
+	// TargetProduct discounts the cylinder subtotal
+	TargetProduct Target = "product"
+	// TargetDeliveryFee discounts the delivery fee, e.g. 100% off for free delivery
+	TargetDeliveryFee Target = "delivery_fee"
+)
+
+var (
+	ErrCampaignNotFound  = errors.New("campaign not found")
+	ErrInvalidCode       = errors.New("promo code not found")
+	ErrCampaignInactive  = errors.New("promo code is not active")
+	ErrMinOrderNotMet    = errors.New("order does not meet the promo minimum")
+	ErrNotEligible       = errors.New("promo code does not apply to this order")
+	ErrFirstOrderOnly    = errors.New("promo code is only valid on a first order")
+	ErrUsageLimitReached = errors.New("promo code usage limit reached")
+	ErrDuplicateCode     = errors.New("promo code already exists")
+	ErrInvalidCampaign   = errors.New("invalid campaign")
+	ErrAlreadyRedeemed   = errors.New("order already has a promotion")
+	ErrNoDiscount        = errors.New("promo code gives no discount on this order")
+)
+
+// Campaign is a discount rule. Campaigns with a code apply only when the customer enters
+// it; campaigns without one apply automatically to every eligible order.
+type Campaign struct {
+	ID             uuid.UUID    `json:"id"`
+	Name           string       `json:"name"`
+	Description    string       `json:"description,omitempty"`
+	Code           string       `json:"code,omitempty"`
+	DiscountType   DiscountType `json:"discount_type"`
+	DiscountValue  float64      `json:"discount_value"`
+	MaxDiscount    float64      `json:"max_discount,omitempty"`
+	AppliesTo      Target       `json:"applies_to"`
+	MinOrderAmount float64      `json:"min_order_amount,omitempty"`
+	CylinderType   string       `json:"cylinder_type,omitempty"`
+	ProviderID     *uuid.UUID   `json:"provider_id,omitempty"`
+	FirstOrderOnly bool         `json:"first_order_only"`
+	MaxUsesPerUser int          `json:"max_uses_per_user,omitempty"`
+	MaxUsesTotal   int          `json:"max_uses_total,omitempty"`
+	StartsAt       *time.Time   `json:"starts_at,omitempty"`
+	EndsAt         *time.Time   `json:"ends_at,omitempty"`
+	Active         bool         `json:"active"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+// Automatic reports whether the campaign applies without a code
+func (c *Campaign) Automatic() bool {
+	return c.Code == ""
+}
+
+// CampaignRequest creates or replaces a campaign
+type CampaignRequest struct {
+	Name           string       `json:"name" binding:"required"`
+	Description    string       `json:"description"`
+	Code           string       `json:"code"`
+	DiscountType   DiscountType `json:"discount_type" binding:"required,oneof=percentage fixed"`
+	DiscountValue  float64      `json:"discount_value" binding:"required,gt=0"`
+	MaxDiscount    float64      `json:"max_discount" binding:"gte=0"`
+	AppliesTo      Target       `json:"applies_to" binding:"required,oneof=product delivery_fee"`
+	MinOrderAmount float64      `json:"min_order_amount" binding:"gte=0"`
+	CylinderType   string       `json:"cylinder_type"`
+	ProviderID     *uuid.UUID   `json:"provider_id"`
+	FirstOrderOnly bool         `json:"first_order_only"`
+	MaxUsesPerUser int          `json:"max_uses_per_user" binding:"gte=0"`
+	MaxUsesTotal   int          `json:"max_uses_total" binding:"gte=0"`
+	StartsAt       *time.Time   `json:"starts_at"`
+	EndsAt         *time.Time   `json:"ends_at"`
+	Active         *bool        `json:"active"`
+}
+
+// Campaign builds the campaign the request describes
+func (r *CampaignRequest) Campaign() (*Campaign, error) {
+	if r.DiscountType == DiscountPercentage && r.DiscountValue > 100 {
+		return nil, fmt.Errorf("%w: percentage discount cannot exceed 100", ErrInvalidCampaign)
+	}
+	if r.StartsAt != nil && r.EndsAt != nil && !r.EndsAt.After(*r.StartsAt) {
+		return nil, fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidCampaign)
+	}
+
+	c := &Campaign{
+		Name:           strings.TrimSpace(r.Name),
+		Description:    r.Description,
+		Code:           NormalizeCode(r.Code),
+		DiscountType:   r.DiscountType,
+		DiscountValue:  r.DiscountValue,
+		MaxDiscount:    r.MaxDiscount,
+		AppliesTo:      r.AppliesTo,
+		MinOrderAmount: r.MinOrderAmount,
+		CylinderType:   r.CylinderType,
+		ProviderID:     r.ProviderID,
+		FirstOrderOnly: r.FirstOrderOnly,
+		MaxUsesPerUser: r.MaxUsesPerUser,
+		MaxUsesTotal:   r.MaxUsesTotal,
+		StartsAt:       r.StartsAt,
+		EndsAt:         r.EndsAt,
+		Active:         true,
+	}
+	if r.Active != nil {
+		c.Active = *r.Active
+	}
+	return c, nil
+}
+
+// NormalizeCode makes codes case-insensitive: "first20 " and "FIRST20" are the same code
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// OrderContext is what a campaign's rules are checked against
+type OrderContext struct {
+	UserID       uuid.UUID
+	ProviderID   *uuid.UUID
+	CylinderType string
+	// Subtotal is the cylinder price times quantity, before fees
+	Subtotal    float64
+	DeliveryFee float64
+}
+
+// Usage is how often a campaign has been used, by this customer and overall, and how
+// many orders the customer has placed before. Rejected orders are not counted.
+type Usage struct {
+	UserRedemptions  int
+	TotalRedemptions int
+	PriorOrders      int
+}
+
+// Applied is a campaign's discount on a particular order
+type Applied struct {
+	CampaignID uuid.UUID `json:"campaign_id"`
+	Name       string    `json:"name"`
+	Code       string    `json:"code,omitempty"`
+	AppliesTo  Target    `json:"applies_to"`
+	Discount   float64   `json:"discount"`
+}
+
+// withinCaps reports whether the campaign can be used once more by the customer whose
+// usage is given. Zero caps mean unlimited.
+func withinCaps(c *Campaign, u Usage) bool {
+	return (c.MaxUsesPerUser <= 0 || u.UserRedemptions < c.MaxUsesPerUser) &&
+		(c.MaxUsesTotal <= 0 || u.TotalRedemptions < c.MaxUsesTotal)
+}
+
+// Evaluate checks the campaign's rules against an order and returns the discount it gives
+func Evaluate(c *Campaign, o OrderContext, u Usage, now time.Time) (float64, error) {
+	if !c.Active || (c.StartsAt != nil && now.Before(*c.StartsAt)) || (c.EndsAt != nil && !now.Before(*c.EndsAt)) {
+		return 0, ErrCampaignInactive
+	}
+	if c.CylinderType != "" && c.CylinderType != o.CylinderType {
+		return 0, ErrNotEligible
+	}
+	if c.ProviderID != nil && (o.ProviderID == nil || *c.ProviderID != *o.ProviderID) {
+		return 0, ErrNotEligible
+	}
+	if o.Subtotal < c.MinOrderAmount {
+		return 0, ErrMinOrderNotMet
+	}
+	if c.FirstOrderOnly && u.PriorOrders > 0 {
+		return 0, ErrFirstOrderOnly
+	}
+	if !withinCaps(c, u) {
+		return 0, ErrUsageLimitReached
+	}
+
+	base := o.Subtotal
+	if c.AppliesTo == TargetDeliveryFee {
+		base = o.DeliveryFee
+	}
+
+	discount := c.DiscountValue
+	if c.DiscountType == DiscountPercentage {
+		discount = base * c.DiscountValue / 100
+		if c.MaxDiscount > 0 {
+			discount = math.Min(discount, c.MaxDiscount)
+		}
+	}
+	discount = math.Round(math.Min(discount, base)*100) / 100
+	if discount <= 0 {
+		return 0, ErrNoDiscount
+	}
+	return discount, nil
+}
+
+// Redemption is one use of a campaign on an order
+type Redemption struct {
+	ID         uuid.UUID `json:"id"`
+	CampaignID uuid.UUID `json:"campaign_id"`
+	OrderID    uuid.UUID `json:"order_id"`
+	UserID     uuid.UUID `json:"user_id"`
+	Code       string    `json:"code,omitempty"`
+	Discount   float64   `json:"discount"`
+	OrderTotal float64   `json:"order_total"`
+	Status     string    `json:"order_status"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CampaignReport summarises a campaign's redemptions. Redemptions on rejected orders
+// are left out.
+type CampaignReport struct {
+	Campaign      Campaign `json:"campaign"`
+	Redemptions   int      `json:"redemptions"`
+	UniqueUsers   int      `json:"unique_users"`
+	TotalDiscount float64  `json:"total_discount"`
+	OrderRevenue  float64  `json:"order_revenue"`
+}
@@ -0,0 +1,83 @@
+package promo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluatePercentageOnProduct(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	c := &Campaign{
+		Code: "FIRST20", DiscountType: DiscountPercentage, DiscountValue: 20, MaxDiscount: 50,
+		AppliesTo: TargetProduct, FirstOrderOnly: true, Active: true,
+	}
+	o := OrderContext{CylinderType: "13KG", Subtotal: 200, DeliveryFee: 10}
+
+	discount, err := Evaluate(c, o, Usage{}, now)
+	require.NoError(t, err)
+	assert.Equal(t, 40.0, discount)
+
+	// The cap limits large orders
+	o.Subtotal = 500
+	discount, err = Evaluate(c, o, Usage{}, now)
+	require.NoError(t, err)
+	assert.Equal(t, 50.0, discount)
+
+	_, err = Evaluate(c, o, Usage{PriorOrders: 1}, now)
+	assert.ErrorIs(t, err, ErrFirstOrderOnly)
+}
+
+func TestEvaluateFreeDeliveryWindow(t *testing.T) {
+	start := time.Date(2025, 6, 7, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 2)
+	c := &Campaign{
+		DiscountType: DiscountPercentage, DiscountValue: 100, AppliesTo: TargetDeliveryFee,
+		StartsAt: &start, EndsAt: &end, Active: true,
+	}
+	o := OrderContext{CylinderType: "6KG", Subtotal: 100, DeliveryFee: 10}
+
+	discount, err := Evaluate(c, o, Usage{}, start.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, discount)
+
+	_, err = Evaluate(c, o, Usage{}, end)
+	assert.ErrorIs(t, err, ErrCampaignInactive)
+}
+
+func TestEvaluateRules(t *testing.T) {
+	now := time.Now()
+	providerID := uuid.New()
+	c := &Campaign{
+		DiscountType: DiscountFixed, DiscountValue: 25, AppliesTo: TargetProduct, MinOrderAmount: 150,
+		CylinderType: "13KG", ProviderID: &providerID, MaxUsesPerUser: 1, MaxUsesTotal: 100, Active: true,
+	}
+	o := OrderContext{ProviderID: &providerID, CylinderType: "13KG", Subtotal: 200}
+
+	discount, err := Evaluate(c, o, Usage{TotalRedemptions: 99}, now)
+	require.NoError(t, err)
+	assert.Equal(t, 25.0, discount)
+
+	_, err = Evaluate(c, o, Usage{UserRedemptions: 1}, now)
+	assert.ErrorIs(t, err, ErrUsageLimitReached)
+	_, err = Evaluate(c, o, Usage{TotalRedemptions: 100}, now)
+	assert.ErrorIs(t, err, ErrUsageLimitReached)
+
+	other := o
+	other.CylinderType = "6KG"
+	_, err = Evaluate(c, other, Usage{}, now)
+	assert.ErrorIs(t, err, ErrNotEligible)
+
+	other = o
+	other.ProviderID = nil
+	_, err = Evaluate(c, other, Usage{}, now)
+	assert.ErrorIs(t, err, ErrNotEligible)
+
+	other = o
+	other.Subtotal = 100
+	_, err = Evaluate(c, other, Usage{}, now)
+	assert.ErrorIs(t, err, ErrMinOrderNotMet)
+}
@@ -0,0 +1,374 @@
+package promo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Service struct {
+	db *sql.DB
+}
+
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
+
+const campaignColumns = `
+	id, name, COALESCE(description, ''), COALESCE(code, ''), discount_type, discount_value, max_discount,
+	applies_to, min_order_amount, COALESCE(cylinder_type, ''), provider_id, first_order_only,
+	max_uses_per_user, max_uses_total, starts_at, ends_at, active, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func scanCampaign(row rowScanner) (*Campaign, error) {
+	var c Campaign
+	var idStr string
+	var providerIDStr sql.NullString
+	var startsAt, endsAt sql.NullTime
+	err := row.Scan(&idStr, &c.Name, &c.Description, &c.Code, &c.DiscountType, &c.DiscountValue, &c.MaxDiscount,
+		&c.AppliesTo, &c.MinOrderAmount, &c.CylinderType, &providerIDStr, &c.FirstOrderOnly,
+		&c.MaxUsesPerUser, &c.MaxUsesTotal, &startsAt, &endsAt, &c.Active, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	c.ID, _ = uuid.Parse(idStr)
+	if providerIDStr.Valid {
+		parsed, _ := uuid.Parse(providerIDStr.String)
+		c.ProviderID = &parsed
+	}
+	if startsAt.Valid {
+		c.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		c.EndsAt = &endsAt.Time
+	}
+	return &c, nil
+}
+
+// campaignArgs are the campaign's settable columns in the order the queries below use
+func campaignArgs(c *Campaign) []interface{} {
+	var providerID interface{}
+	if c.ProviderID != nil {
+		providerID = c.ProviderID.String()
+	}
+	return []interface{}{
+		c.Name, c.Description, c.Code, c.DiscountType, c.DiscountValue, c.MaxDiscount, c.AppliesTo,
+		c.MinOrderAmount, c.CylinderType, providerID, c.FirstOrderOnly, c.MaxUsesPerUser, c.MaxUsesTotal,
+		c.StartsAt, c.EndsAt, c.Active,
+	}
+}
+
+// CreateCampaign adds a promo code or automatic campaign
+func (s *Service) CreateCampaign(c *Campaign) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var idStr string
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO promo_campaigns (name, description, code, discount_type, discount_value, max_discount, applies_to,
+			min_order_amount, cylinder_type, provider_id, first_order_only, max_uses_per_user, max_uses_total,
+			starts_at, ends_at, active)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11, $12, $13, $14, $15, $16)
+		RETURNING id, created_at, updated_at
+	`, campaignArgs(c)...).Scan(&idStr, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return ErrDuplicateCode
+		}
+		return fmt.Errorf("failed to create campaign: %w", err)
+	}
+	c.ID, _ = uuid.Parse(idStr)
+
+	return nil
+}
+
+// UpdateCampaign replaces a campaign's rules
+func (s *Service) UpdateCampaign(c *Campaign) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	args := append(campaignArgs(c), c.ID.String())
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE promo_campaigns SET
+			name = $1, description = NULLIF($2, ''), code = NULLIF($3, ''), discount_type = $4, discount_value = $5,
+			max_discount = $6, applies_to = $7, min_order_amount = $8, cylinder_type = NULLIF($9, ''),
+			provider_id = $10, first_order_only = $11, max_uses_per_user = $12, max_uses_total = $13,
+			starts_at = $14, ends_at = $15, active = $16
+		WHERE id = $17
+		RETURNING created_at, updated_at
+	`, args...).Scan(&c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrCampaignNotFound
+		}
+		if strings.Contains(err.Error(), "duplicate key") {
+			return ErrDuplicateCode
+		}
+		return fmt.Errorf("failed to update campaign: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) GetCampaign(id uuid.UUID) (*Campaign, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c, err := scanCampaign(s.db.QueryRowContext(ctx,
+		`SELECT `+campaignColumns+` FROM promo_campaigns WHERE id = $1`, id.String()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCampaignNotFound
+		}
+		return nil, fmt.Errorf("failed to get campaign: %w", err)
+	}
+	return c, nil
+}
+
+// ListCampaigns returns every campaign, or with liveOnly just the automatic ones running now
+func (s *Service) ListCampaigns(liveOnly bool) ([]Campaign, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + campaignColumns + ` FROM promo_campaigns`
+	if liveOnly {
+		query += ` WHERE active AND code IS NULL
+			AND (starts_at IS NULL OR starts_at <= CURRENT_TIMESTAMP)
+			AND (ends_at IS NULL OR ends_at > CURRENT_TIMESTAMP)`
+	}
+	query += ` ORDER BY created_at DESC`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list campaigns: %w", err)
+	}
+	defer rows.Close()
+
+	campaigns := []Campaign{}
+	for rows.Next() {
+		c, err := scanCampaign(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan campaign: %w", err)
+		}
+		campaigns = append(campaigns, *c)
+	}
+
+	return campaigns, rows.Err()
+}
+
+// Apply finds the discount for an order. A code the customer entered must apply or an
+// error says why; without one the best automatic campaign is used, and nil means none
+// applies.
+func (s *Service) Apply(code string, o OrderContext) (*Applied, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	if code = NormalizeCode(code); code != "" {
+		c, err := scanCampaign(s.db.QueryRowContext(ctx,
+			`SELECT `+campaignColumns+` FROM promo_campaigns WHERE code = $1`, code))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, ErrInvalidCode
+			}
+			return nil, fmt.Errorf("failed to get promo code: %w", err)
+		}
+		usage, err := usage(ctx, s.db, c.ID, o.UserID)
+		if err != nil {
+			return nil, err
+		}
+		discount, err := Evaluate(c, o, usage, now)
+		if err != nil {
+			return nil, err
+		}
+		return &Applied{CampaignID: c.ID, Name: c.Name, Code: c.Code, AppliesTo: c.AppliesTo, Discount: discount}, nil
+	}
+
+	campaigns, err := s.ListCampaigns(true)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *Applied
+	for i := range campaigns {
+		c := &campaigns[i]
+		usage, err := usage(ctx, s.db, c.ID, o.UserID)
+		if err != nil {
+			return nil, err
+		}
+		discount, err := Evaluate(c, o, usage, now)
+		if err != nil {
+			continue
+		}
+		if best == nil || discount > best.Discount {
+			best = &Applied{CampaignID: c.ID, Name: c.Name, AppliesTo: c.AppliesTo, Discount: discount}
+		}
+	}
+	return best, nil
+}
+
+func usage(ctx context.Context, db queryRower, campaignID, userID uuid.UUID) (Usage, error) {
+	var u Usage
+	err := db.QueryRowContext(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE r.user_id = $2),
+			COUNT(*),
+			(SELECT COUNT(*) FROM orders WHERE user_id = $2 AND status <> 'rejected')
+		FROM promo_redemptions r
+		JOIN orders o ON o.id = r.order_id
+		WHERE r.campaign_id = $1 AND o.status <> 'rejected'
+	`, campaignID.String(), userID.String()).Scan(&u.UserRedemptions, &u.TotalRedemptions, &u.PriorOrders)
+	if err != nil {
+		return u, fmt.Errorf("failed to get promo usage: %w", err)
+	}
+	return u, nil
+}
+
+// RedeemTx records that an order used a campaign, as part of the transaction saving the
+// order. The campaign is locked while its usage caps are checked again, so orders placed
+// at the same moment can't take it past them; an error means the order must not be saved.
+func (s *Service) RedeemTx(tx *sql.Tx, a *Applied, orderID, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var c Campaign
+	err := tx.QueryRowContext(ctx, `
+		SELECT max_uses_per_user, max_uses_total FROM promo_campaigns WHERE id = $1 FOR UPDATE
+	`, a.CampaignID.String()).Scan(&c.MaxUsesPerUser, &c.MaxUsesTotal)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrCampaignNotFound
+		}
+		return fmt.Errorf("failed to lock campaign: %w", err)
+	}
+	u, err := usage(ctx, tx, a.CampaignID, userID)
+	if err != nil {
+		return err
+	}
+	if !withinCaps(&c, u) {
+		return ErrUsageLimitReached
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO promo_redemptions (campaign_id, order_id, user_id, code, discount)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+	`, a.CampaignID.String(), orderID.String(), userID.String(), a.Code, a.Discount)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return ErrAlreadyRedeemed
+		}
+		return fmt.Errorf("failed to record redemption: %w", err)
+	}
+	return nil
+}
+
+// Report summarises redemptions per campaign
+func (s *Service) Report() ([]CampaignReport, error) {
+	campaigns, err := s.ListCampaigns(false)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT r.campaign_id, COUNT(*), COUNT(DISTINCT r.user_id), COALESCE(SUM(r.discount), 0),
+			COALESCE(SUM(o.grand_total), 0)
+		FROM promo_redemptions r
+		JOIN orders o ON o.id = r.order_id
+		WHERE o.status <> 'rejected'
+		GROUP BY r.campaign_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarise redemptions: %w", err)
+	}
+	defer rows.Close()
+
+	stats := map[uuid.UUID]CampaignReport{}
+	for rows.Next() {
+		var r CampaignReport
+		var idStr string
+		if err := rows.Scan(&idStr, &r.Redemptions, &r.UniqueUsers, &r.TotalDiscount, &r.OrderRevenue); err != nil {
+			return nil, fmt.Errorf("failed to scan redemption summary: %w", err)
+		}
+		id, _ := uuid.Parse(idStr)
+		stats[id] = r
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	reports := make([]CampaignReport, 0, len(campaigns))
+	for _, c := range campaigns {
+		r := stats[c.ID]
+		r.Campaign = c
+		reports = append(reports, r)
+	}
+	return reports, nil
+}
+
+// ListRedemptions returns a campaign's redemptions, newest first
+func (s *Service) ListRedemptions(campaignID uuid.UUID, limit, offset int) ([]Redemption, error) {
+	if _, err := s.GetCampaign(campaignID); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT r.id, r.campaign_id, r.order_id, r.user_id, COALESCE(r.code, ''), r.discount, o.grand_total, o.status,
+			r.created_at
+		FROM promo_redemptions r
+		JOIN orders o ON o.id = r.order_id
+		WHERE r.campaign_id = $1
+		ORDER BY r.created_at DESC
+		LIMIT $2 OFFSET $3
+	`, campaignID.String(), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list redemptions: %w", err)
+	}
+	defer rows.Close()
+
+	redemptions := []Redemption{}
+	for rows.Next() {
+		var r Redemption
+		var idStr, campaignIDStr, orderIDStr, userIDStr string
+		if err := rows.Scan(&idStr, &campaignIDStr, &orderIDStr, &userIDStr, &r.Code, &r.Discount, &r.OrderTotal,
+			&r.Status, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan redemption: %w", err)
+		}
+		r.ID, _ = uuid.Parse(idStr)
+		r.CampaignID, _ = uuid.Parse(campaignIDStr)
+		r.OrderID, _ = uuid.Parse(orderIDStr)
+		r.UserID, _ = uuid.Parse(userIDStr)
+		redemptions = append(redemptions, r)
+	}
+
+	return redemptions, rows.Err()
+}
+
+// IsRuleError reports whether err means the promotion does not apply, as opposed to a
+// failure looking it up
+func IsRuleError(err error) bool {
+	for _, target := range []error{ErrInvalidCode, ErrCampaignInactive, ErrMinOrderNotMet, ErrNotEligible,
+		ErrFirstOrderOnly, ErrUsageLimitReached, ErrNoDiscount} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
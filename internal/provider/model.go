@@ -4,16 +4,18 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/review"
 )
 
 type Provider struct {
-	ID           uuid.UUID `json:"id"`
-	Name         string    `json:"name"`
-	Address      string    `json:"address"`
-	Phone        string    `json:"phone"`
-	Rating       float64   `json:"rating"`
-	Reviews      []string  `json:"reviews"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	ProfileImage string    `json:"profile_image"`
+	ID           uuid.UUID             `json:"id"`
+	Name         string                `json:"name"`
+	Address      string                `json:"address"`
+	Phone        string                `json:"phone"`
+	Rating       float64               `json:"rating"`
+	RatingCount  int                   `json:"rating_count"`
+	Reviews      []review.PublicReview `json:"reviews"`
+	CreatedAt    time.Time             `json:"created_at"`
+	UpdatedAt    time.Time             `json:"updated_at"`
+	ProfileImage string                `json:"profile_image"`
 }
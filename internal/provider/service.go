@@ -42,8 +42,8 @@ func NewService(db *sql.DB) *Service {
 	}
 }
 
-func (s *Service) GetProviderOrders(providerID uuid.UUID) ([]order.Order, error) {
-	return s.orderService.GetProviderOrders(providerID)
+func (s *Service) GetProviderOrders(providerID uuid.UUID, filter order.ListFilter) (*order.OrderPage, error) {
+	return s.orderService.GetProviderOrders(providerID, filter)
 }
 
 func (s *Service) AcceptOrder(providerID uuid.UUID, orderID uuid.UUID) error {
@@ -134,7 +134,8 @@ func (s *Service) GetProviderById(providerID string) (*Provider, error) {
 	defer cancel()
 
 	query := `
-		SELECT id, name, phone_number, rating, profile_image, created_at, updated_at
+		SELECT id, name, phone_number, COALESCE(rating_average, rating), COALESCE(rating_count, 0),
+			profile_image, created_at, updated_at
 		FROM users
 		WHERE id = $1 AND user_type = 'provider'
 	`
@@ -142,9 +143,10 @@ func (s *Service) GetProviderById(providerID string) (*Provider, error) {
 	var provider Provider
 	var name, phone, profileImage string
 	var rating float64
+	var ratingCount int
 
 	err := s.db.QueryRowContext(ctx, query, providerID).Scan(
-		&provider.ID, &name, &phone, &rating, &profileImage, &provider.CreatedAt, &provider.UpdatedAt,
+		&provider.ID, &name, &phone, &rating, &ratingCount, &profileImage, &provider.CreatedAt, &provider.UpdatedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("provider not found with ID %s", providerID)
@@ -153,6 +155,7 @@ func (s *Service) GetProviderById(providerID string) (*Provider, error) {
 	provider.Name = name
 	provider.Phone = phone
 	provider.Rating = rating
+	provider.RatingCount = ratingCount
 	provider.ProfileImage = profileImage
 
 	return &provider, nil
@@ -0,0 +1,148 @@
+package reconciliation
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Kind string
+type IssueType string
+type IssueStatus string
+
+const (
+	// KindDeposit covers order payments and wallet top-ups
+	KindDeposit Kind = "deposit"
+	// KindRefund covers refunds of order payments, including those started for edited orders
+	KindRefund Kind = "refund"
+)
+
+const (
+	IssueAmountMismatch IssueType = "amount_mismatch"
+	IssueOrderCancelled IssueType = "order_cancelled"
+	IssueNotFound       IssueType = "not_found"
+)
+
+const (
+	IssueOpen     IssueStatus = "open"
+	IssueResolved IssueStatus = "resolved"
+)
+
+// Run triggers
+const (
+	TriggerSchedule = "schedule"
+	TriggerAdmin    = "admin"
+)
+
+// NotFoundGrace is how long PawaPay may take to know about a payment we started before
+// its absence is flagged
+const NotFoundGrace = 24 * time.Hour
+
+var (
+	ErrRunNotFound   = errors.New("reconciliation run not found")
+	ErrIssueNotFound = errors.New("reconciliation issue not found")
+)
+
+// Run is one pass over the payments stuck in pending
+type Run struct {
+	ID         uuid.UUID  `json:"id"`
+	Trigger    string     `json:"trigger"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Checked    int        `json:"checked"`
+	Updated    int        `json:"updated"`
+	Flagged    int        `json:"flagged"`
+	Errors     int        `json:"errors"`
+	Issues     []Issue    `json:"issues,omitempty"`
+}
+
+// Issue is a payment whose PawaPay outcome doesn't fit our records. It is left as it is
+// until an admin looks at it.
+type Issue struct {
+	ID             uuid.UUID   `json:"id"`
+	RunID          uuid.UUID   `json:"run_id"`
+	Kind           Kind        `json:"kind"`
+	Reference      string      `json:"reference"`
+	OrderID        *uuid.UUID  `json:"order_id,omitempty"`
+	Type           IssueType   `json:"type"`
+	Details        string      `json:"details"`
+	ProviderStatus string      `json:"provider_status"`
+	ExpectedAmount float64     `json:"expected_amount"`
+	ActualAmount   *float64    `json:"actual_amount,omitempty"`
+	Status         IssueStatus `json:"status"`
+	ResolutionNote string      `json:"resolution_note,omitempty"`
+	ResolvedBy     *uuid.UUID  `json:"resolved_by,omitempty"`
+	ResolvedAt     *time.Time  `json:"resolved_at,omitempty"`
+	CreatedAt      time.Time   `json:"created_at"`
+}
+
+type ResolveRequest struct {
+	Note string `json:"note" binding:"required"`
+}
+
+// candidate is a pending payment old enough to check with PawaPay
+type candidate struct {
+	Kind        Kind
+	Reference   string
+	OrderID     *uuid.UUID
+	Amount      float64
+	OrderStatus string
+	CreatedAt   time.Time
+	// CheckoutExpiresAt is set for payments started on a hosted payment page, which PawaPay
+	// only knows about once the payer confirms
+	CheckoutExpiresAt *time.Time
+}
+
+// finding is what PawaPay reported for a candidate
+type finding struct {
+	Status   string
+	Amount   string
+	Currency string
+}
+
+// inspect compares PawaPay's outcome with our record and lists what doesn't add up.
+// Payments PawaPay hasn't finished with yet raise nothing.
+func inspect(c candidate, f finding, now time.Time) []Issue {
+	var issues []Issue
+	flag := func(t IssueType, details string, actual *float64) {
+		issues = append(issues, Issue{
+			Kind:           c.Kind,
+			Reference:      c.Reference,
+			OrderID:        c.OrderID,
+			Type:           t,
+			Details:        details,
+			ProviderStatus: f.Status,
+			ExpectedAmount: c.Amount,
+			ActualAmount:   actual,
+		})
+	}
+
+	switch f.Status {
+	case "NOT_FOUND":
+		if c.CheckoutExpiresAt == nil && now.Sub(c.CreatedAt) >= NotFoundGrace {
+			flag(IssueNotFound, "PawaPay has no record of this "+string(c.Kind), nil)
+		}
+	case "COMPLETED":
+		if amount, err := strconv.ParseFloat(f.Amount, 64); err == nil && math.Abs(amount-c.Amount) >= 0.005 {
+			flag(IssueAmountMismatch, "PawaPay amount differs from the amount we requested", &amount)
+		}
+		if c.Kind == KindDeposit && c.OrderStatus == "rejected" {
+			flag(IssueOrderCancelled, "Payment completed for an order that was rejected; the customer may need a refund", nil)
+		}
+	}
+	return issues
+}
+
+// abandoned reports whether the payer left a hosted payment page without ever paying, in
+// which case the payment is failed rather than flagged
+func abandoned(c candidate, f finding, now time.Time) bool {
+	return f.Status == "NOT_FOUND" && c.CheckoutExpiresAt != nil && now.After(*c.CheckoutExpiresAt)
+}
+
+// final reports whether PawaPay has settled the payment one way or the other
+func final(status string) bool {
+	return status == "COMPLETED" || status == "FAILED"
+}
@@ -0,0 +1,50 @@
+package reconciliation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInspect(t *testing.T) {
+	now := time.Now()
+	deposit := candidate{Kind: KindDeposit, Reference: "dep-1", Amount: 250, OrderStatus: "pending", CreatedAt: now.Add(-time.Hour)}
+
+	// A clean completion raises nothing and can be applied
+	assert.Empty(t, inspect(deposit, finding{Status: "COMPLETED", Amount: "250.00"}, now))
+	assert.Empty(t, inspect(deposit, finding{Status: "FAILED"}, now))
+	assert.Empty(t, inspect(deposit, finding{Status: "ACCEPTED"}, now))
+
+	issues := inspect(deposit, finding{Status: "COMPLETED", Amount: "200.00"}, now)
+	if assert.Len(t, issues, 1) {
+		assert.Equal(t, IssueAmountMismatch, issues[0].Type)
+		assert.Equal(t, 200.0, *issues[0].ActualAmount)
+	}
+
+	rejected := deposit
+	rejected.OrderStatus = "rejected"
+	issues = inspect(rejected, finding{Status: "COMPLETED", Amount: "250"}, now)
+	if assert.Len(t, issues, 1) {
+		assert.Equal(t, IssueOrderCancelled, issues[0].Type)
+	}
+
+	// PawaPay gets a grace period before a missing payment is flagged
+	assert.Empty(t, inspect(deposit, finding{Status: "NOT_FOUND"}, now))
+	old := deposit
+	old.CreatedAt = now.Add(-2 * NotFoundGrace)
+	issues = inspect(old, finding{Status: "NOT_FOUND"}, now)
+	if assert.Len(t, issues, 1) {
+		assert.Equal(t, IssueNotFound, issues[0].Type)
+	}
+
+	// A payment page the payer never confirmed is unknown to PawaPay; it is failed once the
+	// page expires instead of being flagged
+	expires := now.Add(-time.Minute)
+	hosted := old
+	hosted.CheckoutExpiresAt = &expires
+	assert.Empty(t, inspect(hosted, finding{Status: "NOT_FOUND"}, now))
+	assert.True(t, abandoned(hosted, finding{Status: "NOT_FOUND"}, now))
+	assert.False(t, abandoned(hosted, finding{Status: "ACCEPTED"}, now))
+	assert.False(t, abandoned(old, finding{Status: "NOT_FOUND"}, now))
+}
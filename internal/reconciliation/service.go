@@ -0,0 +1,676 @@
+package reconciliation
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+	"github.com/yakumwamba/lpg-delivery-system/internal/pawapay"
+	"github.com/yakumwamba/lpg-delivery-system/internal/payment"
+)
+
+// Service finds payments left pending because a PawaPay callback never arrived, asks
+// PawaPay for their outcome and applies it through the callback handler. Payouts are not
+// recorded locally, so only deposits and refunds are checked.
+type Service struct {
+	db        *sql.DB
+	pawaPay   *pawapay.Client
+	callbacks *pawapay.CallbackHandler
+	threshold time.Duration
+}
+
+// NewService creates the reconciler. Payments are only checked once they have been
+// pending for longer than threshold.
+func NewService(db *sql.DB, pawaPay *pawapay.Client, callbacks *pawapay.CallbackHandler, threshold time.Duration) *Service {
+	return &Service{db: db, pawaPay: pawaPay, callbacks: callbacks, threshold: threshold}
+}
+
+// Run checks every stale pending payment once and records what it did
+func (s *Service) Run(trigger string) (*Run, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	run := &Run{Trigger: trigger}
+	var idStr string
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO reconciliation_runs (trigger) VALUES ($1) RETURNING id, started_at`, trigger,
+	).Scan(&idStr, &run.StartedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start reconciliation run: %w", err)
+	}
+	run.ID, _ = uuid.Parse(idStr)
+
+	candidates, err := s.stalePayments(ctx, time.Now().Add(-s.threshold))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range candidates {
+		run.Checked++
+		status, err := s.pawaPay.GetPaymentStatus(string(c.Kind)+"s", c.Reference)
+		if err != nil {
+			log.Printf("ERROR: Failed to check %s %s with PawaPay: %v", c.Kind, c.Reference, err)
+			run.Errors++
+			continue
+		}
+		f := finding{Status: status.Status, Amount: status.Amount, Currency: status.Currency}
+
+		// Inconsistent payments are flagged and left alone for an admin to sort out
+		if issues := inspect(c, f, time.Now()); len(issues) > 0 {
+			for _, issue := range issues {
+				flagged, err := s.flag(ctx, run.ID, issue)
+				if err != nil {
+					log.Printf("ERROR: Failed to flag %s %s: %v", c.Kind, c.Reference, err)
+					run.Errors++
+				} else if flagged {
+					run.Flagged++
+				}
+			}
+			continue
+		}
+		if abandoned(c, f, time.Now()) {
+			f.Status = "FAILED"
+		}
+		if !final(f.Status) {
+			continue
+		}
+
+		if err := s.apply(ctx, c, f, status.DepositID); err != nil {
+			log.Printf("ERROR: Failed to apply %s %s status %s: %v", c.Kind, c.Reference, f.Status, err)
+			run.Errors++
+			continue
+		}
+		run.Updated++
+	}
+
+	now := time.Now()
+	run.FinishedAt = &now
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE reconciliation_runs
+		SET finished_at = $1, checked = $2, updated = $3, flagged = $4, errors = $5
+		WHERE id = $6
+	`, now, run.Checked, run.Updated, run.Flagged, run.Errors, run.ID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to finish reconciliation run: %w", err)
+	}
+
+	return run, nil
+}
+
+// stalePayments lists order payments, wallet top-ups and refunds still pending from
+// before the cutoff
+func (s *Service) stalePayments(ctx context.Context, cutoff time.Time) ([]candidate, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT 'deposit', p.transaction_ref, p.order_id, p.amount, COALESCE(o.status, ''), p.created_at,
+			p.checkout_expires_at
+		FROM payments p
+		LEFT JOIN orders o ON o.id = p.order_id
+		WHERE p.status = 'pending' AND p.provider = 'pawapay' AND p.transaction_ref IS NOT NULL
+			AND p.created_at < $1
+		UNION ALL
+		SELECT 'deposit', t.deposit_id, NULL::uuid, t.amount, '', t.created_at, NULL::timestamp
+		FROM wallet_topups t
+		WHERE t.status = 'pending' AND t.created_at < $1
+		UNION ALL
+		SELECT 'refund', r.id::text, r.order_id, r.amount, COALESCE(o.status, ''),
+			COALESCE(r.submitted_at, r.created_at), NULL::timestamp
+		FROM refunds r
+		LEFT JOIN orders o ON o.id = r.order_id
+		WHERE r.status = 'processing' AND COALESCE(r.submitted_at, r.created_at) < $1
+		ORDER BY 6
+	`, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find pending payments: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []candidate
+	for rows.Next() {
+		var c candidate
+		var orderIDStr sql.NullString
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&c.Kind, &c.Reference, &orderIDStr, &c.Amount, &c.OrderStatus, &c.CreatedAt, &expiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan pending payment: %w", err)
+		}
+		if orderIDStr.Valid {
+			parsed, _ := uuid.Parse(orderIDStr.String)
+			c.OrderID = &parsed
+		}
+		if expiresAt.Valid {
+			c.CheckoutExpiresAt = &expiresAt.Time
+		}
+		candidates = append(candidates, c)
+	}
+
+	return candidates, rows.Err()
+}
+
+// apply moves a payment to PawaPay's final status the same way its callback would have
+func (s *Service) apply(ctx context.Context, c candidate, f finding, depositID string) error {
+	switch c.Kind {
+	case KindDeposit:
+		return s.callbacks.ProcessDeposit(pawapay.DepositCallback{
+			DepositID: c.Reference,
+			Status:    f.Status,
+			Amount:    f.Amount,
+			Currency:  f.Currency,
+		})
+	case KindRefund:
+		return s.callbacks.ProcessRefund(pawapay.RefundCallback{
+			RefundID:  c.Reference,
+			DepositID: depositID,
+			Status:    f.Status,
+			Amount:    f.Amount,
+			Currency:  f.Currency,
+		})
+	}
+	return nil
+}
+
+// flag records an issue unless the same one is already open. It reports whether a new
+// issue was raised.
+func (s *Service) flag(ctx context.Context, runID uuid.UUID, issue Issue) (bool, error) {
+	var orderIDArg interface{}
+	if issue.OrderID != nil {
+		orderIDArg = issue.OrderID.String()
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO reconciliation_issues (
+			run_id, kind, reference, order_id, type, details, provider_status, expected_amount, actual_amount
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, runID.String(), issue.Kind, issue.Reference, orderIDArg, issue.Type, issue.Details,
+		issue.ProviderStatus, issue.ExpectedAmount, issue.ActualAmount)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to record reconciliation issue: %w", err)
+	}
+	return true, nil
+}
+
+// ListRuns returns reconciliation run history, newest first
+func (s *Service) ListRuns(limit, offset int) ([]Run, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, trigger, started_at, finished_at, checked, updated, flagged, errors
+		FROM reconciliation_runs
+		ORDER BY started_at DESC
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reconciliation runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []Run{}
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+
+	return runs, rows.Err()
+}
+
+// GetRun returns a run with the issues it raised
+func (s *Service) GetRun(id uuid.UUID) (*Run, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	run, err := scanRun(s.db.QueryRowContext(ctx, `
+		SELECT id, trigger, started_at, finished_at, checked, updated, flagged, errors
+		FROM reconciliation_runs
+		WHERE id = $1
+	`, id.String()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrRunNotFound
+		}
+		return nil, err
+	}
+
+	run.Issues, err = s.listIssues(ctx, "run_id = $1", []interface{}{id.String()}, 500, 0)
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// ListIssues returns flagged payments, optionally only those with the given status
+func (s *Service) ListIssues(status IssueStatus, limit, offset int) ([]Issue, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return s.listIssues(ctx, "($1 = '' OR status = $1)", []interface{}{string(status)}, limit, offset)
+}
+
+// ResolveIssue closes an issue once an admin has dealt with it
+func (s *Service) ResolveIssue(id, adminID uuid.UUID, note string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE reconciliation_issues
+		SET status = 'resolved', resolution_note = $1, resolved_by = $2, resolved_at = $3
+		WHERE id = $4 AND status = 'open'
+	`, note, adminID.String(), time.Now(), id.String())
+	if err != nil {
+		return fmt.Errorf("failed to resolve reconciliation issue: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrIssueNotFound
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRun(row rowScanner) (*Run, error) {
+	var run Run
+	var idStr string
+	var finishedAt sql.NullTime
+	if err := row.Scan(&idStr, &run.Trigger, &run.StartedAt, &finishedAt,
+		&run.Checked, &run.Updated, &run.Flagged, &run.Errors); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan reconciliation run: %w", err)
+	}
+	run.ID, _ = uuid.Parse(idStr)
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+	return &run, nil
+}
+
+func (s *Service) listIssues(ctx context.Context, where string, args []interface{}, limit, offset int) ([]Issue, error) {
+	n := len(args)
+	args = append(args, limit, offset)
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT id, run_id, kind, reference, order_id, type, details, provider_status, expected_amount,
+			actual_amount, status, COALESCE(resolution_note, ''), resolved_by, resolved_at, created_at
+		FROM reconciliation_issues
+		WHERE %s
+		ORDER BY created_at DESC
+		LIMIT $%d OFFSET $%d
+	`, where, n+1, n+2), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reconciliation issues: %w", err)
+	}
+	defer rows.Close()
+
+	issues := []Issue{}
+	for rows.Next() {
+		var i Issue
+		var idStr, runIDStr string
+		var orderIDStr, resolvedByStr sql.NullString
+		var actual sql.NullFloat64
+		var resolvedAt sql.NullTime
+		if err := rows.Scan(&idStr, &runIDStr, &i.Kind, &i.Reference, &orderIDStr, &i.Type, &i.Details,
+			&i.ProviderStatus, &i.ExpectedAmount, &actual, &i.Status, &i.ResolutionNote, &resolvedByStr,
+			&resolvedAt, &i.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan reconciliation issue: %w", err)
+		}
+		i.ID, _ = uuid.Parse(idStr)
+		i.RunID, _ = uuid.Parse(runIDStr)
+		if orderIDStr.Valid {
+			parsed, _ := uuid.Parse(orderIDStr.String)
+			i.OrderID = &parsed
+		}
+		if actual.Valid {
+			i.ActualAmount = &actual.Float64
+		}
+		if resolvedByStr.Valid {
+			parsed, _ := uuid.Parse(resolvedByStr.String)
+			i.ResolvedBy = &parsed
+		}
+		if resolvedAt.Valid {
+			i.ResolvedAt = &resolvedAt.Time
+		}
+		issues = append(issues, i)
+	}
+
+	return issues, rows.Err()
+}
+
+// ImportStatement matches a PawaPay or DPO settlement statement against our payments and
+// stores the result. The period the statement covers is taken from its lines unless
+// from or to are given; our completed payments in that period that it leaves out are
+// listed too.
+func (s *Service) ImportStatement(provider, filename string, file io.Reader, from, to *time.Time, adminID uuid.UUID) (*Statement, error) {
+	if provider != payment.ProviderPawaPay && provider != payment.ProviderDPO {
+		return nil, ErrUnsupportedProvider
+	}
+	lines, err := ParseStatement(file)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	st := &Statement{Provider: provider, Filename: filename, ImportedBy: &adminID}
+	st.PeriodStart, st.PeriodEnd = statementPeriod(lines)
+	if from != nil {
+		st.PeriodStart = from
+	}
+	if to != nil {
+		st.PeriodEnd = to
+	}
+
+	records, err := s.statementRecords(ctx, provider, lines)
+	if err != nil {
+		return nil, err
+	}
+	var expected []record
+	if st.PeriodStart != nil && st.PeriodEnd != nil {
+		if expected, err = s.completedRecords(ctx, provider, *st.PeriodStart, *st.PeriodEnd); err != nil {
+			return nil, err
+		}
+	}
+
+	entries := matchStatement(lines, records, expected)
+	summarize(st, entries)
+	if err := s.saveStatement(ctx, st, entries); err != nil {
+		return nil, err
+	}
+	st.Entries = entries
+	return st, nil
+}
+
+// statementRecords finds the payments, wallet top-ups and refunds the statement's lines
+// refer to, keyed by recordKey. DPO payments are also keyed by their order ID, which DPO
+// reports as the company reference.
+func (s *Service) statementRecords(ctx context.Context, provider string, lines []StatementLine) (map[string]record, error) {
+	refs := make([]string, 0, len(lines))
+	companyRefs := []string{}
+	for _, l := range lines {
+		refs = append(refs, l.Reference)
+		if l.CompanyRef != "" {
+			companyRefs = append(companyRefs, l.CompanyRef)
+		}
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT 'deposit', COALESCE(p.transaction_ref, ''), p.order_id, p.amount, p.status,
+			CASE WHEN p.order_id::text = ANY($3) THEN p.order_id::text ELSE '' END
+		FROM payments p
+		WHERE p.provider = $1 AND (p.transaction_ref = ANY($2) OR p.order_id::text = ANY($3))
+		UNION ALL
+		SELECT 'deposit', t.deposit_id, NULL::uuid, t.amount, t.status, ''
+		FROM wallet_topups t
+		WHERE $1 = 'pawapay' AND t.deposit_id = ANY($2)
+		UNION ALL
+		SELECT 'refund', r.id::text, r.order_id, r.amount, r.status, ''
+		FROM refunds r
+		WHERE $1 = 'pawapay' AND r.id::text = ANY($2)
+	`, provider, refs, companyRefs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up statement references: %w", err)
+	}
+	defer rows.Close()
+
+	records := map[string]record{}
+	for rows.Next() {
+		var r record
+		var orderIDStr sql.NullString
+		var companyRef string
+		if err := rows.Scan(&r.Kind, &r.Reference, &orderIDStr, &r.Amount, &r.Status, &companyRef); err != nil {
+			return nil, fmt.Errorf("failed to scan statement record: %w", err)
+		}
+		r.Status = recordStatus(r.Status)
+		if orderIDStr.Valid {
+			parsed, _ := uuid.Parse(orderIDStr.String)
+			r.OrderID = &parsed
+		}
+		if r.Reference != "" {
+			records[recordKey(r.Kind, r.Reference)] = r
+		}
+		// An order may have several DPO attempts; the completed one is the one DPO settled
+		if companyRef != "" {
+			key := recordKey(r.Kind, companyRef)
+			if existing, ok := records[key]; !ok || existing.Status != "COMPLETED" {
+				records[key] = r
+			}
+		}
+	}
+	return records, rows.Err()
+}
+
+// completedRecords lists the payments and refunds we completed through the gateway
+// during a statement's period, its last day included
+func (s *Service) completedRecords(ctx context.Context, provider string, start, end time.Time) ([]record, error) {
+	from, until := periodBounds(start, end)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT 'deposit', p.transaction_ref, p.order_id, p.amount, p.created_at
+		FROM payments p
+		WHERE p.provider = $1 AND p.status = 'completed' AND p.transaction_ref IS NOT NULL
+			AND p.created_at >= $2 AND p.created_at < $3
+		UNION ALL
+		SELECT 'deposit', t.deposit_id, NULL::uuid, t.amount, t.created_at
+		FROM wallet_topups t
+		WHERE $1 = 'pawapay' AND t.status = 'completed' AND t.created_at >= $2 AND t.created_at < $3
+		UNION ALL
+		SELECT 'refund', r.id::text, r.order_id, r.amount, COALESCE(r.submitted_at, r.created_at)
+		FROM refunds r
+		WHERE $1 = 'pawapay' AND r.status = 'completed'
+			AND COALESCE(r.submitted_at, r.created_at) >= $2 AND COALESCE(r.submitted_at, r.created_at) < $3
+		ORDER BY 5
+	`, provider, from, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list completed payments: %w", err)
+	}
+	defer rows.Close()
+
+	var records []record
+	for rows.Next() {
+		r := record{Status: "COMPLETED"}
+		var orderIDStr sql.NullString
+		var createdAt time.Time
+		if err := rows.Scan(&r.Kind, &r.Reference, &orderIDStr, &r.Amount, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan completed payment: %w", err)
+		}
+		if orderIDStr.Valid {
+			parsed, _ := uuid.Parse(orderIDStr.String)
+			r.OrderID = &parsed
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+func (s *Service) saveStatement(ctx context.Context, st *Statement, entries []StatementEntry) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to save statement: %w", err)
+	}
+	defer tx.Rollback()
+
+	var idStr string
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO statement_imports (
+			provider, filename, period_start, period_end, lines, matched, flagged,
+			statement_total, recorded_total, fee_total, imported_by
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at
+	`, st.Provider, st.Filename, st.PeriodStart, st.PeriodEnd, st.Lines, st.Matched, st.Flagged,
+		st.StatementTotal, st.RecordedTotal, st.FeeTotal, st.ImportedBy.String(),
+	).Scan(&idStr, &st.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save statement: %w", err)
+	}
+	st.ID, _ = uuid.Parse(idStr)
+
+	for i := range entries {
+		e := &entries[i]
+		var orderIDArg interface{}
+		if e.OrderID != nil {
+			orderIDArg = e.OrderID.String()
+		}
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO statement_entries (
+				import_id, line_number, kind, reference, result, details, statement_status,
+				statement_amount, fee, occurred_at, record_status, record_amount, order_id
+			) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $10, NULLIF($11, ''), $12, $13)
+			RETURNING id
+		`, st.ID.String(), e.LineNumber, e.Kind, e.Reference, e.Result, e.Details, e.StatementStatus,
+			e.StatementAmount, e.Fee, e.OccurredAt, e.RecordStatus, e.RecordAmount, orderIDArg,
+		).Scan(&idStr)
+		if err != nil {
+			return fmt.Errorf("failed to save statement entry: %w", err)
+		}
+		e.ID, _ = uuid.Parse(idStr)
+	}
+
+	return tx.Commit()
+}
+
+// ListStatements returns imported statements, newest first, without their entries
+func (s *Service) ListStatements(limit, offset int) ([]Statement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, provider, filename, period_start, period_end, lines, matched, flagged,
+			statement_total, recorded_total, fee_total, imported_by, created_at
+		FROM statement_imports
+		ORDER BY created_at DESC
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list statements: %w", err)
+	}
+	defer rows.Close()
+
+	statements := []Statement{}
+	for rows.Next() {
+		st, err := scanStatement(rows)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, *st)
+	}
+	return statements, rows.Err()
+}
+
+// GetStatement returns a statement with every entry, flagged ones first
+func (s *Service) GetStatement(id uuid.UUID) (*Statement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	st, err := scanStatement(s.db.QueryRowContext(ctx, `
+		SELECT id, provider, filename, period_start, period_end, lines, matched, flagged,
+			statement_total, recorded_total, fee_total, imported_by, created_at
+		FROM statement_imports
+		WHERE id = $1
+	`, id.String()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrStatementNotFound
+		}
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, line_number, kind, reference, result, details, COALESCE(statement_status, ''),
+			statement_amount, fee, occurred_at, COALESCE(record_status, ''), record_amount, order_id
+		FROM statement_entries
+		WHERE import_id = $1
+		ORDER BY result = 'matched', line_number NULLS LAST, reference
+	`, id.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list statement entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []StatementEntry{}
+	for rows.Next() {
+		var e StatementEntry
+		var idStr string
+		var lineNumber sql.NullInt64
+		var statementAmount, fee, recordAmount sql.NullString
+		var occurredAt sql.NullTime
+		var orderIDStr sql.NullString
+		if err := rows.Scan(&idStr, &lineNumber, &e.Kind, &e.Reference, &e.Result, &e.Details,
+			&e.StatementStatus, &statementAmount, &fee, &occurredAt, &e.RecordStatus, &recordAmount,
+			&orderIDStr); err != nil {
+			return nil, fmt.Errorf("failed to scan statement entry: %w", err)
+		}
+		e.ID, _ = uuid.Parse(idStr)
+		if lineNumber.Valid {
+			n := int(lineNumber.Int64)
+			e.LineNumber = &n
+		}
+		e.StatementAmount = nullAmount(statementAmount)
+		e.Fee = nullAmount(fee)
+		e.RecordAmount = nullAmount(recordAmount)
+		if occurredAt.Valid {
+			e.OccurredAt = &occurredAt.Time
+		}
+		if orderIDStr.Valid {
+			parsed, _ := uuid.Parse(orderIDStr.String)
+			e.OrderID = &parsed
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	summarize(st, entries)
+	st.Entries = entries
+	return st, nil
+}
+
+func scanStatement(row rowScanner) (*Statement, error) {
+	var st Statement
+	var idStr string
+	var periodStart, periodEnd sql.NullTime
+	var importedByStr sql.NullString
+	if err := row.Scan(&idStr, &st.Provider, &st.Filename, &periodStart, &periodEnd, &st.Lines,
+		&st.Matched, &st.Flagged, &st.StatementTotal, &st.RecordedTotal, &st.FeeTotal,
+		&importedByStr, &st.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan statement: %w", err)
+	}
+	st.ID, _ = uuid.Parse(idStr)
+	if periodStart.Valid {
+		st.PeriodStart = &periodStart.Time
+	}
+	if periodEnd.Valid {
+		st.PeriodEnd = &periodEnd.Time
+	}
+	if importedByStr.Valid {
+		parsed, _ := uuid.Parse(importedByStr.String)
+		st.ImportedBy = &parsed
+	}
+	st.Difference = st.StatementTotal - st.RecordedTotal
+	return &st, nil
+}
+
+func nullAmount(v sql.NullString) *money.Amount {
+	if !v.Valid {
+		return nil
+	}
+	a, err := money.Parse(v.String)
+	if err != nil {
+		return nil
+	}
+	return &a
+}
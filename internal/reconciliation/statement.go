@@ -0,0 +1,467 @@
+package reconciliation
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+)
+
+// KindPayout is a statement line for money we sent out. Payouts are not recorded
+// locally, so those lines are listed but not matched.
+const KindPayout Kind = "payout"
+
+// Result is how a statement line, or one of our payments, compared
+type Result string
+
+const (
+	ResultMatched        Result = "matched"
+	ResultAmountMismatch Result = "amount_mismatch"
+	// ResultStatusMismatch is a line the gateway settled one way and we recorded another
+	ResultStatusMismatch Result = "status_mismatch"
+	// ResultDuplicate is a reference the statement lists more than once
+	ResultDuplicate Result = "duplicate"
+	// ResultMissingInRecords is a line with no payment of ours behind it
+	ResultMissingInRecords Result = "missing_in_records"
+	// ResultMissingFromStatement is a payment we completed in the statement's period that
+	// the gateway did not settle
+	ResultMissingFromStatement Result = "missing_from_statement"
+	// ResultCurrencyMismatch is a line settled in a currency other than kwacha. Its amount
+	// can't be compared with ours and is left out of the totals.
+	ResultCurrencyMismatch Result = "currency_mismatch"
+	ResultIgnored          Result = "ignored"
+)
+
+var (
+	ErrStatementNotFound   = errors.New("statement not found")
+	ErrInvalidStatement    = errors.New("invalid statement file")
+	ErrUnsupportedProvider = errors.New("statements can only be imported for pawapay or dpo")
+)
+
+// Statement is an uploaded settlement statement and how it reconciled with our records
+type Statement struct {
+	ID             uuid.UUID        `json:"id"`
+	Provider       string           `json:"provider"`
+	Filename       string           `json:"filename"`
+	PeriodStart    *time.Time       `json:"period_start,omitempty"`
+	PeriodEnd      *time.Time       `json:"period_end,omitempty"`
+	Lines          int              `json:"lines"`
+	Matched        int              `json:"matched"`
+	Flagged        int              `json:"flagged"`
+	StatementTotal money.Amount     `json:"statement_total"` // settled deposits less refunds, before fees
+	RecordedTotal  money.Amount     `json:"recorded_total"`  // the same from our records
+	FeeTotal       money.Amount     `json:"fee_total"`
+	Difference     money.Amount     `json:"difference"`
+	ImportedBy     *uuid.UUID       `json:"imported_by,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+	Counts         map[Result]int   `json:"counts,omitempty"`
+	Entries        []StatementEntry `json:"entries,omitempty"`
+}
+
+// StatementEntry is one line of a statement, or one of our payments missing from it
+type StatementEntry struct {
+	ID              uuid.UUID     `json:"id"`
+	LineNumber      *int          `json:"line_number,omitempty"`
+	Kind            Kind          `json:"kind"`
+	Reference       string        `json:"reference"`
+	Result          Result        `json:"result"`
+	Details         string        `json:"details,omitempty"`
+	StatementStatus string        `json:"statement_status,omitempty"`
+	StatementAmount *money.Amount `json:"statement_amount,omitempty"`
+	Fee             *money.Amount `json:"fee,omitempty"`
+	OccurredAt      *time.Time    `json:"occurred_at,omitempty"`
+	RecordStatus    string        `json:"record_status,omitempty"`
+	RecordAmount    *money.Amount `json:"record_amount,omitempty"`
+	OrderID         *uuid.UUID    `json:"order_id,omitempty"`
+}
+
+// StatementLine is a transaction read from a statement file
+type StatementLine struct {
+	LineNumber int
+	Kind       Kind
+	Reference  string
+	CompanyRef string // DPO's company reference, which is our order ID
+	Status     string // COMPLETED, FAILED or the gateway's own status
+	Amount     money.Amount
+	Currency   string
+	Fee        money.Amount
+	OccurredAt *time.Time
+}
+
+// record is one of our payments a statement line can match. Status is COMPLETED, FAILED
+// or PENDING, in the statement's terms.
+type record struct {
+	Kind      Kind
+	Reference string
+	OrderID   *uuid.UUID
+	Amount    money.Amount
+	Status    string
+}
+
+// Header names the gateways use for each column, after lowercasing and dropping
+// everything but letters and digits. The first one present wins.
+var statementColumns = map[string][]string{
+	"reference":  {"depositid", "refundid", "payoutid", "transactionid", "transactiontoken", "transtoken", "token", "reference", "id"},
+	"companyref": {"companyref", "companyreference", "merchantreference"},
+	"kind":       {"type", "transactiontype", "operationtype"},
+	"status":     {"status", "transactionstatus", "result"},
+	"amount":     {"amount", "depositedamount", "transactionamount", "requestedamount"},
+	"currency":   {"currency"},
+	"fee":        {"fee", "fees", "commission", "charges"},
+	"date":       {"created", "date", "transactiondate", "createdat", "timestamp", "settlementdate"},
+}
+
+var statementDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"02/01/2006 15:04:05",
+	"02/01/2006 15:04",
+	"02/01/2006",
+}
+
+// ParseStatement reads a settlement statement CSV exported from PawaPay or DPO. Only the
+// reference and amount columns are required; lines without a type are taken as deposits
+// and lines without a status as completed.
+func ParseStatement(r io.Reader) ([]StatementLine, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidStatement, err)
+	}
+	columns := map[string]int{}
+	for field, names := range statementColumns {
+		for _, name := range names {
+			if i := indexOfHeader(header, name); i >= 0 {
+				columns[field] = i
+				break
+			}
+		}
+	}
+	for _, required := range []string{"reference", "amount"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: no %s column", ErrInvalidStatement, required)
+		}
+	}
+
+	var lines []StatementLine
+	for lineNumber := 2; ; lineNumber++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidStatement, err)
+		}
+		cell := func(field string) string {
+			i, ok := columns[field]
+			if !ok || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+		if cell("reference") == "" && cell("amount") == "" {
+			continue
+		}
+
+		line := StatementLine{
+			LineNumber: lineNumber,
+			Kind:       statementKind(cell("kind")),
+			Reference:  cell("reference"),
+			CompanyRef: cell("companyref"),
+			Status:     statementStatus(cell("status")),
+			Currency:   strings.ToUpper(cell("currency")),
+		}
+		if line.Reference == "" {
+			return nil, fmt.Errorf("%w: line %d has no reference", ErrInvalidStatement, lineNumber)
+		}
+		if line.Amount, err = statementAmount(cell("amount")); err != nil {
+			return nil, fmt.Errorf("%w: line %d amount %q", ErrInvalidStatement, lineNumber, cell("amount"))
+		}
+		if raw := cell("fee"); raw != "" {
+			if line.Fee, err = statementAmount(raw); err != nil {
+				return nil, fmt.Errorf("%w: line %d fee %q", ErrInvalidStatement, lineNumber, raw)
+			}
+		}
+		if raw := cell("date"); raw != "" {
+			t, err := statementDate(raw)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d date %q", ErrInvalidStatement, lineNumber, raw)
+			}
+			line.OccurredAt = &t
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("%w: no transactions", ErrInvalidStatement)
+	}
+	return lines, nil
+}
+
+func indexOfHeader(header []string, name string) int {
+	for i, h := range header {
+		key := strings.Map(func(r rune) rune {
+			if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+				return r
+			}
+			return -1
+		}, strings.ToLower(h))
+		if key == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func statementKind(raw string) Kind {
+	switch strings.ToUpper(raw) {
+	case "REFUND", "REFUNDS":
+		return KindRefund
+	case "PAYOUT", "PAYOUTS", "DISBURSEMENT":
+		return KindPayout
+	}
+	return KindDeposit
+}
+
+// statementStatus maps the gateways' wording onto PawaPay's final statuses
+func statementStatus(raw string) string {
+	switch s := strings.ToUpper(raw); s {
+	case "", "COMPLETED", "COMPLETE", "SUCCESS", "SUCCESSFUL", "PAID", "SETTLED", "APPROVED":
+		return "COMPLETED"
+	case "FAILED", "FAILURE", "DECLINED", "CANCELLED", "REJECTED":
+		return "FAILED"
+	default:
+		return s
+	}
+}
+
+// statementAmount reads amounts such as "1,250.00" or "ZMW 250.50"
+func statementAmount(raw string) (money.Amount, error) {
+	cleaned := strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || r == '.' || r == '-' {
+			return r
+		}
+		return -1
+	}, raw)
+	return money.Parse(cleaned)
+}
+
+func statementDate(raw string) (time.Time, error) {
+	for _, layout := range statementDateLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date %q", raw)
+}
+
+// statementPeriod is the span of the lines' dates, or nils if none are dated
+func statementPeriod(lines []StatementLine) (start, end *time.Time) {
+	for _, l := range lines {
+		if l.OccurredAt == nil {
+			continue
+		}
+		if start == nil || l.OccurredAt.Before(*start) {
+			start = l.OccurredAt
+		}
+		if end == nil || l.OccurredAt.After(*end) {
+			end = l.OccurredAt
+		}
+	}
+	return start, end
+}
+
+// periodBounds turns a statement period into the half-open range [from, until) of whole
+// days it covers, so payments made any time on its last day are included
+func periodBounds(start, end time.Time) (from, until time.Time) {
+	from = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	until = time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, end.Location()).AddDate(0, 0, 1)
+	return from, until
+}
+
+func recordKey(kind Kind, reference string) string {
+	return string(kind) + "|" + reference
+}
+
+// matchStatement compares each line with the record of ours it refers to, then lists the
+// expected records, our completed payments in the statement's period, it never mentioned.
+// Records are keyed by recordKey; DPO lines fall back to their company reference.
+func matchStatement(lines []StatementLine, records map[string]record, expected []record) []StatementEntry {
+	var entries []StatementEntry
+	seenLine := map[string]int{}
+	settled := map[string]bool{}
+
+	for _, l := range lines {
+		amount, fee := l.Amount, l.Fee
+		lineNumber := l.LineNumber
+		e := StatementEntry{
+			LineNumber:      &lineNumber,
+			Kind:            l.Kind,
+			Reference:       l.Reference,
+			StatementStatus: l.Status,
+			StatementAmount: &amount,
+			OccurredAt:      l.OccurredAt,
+		}
+		if fee != 0 {
+			e.Fee = &fee
+		}
+
+		key := recordKey(l.Kind, l.Reference)
+		r, found := records[key]
+		if !found && l.CompanyRef != "" {
+			r, found = records[recordKey(l.Kind, l.CompanyRef)]
+		}
+		if found {
+			recordAmount := r.Amount
+			e.RecordStatus, e.RecordAmount, e.OrderID = r.Status, &recordAmount, r.OrderID
+			key = recordKey(r.Kind, r.Reference)
+		}
+
+		switch {
+		case l.Kind == KindPayout:
+			e.Result, e.Details = ResultIgnored, "Payouts are not recorded locally"
+		case l.Currency != "" && l.Currency != string(money.ZMW):
+			e.Result = ResultCurrencyMismatch
+			e.Details = fmt.Sprintf("Statement line is in %s, not %s", l.Currency, money.ZMW)
+		case seenLine[key] > 0:
+			e.Result = ResultDuplicate
+			e.Details = fmt.Sprintf("Also listed on line %d", seenLine[key])
+		case !found:
+			e.Result, e.Details = ResultMissingInRecords, "No "+string(l.Kind)+" of ours has this reference"
+		case l.Status != r.Status && (l.Status == "COMPLETED" || r.Status == "COMPLETED"):
+			e.Result = ResultStatusMismatch
+			e.Details = fmt.Sprintf("Statement shows %s but we recorded %s", l.Status, r.Status)
+		case l.Status == "COMPLETED" && l.Amount != r.Amount:
+			e.Result = ResultAmountMismatch
+			e.Details = fmt.Sprintf("Statement amount %s differs from our %s by %s", l.Amount, r.Amount, l.Amount-r.Amount)
+		default:
+			e.Result = ResultMatched
+		}
+
+		if seenLine[key] == 0 {
+			seenLine[key] = l.LineNumber
+		}
+		if found {
+			settled[key] = true
+		}
+		entries = append(entries, e)
+	}
+
+	for _, r := range expected {
+		if settled[recordKey(r.Kind, r.Reference)] {
+			continue
+		}
+		recordAmount := r.Amount
+		entries = append(entries, StatementEntry{
+			Kind:         r.Kind,
+			Reference:    r.Reference,
+			Result:       ResultMissingFromStatement,
+			Details:      "We recorded this " + string(r.Kind) + " as completed but the statement does not list it",
+			RecordStatus: r.Status,
+			RecordAmount: &recordAmount,
+			OrderID:      r.OrderID,
+		})
+	}
+	return entries
+}
+
+// summarize fills in a statement's counts and totals from its entries. Totals are
+// deposits less refunds, counting only what each side completed; duplicate lines count
+// on the statement side, since the money moved twice.
+func summarize(st *Statement, entries []StatementEntry) {
+	st.Lines, st.Matched, st.Flagged = 0, 0, 0
+	st.StatementTotal, st.RecordedTotal, st.FeeTotal = 0, 0, 0
+	st.Counts = map[Result]int{}
+	recorded := map[string]bool{}
+
+	for _, e := range entries {
+		st.Counts[e.Result]++
+		if e.LineNumber != nil {
+			st.Lines++
+		}
+		switch e.Result {
+		case ResultMatched:
+			st.Matched++
+		case ResultIgnored:
+		default:
+			st.Flagged++
+		}
+
+		sign := money.Amount(1)
+		switch e.Kind {
+		case KindRefund:
+			sign = -1
+		case KindPayout:
+			continue
+		}
+		if e.Result != ResultCurrencyMismatch {
+			if e.StatementAmount != nil && e.StatementStatus == "COMPLETED" {
+				st.StatementTotal += sign * *e.StatementAmount
+			}
+			if e.Fee != nil {
+				st.FeeTotal += *e.Fee
+			}
+		}
+		key := recordKey(e.Kind, e.Reference)
+		if e.RecordAmount != nil && e.RecordStatus == "COMPLETED" && !recorded[key] {
+			recorded[key] = true
+			st.RecordedTotal += sign * *e.RecordAmount
+		}
+	}
+	st.Difference = st.StatementTotal - st.RecordedTotal
+}
+
+// recordStatus maps our payment and refund statuses onto the statement's terms
+func recordStatus(status string) string {
+	switch status {
+	case "completed":
+		return "COMPLETED"
+	case "failed", "rejected":
+		return "FAILED"
+	}
+	return "PENDING"
+}
+
+// WriteStatementCSV exports a statement's entries for finance's spreadsheets
+func WriteStatementCSV(w io.Writer, st *Statement) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{
+		"line", "kind", "reference", "result", "details", "statement_status", "statement_amount",
+		"fee", "occurred_at", "record_status", "record_amount", "order_id",
+	})
+	for _, e := range st.Entries {
+		row := []string{"", string(e.Kind), e.Reference, string(e.Result), e.Details, e.StatementStatus,
+			optionalAmount(e.StatementAmount), optionalAmount(e.Fee), "", e.RecordStatus,
+			optionalAmount(e.RecordAmount), ""}
+		if e.LineNumber != nil {
+			row[0] = fmt.Sprint(*e.LineNumber)
+		}
+		if e.OccurredAt != nil {
+			row[8] = e.OccurredAt.Format(time.RFC3339)
+		}
+		if e.OrderID != nil {
+			row[11] = e.OrderID.String()
+		}
+		out.Write(row)
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func optionalAmount(a *money.Amount) string {
+	if a == nil {
+		return ""
+	}
+	return a.String()
+}
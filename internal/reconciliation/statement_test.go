@@ -0,0 +1,128 @@
+package reconciliation
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+)
+
+func TestParseStatement(t *testing.T) {
+	csv := "Deposit ID,Type,Status,Amount,Currency,Fee,Created\n" +
+		"dep-1,DEPOSIT,COMPLETED,\"1,250.00\",ZMW,12.50,2026-10-01T08:30:00Z\n" +
+		"ref-1,REFUND,COMPLETED,100,ZMW,,2026-10-02 09:00:00\n" +
+		",,,,,,\n" +
+		"dep-2,DEPOSIT,FAILED,ZMW 80.5,ZMW,0,2026-10-03\n"
+	lines, err := ParseStatement(strings.NewReader(csv))
+	require.NoError(t, err)
+	require.Len(t, lines, 3)
+
+	assert.Equal(t, StatementLine{
+		LineNumber: 2, Kind: KindDeposit, Reference: "dep-1", Status: "COMPLETED",
+		Amount: 125000, Currency: "ZMW", Fee: 1250, OccurredAt: lines[0].OccurredAt,
+	}, lines[0])
+	assert.Equal(t, time.Date(2026, 10, 1, 8, 30, 0, 0, time.UTC), *lines[0].OccurredAt)
+	assert.Equal(t, KindRefund, lines[1].Kind)
+	assert.Equal(t, 5, lines[2].LineNumber)
+	assert.Equal(t, "FAILED", lines[2].Status)
+	assert.Equal(t, money.Amount(8050), lines[2].Amount)
+
+	// DPO exports name their columns differently and have no type
+	lines, err = ParseStatement(strings.NewReader("Transaction Token,Company Ref,Result,Transaction Amount\ntok-1,order-1,Approved,99.99\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "tok-1", lines[0].Reference)
+	assert.Equal(t, "order-1", lines[0].CompanyRef)
+	assert.Equal(t, "COMPLETED", lines[0].Status)
+	assert.Equal(t, KindDeposit, lines[0].Kind)
+
+	_, err = ParseStatement(strings.NewReader("Deposit ID,Status\ndep-1,COMPLETED\n"))
+	assert.ErrorIs(t, err, ErrInvalidStatement)
+	_, err = ParseStatement(strings.NewReader("Deposit ID,Amount\ndep-1,lots\n"))
+	assert.ErrorIs(t, err, ErrInvalidStatement)
+	_, err = ParseStatement(strings.NewReader("Deposit ID,Amount\n"))
+	assert.ErrorIs(t, err, ErrInvalidStatement)
+}
+
+func TestMatchStatement(t *testing.T) {
+	orderID := uuid.New()
+	records := map[string]record{
+		recordKey(KindDeposit, "dep-ok"):         {Kind: KindDeposit, Reference: "dep-ok", Amount: 25000, Status: "COMPLETED", OrderID: &orderID},
+		recordKey(KindDeposit, "dep-short"):      {Kind: KindDeposit, Reference: "dep-short", Amount: 25000, Status: "COMPLETED"},
+		recordKey(KindDeposit, "dep-pending"):    {Kind: KindDeposit, Reference: "dep-pending", Amount: 5000, Status: "PENDING"},
+		recordKey(KindDeposit, "dep-failed"):     {Kind: KindDeposit, Reference: "dep-failed", Amount: 5000, Status: "FAILED"},
+		recordKey(KindRefund, "ref-1"):           {Kind: KindRefund, Reference: "ref-1", Amount: 5000, Status: "COMPLETED", OrderID: &orderID},
+		recordKey(KindDeposit, orderID.String()): {Kind: KindDeposit, Reference: "tok-9", Amount: 7000, Status: "COMPLETED"},
+	}
+	lines := []StatementLine{
+		{LineNumber: 2, Kind: KindDeposit, Reference: "dep-ok", Status: "COMPLETED", Amount: 25000, Fee: 250},
+		{LineNumber: 3, Kind: KindDeposit, Reference: "dep-short", Status: "COMPLETED", Amount: 20000},
+		{LineNumber: 4, Kind: KindDeposit, Reference: "dep-pending", Status: "COMPLETED", Amount: 5000},
+		{LineNumber: 5, Kind: KindDeposit, Reference: "dep-failed", Status: "FAILED", Amount: 5000},
+		{LineNumber: 6, Kind: KindDeposit, Reference: "dep-unknown", Status: "COMPLETED", Amount: 1000},
+		{LineNumber: 7, Kind: KindDeposit, Reference: "dep-ok", Status: "COMPLETED", Amount: 25000},
+		{LineNumber: 8, Kind: KindRefund, Reference: "ref-1", Status: "COMPLETED", Amount: 5000},
+		{LineNumber: 9, Kind: KindPayout, Reference: "pay-1", Status: "COMPLETED", Amount: 3000},
+		{LineNumber: 10, Kind: KindDeposit, Reference: "other-token", CompanyRef: orderID.String(), Status: "COMPLETED", Amount: 7000},
+		{LineNumber: 11, Kind: KindDeposit, Reference: "dep-usd", Status: "COMPLETED", Amount: 1500, Currency: "USD", Fee: 15},
+	}
+	expected := []record{
+		records[recordKey(KindDeposit, "dep-ok")],
+		records[recordKey(KindRefund, "ref-1")],
+		{Kind: KindDeposit, Reference: "dep-lost", Amount: 9000, Status: "COMPLETED"},
+	}
+
+	entries := matchStatement(lines, records, expected)
+	results := make([]Result, len(entries))
+	for i, e := range entries {
+		results[i] = e.Result
+	}
+	assert.Equal(t, []Result{
+		ResultMatched, ResultAmountMismatch, ResultStatusMismatch, ResultMatched, ResultMissingInRecords,
+		ResultDuplicate, ResultMatched, ResultIgnored, ResultMatched, ResultCurrencyMismatch, ResultMissingFromStatement,
+	}, results)
+	assert.Equal(t, "Statement amount 200.00 differs from our 250.00 by -50.00", entries[1].Details)
+	assert.Equal(t, "Also listed on line 2", entries[5].Details)
+	assert.Equal(t, &orderID, entries[0].OrderID)
+	assert.Equal(t, "Statement line is in USD, not ZMW", entries[9].Details)
+	assert.Equal(t, "dep-lost", entries[10].Reference)
+	assert.Nil(t, entries[10].LineNumber)
+
+	var st Statement
+	summarize(&st, entries)
+	assert.Equal(t, 10, st.Lines)
+	assert.Equal(t, 4, st.Matched)
+	assert.Equal(t, 6, st.Flagged)
+	assert.Equal(t, 1, st.Counts[ResultDuplicate])
+	// Completed deposits 250 + 200 + 50 + 10 + 250 + 70 less the K50 refund; the USD line is left out
+	assert.Equal(t, money.Amount(78000), st.StatementTotal)
+	// Our completed deposits 250 + 250 + 70 + 90 less the refund, each counted once
+	assert.Equal(t, money.Amount(61000), st.RecordedTotal)
+	assert.Equal(t, money.Amount(17000), st.Difference)
+	assert.Equal(t, money.Amount(250), st.FeeTotal)
+}
+
+func TestPeriodBounds(t *testing.T) {
+	from, until := periodBounds(
+		time.Date(2026, 10, 1, 8, 30, 0, 0, time.UTC),
+		time.Date(2026, 10, 31, 0, 0, 0, 0, time.UTC),
+	)
+	assert.Equal(t, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), from)
+	// A payment late on the last day is still inside the period
+	assert.Equal(t, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), until)
+}
+
+func TestWriteStatementCSV(t *testing.T) {
+	line, amount := 2, money.Amount(25000)
+	var out strings.Builder
+	require.NoError(t, WriteStatementCSV(&out, &Statement{Entries: []StatementEntry{
+		{LineNumber: &line, Kind: KindDeposit, Reference: "dep-1", Result: ResultMissingInRecords, Details: "No deposit, anywhere", StatementStatus: "COMPLETED", StatementAmount: &amount},
+	}}))
+	assert.Equal(t,
+		"line,kind,reference,result,details,statement_status,statement_amount,fee,occurred_at,record_status,record_amount,order_id\n"+
+			"2,deposit,dep-1,missing_in_records,\"No deposit, anywhere\",COMPLETED,250.00,,,,,\n",
+		out.String())
+}
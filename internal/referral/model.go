@@ -0,0 +1,155 @@
+package referral
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/address"
+	"github.com/yakumwamba/lpg-delivery-system/internal/phone"
+)
+
+type Status string
+
+const (
+	StatusPending  Status = "pending"
+	StatusRewarded Status = "rewarded"
+	StatusRejected Status = "rejected"
+)
+
+// Rewards paid into each party's wallet once the referee's first order is delivered and paid
+const (
+	ReferrerReward = 20.0
+	RefereeReward  = 20.0
+)
+
+// SameAddressKm is how close two delivery pins must be to count as the same household
+const SameAddressKm = 0.05
+
+// codeAlphabet leaves out characters that are easy to misread when a code is shared aloud
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+var (
+	ErrInvalidCode     = errors.New("referral code not found")
+	ErrSelfReferral    = errors.New("you cannot use your own referral code")
+	ErrAlreadyReferred = errors.New("account was already referred")
+)
+
+// Referral links a new customer to the customer whose code they signed up with
+type Referral struct {
+	ID              uuid.UUID  `json:"id"`
+	ReferrerID      uuid.UUID  `json:"referrer_id"`
+	RefereeID       uuid.UUID  `json:"referee_id"`
+	RefereeName     string     `json:"referee_name,omitempty"`
+	Code            string     `json:"code"`
+	Status          Status     `json:"status"`
+	RejectionReason string     `json:"rejection_reason,omitempty"`
+	OrderID         *uuid.UUID `json:"order_id,omitempty"`
+	ReferrerReward  float64    `json:"referrer_reward"`
+	RefereeReward   float64    `json:"referee_reward"`
+	RewardedAt      *time.Time `json:"rewarded_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// Dashboard is a customer's referral code and how their referrals are doing
+type Dashboard struct {
+	Code              string     `json:"code"`
+	ShareLink         string     `json:"share_link"`
+	RewardPerReferral float64    `json:"reward_per_referral"`
+	Referred          int        `json:"referred"`
+	Pending           int        `json:"pending"`
+	Rewarded          int        `json:"rewarded"`
+	Rejected          int        `json:"rejected"`
+	TotalEarned       float64    `json:"total_earned"`
+	ReferredBy        *uuid.UUID `json:"referred_by,omitempty"`
+	Referrals         []Referral `json:"referrals"`
+}
+
+// RunResult is what one pass over the pending referrals did
+type RunResult struct {
+	Checked  int `json:"checked"`
+	Rewarded int `json:"rewarded"`
+	Rejected int `json:"rejected"`
+	Failed   int `json:"failed"`
+}
+
+// Party is what the fraud checks know about one side of a referral
+type Party struct {
+	Phone     string
+	DeviceIDs []string
+	Addresses []string
+	Pins      [][2]float64
+}
+
+// FraudReason explains why a referral looks like one person referring themselves, or
+// returns "" when the two parties look independent
+func FraudReason(referrer, referee Party) string {
+	if samePhone(referrer.Phone, referee.Phone) {
+		return "same phone number as referrer"
+	}
+
+	devices := map[string]bool{}
+	for _, d := range referrer.DeviceIDs {
+		devices[d] = true
+	}
+	for _, d := range referee.DeviceIDs {
+		if devices[d] {
+			return "same device as referrer"
+		}
+	}
+
+	addresses := map[string]bool{}
+	for _, a := range referrer.Addresses {
+		if n := normalizeAddress(a); n != "" {
+			addresses[n] = true
+		}
+	}
+	for _, a := range referee.Addresses {
+		if addresses[normalizeAddress(a)] {
+			return "same delivery address as referrer"
+		}
+	}
+	for _, p := range referee.Pins {
+		for _, q := range referrer.Pins {
+			if address.DistanceKm(p[0], p[1], q[0], q[1]) <= SameAddressKm {
+				return "same delivery location as referrer"
+			}
+		}
+	}
+
+	return ""
+}
+
+// samePhone compares numbers in E.164 form, so 0977123456 and +260977123456 match.
+// Numbers that are not valid Zambian numbers never match.
+func samePhone(a, b string) bool {
+	na, err := phone.Normalize(a)
+	if err != nil {
+		return false
+	}
+	nb, err := phone.Normalize(b)
+	return err == nil && na == nb
+}
+
+func normalizeAddress(a string) string {
+	return strings.Join(strings.Fields(strings.ToLower(strings.NewReplacer(",", " ", ".", " ").Replace(a))), " ")
+}
+
+// newCode builds a referral code from the start of the customer's name and random
+// characters, e.g. "MARY7KQ2"
+func newCode(name string, random []byte) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(name) {
+		if b.Len() == 4 {
+			break
+		}
+		if r >= 'A' && r <= 'Z' {
+			b.WriteRune(r)
+		}
+	}
+	for _, c := range random {
+		b.WriteByte(codeAlphabet[int(c)%len(codeAlphabet)])
+	}
+	return b.String()
+}
@@ -0,0 +1,41 @@
+package referral
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFraudReason(t *testing.T) {
+	referrer := Party{
+		Phone:     "+260977123456",
+		DeviceIDs: []string{"device-a"},
+		Addresses: []string{"Plot 12, Kabulonga Road"},
+		Pins:      [][2]float64{{-15.4167, 28.3333}},
+	}
+
+	tests := []struct {
+		name    string
+		referee Party
+		flagged bool
+	}{
+		{"independent", Party{Phone: "0966000111", DeviceIDs: []string{"device-b"}, Addresses: []string{"House 4, Chelston"}, Pins: [][2]float64{{-15.37, 28.39}}}, false},
+		{"same phone in local format", Party{Phone: "0977 123 456"}, true},
+		{"same last nine digits in another country", Party{Phone: "+44 977 123 456"}, false},
+		{"shared device", Party{Phone: "0966000111", DeviceIDs: []string{"device-b", "device-a"}}, true},
+		{"same address written differently", Party{Phone: "0966000111", Addresses: []string{"plot 12  kabulonga road."}}, true},
+		{"pin next door", Party{Phone: "0966000111", Pins: [][2]float64{{-15.4169, 28.3334}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.flagged, FraudReason(referrer, tt.referee) != "")
+		})
+	}
+}
+
+func TestNewCode(t *testing.T) {
+	assert.Equal(t, "MARYABCD", newCode("Mary Banda", []byte{0, 1, 2, 3}))
+	assert.Equal(t, "JOABCD", newCode("Jo", []byte{0, 1, 2, 3}))
+	assert.Equal(t, "ABCD", newCode("", []byte{32, 33, 34, 35}))
+}
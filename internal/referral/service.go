@@ -0,0 +1,430 @@
+package referral
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/wallet"
+)
+
+type Service struct {
+	db            *sql.DB
+	walletService *wallet.Service
+	deepLinkBase  string
+}
+
+// NewService creates the referral service. Rewards are paid into customers' wallets;
+// deepLinkBase is the app's URL scheme used to build share links.
+func NewService(db *sql.DB, walletService *wallet.Service, deepLinkBase string) *Service {
+	return &Service{db: db, walletService: walletService, deepLinkBase: deepLinkBase}
+}
+
+// ShareLink opens the app's sign-up screen with the code filled in
+func (s *Service) ShareLink(code string) string {
+	return s.deepLinkBase + "signup?ref=" + code
+}
+
+// GetCode returns the customer's referral code, creating it the first time
+func (s *Service) GetCode(userID uuid.UUID) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var code, name string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COALESCE(c.code, ''), u.name
+		FROM users u
+		LEFT JOIN referral_codes c ON c.user_id = u.id
+		WHERE u.id = $1
+	`, userID.String()).Scan(&code, &name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get referral code: %w", err)
+	}
+	if code != "" {
+		return code, nil
+	}
+
+	// Retry on the rare clash with another customer's code
+	for attempt := 0; attempt < 5; attempt++ {
+		random := make([]byte, 4)
+		if _, err := rand.Read(random); err != nil {
+			return "", fmt.Errorf("failed to generate referral code: %w", err)
+		}
+		code = newCode(name, random)
+
+		err = s.db.QueryRowContext(ctx, `
+			INSERT INTO referral_codes (user_id, code) VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE SET code = referral_codes.code
+			RETURNING code
+		`, userID.String(), code).Scan(&code)
+		if err == nil {
+			return code, nil
+		}
+		if !strings.Contains(err.Error(), "duplicate key") {
+			return "", fmt.Errorf("failed to save referral code: %w", err)
+		}
+	}
+	return "", errors.New("failed to generate a unique referral code")
+}
+
+// Lookup returns the customer a referral code belongs to
+func (s *Service) Lookup(code string) (uuid.UUID, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var userIDStr string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT user_id FROM referral_codes WHERE code = $1`, strings.ToUpper(strings.TrimSpace(code)),
+	).Scan(&userIDStr)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return uuid.Nil, ErrInvalidCode
+		}
+		return uuid.Nil, fmt.Errorf("failed to look up referral code: %w", err)
+	}
+	userID, _ := uuid.Parse(userIDStr)
+	return userID, nil
+}
+
+// RecordDevice remembers a device the customer signed up or logged in from, for the
+// referral fraud checks
+func (s *Service) RecordDevice(userID uuid.UUID, deviceID string) error {
+	if deviceID = strings.TrimSpace(deviceID); deviceID == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO user_devices (user_id, device_id) VALUES ($1, $2)
+		ON CONFLICT (user_id, device_id) DO NOTHING
+	`, userID.String(), deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to record device: %w", err)
+	}
+	return nil
+}
+
+// Attribute records that a new customer signed up with a referral code
+func (s *Service) Attribute(refereeID uuid.UUID, code string) (*Referral, error) {
+	referrerID, err := s.Lookup(code)
+	if err != nil {
+		return nil, err
+	}
+	if referrerID == refereeID {
+		return nil, ErrSelfReferral
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	r := &Referral{
+		ReferrerID: referrerID,
+		RefereeID:  refereeID,
+		Code:       strings.ToUpper(strings.TrimSpace(code)),
+		Status:     StatusPending,
+	}
+	var idStr string
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO referrals (referrer_id, referee_id, code, status)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, referrerID.String(), refereeID.String(), r.Code, r.Status).Scan(&idStr, &r.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, ErrAlreadyReferred
+		}
+		return nil, fmt.Errorf("failed to record referral: %w", err)
+	}
+	r.ID, _ = uuid.Parse(idStr)
+
+	return r, nil
+}
+
+// RewardQualified rewards every pending referral whose referee now has a delivered and
+// paid order
+func (s *Service) RewardQualified() (*RunResult, error) {
+	return s.processPending(nil)
+}
+
+// RewardFor checks one customer's pending referral, e.g. right after their order is delivered
+func (s *Service) RewardFor(refereeID uuid.UUID) (*RunResult, error) {
+	return s.processPending(&refereeID)
+}
+
+type qualified struct {
+	id, referrerID, refereeID, orderID uuid.UUID
+}
+
+func (s *Service) processPending(refereeID *uuid.UUID) (*RunResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var refereeArg interface{}
+	if refereeID != nil {
+		refereeArg = refereeID.String()
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT r.id, r.referrer_id, r.referee_id, q.id
+		FROM referrals r
+		JOIN LATERAL (
+			SELECT o.id FROM orders o
+			WHERE o.user_id = r.referee_id AND o.status = 'delivered' AND o.payment_status = 'paid'
+			ORDER BY o.created_at
+			LIMIT 1
+		) q ON TRUE
+		WHERE r.status = 'pending' AND ($1::uuid IS NULL OR r.referee_id = $1::uuid)
+	`, refereeArg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find qualifying referrals: %w", err)
+	}
+
+	var pending []qualified
+	for rows.Next() {
+		var idStr, referrerStr, refereeStr, orderStr string
+		if err := rows.Scan(&idStr, &referrerStr, &refereeStr, &orderStr); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan referral: %w", err)
+		}
+		var q qualified
+		q.id, _ = uuid.Parse(idStr)
+		q.referrerID, _ = uuid.Parse(referrerStr)
+		q.refereeID, _ = uuid.Parse(refereeStr)
+		q.orderID, _ = uuid.Parse(orderStr)
+		pending = append(pending, q)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := &RunResult{Checked: len(pending)}
+	for _, q := range pending {
+		rewarded, err := s.settle(ctx, q)
+		switch {
+		case err != nil:
+			log.Printf("ERROR: Failed to settle referral %s: %v", q.id, err)
+			result.Failed++
+		case rewarded:
+			result.Rewarded++
+		default:
+			result.Rejected++
+		}
+	}
+	return result, nil
+}
+
+// settle runs the fraud checks on a qualifying referral and then either pays both
+// rewards or rejects it
+func (s *Service) settle(ctx context.Context, q qualified) (bool, error) {
+	referrer, err := s.party(ctx, q.referrerID)
+	if err != nil {
+		return false, err
+	}
+	referee, err := s.party(ctx, q.refereeID)
+	if err != nil {
+		return false, err
+	}
+
+	if reason := FraudReason(referrer, referee); reason != "" {
+		_, err := s.db.ExecContext(ctx, `
+			UPDATE referrals SET status = 'rejected', rejection_reason = $1, order_id = $2
+			WHERE id = $3 AND status = 'pending'
+		`, reason, q.orderID.String(), q.id.String())
+		if err != nil {
+			return false, fmt.Errorf("failed to reject referral: %w", err)
+		}
+		return false, nil
+	}
+
+	// The wallet references make each reward idempotent if a run is retried
+	ref := "referral:" + q.id.String()
+	if _, err := s.walletService.CreditReward(q.referrerID, ReferrerReward, ref+":referrer",
+		"Referral reward"); err != nil && !errors.Is(err, wallet.ErrDuplicateReference) {
+		return false, err
+	}
+	if _, err := s.walletService.CreditReward(q.refereeID, RefereeReward, ref+":referee",
+		"Welcome reward for joining by referral"); err != nil && !errors.Is(err, wallet.ErrDuplicateReference) {
+		return false, err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE referrals
+		SET status = 'rewarded', order_id = $1, referrer_reward = $2, referee_reward = $3, rewarded_at = $4
+		WHERE id = $5 AND status = 'pending'
+	`, q.orderID.String(), ReferrerReward, RefereeReward, time.Now(), q.id.String())
+	if err != nil {
+		return false, fmt.Errorf("failed to mark referral rewarded: %w", err)
+	}
+	return true, nil
+}
+
+// party gathers a customer's phone, devices and delivery locations
+func (s *Service) party(ctx context.Context, userID uuid.UUID) (Party, error) {
+	var p Party
+	err := s.db.QueryRowContext(ctx,
+		`SELECT COALESCE(phone_number, '') FROM users WHERE id = $1`, userID.String(),
+	).Scan(&p.Phone)
+	if err != nil {
+		return p, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT device_id FROM user_devices WHERE user_id = $1`, userID.String())
+	if err != nil {
+		return p, fmt.Errorf("failed to get devices: %w", err)
+	}
+	for rows.Next() {
+		var d string
+		if err := rows.Scan(&d); err != nil {
+			rows.Close()
+			return p, fmt.Errorf("failed to scan device: %w", err)
+		}
+		p.DeviceIDs = append(p.DeviceIDs, d)
+	}
+	rows.Close()
+
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT DISTINCT delivery_address FROM orders
+		WHERE user_id = $1 AND status <> 'rejected' AND delivery_address <> ''
+	`, userID.String())
+	if err != nil {
+		return p, fmt.Errorf("failed to get delivery addresses: %w", err)
+	}
+	for rows.Next() {
+		var a string
+		if err := rows.Scan(&a); err != nil {
+			rows.Close()
+			return p, fmt.Errorf("failed to scan delivery address: %w", err)
+		}
+		p.Addresses = append(p.Addresses, a)
+	}
+	rows.Close()
+
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT (address->>'latitude')::float8, (address->>'longitude')::float8
+		FROM customer_addresses WHERE user_id = $1 AND address->>'latitude' IS NOT NULL
+		UNION
+		SELECT (structured_address->>'latitude')::float8, (structured_address->>'longitude')::float8
+		FROM orders
+		WHERE user_id = $1 AND structured_address->>'latitude' IS NOT NULL AND status <> 'rejected'
+	`, userID.String())
+	if err != nil {
+		return p, fmt.Errorf("failed to get delivery pins: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pin [2]float64
+		if err := rows.Scan(&pin[0], &pin[1]); err != nil {
+			return p, fmt.Errorf("failed to scan delivery pin: %w", err)
+		}
+		p.Pins = append(p.Pins, pin)
+	}
+
+	return p, rows.Err()
+}
+
+// GetDashboard returns the customer's code, share link and referral results
+func (s *Service) GetDashboard(userID uuid.UUID) (*Dashboard, error) {
+	code, err := s.GetCode(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	d := &Dashboard{Code: code, ShareLink: s.ShareLink(code), RewardPerReferral: ReferrerReward}
+
+	var referredBy sql.NullString
+	err = s.db.QueryRowContext(ctx,
+		`SELECT referrer_id FROM referrals WHERE referee_id = $1`, userID.String(),
+	).Scan(&referredBy)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get referrer: %w", err)
+	}
+	if referredBy.Valid {
+		parsed, _ := uuid.Parse(referredBy.String)
+		d.ReferredBy = &parsed
+	}
+
+	d.Referrals, err = s.listReferrals(ctx, "r.referrer_id = $1", []interface{}{userID.String()}, 100, 0)
+	if err != nil {
+		return nil, err
+	}
+	d.Referred = len(d.Referrals)
+	for i := range d.Referrals {
+		r := &d.Referrals[i]
+		// Only show referrers the first name of the people they invited
+		r.RefereeName = strings.SplitN(strings.TrimSpace(r.RefereeName), " ", 2)[0]
+		switch r.Status {
+		case StatusPending:
+			d.Pending++
+		case StatusRewarded:
+			d.Rewarded++
+			d.TotalEarned += r.ReferrerReward
+		case StatusRejected:
+			d.Rejected++
+		}
+	}
+
+	return d, nil
+}
+
+// ListReferrals returns referrals for review, optionally filtered by status
+func (s *Service) ListReferrals(status Status, limit, offset int) ([]Referral, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return s.listReferrals(ctx, "($1 = '' OR r.status = $1)", []interface{}{string(status)}, limit, offset)
+}
+
+func (s *Service) listReferrals(ctx context.Context, where string, args []interface{}, limit, offset int) ([]Referral, error) {
+	n := len(args)
+	args = append(args, limit, offset)
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT r.id, r.referrer_id, r.referee_id, u.name, r.code, r.status, COALESCE(r.rejection_reason, ''),
+			r.order_id, r.referrer_reward, r.referee_reward, r.rewarded_at, r.created_at
+		FROM referrals r
+		JOIN users u ON u.id = r.referee_id
+		WHERE %s
+		ORDER BY r.created_at DESC
+		LIMIT $%d OFFSET $%d
+	`, where, n+1, n+2), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list referrals: %w", err)
+	}
+	defer rows.Close()
+
+	referrals := []Referral{}
+	for rows.Next() {
+		var r Referral
+		var idStr, referrerStr, refereeStr string
+		var orderIDStr sql.NullString
+		var rewardedAt sql.NullTime
+		if err := rows.Scan(&idStr, &referrerStr, &refereeStr, &r.RefereeName, &r.Code, &r.Status,
+			&r.RejectionReason, &orderIDStr, &r.ReferrerReward, &r.RefereeReward, &rewardedAt, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan referral: %w", err)
+		}
+		r.ID, _ = uuid.Parse(idStr)
+		r.ReferrerID, _ = uuid.Parse(referrerStr)
+		r.RefereeID, _ = uuid.Parse(refereeStr)
+		if orderIDStr.Valid {
+			parsed, _ := uuid.Parse(orderIDStr.String)
+			r.OrderID = &parsed
+		}
+		if rewardedAt.Valid {
+			r.RewardedAt = &rewardedAt.Time
+		}
+		referrals = append(referrals, r)
+	}
+
+	return referrals, rows.Err()
+}
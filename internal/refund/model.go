@@ -0,0 +1,134 @@
+package refund
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+)
+
+type Status string
+
+const (
+	// StatusRequested is waiting for an admin to approve or reject it
+	StatusRequested Status = "requested"
+	StatusRejected  Status = "rejected"
+	// StatusProcessing has been sent to PawaPay, which reports the outcome by callback
+	StatusProcessing Status = "processing"
+	StatusCompleted  Status = "completed"
+	StatusFailed     Status = "failed"
+)
+
+// Who asked for a refund
+const (
+	RequesterCustomer = "customer"
+	RequesterAdmin    = "admin"
+)
+
+var (
+	ErrRefundNotFound      = errors.New("refund not found")
+	ErrInvalidAmount       = errors.New("refund amount must be greater than zero")
+	ErrNothingToRefund     = errors.New("order has no completed mobile money payment to refund")
+	ErrExceedsRefundable   = errors.New("refund exceeds what is left of the payment")
+	ErrAlreadyRefunded     = errors.New("order has already been refunded")
+	ErrNotAwaitingApproval = errors.New("refund is not awaiting approval")
+	ErrSelfApproval        = errors.New("a refund cannot be approved by the admin who requested it")
+	ErrNotProcessing       = errors.New("refund is not waiting on PawaPay")
+)
+
+// Refund returns all or part of one PawaPay deposit to the payer
+type Refund struct {
+	ID            uuid.UUID    `json:"id"`
+	PaymentID     uuid.UUID    `json:"payment_id"`
+	OrderID       uuid.UUID    `json:"order_id"`
+	DepositID     string       `json:"deposit_id"`
+	Amount        money.Amount `json:"amount"`
+	Reason        string       `json:"reason"`
+	Status        Status       `json:"status"`
+	RequestedBy   uuid.UUID    `json:"requested_by"`
+	RequesterRole string       `json:"requester_role"`
+	ReviewedBy    *uuid.UUID   `json:"reviewed_by,omitempty"`
+	ReviewNote    string       `json:"review_note,omitempty"`
+	ReviewedAt    *time.Time   `json:"reviewed_at,omitempty"`
+	FailureReason string       `json:"failure_reason,omitempty"`
+	SubmittedAt   *time.Time   `json:"submitted_at,omitempty"`
+	CompletedAt   *time.Time   `json:"completed_at,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+// CreateRefundRequest asks for a refund of an order's payment. Amount may be left out to
+// refund everything still refundable; PaymentID picks one of several payments made for
+// the order, such as a top-up after an edit, and defaults to the latest.
+type CreateRefundRequest struct {
+	Amount    money.Amount `json:"amount"`
+	Reason    string       `json:"reason" binding:"required"`
+	PaymentID *uuid.UUID   `json:"payment_id"`
+}
+
+type ReviewRequest struct {
+	Note string `json:"note"`
+}
+
+// Summary is what each gateway collected and refunded over a period, for settling with
+// the providers
+type Summary struct {
+	Provider       string       `json:"provider"`
+	Payments       int          `json:"payments"`
+	Collected      money.Amount `json:"collected"`
+	Refunds        int          `json:"refunds"`
+	Refunded       money.Amount `json:"refunded"`
+	PendingRefunds money.Amount `json:"pending_refunds"`
+	Net            money.Amount `json:"net"`
+}
+
+// Report is the refunds report for a period
+type Report struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Summary []Summary `json:"summary"`
+	Refunds []Refund  `json:"refunds"`
+}
+
+// resolveAmount checks a requested amount against what is left of the payment once
+// earlier refunds that are pending or done, and money already returned to the customer's
+// wallet for the order, are taken off. Zero means everything left.
+func resolveAmount(requested, paid, committed, walletRefunded money.Amount) (money.Amount, error) {
+	left := paid - committed - walletRefunded
+	if left <= 0 {
+		return 0, ErrExceedsRefundable
+	}
+	if requested == 0 {
+		return left, nil
+	}
+	if requested < 0 {
+		return 0, ErrInvalidAmount
+	}
+	if requested > left {
+		return 0, ErrExceedsRefundable
+	}
+	return requested, nil
+}
+
+// needsApproval reports whether a refund must wait for an admin. A zero threshold sends
+// every refund for approval. Customers only get their money back straight away for
+// orders that were cancelled or rejected, and so never delivered.
+func needsApproval(requesterRole, orderStatus string, amount, threshold money.Amount) bool {
+	if requesterRole == RequesterCustomer && orderStatus != "rejected" {
+		return true
+	}
+	return threshold <= 0 || amount > threshold
+}
+
+// statusFromPawaPay maps a PawaPay refund status onto ours. Statuses that aren't final
+// leave the refund processing.
+func statusFromPawaPay(status string) Status {
+	switch status {
+	case "COMPLETED":
+		return StatusCompleted
+	case "FAILED", "REJECTED":
+		return StatusFailed
+	}
+	return StatusProcessing
+}
@@ -0,0 +1,60 @@
+package refund
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+)
+
+func TestResolveAmount(t *testing.T) {
+	// Leaving the amount out refunds whatever is left
+	amount, err := resolveAmount(0, 25000, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, money.Amount(25000), amount)
+
+	amount, err = resolveAmount(0, 25000, 10050, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, money.Amount(14950), amount)
+
+	amount, err = resolveAmount(8000, 25000, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, money.Amount(8000), amount)
+
+	_, err = resolveAmount(-1000, 25000, 0, 0)
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+
+	// Pending and completed refunds count against the payment
+	_, err = resolveAmount(20000, 25000, 10000, 0)
+	assert.ErrorIs(t, err, ErrExceedsRefundable)
+	_, err = resolveAmount(0, 25000, 25000, 0)
+	assert.ErrorIs(t, err, ErrExceedsRefundable)
+
+	// So does money already returned to the customer's wallet for the order
+	amount, err = resolveAmount(0, 25000, 0, 5000)
+	assert.NoError(t, err)
+	assert.Equal(t, money.Amount(20000), amount)
+	_, err = resolveAmount(0, 25000, 0, 25000)
+	assert.ErrorIs(t, err, ErrExceedsRefundable)
+}
+
+func TestNeedsApproval(t *testing.T) {
+	assert.False(t, needsApproval(RequesterAdmin, "delivered", 10000, 50000))
+	assert.False(t, needsApproval(RequesterAdmin, "delivered", 50000, 50000))
+	assert.True(t, needsApproval(RequesterAdmin, "delivered", 50001, 50000))
+	assert.True(t, needsApproval(RequesterAdmin, "rejected", 1000, 0))
+
+	// Customers are refunded without review only for orders that never went out
+	assert.False(t, needsApproval(RequesterCustomer, "rejected", 10000, 50000))
+	assert.True(t, needsApproval(RequesterCustomer, "rejected", 50001, 50000))
+	assert.True(t, needsApproval(RequesterCustomer, "delivered", 10000, 50000))
+	assert.True(t, needsApproval(RequesterCustomer, "pending", 10000, 50000))
+}
+
+func TestStatusFromPawaPay(t *testing.T) {
+	assert.Equal(t, StatusCompleted, statusFromPawaPay("COMPLETED"))
+	assert.Equal(t, StatusFailed, statusFromPawaPay("FAILED"))
+	assert.Equal(t, StatusFailed, statusFromPawaPay("REJECTED"))
+	assert.Equal(t, StatusProcessing, statusFromPawaPay("SUBMITTED"))
+	assert.Equal(t, StatusProcessing, statusFromPawaPay("ENQUEUED"))
+}
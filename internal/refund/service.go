@@ -0,0 +1,445 @@
+package refund
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+	"github.com/yakumwamba/lpg-delivery-system/internal/pawapay"
+)
+
+// Service refunds PawaPay deposits made for orders. Refunds up to the approval threshold
+// are sent to PawaPay straight away; larger ones, and customers' refunds on orders that
+// weren't cancelled, wait for an admin. PawaPay reports the
+// outcome by callback, which the service takes through HandleRefundCallback.
+type Service struct {
+	db          *sql.DB
+	pawaPay     *pawapay.Client
+	threshold   money.Amount
+	onCompleted []func(orderID uuid.UUID)
+}
+
+// NewService creates the refund service. Refunds that bring the total refunded on a
+// payment above approvalThreshold need an admin's approval; zero requires it for all.
+func NewService(db *sql.DB, pawaPay *pawapay.Client, approvalThreshold money.Amount) *Service {
+	return &Service{db: db, pawaPay: pawaPay, threshold: approvalThreshold}
+}
+
+// OnCompleted adds a function called with the order's ID each time one of its refunds
+// completes. It is meant to be called while the server is being wired up.
+func (s *Service) OnCompleted(fn func(orderID uuid.UUID)) {
+	s.onCompleted = append(s.onCompleted, fn)
+}
+
+const refundColumns = `
+	id, payment_id, order_id, deposit_id, amount, reason, status, requested_by, requester_role,
+	reviewed_by, COALESCE(review_note, ''), reviewed_at, COALESCE(failure_reason, ''),
+	submitted_at, completed_at, created_at, updated_at
+`
+
+// Request records a refund of an order's mobile money payment and sends it to PawaPay
+// unless it needs approval first
+func (s *Service) Request(orderID uuid.UUID, req CreateRefundRequest, requestedBy uuid.UUID, requesterRole string) (*Refund, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT p.id, p.transaction_ref, p.amount,
+			COALESCE((SELECT SUM(r.amount) FROM refunds r
+				WHERE r.payment_id = p.id AND r.status IN ('requested', 'processing', 'completed')), 0),
+			COALESCE((SELECT SUM(t.amount) FROM wallet_transactions t
+				WHERE t.order_id = o.id AND t.type = 'refund'), 0),
+			o.status, o.payment_status
+		FROM payments p
+		JOIN orders o ON o.id = p.order_id
+		WHERE p.order_id = $1 AND p.provider = 'pawapay' AND p.status = 'completed'
+			AND p.transaction_ref IS NOT NULL AND ($2::uuid IS NULL OR p.id = $2)
+		ORDER BY p.created_at DESC
+		LIMIT 1
+	`
+	var paymentIDArg interface{}
+	if req.PaymentID != nil {
+		paymentIDArg = req.PaymentID.String()
+	}
+
+	var paymentIDStr, depositID, orderStatus, paymentStatus string
+	var paid, committed, walletRefunded money.Amount
+	err := s.db.QueryRowContext(ctx, query, orderID.String(), paymentIDArg).Scan(
+		&paymentIDStr, &depositID, &paid, &committed, &walletRefunded, &orderStatus, &paymentStatus)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNothingToRefund
+		}
+		return nil, fmt.Errorf("failed to find payment to refund: %w", err)
+	}
+	// A rejected order's payment is returned to the wallet when the provider rejects it
+	if paymentStatus == "refunded" {
+		return nil, ErrAlreadyRefunded
+	}
+
+	amount, err := resolveAmount(req.Amount, paid, committed, walletRefunded)
+	if err != nil {
+		return nil, err
+	}
+
+	refund := &Refund{
+		ID:            uuid.New(),
+		OrderID:       orderID,
+		DepositID:     depositID,
+		Amount:        amount,
+		Reason:        req.Reason,
+		Status:        StatusRequested,
+		RequestedBy:   requestedBy,
+		RequesterRole: requesterRole,
+	}
+	refund.PaymentID, _ = uuid.Parse(paymentIDStr)
+
+	// The refundable amount is checked again as the row is written, so two requests made
+	// at once can't refund the same money twice
+	insert := `
+		INSERT INTO refunds (
+			id, payment_id, order_id, deposit_id, amount, reason, status, requested_by, requester_role
+		)
+		SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9
+		WHERE (SELECT COALESCE(SUM(amount), 0) FROM refunds
+			WHERE payment_id = $2 AND status IN ('requested', 'processing', 'completed'))
+			+ (SELECT COALESCE(SUM(amount), 0) FROM wallet_transactions
+				WHERE order_id = $3 AND type = 'refund') + $5 <= $10
+		RETURNING created_at, updated_at
+	`
+	err = s.db.QueryRowContext(ctx, insert,
+		refund.ID.String(), refund.PaymentID.String(), orderID.String(), depositID, amount, req.Reason,
+		StatusRequested, requestedBy.String(), requesterRole, paid,
+	).Scan(&refund.CreatedAt, &refund.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrExceedsRefundable
+		}
+		return nil, fmt.Errorf("failed to create refund: %w", err)
+	}
+
+	if needsApproval(requesterRole, orderStatus, committed+amount, s.threshold) {
+		log.Printf("Refund %s of %s for order %s is waiting for approval", refund.ID, amount, orderID)
+		return refund, nil
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		`UPDATE refunds SET status = $1, updated_at = NOW() WHERE id = $2 AND status = $3`,
+		StatusProcessing, refund.ID.String(), StatusRequested)
+	if err != nil {
+		return nil, fmt.Errorf("failed to approve refund: %w", err)
+	}
+	refund.Status = StatusProcessing
+	s.submit(ctx, refund)
+	return s.Get(refund.ID)
+}
+
+// Approve sends a refund waiting for approval to PawaPay
+func (s *Service) Approve(id, adminID uuid.UUID, note string) (*Refund, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	refund, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if refund.RequesterRole == RequesterAdmin && refund.RequestedBy == adminID {
+		return nil, ErrSelfApproval
+	}
+
+	if err := s.review(ctx, refund, adminID, note, StatusProcessing); err != nil {
+		return nil, err
+	}
+	s.submit(ctx, refund)
+	return s.Get(id)
+}
+
+// Reject turns down a refund waiting for approval
+func (s *Service) Reject(id, adminID uuid.UUID, note string) (*Refund, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	refund, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.review(ctx, refund, adminID, note, StatusRejected); err != nil {
+		return nil, err
+	}
+	return s.Get(id)
+}
+
+// review records an admin's decision on a refund that is still waiting for one
+func (s *Service) review(ctx context.Context, refund *Refund, adminID uuid.UUID, note string, next Status) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE refunds
+		SET status = $1, reviewed_by = $2, review_note = NULLIF($3, ''), reviewed_at = NOW(), updated_at = NOW()
+		WHERE id = $4 AND status = $5
+	`, next, adminID.String(), note, refund.ID.String(), StatusRequested)
+	if err != nil {
+		return fmt.Errorf("failed to review refund: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotAwaitingApproval
+	}
+	refund.Status = next
+	return nil
+}
+
+// Resubmit sends a refund PawaPay never acknowledged again. PawaPay ignores a refund ID
+// it has already accepted, so this can't pay out twice.
+func (s *Service) Resubmit(id uuid.UUID) (*Refund, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	refund, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if refund.Status != StatusProcessing {
+		return nil, ErrNotProcessing
+	}
+	s.submit(ctx, refund)
+	return s.Get(id)
+}
+
+// submit sends an approved refund to PawaPay. A refund PawaPay turns down is failed; one
+// that couldn't be sent stays processing with the error noted, for reconciliation or an
+// admin to pick up.
+func (s *Service) submit(ctx context.Context, refund *Refund) {
+	response, err := s.pawaPay.InitiateRefund(pawapay.Refund{
+		RefundID:  refund.ID.String(),
+		DepositID: refund.DepositID,
+		Amount:    refund.Amount.String(),
+		Currency:  string(money.ZMW),
+		Metadata:  []pawapay.Metadata{{FieldName: "orderId", Value: refund.OrderID.String()}},
+	})
+
+	status, failure := StatusProcessing, ""
+	switch {
+	case err != nil:
+		failure = "could not submit refund: " + err.Error()
+		log.Printf("ERROR: Refund %s for order %s: %s", refund.ID, refund.OrderID, failure)
+	case response.Status == "REJECTED":
+		status, failure = StatusFailed, "rejected by PawaPay"
+		if response.RejectionReason != nil {
+			failure = fmt.Sprintf("rejected by PawaPay: %s - %s",
+				response.RejectionReason.RejectionCode, response.RejectionReason.RejectionMessage)
+		}
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE refunds
+		SET status = $1, failure_reason = NULLIF($2, ''), submitted_at = COALESCE(submitted_at, NOW()), updated_at = NOW()
+		WHERE id = $3 AND status = $4
+	`, status, failure, refund.ID.String(), StatusProcessing)
+	if err != nil {
+		log.Printf("ERROR: Failed to record submission of refund %s: %v", refund.ID, err)
+	}
+}
+
+// HandleRefundCallback applies PawaPay's outcome for one of our refunds. It is registered
+// as a refund hook on the PawaPay callback handler. Once an order's gateway payments are
+// refunded in full the order is marked refunded.
+func (s *Service) HandleRefundCallback(callback pawapay.RefundCallback) (bool, error) {
+	id, err := uuid.Parse(callback.RefundID)
+	if err != nil {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	next := statusFromPawaPay(callback.Status)
+	var failure string
+	if callback.FailureReason != nil {
+		failure = callback.FailureReason.FailureCode + " - " + callback.FailureReason.FailureMessage
+	}
+
+	var orderIDStr string
+	err = s.db.QueryRowContext(ctx, `SELECT order_id FROM refunds WHERE id = $1`, id.String()).Scan(&orderIDStr)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to find refund: %w", err)
+	}
+	if next == StatusProcessing {
+		return true, nil
+	}
+
+	// Only a refund still waiting on PawaPay moves, so a repeated or late callback can't
+	// flip a finished refund
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE refunds
+		SET status = $1, failure_reason = NULLIF($2, ''),
+			completed_at = CASE WHEN $1 = 'completed' THEN NOW() ELSE completed_at END, updated_at = NOW()
+		WHERE id = $3 AND status = $4
+	`, next, failure, id.String(), StatusProcessing)
+	if err != nil {
+		return false, fmt.Errorf("failed to update refund: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 || next != StatusCompleted {
+		return true, nil
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE orders
+		SET payment_status = 'refunded', updated_at = NOW()
+		WHERE id = $1 AND payment_status = 'paid'
+			AND (SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE order_id = $1 AND status = 'completed')
+				>= (SELECT COALESCE(SUM(amount), 0) FROM payments WHERE order_id = $1 AND status = 'completed')
+	`, orderIDStr)
+	if err != nil {
+		return true, fmt.Errorf("failed to mark order refunded: %w", err)
+	}
+
+	orderID, _ := uuid.Parse(orderIDStr)
+	for _, fn := range s.onCompleted {
+		fn(orderID)
+	}
+	return true, nil
+}
+
+// Get returns one refund
+func (s *Service) Get(id uuid.UUID) (*Refund, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	refund, err := scanRefund(s.db.QueryRowContext(ctx,
+		`SELECT `+refundColumns+` FROM refunds WHERE id = $1`, id.String()))
+	if err == sql.ErrNoRows {
+		return nil, ErrRefundNotFound
+	}
+	return refund, err
+}
+
+// ListForOrder returns an order's refunds, newest first
+func (s *Service) ListForOrder(orderID uuid.UUID) ([]Refund, error) {
+	return s.list(`WHERE order_id = $1 ORDER BY created_at DESC`, orderID.String())
+}
+
+// List returns refunds in a status, or all of them when status is empty, newest first
+func (s *Service) List(status Status, limit, offset int) ([]Refund, error) {
+	return s.list(`WHERE ($1::text = '' OR status = $1) ORDER BY created_at DESC LIMIT $2 OFFSET $3`,
+		string(status), limit, offset)
+}
+
+func (s *Service) list(where string, args ...interface{}) ([]Refund, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+refundColumns+` FROM refunds `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list refunds: %w", err)
+	}
+	defer rows.Close()
+
+	refunds := []Refund{}
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, *refund)
+	}
+	return refunds, rows.Err()
+}
+
+// Report sums what each gateway collected and refunded between from and to, and lists the
+// refunds requested in that time
+func (s *Service) Report(from, to time.Time) (*Report, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		WITH collected AS (
+			SELECT provider, COUNT(*) AS payments, SUM(amount) AS amount
+			FROM payments
+			WHERE status = 'completed' AND updated_at >= $1 AND updated_at < $2
+			GROUP BY provider
+		), refunded AS (
+			SELECT p.provider,
+				COUNT(*) FILTER (WHERE r.status = 'completed') AS refunds,
+				COALESCE(SUM(r.amount) FILTER (WHERE r.status = 'completed'), 0) AS amount,
+				COALESCE(SUM(r.amount) FILTER (WHERE r.status IN ('requested', 'processing')), 0) AS pending
+			FROM refunds r
+			JOIN payments p ON p.id = r.payment_id
+			WHERE COALESCE(r.completed_at, r.created_at) >= $1 AND COALESCE(r.completed_at, r.created_at) < $2
+			GROUP BY p.provider
+		)
+		SELECT COALESCE(c.provider, r.provider), COALESCE(c.payments, 0), COALESCE(c.amount, 0),
+			COALESCE(r.refunds, 0), COALESCE(r.amount, 0), COALESCE(r.pending, 0)
+		FROM collected c
+		FULL OUTER JOIN refunded r ON r.provider = c.provider
+		ORDER BY 1
+	`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build refunds report: %w", err)
+	}
+	defer rows.Close()
+
+	report := &Report{From: from, To: to, Summary: []Summary{}}
+	for rows.Next() {
+		var sum Summary
+		if err := rows.Scan(&sum.Provider, &sum.Payments, &sum.Collected, &sum.Refunds, &sum.Refunded, &sum.PendingRefunds); err != nil {
+			return nil, fmt.Errorf("failed to scan refunds report: %w", err)
+		}
+		sum.Net = sum.Collected - sum.Refunded
+		report.Summary = append(report.Summary, sum)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	report.Refunds, err = s.list(`WHERE created_at >= $1 AND created_at < $2 ORDER BY created_at`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRefund(row rowScanner) (*Refund, error) {
+	var refund Refund
+	var idStr, paymentIDStr, orderIDStr, requestedByStr string
+	var reviewedBy sql.NullString
+	var reviewedAt, submittedAt, completedAt sql.NullTime
+	err := row.Scan(
+		&idStr, &paymentIDStr, &orderIDStr, &refund.DepositID, &refund.Amount, &refund.Reason, &refund.Status,
+		&requestedByStr, &refund.RequesterRole, &reviewedBy, &refund.ReviewNote, &reviewedAt,
+		&refund.FailureReason, &submittedAt, &completedAt, &refund.CreatedAt, &refund.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan refund: %w", err)
+	}
+
+	refund.ID, _ = uuid.Parse(idStr)
+	refund.PaymentID, _ = uuid.Parse(paymentIDStr)
+	refund.OrderID, _ = uuid.Parse(orderIDStr)
+	refund.RequestedBy, _ = uuid.Parse(requestedByStr)
+	if reviewedBy.Valid {
+		parsed, _ := uuid.Parse(reviewedBy.String)
+		refund.ReviewedBy = &parsed
+	}
+	if reviewedAt.Valid {
+		refund.ReviewedAt = &reviewedAt.Time
+	}
+	if submittedAt.Valid {
+		refund.SubmittedAt = &submittedAt.Time
+	}
+	if completedAt.Valid {
+		refund.CompletedAt = &completedAt.Time
+	}
+	return &refund, nil
+}
@@ -0,0 +1,111 @@
+package refund
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+	"github.com/yakumwamba/lpg-delivery-system/internal/wallet"
+	"github.com/yakumwamba/lpg-delivery-system/pkg/database"
+)
+
+// setupTestDB connects to the database in TEST_DATABASE_URL and creates the schema
+func setupTestDB(t *testing.T) *sql.DB {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	pool, err := database.ConnectPostgres(dbURL)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	require.NoError(t, database.InitPostgresSchema(pool))
+
+	return database.GetStdDB(pool)
+}
+
+// createUser adds a user of the given type and removes it, with its orders, when the
+// test ends
+func createUser(t *testing.T, db *sql.DB, userType string) uuid.UUID {
+	id := uuid.New()
+	_, err := db.Exec(`
+		INSERT INTO users (id, password, name, phone_number, user_type) VALUES ($1, 'x', $2, $3, $4)
+	`, id.String(), "Test "+userType, fmt.Sprintf("+2609%s", id.String()[:8]), userType)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Exec(`DELETE FROM users WHERE id = $1`, id.String()) })
+	return id
+}
+
+// createPaidOrder adds a K332.50 mobile money order paid with one completed PawaPay deposit
+func createPaidOrder(t *testing.T, db *sql.DB, customerID, providerID uuid.UUID, status string) uuid.UUID {
+	id := uuid.New()
+	_, err := db.Exec(`
+		INSERT INTO orders (
+			id, user_id, provider_id, status, cylinder_type, quantity, price_per_unit,
+			total_price, delivery_fee, service_charge, grand_total, delivery_address, delivery_method,
+			payment_method, payment_status
+		) VALUES ($1, $2, $3, $4, '6KG', 1, 300, 300, 25, 7.50, 332.50, 'Plot 1, Kabulonga', 'delivery',
+			'mobile_money', 'paid')
+	`, id.String(), customerID.String(), providerID.String(), status)
+	require.NoError(t, err)
+	_, err = db.Exec(`
+		INSERT INTO payments (order_id, amount, status, provider, phone_number, transaction_ref)
+		VALUES ($1, 332.50, 'completed', 'pawapay', '+260971234567', $2)
+	`, id.String(), uuid.New().String())
+	require.NoError(t, err)
+	return id
+}
+
+func TestRequestAfterWalletRefund(t *testing.T) {
+	db := setupTestDB(t)
+	s := NewService(db, nil, 50000)
+	wallets := wallet.NewService(db, nil)
+
+	customerID := createUser(t, db, "customer")
+	providerID := createUser(t, db, "provider")
+
+	// A rejected order's payment has already gone back to the wallet
+	rejected := createPaidOrder(t, db, customerID, providerID, "rejected")
+	_, err := wallets.RefundRejectedOrder(rejected)
+	require.NoError(t, err)
+	_, err = s.Request(rejected, CreateRefundRequest{Reason: "Order rejected"}, customerID, RequesterCustomer)
+	assert.ErrorIs(t, err, ErrAlreadyRefunded)
+
+	// Part of a delivered order was refunded to the wallet; only the rest can go back by PawaPay
+	delivered := createPaidOrder(t, db, customerID, providerID, "delivered")
+	_, err = wallets.RefundOrder(delivered, 50, "test-refund:"+delivered.String(), "Short fill")
+	require.NoError(t, err)
+	r, err := s.Request(delivered, CreateRefundRequest{Reason: "Short fill"}, customerID, RequesterCustomer)
+	require.NoError(t, err)
+	assert.Equal(t, money.Amount(28250), r.Amount)
+	assert.Equal(t, StatusRequested, r.Status)
+	_, err = s.Request(delivered, CreateRefundRequest{Reason: "Again"}, customerID, RequesterCustomer)
+	assert.ErrorIs(t, err, ErrExceedsRefundable)
+}
+
+func TestRejectedOrderAfterGatewayRefund(t *testing.T) {
+	db := setupTestDB(t)
+	s := NewService(db, nil, 0)
+	wallets := wallet.NewService(db, nil)
+
+	customerID := createUser(t, db, "customer")
+	providerID := createUser(t, db, "provider")
+	orderID := createPaidOrder(t, db, customerID, providerID, "rejected")
+
+	// A refund waiting for approval already claims K100 of the payment
+	_, err := s.Request(orderID, CreateRefundRequest{Amount: 10000, Reason: "Partial"}, uuid.New(), RequesterAdmin)
+	require.NoError(t, err)
+
+	tx, err := wallets.RefundRejectedOrder(orderID)
+	require.NoError(t, err)
+	require.NotNil(t, tx)
+	assert.InDelta(t, 232.50, tx.Amount, 0.001)
+}
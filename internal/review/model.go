@@ -0,0 +1,81 @@
+package review
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SubjectType string
+type ModerationStatus string
+
+const (
+	SubjectTypeProvider SubjectType = "provider"
+	SubjectTypeCourier  SubjectType = "courier"
+)
+
+const (
+	ModerationStatusPending  ModerationStatus = "pending"
+	ModerationStatusApproved ModerationStatus = "approved"
+	ModerationStatusRejected ModerationStatus = "rejected"
+)
+
+// MaxCommentLength caps the free-text part of a review
+const MaxCommentLength = 500
+
+// Tags customers can attach to a rating, per subject type
+var allowedTags = map[SubjectType][]string{
+	SubjectTypeProvider: {"good_price", "full_cylinder", "fast_response", "good_quality", "underfilled", "slow_response", "damaged_cylinder"},
+	SubjectTypeCourier:  {"on_time", "friendly", "careful_handling", "easy_to_reach", "late", "rude", "hard_to_reach"},
+}
+
+// Review is a customer's rating of the provider or courier on a delivered order
+type Review struct {
+	ID               uuid.UUID        `json:"id"`
+	OrderID          uuid.UUID        `json:"order_id"`
+	CustomerID       uuid.UUID        `json:"customer_id"`
+	SubjectID        uuid.UUID        `json:"subject_id"`
+	SubjectType      SubjectType      `json:"subject_type"`
+	Stars            int              `json:"stars"`
+	Tags             []string         `json:"tags"`
+	Comment          string           `json:"comment,omitempty"`
+	ModerationStatus ModerationStatus `json:"moderation_status"`
+	ModerationNote   string           `json:"moderation_note,omitempty"`
+	CreatedAt        time.Time        `json:"created_at"`
+	UpdatedAt        time.Time        `json:"updated_at"`
+}
+
+// PublicReview is the subset of a review shown on provider profiles
+type PublicReview struct {
+	ID           uuid.UUID `json:"id"`
+	ReviewerName string    `json:"reviewer_name"`
+	Stars        int       `json:"stars"`
+	Tags         []string  `json:"tags"`
+	Comment      string    `json:"comment,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// RatingInput is the rating a customer gives to one party of the order
+type RatingInput struct {
+	Stars   int      `json:"stars"`
+	Tags    []string `json:"tags"`
+	Comment string   `json:"comment"`
+}
+
+// OrderRatingRequest carries the provider and (optional) courier rating for an order
+type OrderRatingRequest struct {
+	Provider *RatingInput `json:"provider"`
+	Courier  *RatingInput `json:"courier"`
+}
+
+// RatingSummary is the aggregate rating of a provider or courier
+type RatingSummary struct {
+	SubjectID uuid.UUID `json:"subject_id"`
+	Average   float64   `json:"average"`
+	Count     int       `json:"count"`
+}
+
+// AllowedTags returns the tags customers may use when rating the given subject type
+func AllowedTags(subjectType SubjectType) []string {
+	return allowedTags[subjectType]
+}
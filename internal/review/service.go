@@ -0,0 +1,375 @@
+package review
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrReviewNotFound   = errors.New("review not found")
+	ErrOrderNotRateable = errors.New("order cannot be rated")
+	ErrAlreadyRated     = errors.New("order has already been rated")
+)
+
+type Service struct {
+	db *sql.DB
+}
+
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
+
+// SubmitOrderRating records the customer's rating of the provider and courier on a delivered order
+// and recomputes the aggregate rating of every rated party
+func (s *Service) SubmitOrderRating(customerID, orderID uuid.UUID, req *OrderRatingRequest) ([]Review, error) {
+	if req.Provider == nil && req.Courier == nil {
+		return nil, fmt.Errorf("%w: no rating provided", ErrOrderNotRateable)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var userIDStr, status string
+	var providerIDStr, courierIDStr sql.NullString
+	err := s.db.QueryRowContext(ctx,
+		`SELECT user_id, status, provider_id, courier_id FROM orders WHERE id = $1`,
+		orderID.String(),
+	).Scan(&userIDStr, &status, &providerIDStr, &courierIDStr)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: order not found", ErrOrderNotRateable)
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	if userIDStr != customerID.String() {
+		return nil, fmt.Errorf("%w: order does not belong to customer", ErrOrderNotRateable)
+	}
+	if status != "delivered" {
+		return nil, fmt.Errorf("%w: order has not been delivered", ErrOrderNotRateable)
+	}
+
+	now := time.Now()
+	var reviews []Review
+
+	if req.Provider != nil {
+		if !providerIDStr.Valid {
+			return nil, fmt.Errorf("%w: order has no provider", ErrOrderNotRateable)
+		}
+		subjectID, _ := uuid.Parse(providerIDStr.String)
+		r, err := newReview(orderID, customerID, subjectID, SubjectTypeProvider, req.Provider, now)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, *r)
+	}
+
+	if req.Courier != nil {
+		if !courierIDStr.Valid {
+			return nil, fmt.Errorf("%w: order has no courier", ErrOrderNotRateable)
+		}
+		subjectID, _ := uuid.Parse(courierIDStr.String)
+		r, err := newReview(orderID, customerID, subjectID, SubjectTypeCourier, req.Courier, now)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, *r)
+	}
+
+	// A single multi-row INSERT keeps the provider and courier ratings all-or-nothing
+	var placeholders []string
+	var args []interface{}
+	for _, r := range reviews {
+		n := len(args)
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11))
+		args = append(args,
+			r.ID.String(), r.OrderID.String(), r.CustomerID.String(), r.SubjectID.String(), r.SubjectType,
+			r.Stars, toPostgresArray(r.Tags), r.Comment, r.ModerationStatus, r.CreatedAt, r.UpdatedAt,
+		)
+	}
+
+	query := `
+		INSERT INTO reviews (
+			id, order_id, customer_id, subject_id, subject_type,
+			stars, tags, comment, moderation_status, created_at, updated_at
+		) VALUES ` + strings.Join(placeholders, ", ")
+
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, ErrAlreadyRated
+		}
+		return nil, fmt.Errorf("failed to save rating: %w", err)
+	}
+
+	for _, r := range reviews {
+		if err := s.RecomputeRating(r.SubjectID); err != nil {
+			// The review is stored; the aggregate is rebuilt on the next rating or moderation
+			fmt.Printf("[ReviewService] Warning: failed to recompute rating for %s: %v\n", r.SubjectID, err)
+		}
+	}
+
+	return reviews, nil
+}
+
+// newReview validates a rating input and builds the review row for it
+func newReview(orderID, customerID, subjectID uuid.UUID, subjectType SubjectType, input *RatingInput, now time.Time) (*Review, error) {
+	if input.Stars < 1 || input.Stars > 5 {
+		return nil, fmt.Errorf("%w: %s stars must be between 1 and 5", ErrOrderNotRateable, subjectType)
+	}
+
+	comment := strings.TrimSpace(input.Comment)
+	if len(comment) > MaxCommentLength {
+		return nil, fmt.Errorf("%w: comment must be at most %d characters", ErrOrderNotRateable, MaxCommentLength)
+	}
+
+	tags, err := validateTags(subjectType, input.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	// Star-only ratings are published straight away; free text waits for moderation
+	moderation := ModerationStatusApproved
+	if comment != "" {
+		moderation = ModerationStatusPending
+	}
+
+	return &Review{
+		ID:               uuid.New(),
+		OrderID:          orderID,
+		CustomerID:       customerID,
+		SubjectID:        subjectID,
+		SubjectType:      subjectType,
+		Stars:            input.Stars,
+		Tags:             tags,
+		Comment:          comment,
+		ModerationStatus: moderation,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}, nil
+}
+
+func validateTags(subjectType SubjectType, tags []string) ([]string, error) {
+	allowed := make(map[string]bool)
+	for _, t := range allowedTags[subjectType] {
+		allowed[t] = true
+	}
+
+	seen := make(map[string]bool)
+	result := []string{}
+	for _, t := range tags {
+		t = strings.TrimSpace(strings.ToLower(t))
+		if !allowed[t] {
+			return nil, fmt.Errorf("%w: unknown %s tag %q", ErrOrderNotRateable, subjectType, t)
+		}
+		if !seen[t] {
+			seen[t] = true
+			result = append(result, t)
+		}
+	}
+	return result, nil
+}
+
+// RecomputeRating rebuilds the aggregate rating of a provider or courier from their reviews.
+// users.rating (used by provider and courier dispatch) holds the rounded average. Moderation
+// only judges a review's text, so every review's stars count, rejected ones included.
+func (s *Service) RecomputeRating(subjectID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE users u
+		SET rating = COALESCE(ROUND(agg.avg_stars), 0),
+			rating_average = COALESCE(ROUND(agg.avg_stars, 2), 0),
+			rating_count = agg.cnt,
+			updated_at = $2
+		FROM (
+			SELECT AVG(stars) AS avg_stars, COUNT(*) AS cnt
+			FROM reviews
+			WHERE subject_id = $1
+		) agg
+		WHERE u.id = $1
+	`
+
+	_, err := s.db.ExecContext(ctx, query, subjectID.String(), time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to recompute rating: %w", err)
+	}
+
+	return nil
+}
+
+// GetOrderReviews returns the reviews submitted for an order
+func (s *Service) GetOrderReviews(orderID uuid.UUID) ([]Review, error) {
+	return s.listReviews(`WHERE order_id = $1 ORDER BY subject_type`, orderID.String())
+}
+
+// GetReviewsByStatus returns reviews awaiting (or having had) moderation, newest first
+func (s *Service) GetReviewsByStatus(status ModerationStatus, limit, offset int) ([]Review, error) {
+	return s.listReviews(`WHERE moderation_status = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3`, status, limit, offset)
+}
+
+func (s *Service) listReviews(where string, args ...interface{}) ([]Review, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT id, order_id, customer_id, subject_id, subject_type, stars,
+			COALESCE(array_to_string(tags, ','), ''), COALESCE(comment, ''),
+			moderation_status, COALESCE(moderation_note, ''), created_at, updated_at
+		FROM reviews
+	` + where
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reviews: %w", err)
+	}
+	defer rows.Close()
+
+	reviews := []Review{}
+	for rows.Next() {
+		var r Review
+		var idStr, orderIDStr, customerIDStr, subjectIDStr, tags string
+
+		err := rows.Scan(
+			&idStr, &orderIDStr, &customerIDStr, &subjectIDStr, &r.SubjectType, &r.Stars,
+			&tags, &r.Comment, &r.ModerationStatus, &r.ModerationNote, &r.CreatedAt, &r.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan review: %w", err)
+		}
+
+		r.ID, _ = uuid.Parse(idStr)
+		r.OrderID, _ = uuid.Parse(orderIDStr)
+		r.CustomerID, _ = uuid.Parse(customerIDStr)
+		r.SubjectID, _ = uuid.Parse(subjectIDStr)
+		r.Tags = splitTags(tags)
+
+		reviews = append(reviews, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reviews: %w", err)
+	}
+
+	return reviews, nil
+}
+
+// GetPublicReviews returns the reviews of a provider or courier for public listing. A
+// comment is only shown once it has been approved; the stars and tags always are.
+func (s *Service) GetPublicReviews(subjectID uuid.UUID, limit, offset int) ([]PublicReview, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT r.id, COALESCE(u.name, ''), r.stars,
+			COALESCE(array_to_string(r.tags, ','), ''),
+			CASE WHEN r.moderation_status = 'approved' THEN COALESCE(r.comment, '') ELSE '' END, r.created_at
+		FROM reviews r
+		LEFT JOIN users u ON r.customer_id = u.id
+		WHERE r.subject_id = $1
+		ORDER BY r.created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, subjectID.String(), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get public reviews: %w", err)
+	}
+	defer rows.Close()
+
+	reviews := []PublicReview{}
+	for rows.Next() {
+		var r PublicReview
+		var idStr, name, tags string
+
+		if err := rows.Scan(&idStr, &name, &r.Stars, &tags, &r.Comment, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan review: %w", err)
+		}
+
+		r.ID, _ = uuid.Parse(idStr)
+		r.ReviewerName = firstName(name)
+		r.Tags = splitTags(tags)
+
+		reviews = append(reviews, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reviews: %w", err)
+	}
+
+	return reviews, nil
+}
+
+// GetRatingSummary returns the stored aggregate rating of a provider or courier
+func (s *Service) GetRatingSummary(subjectID uuid.UUID) (*RatingSummary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	summary := &RatingSummary{SubjectID: subjectID}
+	err := s.db.QueryRowContext(ctx,
+		`SELECT COALESCE(rating_average, 0), COALESCE(rating_count, 0) FROM users WHERE id = $1`,
+		subjectID.String(),
+	).Scan(&summary.Average, &summary.Count)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, fmt.Errorf("failed to get rating summary: %w", err)
+	}
+
+	return summary, nil
+}
+
+// ModerateReview approves or rejects the text of a review and refreshes the subject's rating
+func (s *Service) ModerateReview(reviewID uuid.UUID, status ModerationStatus, note string) error {
+	if status != ModerationStatusApproved && status != ModerationStatusRejected {
+		return fmt.Errorf("invalid moderation status: %s", status)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var subjectIDStr string
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE reviews
+		SET moderation_status = $1, moderation_note = $2, updated_at = $3
+		WHERE id = $4
+		RETURNING subject_id
+	`, status, note, time.Now(), reviewID.String()).Scan(&subjectIDStr)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrReviewNotFound
+		}
+		return fmt.Errorf("failed to moderate review: %w", err)
+	}
+
+	subjectID, _ := uuid.Parse(subjectIDStr)
+	return s.RecomputeRating(subjectID)
+}
+
+// Helper function to format a string slice as a PostgreSQL array literal
+func toPostgresArray(values []string) string {
+	return "{" + strings.Join(values, ",") + "}"
+}
+
+func splitTags(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
+func firstName(name string) string {
+	fields := strings.Fields(name)
+	if len(fields) == 0 {
+		return "Customer"
+	}
+	return fields[0]
+}
@@ -0,0 +1,195 @@
+package review
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yakumwamba/lpg-delivery-system/pkg/database"
+)
+
+func TestNewReview(t *testing.T) {
+	orderID, customerID, subjectID := uuid.New(), uuid.New(), uuid.New()
+	now := time.Now()
+
+	// Star-only ratings are published straight away
+	r, err := newReview(orderID, customerID, subjectID, SubjectTypeCourier,
+		&RatingInput{Stars: 4, Tags: []string{" On_Time", "friendly", "on_time"}}, now)
+	require.NoError(t, err)
+	assert.Equal(t, 4, r.Stars)
+	assert.Equal(t, []string{"on_time", "friendly"}, r.Tags)
+	assert.Equal(t, ModerationStatusApproved, r.ModerationStatus)
+
+	// Free text waits for moderation
+	r, err = newReview(orderID, customerID, subjectID, SubjectTypeProvider,
+		&RatingInput{Stars: 2, Comment: "  Cylinder was underfilled  "}, now)
+	require.NoError(t, err)
+	assert.Equal(t, "Cylinder was underfilled", r.Comment)
+	assert.Equal(t, ModerationStatusPending, r.ModerationStatus)
+
+	for _, input := range []*RatingInput{
+		{Stars: 0},
+		{Stars: 6},
+		{Stars: 3, Comment: strings.Repeat("a", MaxCommentLength+1)},
+		{Stars: 3, Tags: []string{"on_time"}}, // a courier tag on a provider
+	} {
+		_, err := newReview(orderID, customerID, subjectID, SubjectTypeProvider, input, now)
+		assert.ErrorIs(t, err, ErrOrderNotRateable)
+	}
+}
+
+func TestFirstName(t *testing.T) {
+	assert.Equal(t, "Mutale", firstName("Mutale Banda"))
+	assert.Equal(t, "Customer", firstName("  "))
+}
+
+// setupTestDB connects to the database in TEST_DATABASE_URL and creates the schema
+func setupTestDB(t *testing.T) *sql.DB {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	pool, err := database.ConnectPostgres(dbURL)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	require.NoError(t, database.InitPostgresSchema(pool))
+
+	return database.GetStdDB(pool)
+}
+
+// createUser adds a user of the given type and removes it, with its orders and reviews,
+// when the test ends
+func createUser(t *testing.T, db *sql.DB, userType string) uuid.UUID {
+	id := uuid.New()
+	_, err := db.Exec(`
+		INSERT INTO users (id, password, name, phone_number, user_type) VALUES ($1, 'x', $2, $3, $4)
+	`, id.String(), "Test "+userType, fmt.Sprintf("+2609%s", id.String()[:8]), userType)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Exec(`DELETE FROM users WHERE id = $1`, id.String()) })
+	return id
+}
+
+func createOrder(t *testing.T, db *sql.DB, customerID, providerID, courierID uuid.UUID, status string) uuid.UUID {
+	id := uuid.New()
+	_, err := db.Exec(`
+		INSERT INTO orders (
+			id, user_id, provider_id, courier_id, status, cylinder_type, quantity, price_per_unit,
+			total_price, delivery_fee, service_charge, grand_total, delivery_address, delivery_method,
+			payment_method, payment_status
+		) VALUES ($1, $2, $3, $4, $5, '6KG', 1, 300, 300, 25, 7.50, 332.50, 'Plot 1, Kabulonga', 'delivery',
+			'mobile_money', 'paid')
+	`, id.String(), customerID.String(), providerID.String(), courierID.String(), status)
+	require.NoError(t, err)
+	return id
+}
+
+func TestSubmitOrderRating(t *testing.T) {
+	db := setupTestDB(t)
+	s := NewService(db)
+
+	customerID := createUser(t, db, "customer")
+	providerID := createUser(t, db, "provider")
+	courierID := createUser(t, db, "courier")
+
+	pending := createOrder(t, db, customerID, providerID, courierID, "in-transit")
+	_, err := s.SubmitOrderRating(customerID, pending, &OrderRatingRequest{Provider: &RatingInput{Stars: 5}})
+	assert.ErrorIs(t, err, ErrOrderNotRateable)
+
+	orderID := createOrder(t, db, customerID, providerID, courierID, "delivered")
+	_, err = s.SubmitOrderRating(uuid.New(), orderID, &OrderRatingRequest{Provider: &RatingInput{Stars: 5}})
+	assert.ErrorIs(t, err, ErrOrderNotRateable)
+
+	reviews, err := s.SubmitOrderRating(customerID, orderID, &OrderRatingRequest{
+		Provider: &RatingInput{Stars: 4, Tags: []string{"full_cylinder"}},
+		Courier:  &RatingInput{Stars: 2, Comment: "Arrived an hour late"},
+	})
+	require.NoError(t, err)
+	require.Len(t, reviews, 2)
+
+	stored, err := s.GetOrderReviews(orderID)
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	assert.Equal(t, SubjectTypeCourier, stored[0].SubjectType)
+	assert.Equal(t, ModerationStatusPending, stored[0].ModerationStatus)
+	assert.Equal(t, []string{"full_cylinder"}, stored[1].Tags)
+
+	summary, err := s.GetRatingSummary(providerID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, summary.Count)
+	assert.InDelta(t, 4.0, summary.Average, 0.001)
+
+	// An order is rated once per party
+	_, err = s.SubmitOrderRating(customerID, orderID, &OrderRatingRequest{Provider: &RatingInput{Stars: 1}})
+	assert.ErrorIs(t, err, ErrAlreadyRated)
+}
+
+func TestModerateReviewKeepsStars(t *testing.T) {
+	db := setupTestDB(t)
+	s := NewService(db)
+
+	customerID := createUser(t, db, "customer")
+	providerID := createUser(t, db, "provider")
+	courierID := createUser(t, db, "courier")
+
+	first := createOrder(t, db, customerID, providerID, courierID, "delivered")
+	_, err := s.SubmitOrderRating(customerID, first, &OrderRatingRequest{Provider: &RatingInput{Stars: 5}})
+	require.NoError(t, err)
+
+	second := createOrder(t, db, customerID, providerID, courierID, "delivered")
+	reviews, err := s.SubmitOrderRating(customerID, second, &OrderRatingRequest{
+		Provider: &RatingInput{Stars: 2, Comment: "Call me on 0977 000 000 for cheaper gas"},
+	})
+	require.NoError(t, err)
+
+	public, err := s.GetPublicReviews(providerID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, public, 2)
+	assert.Empty(t, public[0].Comment, "text waiting for moderation is hidden")
+
+	require.NoError(t, s.ModerateReview(reviews[0].ID, ModerationStatusRejected, "Contains a phone number"))
+	assert.ErrorIs(t, s.ModerateReview(uuid.New(), ModerationStatusRejected, ""), ErrReviewNotFound)
+	assert.Error(t, s.ModerateReview(reviews[0].ID, ModerationStatusPending, ""))
+
+	// The rejected text stays hidden but its stars still count
+	summary, err := s.GetRatingSummary(providerID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, summary.Count)
+	assert.InDelta(t, 3.5, summary.Average, 0.001)
+
+	public, err = s.GetPublicReviews(providerID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, public, 2)
+	assert.Equal(t, 2, public[0].Stars)
+	assert.Empty(t, public[0].Comment)
+
+	rejected, err := s.GetReviewsByStatus(ModerationStatusRejected, 50, 0)
+	require.NoError(t, err)
+	var found bool
+	for _, r := range rejected {
+		if r.ID == reviews[0].ID {
+			found = true
+			assert.Equal(t, "Contains a phone number", r.ModerationNote)
+		}
+	}
+	assert.True(t, found)
+
+	// Recomputing from scratch gives the same aggregate
+	_, err = db.ExecContext(context.Background(), `UPDATE users SET rating_average = 0, rating_count = 0 WHERE id = $1`, providerID.String())
+	require.NoError(t, err)
+	require.NoError(t, s.RecomputeRating(providerID))
+	summary, err = s.GetRatingSummary(providerID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, summary.Count)
+	assert.InDelta(t, 3.5, summary.Average, 0.001)
+}
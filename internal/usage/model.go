@@ -0,0 +1,162 @@
+package usage
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Source string
+
+const (
+	// SourceHistory means the rate was learned from the customer's own refill intervals
+	SourceHistory  Source = "history"
+	SourceOverride Source = "override"
+	SourceDefault  Source = "default"
+)
+
+const (
+	// DefaultDaysPerKg assumes a typical household, where a 13KG cylinder lasts about six weeks
+	DefaultDaysPerKg = 3.0
+	// DefaultLeadDays is how long before the estimated empty date the reminder goes out
+	DefaultLeadDays = 3
+	// maxIntervals limits learning to the most recent refills, so habits can change
+	maxIntervals = 10
+	// maxIntervalDays drops gaps where the customer clearly bought gas elsewhere or paused
+	maxIntervalDays = 180
+)
+
+// Profile is a customer's consumption settings
+type Profile struct {
+	UserID            uuid.UUID `json:"user_id"`
+	DaysPerKg         float64   `json:"days_per_kg"`
+	Source            Source    `json:"source"`
+	LearnedDaysPerKg  *float64  `json:"learned_days_per_kg,omitempty"`
+	SampleSize        int       `json:"sample_size"`
+	DaysPerKgOverride *float64  `json:"days_per_kg_override,omitempty"`
+	RemindersEnabled  bool      `json:"reminders_enabled"`
+	LeadDays          int       `json:"lead_days"`
+}
+
+type UpdateProfileRequest struct {
+	// DaysPerKgOverride replaces the learned rate; send 0 to go back to the learned one
+	DaysPerKgOverride *float64 `json:"days_per_kg_override" binding:"omitempty,gte=0,lte=60"`
+	RemindersEnabled  *bool    `json:"reminders_enabled"`
+	LeadDays          *int     `json:"lead_days" binding:"omitempty,gte=0,lte=14"`
+}
+
+// Prediction is when a customer's last refill is expected to run out
+type Prediction struct {
+	Profile          Profile   `json:"profile"`
+	LastOrderID      uuid.UUID `json:"last_order_id"`
+	LastOrderedAt    time.Time `json:"last_ordered_at"`
+	CylinderType     string    `json:"cylinder_type"`
+	KgDelivered      float64   `json:"kg_delivered"`
+	EstimatedEmptyAt time.Time `json:"estimated_empty_at"`
+	DaysRemaining    int       `json:"days_remaining"`
+	RemindAt         time.Time `json:"remind_at"`
+}
+
+// Refill is one delivered order as far as consumption is concerned
+type Refill struct {
+	OrderID      uuid.UUID
+	OrderedAt    time.Time
+	CylinderType string
+	Quantity     int
+}
+
+// Kg is the gas delivered by the refill
+func (r Refill) Kg() float64 {
+	return CylinderKg(r.CylinderType) * float64(r.Quantity)
+}
+
+// CylinderKg reads the size out of a cylinder type such as "13KG"
+func CylinderKg(cylinderType string) float64 {
+	kg, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(cylinderType)), "KG"), 64)
+	if err != nil || kg < 0 {
+		return 0
+	}
+	return kg
+}
+
+// LearnDaysPerKg estimates how many days one kilogram lasts the customer from the gaps
+// between refills. Refills on the same day are treated as one purchase. It returns the
+// rate and the number of intervals it is based on, which is zero when there is too
+// little history.
+func LearnDaysPerKg(refills []Refill) (float64, int) {
+	sorted := make([]Refill, len(refills))
+	copy(sorted, refills)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].OrderedAt.Before(sorted[j].OrderedAt) })
+
+	// Merge refills less than a day apart
+	type purchase struct {
+		at time.Time
+		kg float64
+	}
+	var purchases []purchase
+	for _, r := range sorted {
+		if n := len(purchases); n > 0 && r.OrderedAt.Sub(purchases[n-1].at) < 24*time.Hour {
+			purchases[n-1].kg += r.Kg()
+			continue
+		}
+		purchases = append(purchases, purchase{at: r.OrderedAt, kg: r.Kg()})
+	}
+
+	var totalDays, totalKg float64
+	intervals := 0
+	for i := len(purchases) - 1; i > 0 && intervals < maxIntervals; i-- {
+		days := purchases[i].at.Sub(purchases[i-1].at).Hours() / 24
+		kg := purchases[i-1].kg
+		if kg <= 0 || days > maxIntervalDays {
+			continue
+		}
+		totalDays += days
+		totalKg += kg
+		intervals++
+	}
+
+	if intervals == 0 {
+		return 0, 0
+	}
+	return totalDays / totalKg, intervals
+}
+
+// resolve picks the rate to use: the customer's override, then their history, then the default
+func (p *Profile) resolve(learned float64, samples int) {
+	p.SampleSize = samples
+	if samples > 0 {
+		p.LearnedDaysPerKg = &learned
+	}
+	switch {
+	case p.DaysPerKgOverride != nil && *p.DaysPerKgOverride > 0:
+		p.DaysPerKg, p.Source = *p.DaysPerKgOverride, SourceOverride
+	case samples > 0:
+		p.DaysPerKg, p.Source = learned, SourceHistory
+	default:
+		p.DaysPerKg, p.Source = DefaultDaysPerKg, SourceDefault
+	}
+}
+
+// predict estimates when the last refill runs out
+func predict(profile Profile, last Refill, now time.Time) *Prediction {
+	kg := last.Kg()
+	emptyAt := last.OrderedAt.Add(time.Duration(kg * profile.DaysPerKg * float64(24*time.Hour)))
+	daysRemaining := int(emptyAt.Sub(now).Hours() / 24)
+	if daysRemaining < 0 {
+		daysRemaining = 0
+	}
+
+	return &Prediction{
+		Profile:          profile,
+		LastOrderID:      last.OrderID,
+		LastOrderedAt:    last.OrderedAt,
+		CylinderType:     last.CylinderType,
+		KgDelivered:      kg,
+		EstimatedEmptyAt: emptyAt,
+		DaysRemaining:    daysRemaining,
+		RemindAt:         emptyAt.AddDate(0, 0, -profile.LeadDays),
+	}
+}
@@ -0,0 +1,46 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCylinderKg(t *testing.T) {
+	assert.Equal(t, 13.0, CylinderKg("13KG"))
+	assert.Equal(t, 6.0, CylinderKg(" 6kg "))
+	assert.Equal(t, 0.0, CylinderKg("large"))
+}
+
+func TestLearnDaysPerKg(t *testing.T) {
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	refills := []Refill{
+		{OrderedAt: start, CylinderType: "13KG", Quantity: 1},
+		{OrderedAt: start.AddDate(0, 0, 39), CylinderType: "13KG", Quantity: 1},
+		// Two orders on the same day count as one 26kg purchase
+		{OrderedAt: start.AddDate(0, 0, 78), CylinderType: "13KG", Quantity: 1},
+		{OrderedAt: start.AddDate(0, 0, 78).Add(2 * time.Hour), CylinderType: "13KG", Quantity: 1},
+		{OrderedAt: start.AddDate(0, 0, 156), CylinderType: "13KG", Quantity: 1},
+	}
+
+	rate, samples := LearnDaysPerKg(refills)
+	assert.Equal(t, 3, samples)
+	assert.InDelta(t, 156.0/52.0, rate, 0.01)
+
+	_, samples = LearnDaysPerKg(refills[:1])
+	assert.Equal(t, 0, samples)
+}
+
+func TestPredictUsesOverrideFirst(t *testing.T) {
+	override := 2.0
+	p := Profile{DaysPerKgOverride: &override, LeadDays: 3}
+	p.resolve(3.5, 4)
+	assert.Equal(t, SourceOverride, p.Source)
+
+	last := Refill{OrderedAt: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), CylinderType: "6KG", Quantity: 1}
+	prediction := predict(p, last, last.OrderedAt.AddDate(0, 0, 5))
+	assert.Equal(t, last.OrderedAt.AddDate(0, 0, 12), prediction.EstimatedEmptyAt)
+	assert.Equal(t, last.OrderedAt.AddDate(0, 0, 9), prediction.RemindAt)
+	assert.Equal(t, 7, prediction.DaysRemaining)
+}
@@ -0,0 +1,293 @@
+package usage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/notify"
+)
+
+var ErrNoDeliveredOrders = errors.New("no delivered orders to predict from")
+
+// staleAfter stops reminders for refills that ran out long ago; the customer has
+// probably bought gas elsewhere
+const staleAfter = 7 * 24 * time.Hour
+
+type PushSender interface {
+	Send(msg notify.PushMessage) error
+}
+
+type SMSSender interface {
+	SendSMS(to, body string) error
+}
+
+type Service struct {
+	db           *sql.DB
+	push         PushSender
+	sms          SMSSender
+	deepLinkBase string
+}
+
+// NewService creates the usage service. deepLinkBase is the app's URL scheme, such as
+// "zamgas://", used to build the one-tap reorder link in reminders.
+func NewService(db *sql.DB, push PushSender, sms SMSSender, deepLinkBase string) *Service {
+	return &Service{db: db, push: push, sms: sms, deepLinkBase: deepLinkBase}
+}
+
+// ReorderLink opens the app on a prefilled repeat of the given order
+func (s *Service) ReorderLink(orderID uuid.UUID) string {
+	return s.deepLinkBase + "orders?reorder=" + orderID.String()
+}
+
+// GetProfile returns the customer's consumption settings with the rate currently in use
+func (s *Service) GetProfile(userID uuid.UUID) (*Profile, error) {
+	profile, _, err := s.load(userID)
+	return profile, err
+}
+
+// UpdateProfile changes the customer's override rate and reminder settings
+func (s *Service) UpdateProfile(userID uuid.UUID, req *UpdateProfileRequest) (*Profile, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var override interface{}
+	clearOverride := false
+	if req.DaysPerKgOverride != nil {
+		if *req.DaysPerKgOverride > 0 {
+			override = *req.DaysPerKgOverride
+		} else {
+			clearOverride = true
+		}
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO usage_profiles (user_id, days_per_kg_override, reminders_enabled, lead_days)
+		VALUES ($1, $2, COALESCE($3, TRUE), COALESCE($4, $5))
+		ON CONFLICT (user_id) DO UPDATE SET
+			days_per_kg_override = CASE WHEN $6 THEN NULL
+				ELSE COALESCE(EXCLUDED.days_per_kg_override, usage_profiles.days_per_kg_override) END,
+			reminders_enabled = COALESCE($3, usage_profiles.reminders_enabled),
+			lead_days = COALESCE($4, usage_profiles.lead_days)
+	`, userID.String(), override, req.RemindersEnabled, req.LeadDays, DefaultLeadDays, clearOverride)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update usage profile: %w", err)
+	}
+
+	return s.GetProfile(userID)
+}
+
+// Predict estimates when the customer's most recent refill will run out
+func (s *Service) Predict(userID uuid.UUID) (*Prediction, error) {
+	profile, refills, err := s.load(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(refills) == 0 {
+		return nil, ErrNoDeliveredOrders
+	}
+
+	return predict(*profile, latestRefill(refills), time.Now()), nil
+}
+
+// load reads the customer's settings and delivered orders and resolves the rate in use
+func (s *Service) load(userID uuid.UUID) (*Profile, []Refill, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	profile := &Profile{UserID: userID, RemindersEnabled: true, LeadDays: DefaultLeadDays}
+	var override sql.NullFloat64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT days_per_kg_override, reminders_enabled, lead_days
+		FROM usage_profiles
+		WHERE user_id = $1
+	`, userID.String()).Scan(&override, &profile.RemindersEnabled, &profile.LeadDays)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, nil, fmt.Errorf("failed to get usage profile: %w", err)
+	}
+	if override.Valid {
+		profile.DaysPerKgOverride = &override.Float64
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, created_at, cylinder_type, quantity
+		FROM orders
+		WHERE user_id = $1 AND status = 'delivered'
+		ORDER BY created_at DESC
+		LIMIT 30
+	`, userID.String())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get refill history: %w", err)
+	}
+	defer rows.Close()
+
+	var refills []Refill
+	for rows.Next() {
+		var r Refill
+		var idStr string
+		if err := rows.Scan(&idStr, &r.OrderedAt, &r.CylinderType, &r.Quantity); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan refill: %w", err)
+		}
+		r.OrderID, _ = uuid.Parse(idStr)
+		refills = append(refills, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	learned, samples := LearnDaysPerKg(refills)
+	profile.resolve(learned, samples)
+
+	return profile, refills, nil
+}
+
+func latestRefill(refills []Refill) Refill {
+	latest := refills[0]
+	for _, r := range refills[1:] {
+		if r.OrderedAt.After(latest.OrderedAt) {
+			latest = r
+		}
+	}
+	return latest
+}
+
+// SendDueReminders notifies every customer whose gas is expected to run out within their
+// lead time and who has no order on the way. Each refill is reminded about at most once.
+// It returns the number of reminders sent.
+func (s *Service) SendDueReminders(now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT o.user_id
+		FROM orders o
+		LEFT JOIN usage_profiles p ON p.user_id = o.user_id
+		WHERE o.status = 'delivered' AND o.created_at > $1
+			AND COALESCE(p.reminders_enabled, TRUE)
+			AND NOT EXISTS (
+				SELECT 1 FROM orders open
+				WHERE open.user_id = o.user_id AND open.status IN ('pending', 'accepted', 'in-transit')
+			)
+	`, now.AddDate(0, 0, -maxIntervalDays))
+	if err != nil {
+		return 0, fmt.Errorf("failed to find reminder candidates: %w", err)
+	}
+
+	var userIDs []uuid.UUID
+	for rows.Next() {
+		var idStr string
+		if err := rows.Scan(&idStr); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan reminder candidate: %w", err)
+		}
+		id, _ := uuid.Parse(idStr)
+		userIDs = append(userIDs, id)
+	}
+	rows.Close()
+
+	sent := 0
+	for _, userID := range userIDs {
+		profile, refills, err := s.load(userID)
+		if err != nil {
+			log.Printf("[usage] skipping %s: %v", userID, err)
+			continue
+		}
+		if len(refills) == 0 || !profile.RemindersEnabled {
+			continue
+		}
+
+		prediction := predict(*profile, latestRefill(refills), now)
+		if now.Before(prediction.RemindAt) || now.After(prediction.EstimatedEmptyAt.Add(staleAfter)) {
+			continue
+		}
+
+		ok, err := s.sendReminder(userID, prediction)
+		if err != nil {
+			log.Printf("[usage] reminder for %s failed: %v", userID, err)
+			continue
+		}
+		if ok {
+			sent++
+		}
+	}
+
+	return sent, nil
+}
+
+// sendReminder claims the refill's reminder slot and notifies the customer by push,
+// falling back to SMS. It returns false if the refill was already reminded about.
+func (s *Service) sendReminder(userID uuid.UUID, p *Prediction) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var reminderID string
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO refill_reminders (user_id, order_id, estimated_empty_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, order_id) DO NOTHING
+		RETURNING id
+	`, userID.String(), p.LastOrderID.String(), p.EstimatedEmptyAt).Scan(&reminderID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to record reminder: %w", err)
+	}
+
+	// Give the slot back on failure so tomorrow's run tries again
+	sentOK := false
+	defer func() {
+		if !sentOK {
+			s.db.ExecContext(context.Background(), `DELETE FROM refill_reminders WHERE id = $1`, reminderID)
+		}
+	}()
+
+	var phone string
+	var pushToken sql.NullString
+	err = s.db.QueryRowContext(ctx, `SELECT phone_number, expo_push_token FROM users WHERE id = $1`, userID.String()).Scan(&phone, &pushToken)
+	if err != nil {
+		return false, fmt.Errorf("failed to get customer contact: %w", err)
+	}
+
+	link := s.ReorderLink(p.LastOrderID)
+	when := p.EstimatedEmptyAt.Format("Mon 2 Jan")
+	channel := ""
+
+	if s.push != nil && notify.IsExpoToken(pushToken.String) {
+		err := s.push.Send(notify.PushMessage{
+			To:    pushToken.String,
+			Title: fmt.Sprintf("Your %s gas may be running low", p.CylinderType),
+			Body:  fmt.Sprintf("Based on your usual use it should run out around %s. Tap to reorder.", when),
+			Data:  map[string]string{"type": "refill_reminder", "order_id": p.LastOrderID.String(), "url": link},
+		})
+		if err == nil {
+			channel = "push"
+		} else {
+			log.Printf("[usage] push to %s failed, falling back to SMS: %v", userID, err)
+		}
+	}
+	if channel == "" && s.sms != nil && phone != "" {
+		body := fmt.Sprintf("Your %s gas should run out around %s. Reorder in one tap: %s", p.CylinderType, when, link)
+		if err := s.sms.SendSMS(phone, body); err != nil {
+			return false, fmt.Errorf("failed to send SMS: %w", err)
+		}
+		channel = "sms"
+	}
+	if channel == "" {
+		return false, fmt.Errorf("no way to reach customer")
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE refill_reminders SET channel = $1, sent_at = NOW() WHERE id = $2
+	`, channel, reminderID)
+	sentOK = true
+	if err != nil {
+		log.Printf("[usage] reminder %s sent but not marked: %v", reminderID, err)
+	}
+
+	return true, nil
+}
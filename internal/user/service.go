@@ -6,11 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"strings"
 	"time"
-	"unicode"
 
 	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/phone"
 )
 
 type Service struct {
@@ -42,6 +41,11 @@ func (s *Service) CreateUser(user *User) (*User, error) {
 		user.ID = uuid.New()
 	}
 
+	// Store numbers in E.164 so lookups by phone match however the number was typed
+	if normalized, err := phone.Normalize(user.PhoneNumber); err == nil {
+		user.PhoneNumber = normalized
+	}
+
 	// PostgreSQL supports RETURNING
 	query := `
 		INSERT INTO users (
@@ -250,17 +254,11 @@ func (s *Service) UpdateUser(user *User) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Validate phone number format before updating
-	if !validatePhoneNumber(user.PhoneNumber) {
-		log.Printf("[PhoneUpdate] Validation failed for phone: %s", user.PhoneNumber)
-		return fmt.Errorf("invalid phone number format")
-	}
-
-	// Normalize phone number format
-	normalizedPhone, err := normalizePhoneNumber(user.PhoneNumber)
+	// Validate and normalize the phone number to E.164
+	normalizedPhone, err := phone.Normalize(user.PhoneNumber)
 	if err != nil {
-		log.Printf("[PhoneUpdate] Normalization failed for phone: %s, error: %v", user.PhoneNumber, err)
-		return fmt.Errorf("phone number normalization error: %w", err)
+		log.Printf("[PhoneUpdate] Validation failed for phone: %s", user.PhoneNumber)
+		return fmt.Errorf("invalid phone number format: %w", err)
 	}
 
 	log.Printf("[PhoneUpdate] Phone number transformation:")
@@ -300,52 +298,6 @@ func (s *Service) UpdateUser(user *User) error {
 	return nil
 }
 
-// Helper function to validate Zambian phone numbers
-func validatePhoneNumber(phone string) bool {
-	// Remove all spaces and special characters
-	phone = strings.ReplaceAll(phone, " ", "")
-	phone = strings.ReplaceAll(phone, "-", "")
-	phone = strings.ReplaceAll(phone, "+", "")
-
-	// Check if number starts with Zambian country code (260)
-	if !strings.HasPrefix(phone, "260") {
-		return false
-	}
-
-	// Check if the total length is correct (12 digits including country code)
-	if len(phone) != 12 {
-		return false
-	}
-
-	// Check if all characters are digits
-	for _, char := range phone {
-		if !unicode.IsDigit(char) {
-			return false
-		}
-	}
-
-	return true
-}
-
-// Helper function to normalize phone numbers
-func normalizePhoneNumber(phone string) (string, error) {
-	// Remove all spaces and special characters
-	phone = strings.ReplaceAll(phone, " ", "")
-	phone = strings.ReplaceAll(phone, "-", "")
-
-	// If number starts with 0, replace with country code
-	if strings.HasPrefix(phone, "0") {
-		phone = "260" + phone[1:]
-	}
-
-	// If number doesn't have +, add it
-	if !strings.HasPrefix(phone, "+") {
-		phone = "+" + phone
-	}
-
-	return phone, nil
-}
-
 func (s *Service) GetAllProviders() ([]*User, error) {
 	if s.db == nil {
 		return nil, fmt.Errorf("database connection unavailable")
@@ -461,41 +413,6 @@ func (s *Service) UpdateUserLocation(userID uuid.UUID, location Location) error
 	return nil
 }
 
-// In user/service.go
-func validateZambianPhone(phone string) bool {
-	phone = strings.ReplaceAll(phone, " ", "")
-	phone = strings.ReplaceAll(phone, "+", "")
-
-	if len(phone) != 12 || !strings.HasPrefix(phone, "260") {
-		return false
-	}
-
-	firstDigit := phone[3]
-	if firstDigit != '7' && firstDigit != '9' && firstDigit != '8' {
-		return false
-	}
-
-	return true
-}
-
-func normalizeZambianPhone(phone string) (string, error) {
-	phone = strings.ReplaceAll(phone, " ", "")
-
-	if strings.HasPrefix(phone, "0") {
-		phone = "260" + phone[1:]
-	}
-
-	if !strings.HasPrefix(phone, "+") {
-		phone = "+" + phone
-	}
-
-	if !validateZambianPhone(strings.TrimPrefix(phone, "+")) {
-		return "", fmt.Errorf("invalid phone number")
-	}
-
-	return phone, nil
-}
-
 func (s *Service) GetUserByPhoneNumber(phoneNumber string) (*User, error) {
 	if s.db == nil {
 		return nil, fmt.Errorf("database connection unavailable")
@@ -504,7 +421,7 @@ func (s *Service) GetUserByPhoneNumber(phoneNumber string) (*User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	normalizedPhone, err := normalizePhoneNumber(phoneNumber)
+	normalizedPhone, err := phone.Normalize(phoneNumber)
 	if err != nil {
 		return nil, fmt.Errorf("phone number normalization error: %w", err)
 	}
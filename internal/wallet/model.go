@@ -0,0 +1,206 @@
+package wallet
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TransactionType string
+type TopUpStatus string
+
+const (
+	TransactionTopUp          TransactionType = "top_up"
+	TransactionOrderPayment   TransactionType = "order_payment"
+	TransactionRefund         TransactionType = "refund"
+	TransactionGoodwillCredit TransactionType = "goodwill_credit"
+	TransactionReferralReward TransactionType = "referral_reward"
+)
+
+const (
+	TopUpStatusPending   TopUpStatus = "pending"
+	TopUpStatusCompleted TopUpStatus = "completed"
+	TopUpStatusFailed    TopUpStatus = "failed"
+)
+
+// Ledger accounts. Every transaction posts one entry to the customer's wallet and an
+// equal and opposite entry to the account the money came from or went to, so the
+// entries of a transaction always sum to zero.
+const (
+	AccountCustomerWallet = "customer_wallet"
+	// AccountMobileMoney holds money received from PawaPay for top-ups
+	AccountMobileMoney = "mobile_money_clearing"
+	// AccountOrderPayments is settled against orders: spends credit it, refunds debit it
+	AccountOrderPayments = "order_payments"
+	// AccountGoodwill is the platform's cost of credits given as compensation
+	AccountGoodwill = "goodwill_expense"
+	// AccountReferrals is the platform's cost of referral rewards
+	AccountReferrals = "referral_expense"
+)
+
+// MaxTopUpAmount keeps a single top-up within mobile money transaction limits
+const MaxTopUpAmount = 10000.0
+
+var (
+	ErrInsufficientFunds  = errors.New("insufficient wallet balance")
+	ErrInvalidAmount      = errors.New("amount must be greater than zero")
+	ErrTopUpTooLarge      = errors.New("top-up exceeds the maximum amount")
+	ErrTopUpNotFound      = errors.New("top-up not found")
+	ErrOrderNotFound      = errors.New("order not found")
+	ErrOrderNotPayable    = errors.New("order cannot be paid from the wallet")
+	ErrOrderAlreadyPaid   = errors.New("order is already paid")
+	ErrRefundExceedsOrder = errors.New("refund exceeds the amount paid for the order")
+	ErrDuplicateReference = errors.New("a wallet transaction with this reference already exists")
+)
+
+// Wallet is a customer's stored balance
+type Wallet struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Balance   float64   `json:"balance"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Transaction is one movement of a customer's balance. Amount is positive for money
+// entering the wallet and negative for money spent from it.
+type Transaction struct {
+	ID           uuid.UUID       `json:"id"`
+	UserID       uuid.UUID       `json:"user_id"`
+	Type         TransactionType `json:"type"`
+	Amount       float64         `json:"amount"`
+	BalanceAfter float64         `json:"balance_after"`
+	OrderID      *uuid.UUID      `json:"order_id,omitempty"`
+	Reference    string          `json:"reference,omitempty"`
+	Note         string          `json:"note,omitempty"`
+	CreatedBy    *uuid.UUID      `json:"created_by,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// Entry is one side of a transaction in the ledger
+type Entry struct {
+	Account string  `json:"account"`
+	Amount  float64 `json:"amount"`
+}
+
+// TopUp is a mobile money deposit into the wallet. The balance is only credited once
+// PawaPay reports the deposit completed.
+type TopUp struct {
+	ID            uuid.UUID   `json:"id"`
+	UserID        uuid.UUID   `json:"user_id"`
+	DepositID     string      `json:"deposit_id"`
+	Amount        float64     `json:"amount"`
+	PhoneNumber   string      `json:"phone_number"`
+	Status        TopUpStatus `json:"status"`
+	TransactionID *uuid.UUID  `json:"transaction_id,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
+
+type TopUpRequest struct {
+	Amount      float64 `json:"amount" binding:"required,gt=0"`
+	PhoneNumber string  `json:"phone_number"`
+	// Operator overrides the mobile network detected from the number, e.g. "mtn"
+	Operator string `json:"operator"`
+}
+
+// SpendRequest pays an order from the wallet. Without an amount the wallet covers as
+// much of the outstanding total as the balance allows.
+type SpendRequest struct {
+	Amount *float64 `json:"amount"`
+}
+
+// CreditRequest is an admin credit of a customer's wallet
+type CreditRequest struct {
+	Type      TransactionType `json:"type" binding:"required,oneof=refund goodwill_credit"`
+	Amount    float64         `json:"amount" binding:"required,gt=0"`
+	OrderID   *uuid.UUID      `json:"order_id"`
+	Reference string          `json:"reference"`
+	Note      string          `json:"note"`
+}
+
+// OrderPayment is the result of spending from the wallet on an order
+type OrderPayment struct {
+	Transaction *Transaction `json:"transaction"`
+	OrderID     uuid.UUID    `json:"order_id"`
+	WalletPaid  float64      `json:"wallet_paid"`
+	Outstanding float64      `json:"outstanding"`
+	OrderPaid   bool         `json:"order_paid"`
+}
+
+// Statement is the customer's wallet activity over a period
+type Statement struct {
+	UserID         uuid.UUID     `json:"user_id"`
+	From           *time.Time    `json:"from,omitempty"`
+	To             *time.Time    `json:"to,omitempty"`
+	OpeningBalance float64       `json:"opening_balance"`
+	ClosingBalance float64       `json:"closing_balance"`
+	TotalCredits   float64       `json:"total_credits"`
+	TotalDebits    float64       `json:"total_debits"`
+	Transactions   []Transaction `json:"transactions"`
+}
+
+// IntegrityReport lists any breaks in the double-entry invariants
+type IntegrityReport struct {
+	Balanced               bool               `json:"balanced"`
+	UnbalancedTransactions []uuid.UUID        `json:"unbalanced_transactions"`
+	MismatchedWallets      []WalletMismatch   `json:"mismatched_wallets"`
+	AccountTotals          map[string]float64 `json:"account_totals"`
+}
+
+// WalletMismatch is a wallet whose stored balance differs from its ledger entries
+type WalletMismatch struct {
+	UserID        uuid.UUID `json:"user_id"`
+	Balance       float64   `json:"balance"`
+	LedgerBalance float64   `json:"ledger_balance"`
+}
+
+// contraAccount is the account on the other side of the customer's wallet
+func contraAccount(t TransactionType) string {
+	switch t {
+	case TransactionTopUp:
+		return AccountMobileMoney
+	case TransactionGoodwillCredit:
+		return AccountGoodwill
+	case TransactionReferralReward:
+		return AccountReferrals
+	default:
+		return AccountOrderPayments
+	}
+}
+
+// postingEntries returns the balanced pair of entries for a transaction
+func postingEntries(t TransactionType, amount float64) []Entry {
+	return []Entry{
+		{Account: AccountCustomerWallet, Amount: amount},
+		{Account: contraAccount(t), Amount: -amount},
+	}
+}
+
+// spendAmount works out how much to take from the wallet for an order. A requested
+// amount must be covered in full; otherwise the wallet pays what it can.
+func spendAmount(requested *float64, balance, outstanding float64) (float64, error) {
+	if outstanding <= 0 {
+		return 0, ErrOrderAlreadyPaid
+	}
+	if requested != nil {
+		if *requested <= 0 {
+			return 0, ErrInvalidAmount
+		}
+		amount := roundMoney(math.Min(*requested, outstanding))
+		if amount > balance {
+			return 0, ErrInsufficientFunds
+		}
+		return amount, nil
+	}
+
+	amount := roundMoney(math.Min(balance, outstanding))
+	if amount <= 0 {
+		return 0, ErrInsufficientFunds
+	}
+	return amount, nil
+}
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,56 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostingEntriesBalance(t *testing.T) {
+	cases := map[TransactionType]string{
+		TransactionTopUp:          AccountMobileMoney,
+		TransactionOrderPayment:   AccountOrderPayments,
+		TransactionRefund:         AccountOrderPayments,
+		TransactionGoodwillCredit: AccountGoodwill,
+		TransactionReferralReward: AccountReferrals,
+	}
+	for txType, contra := range cases {
+		entries := postingEntries(txType, 125.5)
+		require.Len(t, entries, 2)
+		assert.Equal(t, AccountCustomerWallet, entries[0].Account)
+		assert.Equal(t, contra, entries[1].Account)
+		assert.Zero(t, entries[0].Amount+entries[1].Amount, txType)
+	}
+}
+
+func TestSpendAmount(t *testing.T) {
+	// Without an amount the wallet covers what it can
+	amount, err := spendAmount(nil, 80, 315)
+	require.NoError(t, err)
+	assert.Equal(t, 80.0, amount)
+
+	amount, err = spendAmount(nil, 500, 315)
+	require.NoError(t, err)
+	assert.Equal(t, 315.0, amount)
+
+	_, err = spendAmount(nil, 0, 315)
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+	// A requested amount is capped at what is outstanding but must be covered in full
+	requested := 400.0
+	amount, err = spendAmount(&requested, 500, 315)
+	require.NoError(t, err)
+	assert.Equal(t, 315.0, amount)
+
+	requested = 100
+	_, err = spendAmount(&requested, 50, 315)
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+	requested = -5
+	_, err = spendAmount(&requested, 50, 315)
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+
+	_, err = spendAmount(nil, 50, 0)
+	assert.ErrorIs(t, err, ErrOrderAlreadyPaid)
+}
@@ -0,0 +1,587 @@
+package wallet
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yakumwamba/lpg-delivery-system/internal/money"
+	"github.com/yakumwamba/lpg-delivery-system/internal/pawapay"
+)
+
+type Service struct {
+	db      *sql.DB
+	pawaPay *pawapay.Client
+}
+
+func NewService(db *sql.DB, pawaPay *pawapay.Client) *Service {
+	return &Service{db: db, pawaPay: pawaPay}
+}
+
+// GetWallet returns the customer's balance. Customers who never used the wallet have
+// an empty one.
+func (s *Service) GetWallet(userID uuid.UUID) (*Wallet, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	w := &Wallet{UserID: userID}
+	err := s.db.QueryRowContext(ctx,
+		`SELECT balance, updated_at FROM wallets WHERE user_id = $1`, userID.String(),
+	).Scan(&w.Balance, &w.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get wallet: %w", err)
+	}
+	return w, nil
+}
+
+// post records a transaction and its two ledger entries and moves the wallet balance in
+// a single statement, so a balance never changes without its entries. Debits that
+// would take the balance below zero are refused.
+func (s *Service) post(ctx context.Context, userID uuid.UUID, t TransactionType, amount float64, orderID *uuid.UUID, reference, note string, createdBy *uuid.UUID) (*Transaction, error) {
+	entries := postingEntries(t, amount)
+
+	var orderIDArg, createdByArg interface{}
+	if orderID != nil {
+		orderIDArg = orderID.String()
+	}
+	if createdBy != nil {
+		createdByArg = createdBy.String()
+	}
+
+	tx := &Transaction{
+		UserID:    userID,
+		Type:      t,
+		Amount:    amount,
+		OrderID:   orderID,
+		Reference: reference,
+		Note:      note,
+		CreatedBy: createdBy,
+	}
+
+	var idStr string
+	err := s.db.QueryRowContext(ctx, `
+		WITH w AS (
+			INSERT INTO wallets (user_id, balance) VALUES ($1, $2::numeric)
+			ON CONFLICT (user_id) DO UPDATE SET balance = wallets.balance + EXCLUDED.balance
+			WHERE wallets.balance + EXCLUDED.balance >= 0
+			RETURNING balance
+		), t AS (
+			INSERT INTO wallet_transactions (user_id, type, amount, balance_after, order_id, reference, note, created_by)
+			SELECT $1, $3, $2::numeric, w.balance, $4::uuid, NULLIF($5, ''), NULLIF($6, ''), $7::uuid FROM w
+			RETURNING id, balance_after, created_at
+		), e AS (
+			INSERT INTO wallet_entries (transaction_id, account, user_id, amount)
+			SELECT t.id, $8, $1::uuid, $9::numeric FROM t
+			UNION ALL
+			SELECT t.id, $10, NULL, $11::numeric FROM t
+		)
+		SELECT id, balance_after, created_at FROM t
+	`, userID.String(), amount, t, orderIDArg, reference, note, createdByArg,
+		entries[0].Account, entries[0].Amount, entries[1].Account, entries[1].Amount,
+	).Scan(&idStr, &tx.BalanceAfter, &tx.CreatedAt)
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows, strings.Contains(err.Error(), "violates check constraint"):
+			return nil, ErrInsufficientFunds
+		case strings.Contains(err.Error(), "duplicate key"):
+			return nil, ErrDuplicateReference
+		}
+		return nil, fmt.Errorf("failed to post wallet transaction: %w", err)
+	}
+	tx.ID, _ = uuid.Parse(idStr)
+
+	return tx, nil
+}
+
+// TopUp asks PawaPay to collect money from the customer's phone. The wallet is credited
+// when the deposit completes.
+func (s *Service) TopUp(userID uuid.UUID, req *TopUpRequest) (*TopUp, error) {
+	if req.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if req.Amount > MaxTopUpAmount {
+		return nil, ErrTopUpTooLarge
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	topUp := &TopUp{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Amount:      roundMoney(req.Amount),
+		PhoneNumber: req.PhoneNumber,
+		Status:      TopUpStatusPending,
+	}
+
+	deposit, err := s.pawaPay.InitiateDeposit(topUp.ID.String(), money.New(money.Kwacha(topUp.Amount), money.ZMW), topUp.PhoneNumber, req.Operator)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initiate top-up: %w", err)
+	}
+	topUp.DepositID = deposit.DepositID
+
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO wallet_topups (id, user_id, deposit_id, amount, phone_number, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at, updated_at
+	`, topUp.ID.String(), userID.String(), topUp.DepositID, topUp.Amount, topUp.PhoneNumber, topUp.Status,
+	).Scan(&topUp.CreatedAt, &topUp.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record top-up: %w", err)
+	}
+
+	return topUp, nil
+}
+
+// GetTopUp returns one of the customer's top-ups. A top-up still pending is checked
+// with PawaPay in case its callback was missed.
+func (s *Service) GetTopUp(userID, topUpID uuid.UUID) (*TopUp, error) {
+	topUp, err := s.getTopUp(topUpID)
+	if err != nil {
+		return nil, err
+	}
+	if topUp.UserID != userID {
+		return nil, ErrTopUpNotFound
+	}
+
+	if topUp.Status == TopUpStatusPending {
+		status, err := s.pawaPay.GetPaymentStatus("deposits", topUp.DepositID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check top-up status: %w", err)
+		}
+		if _, err := s.applyDepositStatus(topUp.DepositID, status.Status); err != nil {
+			return nil, err
+		}
+		return s.getTopUp(topUpID)
+	}
+
+	return topUp, nil
+}
+
+// HandleDepositCallback settles a wallet top-up from a PawaPay deposit callback. It
+// reports whether the deposit was a top-up at all, so order payments can be handled
+// elsewhere.
+func (s *Service) HandleDepositCallback(callback pawapay.DepositCallback) (bool, error) {
+	return s.applyDepositStatus(callback.DepositID, callback.Status)
+}
+
+func (s *Service) applyDepositStatus(depositID, status string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var idStr, userIDStr string
+	var amount float64
+	var current TopUpStatus
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, user_id, amount, status FROM wallet_topups WHERE deposit_id = $1`, depositID,
+	).Scan(&idStr, &userIDStr, &amount, &current)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get top-up: %w", err)
+	}
+
+	var next TopUpStatus
+	switch status {
+	case "COMPLETED":
+		next = TopUpStatusCompleted
+	case "FAILED", "REJECTED":
+		next = TopUpStatusFailed
+	default:
+		return true, nil
+	}
+
+	// Claim the pending top-up first so a repeated callback cannot credit it twice
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE wallet_topups SET status = $1 WHERE id = $2 AND status = 'pending'`, next, idStr,
+	)
+	if err != nil {
+		return true, fmt.Errorf("failed to update top-up: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 || next != TopUpStatusCompleted {
+		return true, nil
+	}
+
+	userID, _ := uuid.Parse(userIDStr)
+	tx, err := s.post(ctx, userID, TransactionTopUp, amount, nil, depositID, "Mobile money top-up", nil)
+	if err != nil && !errors.Is(err, ErrDuplicateReference) {
+		// Put the top-up back so the next callback or status check retries the credit
+		s.db.ExecContext(ctx, `UPDATE wallet_topups SET status = 'pending' WHERE id = $1`, idStr)
+		return true, err
+	}
+	if tx != nil {
+		if _, err := s.db.ExecContext(ctx,
+			`UPDATE wallet_topups SET transaction_id = $1 WHERE id = $2`, tx.ID.String(), idStr,
+		); err != nil {
+			return true, fmt.Errorf("failed to link top-up transaction: %w", err)
+		}
+	}
+
+	return true, nil
+}
+
+func (s *Service) getTopUp(topUpID uuid.UUID) (*TopUp, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var t TopUp
+	var idStr, userIDStr string
+	var txIDStr sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, user_id, deposit_id, amount, phone_number, status, transaction_id, created_at, updated_at
+		FROM wallet_topups WHERE id = $1
+	`, topUpID.String()).Scan(&idStr, &userIDStr, &t.DepositID, &t.Amount, &t.PhoneNumber, &t.Status,
+		&txIDStr, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTopUpNotFound
+		}
+		return nil, fmt.Errorf("failed to get top-up: %w", err)
+	}
+	t.ID, _ = uuid.Parse(idStr)
+	t.UserID, _ = uuid.Parse(userIDStr)
+	if txIDStr.Valid {
+		parsed, _ := uuid.Parse(txIDStr.String)
+		t.TransactionID = &parsed
+	}
+	return &t, nil
+}
+
+// PayOrder spends from the wallet on one of the customer's mobile money orders. The
+// order is marked paid once the wallet covers its total; otherwise the rest is left to
+// be paid by mobile money.
+func (s *Service) PayOrder(userID, orderID uuid.UUID, req *SpendRequest) (*OrderPayment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Gateway payments that already went through count towards the total as well
+	var ownerStr, paymentMethod, paymentStatus, status string
+	var grandTotal, walletPaid, gatewayPaid float64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT user_id, payment_method, payment_status, status, grand_total, wallet_paid,
+			COALESCE((SELECT SUM(amount) FROM payments WHERE order_id = orders.id AND status = 'completed'), 0)
+		FROM orders WHERE id = $1
+	`, orderID.String()).Scan(&ownerStr, &paymentMethod, &paymentStatus, &status, &grandTotal, &walletPaid, &gatewayPaid)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	if ownerStr != userID.String() {
+		return nil, ErrOrderNotFound
+	}
+	if paymentStatus == "paid" {
+		return nil, ErrOrderAlreadyPaid
+	}
+	if paymentMethod != "mobile_money" || paymentStatus == "refunded" || status == "rejected" {
+		return nil, ErrOrderNotPayable
+	}
+
+	w, err := s.GetWallet(userID)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := spendAmount(req.Amount, w.Balance, roundMoney(grandTotal-walletPaid-gatewayPaid))
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.post(ctx, userID, TransactionOrderPayment, -amount, &orderID, "", "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	payment := &OrderPayment{Transaction: tx, OrderID: orderID}
+	err = s.db.QueryRowContext(ctx, `
+		UPDATE orders
+		SET wallet_paid = wallet_paid + $1,
+			payment_status = CASE WHEN wallet_paid + $1 + $4 >= grand_total THEN 'paid' ELSE payment_status END,
+			updated_at = $2
+		WHERE id = $3 AND payment_status IN ('pending', 'failed') AND wallet_paid + $1 + $4 <= grand_total
+		RETURNING wallet_paid, grand_total - wallet_paid - $4, payment_status = 'paid'
+	`, amount, time.Now(), orderID.String(), gatewayPaid).Scan(&payment.WalletPaid, &payment.Outstanding, &payment.OrderPaid)
+	if err != nil {
+		// The order changed under us; give the money back rather than leave it unapplied
+		if _, rerr := s.post(ctx, userID, TransactionRefund, amount, &orderID, "reversal:"+tx.ID.String(),
+			"Wallet payment reversed", nil); rerr != nil {
+			return nil, fmt.Errorf("failed to reverse wallet payment %s: %v (after: %w)", tx.ID, rerr, err)
+		}
+		if err == sql.ErrNoRows {
+			return nil, ErrOrderNotPayable
+		}
+		return nil, fmt.Errorf("failed to apply wallet payment: %w", err)
+	}
+
+	return payment, nil
+}
+
+// RefundOrder returns money for an order to the customer's wallet. The reference makes
+// the refund idempotent, so retrying the same refund does not pay it twice.
+func (s *Service) RefundOrder(orderID uuid.UUID, amount float64, reference, note string) (*Transaction, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var userIDStr string
+	err := s.db.QueryRowContext(ctx, `SELECT user_id FROM orders WHERE id = $1`, orderID.String()).Scan(&userIDStr)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	userID, _ := uuid.Parse(userIDStr)
+
+	return s.post(ctx, userID, TransactionRefund, roundMoney(amount), &orderID, reference, note, nil)
+}
+
+// gatewayRefundedColumn selects what has been, or is being, refunded to the payer through
+// PawaPay for the order aliased o, so the wallet never returns the same money again
+const gatewayRefundedColumn = `COALESCE((SELECT SUM(r.amount) FROM refunds r
+	WHERE r.order_id = o.id AND r.status IN ('requested', 'processing', 'completed')), 0)`
+
+// RefundRejectedOrder returns what the customer paid for a rejected mobile money order
+// to their wallet and marks the order refunded. Orders with nothing paid are left alone.
+func (s *Service) RefundRejectedOrder(orderID uuid.UUID) (*Transaction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var paymentMethod, paymentStatus string
+	var grandTotal, walletPaid, gatewayRefunded float64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT o.payment_method, o.payment_status, o.grand_total, o.wallet_paid, `+gatewayRefundedColumn+`
+		FROM orders o WHERE o.id = $1
+	`, orderID.String()).Scan(&paymentMethod, &paymentStatus, &grandTotal, &walletPaid, &gatewayRefunded)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	if paymentMethod != "mobile_money" {
+		return nil, nil
+	}
+
+	paid := walletPaid
+	if paymentStatus == "paid" {
+		paid = roundMoney(grandTotal - gatewayRefunded)
+	}
+	if paid <= 0 {
+		return nil, nil
+	}
+
+	tx, err := s.RefundOrder(orderID, paid, "order-rejected:"+orderID.String(), "Order rejected by provider")
+	if err != nil {
+		if errors.Is(err, ErrDuplicateReference) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE orders SET payment_status = 'refunded', updated_at = $1 WHERE id = $2`, time.Now(), orderID.String(),
+	); err != nil {
+		return tx, fmt.Errorf("failed to mark order refunded: %w", err)
+	}
+	return tx, nil
+}
+
+// Credit adds a refund or goodwill credit to a customer's wallet on behalf of an admin.
+// Refunds against an order cannot exceed what is left of its total.
+func (s *Service) Credit(userID uuid.UUID, req *CreditRequest, adminID uuid.UUID) (*Transaction, error) {
+	if req.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if req.OrderID != nil {
+		var ownerStr string
+		var grandTotal, refunded, gatewayRefunded float64
+		err := s.db.QueryRowContext(ctx, `
+			SELECT o.user_id, o.grand_total,
+				COALESCE((SELECT SUM(t.amount) FROM wallet_transactions t
+					WHERE t.order_id = o.id AND t.type = 'refund'), 0),
+				`+gatewayRefundedColumn+`
+			FROM orders o WHERE o.id = $1
+		`, req.OrderID.String()).Scan(&ownerStr, &grandTotal, &refunded, &gatewayRefunded)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, ErrOrderNotFound
+			}
+			return nil, fmt.Errorf("failed to get order: %w", err)
+		}
+		if ownerStr != userID.String() {
+			return nil, ErrOrderNotFound
+		}
+		if req.Type == TransactionRefund && req.Amount > roundMoney(grandTotal-refunded-gatewayRefunded) {
+			return nil, ErrRefundExceedsOrder
+		}
+	}
+
+	return s.post(ctx, userID, req.Type, roundMoney(req.Amount), req.OrderID, req.Reference, req.Note, &adminID)
+}
+
+// CreditReward pays a referral reward into the customer's wallet. Like refunds, the
+// reference keeps a reward from being paid twice.
+func (s *Service) CreditReward(userID uuid.UUID, amount float64, reference, note string) (*Transaction, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return s.post(ctx, userID, TransactionReferralReward, roundMoney(amount), nil, reference, note, nil)
+}
+
+// GetStatement returns the customer's wallet transactions between from and to, newest
+// first, with the balances either side of the period
+func (s *Service) GetStatement(userID uuid.UUID, from, to *time.Time, limit, offset int) (*Statement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	st := &Statement{UserID: userID, From: from, To: to}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT
+			COALESCE((SELECT balance_after FROM wallet_transactions
+				WHERE user_id = $1 AND $2::timestamp IS NOT NULL AND created_at < $2
+				ORDER BY created_at DESC, id DESC LIMIT 1), 0),
+			COALESCE((SELECT balance_after FROM wallet_transactions
+				WHERE user_id = $1 AND ($3::timestamp IS NULL OR created_at <= $3)
+				ORDER BY created_at DESC, id DESC LIMIT 1), 0),
+			COALESCE(SUM(amount) FILTER (WHERE amount > 0), 0),
+			COALESCE(-SUM(amount) FILTER (WHERE amount < 0), 0)
+		FROM wallet_transactions
+		WHERE user_id = $1 AND ($2::timestamp IS NULL OR created_at >= $2) AND ($3::timestamp IS NULL OR created_at <= $3)
+	`, userID.String(), from, to).Scan(&st.OpeningBalance, &st.ClosingBalance, &st.TotalCredits, &st.TotalDebits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statement totals: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id, type, amount, balance_after, order_id, COALESCE(reference, ''), COALESCE(note, ''),
+			created_by, created_at
+		FROM wallet_transactions
+		WHERE user_id = $1 AND ($2::timestamp IS NULL OR created_at >= $2) AND ($3::timestamp IS NULL OR created_at <= $3)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4 OFFSET $5
+	`, userID.String(), from, to, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallet transactions: %w", err)
+	}
+	defer rows.Close()
+
+	st.Transactions = []Transaction{}
+	for rows.Next() {
+		var t Transaction
+		var idStr, userIDStr string
+		var orderIDStr, createdByStr sql.NullString
+		if err := rows.Scan(&idStr, &userIDStr, &t.Type, &t.Amount, &t.BalanceAfter, &orderIDStr,
+			&t.Reference, &t.Note, &createdByStr, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan wallet transaction: %w", err)
+		}
+		t.ID, _ = uuid.Parse(idStr)
+		t.UserID, _ = uuid.Parse(userIDStr)
+		if orderIDStr.Valid {
+			parsed, _ := uuid.Parse(orderIDStr.String)
+			t.OrderID = &parsed
+		}
+		if createdByStr.Valid {
+			parsed, _ := uuid.Parse(createdByStr.String)
+			t.CreatedBy = &parsed
+		}
+		st.Transactions = append(st.Transactions, t)
+	}
+
+	return st, rows.Err()
+}
+
+// CheckIntegrity verifies the ledger: every transaction's entries sum to zero, every
+// wallet balance equals its entries, and all accounts together net to zero
+func (s *Service) CheckIntegrity() (*IntegrityReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	report := &IntegrityReport{
+		UnbalancedTransactions: []uuid.UUID{},
+		MismatchedWallets:      []WalletMismatch{},
+		AccountTotals:          map[string]float64{},
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT t.id FROM wallet_transactions t
+		LEFT JOIN wallet_entries e ON e.transaction_id = t.id
+		GROUP BY t.id
+		HAVING COUNT(e.id) < 2 OR SUM(e.amount) <> 0
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check transactions: %w", err)
+	}
+	for rows.Next() {
+		var idStr string
+		if err := rows.Scan(&idStr); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		id, _ := uuid.Parse(idStr)
+		report.UnbalancedTransactions = append(report.UnbalancedTransactions, id)
+	}
+	rows.Close()
+
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT w.user_id, w.balance, COALESCE(SUM(e.amount), 0)
+		FROM wallets w
+		LEFT JOIN wallet_entries e ON e.user_id = w.user_id AND e.account = $1
+		GROUP BY w.user_id, w.balance
+		HAVING w.balance <> COALESCE(SUM(e.amount), 0)
+	`, AccountCustomerWallet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check wallet balances: %w", err)
+	}
+	for rows.Next() {
+		var m WalletMismatch
+		var userIDStr string
+		if err := rows.Scan(&userIDStr, &m.Balance, &m.LedgerBalance); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan wallet balance: %w", err)
+		}
+		m.UserID, _ = uuid.Parse(userIDStr)
+		report.MismatchedWallets = append(report.MismatchedWallets, m)
+	}
+	rows.Close()
+
+	rows, err = s.db.QueryContext(ctx, `SELECT account, SUM(amount) FROM wallet_entries GROUP BY account`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to total ledger accounts: %w", err)
+	}
+	defer rows.Close()
+
+	var net float64
+	for rows.Next() {
+		var account string
+		var total float64
+		if err := rows.Scan(&account, &total); err != nil {
+			return nil, fmt.Errorf("failed to scan account total: %w", err)
+		}
+		report.AccountTotals[account] = total
+		net += total
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	report.Balanced = len(report.UnbalancedTransactions) == 0 && len(report.MismatchedWallets) == 0 &&
+		roundMoney(net) == 0
+	return report, nil
+}
@@ -352,6 +352,629 @@ func InitPostgresSchema(pool *pgxpool.Pool) error {
 	) ON CONFLICT (id) DO UPDATE SET
 		password = '$2a$10$ycGjJZdPmXXM68cDW6SZduaHPzgcUzkdRkCi1R3/6zU.zSACWemz2',
 		updated_at = NOW();
+
+	-- Aggregate ratings (users.rating keeps the rounded average used by dispatch)
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS rating_average NUMERIC(3, 2) DEFAULT 0;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS rating_count INTEGER DEFAULT 0;
+
+	-- Reviews table (one rating per order and rated party)
+	CREATE TABLE IF NOT EXISTS reviews (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+		customer_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		subject_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		subject_type VARCHAR(20) NOT NULL CHECK(subject_type IN ('provider', 'courier')),
+		stars SMALLINT NOT NULL CHECK(stars BETWEEN 1 AND 5),
+		tags TEXT[] DEFAULT ARRAY[]::TEXT[],
+		comment TEXT,
+		moderation_status VARCHAR(20) NOT NULL DEFAULT 'approved' CHECK(moderation_status IN ('pending', 'approved', 'rejected')),
+		moderation_note TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(order_id, subject_type)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_reviews_subject_id ON reviews(subject_id, created_at DESC);
+	CREATE INDEX IF NOT EXISTS idx_reviews_moderation_status ON reviews(moderation_status);
+
+	DROP TRIGGER IF EXISTS reviews_updated_at ON reviews;
+	CREATE TRIGGER reviews_updated_at
+		BEFORE UPDATE ON reviews
+		FOR EACH ROW
+		EXECUTE FUNCTION update_updated_at_column();
+
+	-- Order chat messages between customer, provider and courier
+	CREATE TABLE IF NOT EXISTS order_messages (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+		sender_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		sender_role VARCHAR(20) NOT NULL CHECK(sender_role IN ('customer', 'provider', 'courier')),
+		body TEXT,
+		template_key VARCHAR(50),
+		attachment_path TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_order_messages_order_id ON order_messages(order_id, created_at);
+
+	-- Read receipts for order chat messages
+	CREATE TABLE IF NOT EXISTS order_message_reads (
+		message_id UUID NOT NULL REFERENCES order_messages(id) ON DELETE CASCADE,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		read_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (message_id, user_id)
+	);
+
+	-- Tax identification number printed on invoices
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS tpin VARCHAR(20);
+
+	-- Tax invoices for paid orders, numbered sequentially
+	CREATE SEQUENCE IF NOT EXISTS invoice_number_seq START 1;
+
+	CREATE TABLE IF NOT EXISTS invoices (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		order_id UUID NOT NULL UNIQUE REFERENCES orders(id) ON DELETE CASCADE,
+		invoice_number VARCHAR(30) NOT NULL UNIQUE,
+		share_token VARCHAR(64) NOT NULL UNIQUE,
+		subtotal NUMERIC(10, 2) NOT NULL,
+		vat_amount NUMERIC(10, 2) NOT NULL,
+		total NUMERIC(10, 2) NOT NULL,
+		payment_reference VARCHAR(255),
+		issued_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Business accounts ordering on credit
+	CREATE TABLE IF NOT EXISTS business_accounts (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		name TEXT NOT NULL,
+		tpin VARCHAR(20),
+		billing_email VARCHAR(255),
+		billing_phone VARCHAR(50),
+		credit_limit NUMERIC(12, 2) NOT NULL DEFAULT 0,
+		payment_terms_days INTEGER NOT NULL DEFAULT 30,
+		status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK(status IN ('active', 'suspended')),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Users authorised to order on a business account; a user belongs to at most one account
+	CREATE TABLE IF NOT EXISTS business_account_members (
+		account_id UUID NOT NULL REFERENCES business_accounts(id) ON DELETE CASCADE,
+		user_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+		role VARCHAR(20) NOT NULL CHECK(role IN ('admin', 'orderer')),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (account_id, user_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS business_sites (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		account_id UUID NOT NULL REFERENCES business_accounts(id) ON DELETE CASCADE,
+		name TEXT NOT NULL,
+		address TEXT NOT NULL,
+		latitude DOUBLE PRECISION,
+		longitude DOUBLE PRECISION,
+		contact_name TEXT,
+		contact_phone VARCHAR(50),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_business_sites_account_id ON business_sites(account_id);
+
+	-- Monthly statements of on-account orders
+	CREATE TABLE IF NOT EXISTS business_statements (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		account_id UUID NOT NULL REFERENCES business_accounts(id) ON DELETE CASCADE,
+		period_start DATE NOT NULL,
+		period_end DATE NOT NULL,
+		order_count INTEGER NOT NULL DEFAULT 0,
+		total_amount NUMERIC(12, 2) NOT NULL DEFAULT 0,
+		due_date DATE NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK(status IN ('open', 'paid')),
+		paid_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(account_id, period_start)
+	);
+
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS business_account_id UUID REFERENCES business_accounts(id);
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS delivery_site_id UUID REFERENCES business_sites(id) ON DELETE SET NULL;
+	CREATE INDEX IF NOT EXISTS idx_orders_business_account_id ON orders(business_account_id, created_at);
+
+	DROP TRIGGER IF EXISTS business_accounts_updated_at ON business_accounts;
+	CREATE TRIGGER business_accounts_updated_at
+		BEFORE UPDATE ON business_accounts
+		FOR EACH ROW
+		EXECUTE FUNCTION update_updated_at_column();
+
+	DROP TRIGGER IF EXISTS business_sites_updated_at ON business_sites;
+	CREATE TRIGGER business_sites_updated_at
+		BEFORE UPDATE ON business_sites
+		FOR EACH ROW
+		EXECUTE FUNCTION update_updated_at_column();
+
+	DROP TRIGGER IF EXISTS business_statements_updated_at ON business_statements;
+	CREATE TRIGGER business_statements_updated_at
+		BEFORE UPDATE ON business_statements
+		FOR EACH ROW
+		EXECUTE FUNCTION update_updated_at_column();
+
+	-- Cash handovers from a courier to a provider or the platform
+	CREATE TABLE IF NOT EXISTS cash_remittances (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		courier_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		amount NUMERIC(10, 2) NOT NULL CHECK(amount > 0),
+		handed_to VARCHAR(20) NOT NULL CHECK(handed_to IN ('provider', 'platform')),
+		recipient_id UUID REFERENCES users(id) ON DELETE SET NULL,
+		reference TEXT,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'confirmed', 'disputed')),
+		resolved_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_cash_remittances_courier_id ON cash_remittances(courier_id, status);
+	CREATE INDEX IF NOT EXISTS idx_cash_remittances_recipient_id ON cash_remittances(recipient_id, status);
+
+	-- Per-courier cash float: collections add to it, confirmed remittances draw it down
+	CREATE TABLE IF NOT EXISTS courier_cash_ledger (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		courier_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		entry_type VARCHAR(20) NOT NULL CHECK(entry_type IN ('collection', 'remittance')),
+		order_id UUID REFERENCES orders(id) ON DELETE SET NULL,
+		remittance_id UUID REFERENCES cash_remittances(id) ON DELETE SET NULL,
+		amount NUMERIC(10, 2) NOT NULL,
+		amount_due NUMERIC(10, 2),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_courier_cash_ledger_courier_id ON courier_cash_ledger(courier_id, created_at);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_courier_cash_ledger_collection_order
+		ON courier_cash_ledger(order_id) WHERE entry_type = 'collection';
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_courier_cash_ledger_remittance
+		ON courier_cash_ledger(remittance_id) WHERE entry_type = 'remittance';
+
+	-- Customer edits to orders before pickup, with any payment adjustment they caused
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS delivery_notes TEXT;
+
+	CREATE TABLE IF NOT EXISTS order_edits (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+		edited_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		changes JSONB NOT NULL,
+		previous_total NUMERIC(10, 2) NOT NULL,
+		new_total NUMERIC(10, 2) NOT NULL,
+		adjustment_type VARCHAR(20) NOT NULL DEFAULT 'none' CHECK(adjustment_type IN ('none', 'top_up', 'refund')),
+		adjustment_amount NUMERIC(10, 2) NOT NULL DEFAULT 0,
+		adjustment_status VARCHAR(20) NOT NULL DEFAULT 'none' CHECK(adjustment_status IN ('none', 'pending', 'initiated', 'completed', 'failed')),
+		adjustment_ref VARCHAR(255),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_order_edits_order_id ON order_edits(order_id, created_at);
+
+	-- Areas we deliver to, each approximated by a circle; pins on orders and saved addresses must fall inside one
+	CREATE TABLE IF NOT EXISTS service_zones (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		name VARCHAR(100) NOT NULL UNIQUE,
+		latitude DOUBLE PRECISION NOT NULL,
+		longitude DOUBLE PRECISION NOT NULL,
+		radius_km NUMERIC(6, 2) NOT NULL CHECK(radius_km > 0),
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	DROP TRIGGER IF EXISTS service_zones_updated_at ON service_zones;
+	CREATE TRIGGER service_zones_updated_at
+		BEFORE UPDATE ON service_zones
+		FOR EACH ROW
+		EXECUTE FUNCTION update_updated_at_column();
+
+	-- Default Lusaka zones; admins adjust them from the dashboard
+	INSERT INTO service_zones (name, latitude, longitude, radius_km) VALUES
+		('Lusaka CBD', -15.4180, 28.2820, 3),
+		('Kabulonga', -15.4050, 28.3300, 3),
+		('Woodlands', -15.4330, 28.3120, 3),
+		('Chelstone', -15.3700, 28.3800, 4),
+		('Matero', -15.3750, 28.2500, 3),
+		('Kalingalinga', -15.4110, 28.3150, 2),
+		('Chilenje', -15.4480, 28.3100, 3),
+		('Kabwata', -15.4400, 28.2950, 2),
+		('Garden', -15.3900, 28.2650, 2),
+		('Mtendere', -15.4100, 28.3500, 2.5),
+		('Chawama', -15.4550, 28.2750, 3),
+		('Northmead', -15.4000, 28.3000, 2),
+		('Rhodes Park', -15.4100, 28.3000, 1.5),
+		('Olympia', -15.4000, 28.3100, 1.5),
+		('Emmasdale', -15.3950, 28.2700, 2),
+		('Libala', -15.4450, 28.3200, 2.5),
+		('Avondale', -15.3900, 28.3450, 2.5),
+		('Roma', -15.3800, 28.3150, 2.5),
+		('Ibex Hill', -15.4350, 28.3550, 3)
+	ON CONFLICT (name) DO NOTHING;
+
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS structured_address JSONB;
+
+	-- Customer address book; one default per customer
+	CREATE TABLE IF NOT EXISTS customer_addresses (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		label VARCHAR(50) NOT NULL,
+		address JSONB NOT NULL,
+		is_default BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(user_id, label)
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_customer_addresses_default
+		ON customer_addresses(user_id) WHERE is_default;
+
+	DROP TRIGGER IF EXISTS customer_addresses_updated_at ON customer_addresses;
+	CREATE TRIGGER customer_addresses_updated_at
+		BEFORE UPDATE ON customer_addresses
+		FOR EACH ROW
+		EXECUTE FUNCTION update_updated_at_column();
+
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS address_id UUID REFERENCES customer_addresses(id) ON DELETE SET NULL;
+
+	-- Gas consumption settings and refill reminders
+	CREATE TABLE IF NOT EXISTS usage_profiles (
+		user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		days_per_kg_override NUMERIC(6, 2),
+		reminders_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+		lead_days INTEGER NOT NULL DEFAULT 3,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	DROP TRIGGER IF EXISTS usage_profiles_updated_at ON usage_profiles;
+	CREATE TRIGGER usage_profiles_updated_at
+		BEFORE UPDATE ON usage_profiles
+		FOR EACH ROW
+		EXECUTE FUNCTION update_updated_at_column();
+
+	CREATE TABLE IF NOT EXISTS refill_reminders (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+		estimated_empty_at TIMESTAMP NOT NULL,
+		channel VARCHAR(10) CHECK(channel IN ('push', 'sms')),
+		sent_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(user_id, order_id)
+	);
+
+	-- Customer wallets, kept as a double-entry ledger: each transaction posts one entry
+	-- to the customer's wallet and an opposite one to a platform account
+	CREATE TABLE IF NOT EXISTS wallets (
+		user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		balance NUMERIC(12,2) NOT NULL DEFAULT 0 CHECK(balance >= 0),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	DROP TRIGGER IF EXISTS wallets_updated_at ON wallets;
+	CREATE TRIGGER wallets_updated_at
+		BEFORE UPDATE ON wallets
+		FOR EACH ROW
+		EXECUTE FUNCTION update_updated_at_column();
+
+	CREATE TABLE IF NOT EXISTS wallet_transactions (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		type VARCHAR(20) NOT NULL CHECK(type IN ('top_up', 'order_payment', 'refund', 'goodwill_credit', 'referral_reward')),
+		amount NUMERIC(12,2) NOT NULL CHECK(amount <> 0),
+		balance_after NUMERIC(12,2) NOT NULL,
+		order_id UUID REFERENCES orders(id) ON DELETE SET NULL,
+		reference VARCHAR(100),
+		note TEXT,
+		created_by UUID REFERENCES admin_users(id) ON DELETE SET NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_wallet_transactions_user ON wallet_transactions(user_id, created_at DESC);
+	CREATE INDEX IF NOT EXISTS idx_wallet_transactions_order ON wallet_transactions(order_id);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_wallet_transactions_reference
+		ON wallet_transactions(type, reference) WHERE reference IS NOT NULL;
+
+	CREATE TABLE IF NOT EXISTS wallet_entries (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		transaction_id UUID NOT NULL REFERENCES wallet_transactions(id) ON DELETE CASCADE,
+		account VARCHAR(30) NOT NULL,
+		user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+		amount NUMERIC(12,2) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_wallet_entries_transaction ON wallet_entries(transaction_id);
+	CREATE INDEX IF NOT EXISTS idx_wallet_entries_user ON wallet_entries(user_id);
+
+	CREATE TABLE IF NOT EXISTS wallet_topups (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		deposit_id VARCHAR(100) UNIQUE NOT NULL,
+		amount NUMERIC(12,2) NOT NULL CHECK(amount > 0),
+		phone_number VARCHAR(20) NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'completed', 'failed')),
+		transaction_id UUID REFERENCES wallet_transactions(id),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	DROP TRIGGER IF EXISTS wallet_topups_updated_at ON wallet_topups;
+	CREATE TRIGGER wallet_topups_updated_at
+		BEFORE UPDATE ON wallet_topups
+		FOR EACH ROW
+		EXECUTE FUNCTION update_updated_at_column();
+
+	-- Part of an order's total paid from the customer's wallet
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS wallet_paid NUMERIC(10,2) NOT NULL DEFAULT 0;
+
+	-- Refunds for edited orders now go to the wallet and complete straight away
+	ALTER TABLE order_edits DROP CONSTRAINT IF EXISTS order_edits_adjustment_status_check;
+	ALTER TABLE order_edits ADD CONSTRAINT order_edits_adjustment_status_check
+		CHECK(adjustment_status IN ('none', 'pending', 'initiated', 'completed', 'failed'));
+
+	-- Promo codes and automatic discount campaigns. A campaign without a code applies
+	-- to every order that meets its rules.
+	CREATE TABLE IF NOT EXISTS promo_campaigns (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		name VARCHAR(100) NOT NULL,
+		description TEXT,
+		code VARCHAR(40) UNIQUE,
+		discount_type VARCHAR(20) NOT NULL CHECK(discount_type IN ('percentage', 'fixed')),
+		discount_value NUMERIC(10,2) NOT NULL CHECK(discount_value > 0),
+		max_discount NUMERIC(10,2) NOT NULL DEFAULT 0,
+		applies_to VARCHAR(20) NOT NULL CHECK(applies_to IN ('product', 'delivery_fee')),
+		min_order_amount NUMERIC(10,2) NOT NULL DEFAULT 0,
+		cylinder_type VARCHAR(10),
+		provider_id UUID REFERENCES users(id) ON DELETE CASCADE,
+		first_order_only BOOLEAN NOT NULL DEFAULT FALSE,
+		max_uses_per_user INTEGER NOT NULL DEFAULT 0,
+		max_uses_total INTEGER NOT NULL DEFAULT 0,
+		starts_at TIMESTAMP,
+		ends_at TIMESTAMP,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	DROP TRIGGER IF EXISTS promo_campaigns_updated_at ON promo_campaigns;
+	CREATE TRIGGER promo_campaigns_updated_at
+		BEFORE UPDATE ON promo_campaigns
+		FOR EACH ROW
+		EXECUTE FUNCTION update_updated_at_column();
+
+	CREATE TABLE IF NOT EXISTS promo_redemptions (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		campaign_id UUID NOT NULL REFERENCES promo_campaigns(id) ON DELETE CASCADE,
+		order_id UUID NOT NULL UNIQUE REFERENCES orders(id) ON DELETE CASCADE,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		code VARCHAR(40),
+		discount NUMERIC(10,2) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_promo_redemptions_campaign ON promo_redemptions(campaign_id, user_id);
+
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS discount NUMERIC(10,2) NOT NULL DEFAULT 0;
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS promo_code VARCHAR(40);
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS campaign_id UUID REFERENCES promo_campaigns(id) ON DELETE SET NULL;
+
+	CREATE TABLE IF NOT EXISTS referral_codes (
+		user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		code VARCHAR(20) NOT NULL UNIQUE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS user_devices (
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		device_id VARCHAR(255) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, device_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_user_devices_device ON user_devices(device_id);
+
+	CREATE TABLE IF NOT EXISTS referrals (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		referrer_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		referee_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+		code VARCHAR(20) NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'rewarded', 'rejected')),
+		rejection_reason TEXT,
+		order_id UUID REFERENCES orders(id) ON DELETE SET NULL,
+		referrer_reward NUMERIC(10,2) NOT NULL DEFAULT 0,
+		referee_reward NUMERIC(10,2) NOT NULL DEFAULT 0,
+		rewarded_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_referrals_referrer ON referrals(referrer_id, created_at DESC);
+	CREATE INDEX IF NOT EXISTS idx_referrals_status ON referrals(status);
+
+	ALTER TABLE wallet_transactions DROP CONSTRAINT IF EXISTS wallet_transactions_type_check;
+	ALTER TABLE wallet_transactions ADD CONSTRAINT wallet_transactions_type_check
+		CHECK(type IN ('top_up', 'order_payment', 'refund', 'goodwill_credit', 'referral_reward'));
+
+	CREATE TABLE IF NOT EXISTS loyalty_accounts (
+		user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		balance INTEGER NOT NULL DEFAULT 0 CHECK(balance >= 0),
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	DROP TRIGGER IF EXISTS loyalty_accounts_updated_at ON loyalty_accounts;
+	CREATE TRIGGER loyalty_accounts_updated_at
+		BEFORE UPDATE ON loyalty_accounts
+		FOR EACH ROW
+		EXECUTE FUNCTION update_updated_at_column();
+
+	-- order_id has no foreign key: points are redeemed just before the order row is written
+	CREATE TABLE IF NOT EXISTS loyalty_transactions (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		type VARCHAR(20) NOT NULL CHECK(type IN ('earn', 'redeem', 'expire', 'restore')),
+		points INTEGER NOT NULL CHECK(points <> 0),
+		balance_after INTEGER NOT NULL,
+		order_id UUID,
+		amount NUMERIC(12,2) NOT NULL DEFAULT 0,
+		expires_at TIMESTAMP,
+		note TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_loyalty_transactions_user ON loyalty_transactions(user_id, created_at DESC);
+
+	-- Points earned on an order are taken back as it is refunded, a partial refund at a time
+	ALTER TABLE loyalty_transactions DROP CONSTRAINT IF EXISTS loyalty_transactions_type_check;
+	ALTER TABLE loyalty_transactions ADD CONSTRAINT loyalty_transactions_type_check
+		CHECK(type IN ('earn', 'redeem', 'expire', 'restore', 'reverse'));
+	DROP INDEX IF EXISTS idx_loyalty_transactions_order;
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_loyalty_transactions_order_once
+		ON loyalty_transactions(type, order_id) WHERE order_id IS NOT NULL AND type <> 'reverse';
+
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS points_redeemed INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS points_target VARCHAR(20);
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS loyalty_discount NUMERIC(10,2) NOT NULL DEFAULT 0;
+
+	CREATE TABLE IF NOT EXISTS reconciliation_runs (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		trigger VARCHAR(20) NOT NULL CHECK(trigger IN ('schedule', 'admin')),
+		started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		finished_at TIMESTAMP,
+		checked INTEGER NOT NULL DEFAULT 0,
+		updated INTEGER NOT NULL DEFAULT 0,
+		flagged INTEGER NOT NULL DEFAULT 0,
+		errors INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_reconciliation_runs_started ON reconciliation_runs(started_at DESC);
+
+	CREATE TABLE IF NOT EXISTS reconciliation_issues (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		run_id UUID NOT NULL REFERENCES reconciliation_runs(id) ON DELETE CASCADE,
+		kind VARCHAR(20) NOT NULL CHECK(kind IN ('deposit', 'refund')),
+		reference VARCHAR(255) NOT NULL,
+		order_id UUID REFERENCES orders(id) ON DELETE SET NULL,
+		type VARCHAR(30) NOT NULL CHECK(type IN ('amount_mismatch', 'order_cancelled', 'not_found')),
+		details TEXT NOT NULL,
+		provider_status VARCHAR(20) NOT NULL,
+		expected_amount NUMERIC(12,2) NOT NULL,
+		actual_amount NUMERIC(12,2),
+		status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK(status IN ('open', 'resolved')),
+		resolution_note TEXT,
+		resolved_by UUID REFERENCES admin_users(id) ON DELETE SET NULL,
+		resolved_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- A payment is flagged once per problem until an admin resolves it
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_reconciliation_issues_open
+		ON reconciliation_issues(kind, reference, type) WHERE status = 'open';
+	CREATE INDEX IF NOT EXISTS idx_reconciliation_issues_status ON reconciliation_issues(status, created_at DESC);
+
+	-- Every PawaPay callback as received, kept for auditing and replay
+	CREATE TABLE IF NOT EXISTS payment_events (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		provider VARCHAR(20) NOT NULL DEFAULT 'pawapay',
+		type VARCHAR(20) NOT NULL CHECK (type IN ('deposit', 'payout', 'refund')),
+		event_key VARCHAR(255) NOT NULL,
+		reference VARCHAR(255) NOT NULL,
+		status VARCHAR(30) NOT NULL,
+		payload TEXT NOT NULL,
+		signature_verified BOOLEAN NOT NULL DEFAULT FALSE,
+		duplicates INT NOT NULL DEFAULT 0,
+		attempts INT NOT NULL DEFAULT 0,
+		last_error TEXT,
+		processed_at TIMESTAMP,
+		received_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- The body is kept byte for byte as PawaPay sent it, so a signature can be checked again
+	ALTER TABLE payment_events ALTER COLUMN payload TYPE TEXT;
+
+	-- PawaPay repeats a callback until it is acknowledged; each outcome is processed once
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_events_key ON payment_events(provider, type, event_key);
+	CREATE INDEX IF NOT EXISTS idx_payment_events_reference ON payment_events(reference, received_at DESC);
+
+	-- Payments taken on a hosted page (DPO checkout, PawaPay payment page) expire unpaid
+	ALTER TABLE payments ADD COLUMN IF NOT EXISTS checkout_expires_at TIMESTAMP;
+
+	-- Top-ups after an order edit are separate payments; the order stays paid meanwhile
+	ALTER TABLE payments ADD COLUMN IF NOT EXISTS purpose VARCHAR(20) NOT NULL DEFAULT 'order'
+		CHECK(purpose IN ('order', 'adjustment'));
+
+	-- Full and partial refunds of PawaPay deposits. The refund ID is also the PawaPay
+	-- refundId; requests above the approval threshold wait for an admin.
+	CREATE TABLE IF NOT EXISTS refunds (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		payment_id UUID NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+		order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+		deposit_id VARCHAR(255) NOT NULL,
+		amount NUMERIC(10, 2) NOT NULL CHECK (amount > 0),
+		reason TEXT NOT NULL,
+		status VARCHAR(20) NOT NULL CHECK (status IN ('requested', 'rejected', 'processing', 'completed', 'failed')),
+		requested_by UUID NOT NULL,
+		requester_role VARCHAR(20) NOT NULL CHECK (requester_role IN ('customer', 'admin')),
+		reviewed_by UUID REFERENCES admin_users(id) ON DELETE SET NULL,
+		review_note TEXT,
+		reviewed_at TIMESTAMP,
+		failure_reason TEXT,
+		submitted_at TIMESTAMP,
+		completed_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_refunds_order_id ON refunds(order_id);
+	CREATE INDEX IF NOT EXISTS idx_refunds_status ON refunds(status, created_at DESC);
+
+	-- Settlement statements uploaded by finance and how each line matched our records.
+	-- Entries with no line_number are our payments the statement left out.
+	CREATE TABLE IF NOT EXISTS statement_imports (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		provider VARCHAR(20) NOT NULL CHECK (provider IN ('pawapay', 'dpo')),
+		filename TEXT NOT NULL,
+		period_start TIMESTAMP,
+		period_end TIMESTAMP,
+		lines INTEGER NOT NULL DEFAULT 0,
+		matched INTEGER NOT NULL DEFAULT 0,
+		flagged INTEGER NOT NULL DEFAULT 0,
+		statement_total NUMERIC(14, 2) NOT NULL DEFAULT 0,
+		recorded_total NUMERIC(14, 2) NOT NULL DEFAULT 0,
+		fee_total NUMERIC(14, 2) NOT NULL DEFAULT 0,
+		imported_by UUID REFERENCES admin_users(id) ON DELETE SET NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_statement_imports_created ON statement_imports(created_at DESC);
+
+	CREATE TABLE IF NOT EXISTS statement_entries (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		import_id UUID NOT NULL REFERENCES statement_imports(id) ON DELETE CASCADE,
+		line_number INTEGER,
+		kind VARCHAR(20) NOT NULL CHECK (kind IN ('deposit', 'refund', 'payout')),
+		reference VARCHAR(255) NOT NULL,
+		result VARCHAR(30) NOT NULL CHECK (result IN ('matched', 'amount_mismatch', 'status_mismatch',
+			'duplicate', 'missing_in_records', 'missing_from_statement', 'currency_mismatch', 'ignored')),
+		details TEXT NOT NULL DEFAULT '',
+		statement_status VARCHAR(30),
+		statement_amount NUMERIC(12, 2),
+		fee NUMERIC(12, 2),
+		occurred_at TIMESTAMP,
+		record_status VARCHAR(20),
+		record_amount NUMERIC(12, 2),
+		order_id UUID REFERENCES orders(id) ON DELETE SET NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_statement_entries_import ON statement_entries(import_id, result);
+
+	-- Lines settled in another currency are flagged rather than compared
+	ALTER TABLE statement_entries DROP CONSTRAINT IF EXISTS statement_entries_result_check;
+	ALTER TABLE statement_entries ADD CONSTRAINT statement_entries_result_check
+		CHECK (result IN ('matched', 'amount_mismatch', 'status_mismatch',
+			'duplicate', 'missing_in_records', 'missing_from_statement', 'currency_mismatch', 'ignored'));
 	`
 
 	_, err := pool.Exec(ctx, schema)
@@ -20,6 +20,8 @@ const (
 	EventLocationUpdate EventType = "location:updated"
 	EventPaymentUpdate  EventType = "payment:updated"
 	EventUserUpdate     EventType = "user:updated"
+	EventChatMessage    EventType = "chat:message"
+	EventChatRead       EventType = "chat:read"
 )
 
 // Event represents a real-time event
@@ -177,6 +179,26 @@ func (h *Hub) BroadcastPaymentUpdate(orderID string, userID string, payload inte
 	})
 }
 
+// BroadcastChatMessage delivers a new order chat message to one participant
+func (h *Hub) BroadcastChatMessage(orderID string, recipientID string, payload interface{}) {
+	h.Broadcast(Event{
+		Type:    EventChatMessage,
+		OrderID: orderID,
+		UserID:  recipientID,
+		Payload: payload,
+	})
+}
+
+// BroadcastChatRead notifies a participant that their messages were read
+func (h *Hub) BroadcastChatRead(orderID string, recipientID string, payload interface{}) {
+	h.Broadcast(Event{
+		Type:    EventChatRead,
+		OrderID: orderID,
+		UserID:  recipientID,
+		Payload: payload,
+	})
+}
+
 // ReadPump reads messages from the WebSocket connection
 func (c *Client) ReadPump() {
 	defer func() {